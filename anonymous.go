@@ -0,0 +1,128 @@
+package memomarket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Anonymous publishing, when an admin turns it on in config.json, lets
+// people publish without an account. The pack belongs to the anonymous
+// system user and the response carries a one-time claim token; whoever
+// later presents it from a registered account takes the pack over. Until
+// then only admins can edit or delete it, and each IP may publish only a few
+// packs an hour.
+
+const anonymousUserID = "anonymous"
+
+const claimTokenPrefix = "mmc_"
+
+// anonPublishing holds the effective settings, reported by /api/capabilities.
+var anonPublishing AnonymousPublishingConfig
+
+var anonPublishLimiter *rateLimiter
+
+func loadAnonymousPublishing(cfg *AnonymousPublishingConfig) {
+	c := AnonymousPublishingConfig{PerHour: 3}
+	if cfg != nil {
+		c.Enabled = cfg.Enabled
+		if cfg.PerHour > 0 {
+			c.PerHour = cfg.PerHour
+		}
+	}
+	anonPublishing = c
+	anonPublishLimiter = newRateLimiter(c.PerHour, time.Hour, c.PerHour)
+}
+
+func init() {
+	loadAnonymousPublishing(nil)
+}
+
+// POST /api/memo-packs without a token — publish anonymously (public, when
+// enabled). The claim token is returned only here.
+func handlePublishAnonymous(w http.ResponseWriter, r *http.Request) {
+	if !anonPublishing.Enabled {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "missing or invalid token"})
+		return
+	}
+	if ok, wait := anonPublishLimiter.allow("ip:" + remoteIP(r)); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: "too many anonymous packs; sign in or try again later"})
+		return
+	}
+	anon, err := store.EnsureAnonymousUser()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to publish"})
+		return
+	}
+	pack := publishMemoPack(w, r, anon)
+	if pack == nil {
+		return
+	}
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	token := claimTokenPrefix + hex.EncodeToString(secret)
+	if err := store.CreatePackClaim(pack.ID, hashAPIKey(token)); err != nil {
+		// An unclaimable anonymous pack is of no use to its publisher.
+		store.DeleteMemoPack(pack.ID, anon.ID)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to publish"})
+		return
+	}
+	writeJSON(w, http.StatusCreated, AnonymousPublishResp{MemoPack: pack, ClaimToken: token})
+}
+
+// POST /api/memo-packs/{id}/claim — take over an anonymous pack with its
+// claim token (auth required).
+func handleClaimPack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	var req ClaimPackReq
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	req.ClaimToken = strings.TrimSpace(req.ClaimToken)
+	if req.ClaimToken == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "claim_token is required"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if pack.AuthorID != anonymousUserID {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is not anonymous"})
+		return
+	}
+	claimed, err := store.ClaimPack(pack.ID, hashAPIKey(req.ClaimToken), user)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to claim pack"})
+		return
+	}
+	if !claimed {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "invalid claim token"})
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackClaim, TargetKind: "pack", TargetID: pack.ID, Diff: fmt.Sprintf("author anonymous→%s", user.Username)})
+	pack, err = store.GetMemoPack(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load pack"})
+		return
+	}
+	writeJSON(w, http.StatusOK, pack)
+}
@@ -0,0 +1,459 @@
+package memomarket_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/n0n4we/memomarket"
+	"github.com/n0n4we/memomarket/testserver"
+)
+
+// accessPacks publishes one pack of each kind whose reads are restricted,
+// all by author.
+func accessPacks(srv *testserver.Server, author *memomarket.User) map[string]*memomarket.MemoPack {
+	return map[string]*memomarket.MemoPack{
+		"public":       srv.CreatePack(author, memomarket.PublishMemoPackReq{Name: "Public pack"}),
+		"require_auth": srv.CreatePack(author, memomarket.PublishMemoPackReq{Name: "Members pack", RequireAuth: true}),
+		"private":      srv.CreatePack(author, memomarket.PublishMemoPackReq{Name: "Private pack", Visibility: "private"}),
+		"draft":        srv.CreatePack(author, memomarket.PublishMemoPackReq{Name: "Draft pack", Draft: true}),
+	}
+}
+
+// get sends a GET as user and returns the response with its body read.
+func get(t *testing.T, srv *testserver.Server, user *memomarket.User, path string) (*http.Response, []byte) {
+	t.Helper()
+	resp := srv.Do(user, http.MethodGet, path, nil)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return resp, body
+}
+
+func TestEvalRunsAccess(t *testing.T) {
+	srv := testserver.New(t, memomarket.ServerOptions{})
+	alice, bob := srv.CreateUser("alice"), srv.CreateUser("bob")
+	packs := accessPacks(srv, alice)
+
+	tests := []struct {
+		pack    string
+		user    *memomarket.User
+		want    int
+		private bool
+	}{
+		{"public", nil, http.StatusOK, false},
+		{"public", bob, http.StatusOK, false},
+		{"require_auth", nil, http.StatusUnauthorized, false},
+		{"require_auth", bob, http.StatusOK, true},
+		{"private", nil, http.StatusNotFound, false},
+		{"private", bob, http.StatusNotFound, false},
+		{"private", alice, http.StatusOK, true},
+		{"draft", nil, http.StatusNotFound, false},
+		{"draft", bob, http.StatusNotFound, false},
+		{"draft", alice, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		resp, body := get(t, srv, tt.user, "/api/memo-packs/"+packs[tt.pack].ID+"/evals/runs")
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s pack as %s: status %d, want %d: %s", tt.pack, username(tt.user), resp.StatusCode, tt.want, body)
+			continue
+		}
+		if tt.private && !strings.HasPrefix(resp.Header.Get("Cache-Control"), "private") {
+			t.Errorf("%s pack as %s: Cache-Control %q, want private", tt.pack, username(tt.user), resp.Header.Get("Cache-Control"))
+		}
+	}
+}
+
+func TestPackStatsAccess(t *testing.T) {
+	srv := testserver.New(t, memomarket.ServerOptions{})
+	alice, bob := srv.CreateUser("alice"), srv.CreateUser("bob")
+	packs := accessPacks(srv, alice)
+
+	tests := []struct {
+		pack    string
+		user    *memomarket.User
+		want    int
+		private bool
+	}{
+		{"public", nil, http.StatusOK, false},
+		{"require_auth", nil, http.StatusOK, true},
+		{"private", nil, http.StatusNotFound, false},
+		{"private", bob, http.StatusNotFound, false},
+		{"private", alice, http.StatusOK, true},
+		{"draft", nil, http.StatusNotFound, false},
+		{"draft", alice, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		resp, body := get(t, srv, tt.user, "/api/memo-packs/"+packs[tt.pack].ID+"/stats")
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s pack as %s: status %d, want %d: %s", tt.pack, username(tt.user), resp.StatusCode, tt.want, body)
+			continue
+		}
+		if cc := resp.Header.Get("Cache-Control"); tt.want == http.StatusOK && strings.HasPrefix(cc, "private") != tt.private {
+			t.Errorf("%s pack as %s: Cache-Control %q, private %v", tt.pack, username(tt.user), cc, tt.private)
+		}
+	}
+}
+
+func TestAttachmentAccess(t *testing.T) {
+	srv := testserver.New(t, memomarket.ServerOptions{})
+	alice, bob := srv.CreateUser("alice"), srv.CreateUser("bob")
+	packs := accessPacks(srv, alice)
+	urls := map[string]string{}
+	for _, name := range []string{"public", "require_auth", "private"} {
+		var a memomarket.Attachment
+		path := "/api/memo-packs/" + packs[name].ID + "/attachments?filename=notes.txt"
+		if status := srv.DoJSON(alice, http.MethodPost, path, strings.NewReader("attached notes"), &a); status != http.StatusCreated {
+			t.Fatalf("upload to %s pack: status %d", name, status)
+		}
+		urls[name] = a.URL
+	}
+
+	tests := []struct {
+		pack string
+		user *memomarket.User
+		want int
+	}{
+		{"public", nil, http.StatusOK},
+		{"require_auth", nil, http.StatusUnauthorized},
+		{"require_auth", bob, http.StatusOK},
+		{"require_auth", alice, http.StatusOK},
+		{"private", nil, http.StatusNotFound},
+		{"private", bob, http.StatusNotFound},
+		{"private", alice, http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp, body := get(t, srv, tt.user, "/api/memo-packs/"+packs[tt.pack].ID+"/attachments")
+		if resp.StatusCode != tt.want {
+			t.Errorf("list %s pack as %s: status %d, want %d: %s", tt.pack, username(tt.user), resp.StatusCode, tt.want, body)
+		}
+		// A signed link doesn't outlive the caller's access to the pack.
+		resp, body = get(t, srv, tt.user, urls[tt.pack])
+		if resp.StatusCode != tt.want {
+			t.Errorf("download from %s pack as %s: status %d, want %d: %s", tt.pack, username(tt.user), resp.StatusCode, tt.want, body)
+		} else if tt.want == http.StatusOK && string(body) != "attached notes" {
+			t.Errorf("download from %s pack as %s: body %q", tt.pack, username(tt.user), body)
+		}
+	}
+}
+
+func TestAttachmentShareLink(t *testing.T) {
+	srv := testserver.New(t, memomarket.ServerOptions{})
+	alice := srv.CreateUser("alice")
+	pack := srv.CreatePack(alice, memomarket.PublishMemoPackReq{Name: "Shared pack", Visibility: "private"})
+	base := "/api/memo-packs/" + pack.ID
+	if status := srv.DoJSON(alice, http.MethodPost, base+"/attachments?filename=notes.txt", strings.NewReader("shared notes"), nil); status != http.StatusCreated {
+		t.Fatalf("upload: status %d", status)
+	}
+	var link memomarket.ShareLink
+	if status := srv.DoJSON(alice, http.MethodPost, base+"/share-links", memomarket.ShareLinkReq{}, &link); status != http.StatusCreated {
+		t.Fatalf("create share link: status %d", status)
+	}
+	var list []memomarket.Attachment
+	if status := srv.DoJSON(nil, http.MethodGet, base+"/attachments?share="+link.Token, nil, &list); status != http.StatusOK || len(list) != 1 {
+		t.Fatalf("list through share link: status %d, %d attachments", status, len(list))
+	}
+	shared := list[0].URL
+	unshared, _, _ := strings.Cut(shared, "&share=")
+
+	// Steps run in order: the link's grant lasts until it is revoked.
+	tests := []struct {
+		name   string
+		url    string
+		revoke bool
+		want   int
+	}{
+		{"shared URL", shared, false, http.StatusOK},
+		{"share token stripped", unshared, false, http.StatusForbidden},
+		{"shared URL after revoke", shared, true, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if tt.revoke {
+			resp := srv.Do(alice, http.MethodDelete, base+"/share-links/"+link.ID, nil)
+			resp.Body.Close()
+		}
+		resp, body := get(t, srv, nil, tt.url)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, resp.StatusCode, tt.want, body)
+		} else if tt.want == http.StatusOK && string(body) != "shared notes" {
+			t.Errorf("%s: body %q", tt.name, body)
+		}
+	}
+}
+
+func TestCoverCaching(t *testing.T) {
+	srv := testserver.New(t, memomarket.ServerOptions{})
+	alice := srv.CreateUser("alice")
+	packs := accessPacks(srv, alice)
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pack    string
+		private bool
+	}{
+		{"public", false},
+		{"require_auth", true},
+		{"private", true},
+		{"draft", true},
+	}
+	for _, tt := range tests {
+		var pack memomarket.MemoPack
+		path := "/api/memo-packs/" + packs[tt.pack].ID + "/cover"
+		if status := srv.DoJSON(alice, http.MethodPut, path, bytes.NewReader(img.Bytes()), &pack); status != http.StatusOK {
+			t.Fatalf("upload cover of %s pack: status %d", tt.pack, status)
+		}
+		// The versioned URL is cached as immutable, so it must not reach
+		// shared caches unless the pack is public.
+		for _, url := range []string{path, pack.CoverURL} {
+			resp, body := get(t, srv, alice, url)
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("%s: status %d: %s", url, resp.StatusCode, body)
+				continue
+			}
+			if cc := resp.Header.Get("Cache-Control"); strings.HasPrefix(cc, "private") != tt.private {
+				t.Errorf("%s pack cover %s: Cache-Control %q, private %v", tt.pack, url, cc, tt.private)
+			}
+		}
+	}
+}
+
+func TestPackPatch(t *testing.T) {
+	srv := testserver.New(t, memomarket.ServerOptions{})
+	alice, bob := srv.CreateUser("alice"), srv.CreateUser("bob")
+	pack := srv.CreatePack(alice, memomarket.PublishMemoPackReq{Name: "Patched pack", Homepage: "https://example.com"})
+	path := "/api/memo-packs/" + pack.ID
+
+	tests := []struct {
+		user  *memomarket.User
+		patch map[string]any
+		want  int
+		check func(*memomarket.MemoPack) bool
+	}{
+		{alice, map[string]any{"description": "Patched."}, http.StatusOK,
+			func(p *memomarket.MemoPack) bool { return p.Description == "Patched." && p.Name == "Patched pack" }},
+		{alice, map[string]any{"homepage": nil}, http.StatusOK,
+			func(p *memomarket.MemoPack) bool { return p.Homepage == "" && p.Description == "Patched." }},
+		{bob, map[string]any{"description": "Not mine."}, http.StatusForbidden, nil},
+		{nil, map[string]any{"description": "Anonymous."}, http.StatusUnauthorized, nil},
+	}
+	for i, tt := range tests {
+		var got memomarket.MemoPack
+		status := srv.DoJSON(tt.user, http.MethodPatch, path, tt.patch, &got)
+		if status != tt.want {
+			t.Errorf("patch %d as %s: status %d, want %d", i+1, username(tt.user), status, tt.want)
+			continue
+		}
+		if tt.check != nil && !tt.check(&got) {
+			t.Errorf("patch %d as %s: got %+v", i+1, username(tt.user), got)
+		}
+	}
+}
+
+func TestBatchPublishGob(t *testing.T) {
+	srv := testserver.New(t, memomarket.ServerOptions{})
+	alice := srv.CreateUser("alice")
+	packs := func(names ...string) []byte {
+		var reqs []memomarket.PublishMemoPackReq
+		for _, name := range names {
+			reqs = append(reqs, memomarket.PublishMemoPackReq{Name: name, Description: "Sent as gob.",
+				Memos: []memomarket.Memo{{Title: "Example", Content: "An example memo."}}})
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(reqs); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name, contentType, accept string
+		body                      []byte
+		want                      int
+		results                   int
+	}{
+		{"gob in, gob out", "application/x-gob", "application/x-gob", packs("Gob one", "Gob two"), http.StatusCreated, 2},
+		{"gob in, JSON out", "application/x-gob", "", packs("Gob three"), http.StatusCreated, 1},
+		{"refused pack, gob out", "application/x-gob", "application/x-gob", packs(""), http.StatusUnprocessableEntity, 1},
+		{"invalid gob", "application/x-gob", "", []byte("not gob"), http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/memo-packs/batch", bytes.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+alice.Token)
+		req.Header.Set("Content-Type", tt.contentType)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d: %q", tt.name, resp.StatusCode, tt.want, body)
+			continue
+		}
+		if tt.results == 0 {
+			continue
+		}
+		var out memomarket.BatchResponse
+		if resp.Header.Get("Content-Type") == "application/x-gob" {
+			err = gob.NewDecoder(bytes.NewReader(body)).Decode(&out)
+		} else {
+			err = json.Unmarshal(body, &out)
+		}
+		if err != nil || len(out.Results) != tt.results {
+			t.Errorf("%s: %d results, want %d (%v)", tt.name, len(out.Results), tt.results, err)
+		}
+		if tt.accept == "" && resp.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s: Content-Type %q, want JSON", tt.name, resp.Header.Get("Content-Type"))
+		}
+	}
+}
+
+func TestDownloadRangeResume(t *testing.T) {
+	srv := testserver.New(t, memomarket.ServerOptions{})
+	alice := srv.CreateUser("alice")
+	pack := srv.CreatePack(alice, memomarket.PublishMemoPackReq{Name: "Resumable pack"})
+	path := "/api/memo-packs/" + pack.ID + "/download"
+
+	resp, full := get(t, srv, nil, path)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("download: status %d: %s", resp.StatusCode, full)
+	}
+	etag := resp.Header.Get("ETag")
+	// Someone else's download in between changes the counter, but not the
+	// body being resumed.
+	if resp, body := get(t, srv, nil, path); resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != etag {
+		t.Fatalf("second download: status %d, ETag %q, want %q: %s", resp.StatusCode, resp.Header.Get("ETag"), etag, body)
+	}
+
+	tests := []struct {
+		rng, ifRange string
+		want         int
+		body         []byte
+	}{
+		{"bytes=10-", etag, http.StatusPartialContent, full[10:]},
+		{"bytes=0-9", etag, http.StatusPartialContent, full[:10]},
+		{"bytes=10-", `"stale"`, http.StatusOK, full},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Range", tt.rng)
+		req.Header.Set("If-Range", tt.ifRange)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Range %s: %v", tt.rng, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.want || !bytes.Equal(body, tt.body) {
+			t.Errorf("Range %s, If-Range %s: status %d, body %q; want %d, %q", tt.rng, tt.ifRange, resp.StatusCode, body, tt.want, tt.body)
+		}
+	}
+}
+
+func TestClaimPack(t *testing.T) {
+	cfg := testserver.DefaultConfig()
+	cfg.AnonymousPublishing = &memomarket.AnonymousPublishingConfig{Enabled: true}
+	srv := testserver.New(t, memomarket.ServerOptions{Config: cfg})
+	bob, carol := srv.CreateUser("bob"), srv.CreateUser("carol")
+	var published memomarket.AnonymousPublishResp
+	req := memomarket.PublishMemoPackReq{Name: "Anonymous pack", Description: "Published without an account.",
+		Memos: []memomarket.Memo{{Title: "Example", Content: "An example memo."}}}
+	if status := srv.DoJSON(nil, http.MethodPost, "/api/memo-packs", req, &published); status != http.StatusCreated {
+		t.Fatalf("anonymous publish: status %d", status)
+	}
+	if published.ClaimToken == "" {
+		t.Fatal("anonymous publish returned no claim token")
+	}
+	path := "/api/memo-packs/" + published.ID + "/claim"
+
+	// Steps run in order: the token works once, for the first account that
+	// presents it.
+	tests := []struct {
+		user  *memomarket.User
+		token string
+		want  int
+	}{
+		{nil, published.ClaimToken, http.StatusUnauthorized},
+		{bob, "", http.StatusBadRequest},
+		{bob, "mmc_wrong", http.StatusForbidden},
+		{bob, published.ClaimToken, http.StatusOK},
+		{carol, published.ClaimToken, http.StatusConflict},
+		{bob, published.ClaimToken, http.StatusConflict},
+	}
+	for i, tt := range tests {
+		var pack memomarket.MemoPack
+		status := srv.DoJSON(tt.user, http.MethodPost, path, memomarket.ClaimPackReq{ClaimToken: tt.token}, &pack)
+		if status != tt.want {
+			t.Errorf("step %d: claim as %s: status %d, want %d", i+1, username(tt.user), status, tt.want)
+		}
+		if status == http.StatusOK && pack.AuthorID != tt.user.ID {
+			t.Errorf("step %d: claimed pack's author %q, want %q", i+1, pack.AuthorID, tt.user.ID)
+		}
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	srv := testserver.New(t, memomarket.ServerOptions{})
+	alice := srv.CreateUser("alice")
+	first := alice.RefreshToken
+	var second memomarket.TokenResponse
+
+	// Steps run in order: each refresh token is redeemed once, and the
+	// access token it replaced stops working.
+	tests := []struct {
+		name  string
+		token func() string
+		want  int
+		out   *memomarket.TokenResponse
+	}{
+		{"first refresh", func() string { return first }, http.StatusOK, &second},
+		{"first token again", func() string { return first }, http.StatusUnauthorized, nil},
+		{"second refresh", func() string { return second.RefreshToken }, http.StatusOK, nil},
+		{"second token again", func() string { return second.RefreshToken }, http.StatusUnauthorized, nil},
+		{"unknown token", func() string { return "not-a-token" }, http.StatusUnauthorized, nil},
+		{"no token", func() string { return "" }, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		var out memomarket.TokenResponse
+		status := srv.DoJSON(nil, http.MethodPost, "/api/token/refresh", memomarket.RefreshTokenReq{RefreshToken: tt.token()}, &out)
+		if status != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, status, tt.want)
+		}
+		if tt.out != nil {
+			*tt.out = out
+		}
+	}
+	if second.Token == "" || second.RefreshToken == first {
+		t.Fatalf("first refresh returned %+v", second)
+	}
+	if status := srv.DoJSON(alice, http.MethodGet, "/api/me", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("replaced access token: status %d, want 401", status)
+	}
+}
+
+func username(u *memomarket.User) string {
+	if u == nil {
+		return "anonymous"
+	}
+	return u.Username
+}
@@ -0,0 +1,67 @@
+package memomarket
+
+import "net/http"
+
+// Authors archive packs they no longer maintain. An archived pack keeps
+// being served and downloaded, but it can't be changed until it is
+// unarchived: edits, GitHub syncs, eval changes, version promotions and
+// retagging all refuse it. Listings label it with "archived" and filter on
+// ?archived=true or false.
+
+// rejectArchived writes the error response and returns true when pack is
+// archived.
+func rejectArchived(w http.ResponseWriter, pack *MemoPack) bool {
+	if !pack.Archived {
+		return false
+	}
+	writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is archived; unarchive it to make changes"})
+	return true
+}
+
+// POST /api/memo-packs/{id}/archive — archive a pack (owner only). DELETE
+// unarchives it.
+func handlePackArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if pack.AuthorID != user.ID && !isAdmin(user) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+		return
+	}
+	action, at := auditPackUnarchive, ""
+	if r.Method == http.MethodPost {
+		if !pack.Published {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "drafts can't be archived"})
+			return
+		}
+		if pack.Archived {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is already archived"})
+			return
+		}
+		action, at = auditPackArchive, nowISO()
+	} else if !pack.Archived {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is not archived"})
+		return
+	}
+	if err := store.SetPackArchived(pack.ID, at); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update pack"})
+		return
+	}
+	recordAudit(r, user, action, "pack", pack.ID, "")
+	pack.ArchivedAt, pack.Archived = at, at != ""
+	writeJSON(w, http.StatusOK, pack)
+}
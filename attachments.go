@@ -0,0 +1,470 @@
+package memomarket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Attachments are files uploaded to a pack, such as examples or reference
+// docs, that its memos list by ID in "attachments". They are uploaded as the
+// raw body of POST /api/memo-packs/{id}/attachments?filename=, by accounts
+// whose trust level allows it, and stored under the data dir or in S3.
+// Downloads go through /api/attachments/{id} with a link signed by this
+// server that expires after a while. A link only works while the caller can
+// still see the pack: once it is deleted, made private or put behind
+// sign-in, the download checks again as the listing did. Links listed
+// through a share link carry its token, so they stop working when it is
+// revoked or expires.
+
+const maxAttachmentFilenameChars = 255
+
+// attachmentDir holds attachments when they aren't stored in S3.
+var attachmentDir = "./data/attachments"
+
+var attachmentConfig = struct {
+	s3     *s3Client
+	urlTTL time.Duration
+}{urlTTL: time.Hour}
+
+// blobBackend stores attachment files by key.
+type blobBackend interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+type diskBackend struct{}
+
+func (diskBackend) path(key string) string {
+	return filepath.Join(attachmentDir, filepath.FromSlash(key))
+}
+
+func (d diskBackend) Put(key string, data []byte, _ string) error {
+	p := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	// Write then rename so a reader never sees a partial file.
+	if err := os.WriteFile(p+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(p+".tmp", p); err != nil {
+		os.Remove(p + ".tmp")
+		return err
+	}
+	return nil
+}
+
+func (d diskBackend) Get(key string) (io.ReadCloser, error) {
+	return os.Open(d.path(key))
+}
+
+func (d diskBackend) Delete(key string) error {
+	err := os.Remove(d.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func attachmentBlobs() blobBackend {
+	if attachmentConfig.s3 != nil {
+		return attachmentConfig.s3
+	}
+	return diskBackend{}
+}
+
+func attachmentKey(a *Attachment) string {
+	return a.PackID + "/" + a.ID
+}
+
+func loadAttachmentsConfig(cfg *AttachmentsConfig) {
+	attachmentConfig.s3, attachmentConfig.urlTTL = nil, time.Hour
+	if cfg == nil {
+		return
+	}
+	if cfg.S3 != nil {
+		attachmentConfig.s3 = &s3Client{cfg: *cfg.S3}
+	}
+	if cfg.URLTTLSeconds > 0 {
+		attachmentConfig.urlTTL = time.Duration(cfg.URLTTLSeconds) * time.Second
+	}
+}
+
+// validateAttachmentsConfig reports an S3 setup that can't work.
+func validateAttachmentsConfig(cfg *AttachmentsConfig) error {
+	if cfg.URLTTLSeconds < 0 {
+		return fmt.Errorf("url_ttl_seconds must not be negative")
+	}
+	s := cfg.S3
+	if s == nil {
+		return nil
+	}
+	if s.Bucket == "" || s.Region == "" || s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return fmt.Errorf("s3 needs bucket, region, access_key_id and secret_access_key")
+	}
+	if s.Endpoint != "" && !isHTTPURL(s.Endpoint) {
+		return fmt.Errorf("s3 endpoint must be an http(s) URL")
+	}
+	return nil
+}
+
+// attachmentSignature signs an attachment ID, expiry and share token with a
+// key derived from the server key.
+func attachmentSignature(id string, expires int64, share string) string {
+	key := sha256.Sum256(append([]byte("memomarket-attachment-urls\n"), serverKey.Seed()...))
+	mac := hmac.New(sha256.New, key[:])
+	fmt.Fprintf(mac, "%s\n%d\n%s", id, expires, share)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signAttachment fills in a's signed download URL. share is the share link
+// token the pack was listed with, if any; the URL carries it so the
+// download is granted the same way while the link lasts.
+func signAttachment(a *Attachment, share string) {
+	expires := time.Now().Add(attachmentConfig.urlTTL).Unix()
+	a.URL = fmt.Sprintf("/api/attachments/%s?expires=%d&sig=%s", a.ID, expires, attachmentSignature(a.ID, expires, share))
+	if share != "" {
+		a.URL += "&share=" + url.QueryEscape(share)
+	}
+	a.ExpiresAt = time.Unix(expires, 0).UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// cleanAttachmentFilename keeps the last path element of an uploaded file's
+// name, without control characters.
+func cleanAttachmentFilename(name string) (string, error) {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, path.Base(strings.ReplaceAll(name, `\`, "/")))
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "", fmt.Errorf("filename is required")
+	}
+	if utf8.RuneCountInString(name) > maxAttachmentFilenameChars {
+		return "", fmt.Errorf("filename is limited to %d characters", maxAttachmentFilenameChars)
+	}
+	return name, nil
+}
+
+// validateMemoAttachments checks that the memos only list attachments of
+// packID, which is empty for a pack that doesn't exist yet.
+func validateMemoAttachments(packID string, memos []Memo) error {
+	for i, m := range memos {
+		for _, id := range m.Attachments {
+			a, err := store.GetAttachment(id)
+			if err != nil || packID == "" || a.PackID != packID {
+				return fmt.Errorf("memo %d: unknown attachment %q; upload it to the pack first", i+1, id)
+			}
+		}
+	}
+	return nil
+}
+
+// copyAttachments gives a new pack its own copies of the attachments its
+// memos list from another pack, rewriting the memos to the copies.
+func copyAttachments(pack *MemoPack, uploaderID string) error {
+	copied := map[string]string{}
+	blobs := attachmentBlobs()
+	for i := range pack.Memos {
+		ids := make([]string, 0, len(pack.Memos[i].Attachments))
+		for _, id := range pack.Memos[i].Attachments {
+			if c, ok := copied[id]; ok {
+				ids = append(ids, c)
+				continue
+			}
+			a, err := store.GetAttachment(id)
+			if err != nil {
+				continue
+			}
+			rc, err := blobs.Get(attachmentKey(a))
+			if err != nil {
+				return err
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			c := *a
+			c.ID, c.PackID, c.UploaderID, c.CreatedAt = newID(), pack.ID, uploaderID, nowISO()
+			if err := blobs.Put(attachmentKey(&c), data, c.ContentType); err != nil {
+				return err
+			}
+			if err := store.InsertAttachment(&c); err != nil {
+				return err
+			}
+			copied[id] = c.ID
+			ids = append(ids, c.ID)
+		}
+		pack.Memos[i].Attachments = ids
+	}
+	return nil
+}
+
+// removeOrphanAttachments deletes the attachments of purged packs, returning
+// how many it removed.
+func removeOrphanAttachments() int {
+	orphans, err := store.ListOrphanAttachments()
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for i := range orphans {
+		if attachmentBlobs().Delete(attachmentKey(&orphans[i])) != nil {
+			continue
+		}
+		if store.DeleteAttachment(orphans[i].ID) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// GET /api/memo-packs/{id}/attachments — the pack's attachments with signed
+// download URLs (public). POST uploads one as the request body, named by
+// ?filename=, and DELETE .../attachments/{aid} removes one no memo lists
+// (owner, collaborator or admin with ?reason=).
+func handlePackAttachments(w http.ResponseWriter, r *http.Request) {
+	_, aid, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/memo-packs/"), "/attachments")
+	aid = strings.Trim(aid, "/")
+	switch {
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && aid == "":
+		listAttachments(w, r)
+	case r.Method == http.MethodPost && aid == "":
+		uploadAttachment(w, r)
+	case r.Method == http.MethodDelete && aid != "":
+		deleteAttachment(w, r, aid)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+func listAttachments(w http.ResponseWriter, r *http.Request) {
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if restrictContent(r, pack) {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "sign in to view this pack's attachments"})
+		return
+	}
+	list, err := store.ListAttachments(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list attachments"})
+		return
+	}
+	share := ""
+	if shareGrants(r, pack) {
+		share = r.URL.Query().Get("share")
+	}
+	for i := range list {
+		signAttachment(&list[i], share)
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	writeJSON(w, http.StatusOK, list)
+}
+
+// attachmentEditor loads the pack of an attachment change and checks the
+// caller may make it. It writes the error response and returns nil
+// otherwise.
+func attachmentEditor(w http.ResponseWriter, r *http.Request) (pack *MemoPack, user *User, moderated bool, reason string) {
+	user = currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return nil, nil, false, ""
+	}
+	if !requireWritable(w, user) {
+		return nil, nil, false, ""
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return nil, nil, false, ""
+	}
+	moderated, reason, ok := checkPackEditor(w, r, user, pack)
+	if !ok {
+		return nil, nil, false, ""
+	}
+	if !moderated && rejectArchived(w, pack) {
+		return nil, nil, false, ""
+	}
+	return pack, user, moderated, reason
+}
+
+func uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	pack, user, moderated, reason := attachmentEditor(w, r)
+	if pack == nil {
+		return
+	}
+	st, err := userTrust(user)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to check trust level"})
+		return
+	}
+	if !st.Limits.Attachments {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: fmt.Sprintf("accounts at trust level %s can't upload attachments", st.Level)})
+		return
+	}
+	filename, err := cleanAttachmentFilename(r.URL.Query().Get("filename"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	existing, err := store.ListAttachments(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list attachments"})
+		return
+	}
+	if limit := contentLimits.MaxAttachments; limit > 0 && len(existing) >= limit {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("a pack may have at most %d attachments", limit)})
+		return
+	}
+	body := r.Body
+	if limit := contentLimits.MaxAttachmentBytes; limit > 0 {
+		body = http.MaxBytesReader(w, r.Body, int64(limit))
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		if _, ok := err.(*http.MaxBytesError); !ok {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "failed to read attachment"})
+			return
+		}
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("attachments must be at most %d bytes", contentLimits.MaxAttachmentBytes)})
+		return
+	}
+	if len(data) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "attachment is empty"})
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if mt, params, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mt != "application/x-www-form-urlencoded" {
+		contentType = mime.FormatMediaType(mt, params)
+	}
+
+	sum := sha256.Sum256(data)
+	a := &Attachment{
+		ID:          newID(),
+		PackID:      pack.ID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		UploaderID:  user.ID,
+		CreatedAt:   nowISO(),
+	}
+	if err := attachmentBlobs().Put(attachmentKey(a), data, contentType); err != nil {
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "failed to store attachment"})
+		return
+	}
+	if err := store.InsertAttachment(a); err != nil {
+		attachmentBlobs().Delete(attachmentKey(a))
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save attachment"})
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackEdit, TargetKind: "pack", TargetID: pack.ID, Reason: reason, Diff: fmt.Sprintf("attachment %s: %q", a.ID, a.Filename)})
+	if moderated {
+		notifyModeration(pack.AuthorID, pack.ID, pack.Contact, fmt.Sprintf("An admin added an attachment to your pack %q.", pack.Name), reason)
+	}
+	signAttachment(a, "")
+	writeJSON(w, http.StatusCreated, a)
+}
+
+func deleteAttachment(w http.ResponseWriter, r *http.Request, aid string) {
+	pack, user, moderated, reason := attachmentEditor(w, r)
+	if pack == nil {
+		return
+	}
+	a, err := store.GetAttachment(aid)
+	if err != nil || a.PackID != pack.ID {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "attachment not found"})
+		return
+	}
+	for _, m := range pack.Memos {
+		for _, id := range m.Attachments {
+			if id == a.ID {
+				writeJSON(w, http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("memo %d still lists this attachment", m.Order)})
+				return
+			}
+		}
+	}
+	if err := attachmentBlobs().Delete(attachmentKey(a)); err != nil {
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "failed to delete attachment"})
+		return
+	}
+	if err := store.DeleteAttachment(a.ID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete attachment"})
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackEdit, TargetKind: "pack", TargetID: pack.ID, Reason: reason, Diff: fmt.Sprintf("attachment %s removed", a.ID)})
+	if moderated {
+		notifyModeration(pack.AuthorID, pack.ID, pack.Contact, fmt.Sprintf("An admin removed an attachment from your pack %q.", pack.Name), reason)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// GET /api/attachments/{id}?expires=&sig= — download an attachment through a
+// signed URL (public while the signature is valid and the pack is visible
+// to the caller, through the share link the URL carries when it was listed
+// with one; sign-in needed when the pack requires it).
+func handleAttachmentDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/attachments/")
+	q := r.URL.Query()
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires ||
+		!hmac.Equal([]byte(q.Get("sig")), []byte(attachmentSignature(id, expires, q.Get("share")))) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "download link is invalid or has expired"})
+		return
+	}
+	a, err := store.GetAttachment(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "attachment not found"})
+		return
+	}
+	pack, err := store.GetMemoPack(a.PackID)
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "attachment not found"})
+		return
+	}
+	if restrictContent(r, pack) {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "sign in to download this attachment"})
+		return
+	}
+	rc, err := attachmentBlobs().Get(attachmentKey(a))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "attachment not found"})
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(max(expires-time.Now().Unix(), 0), 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, rc)
+}
@@ -0,0 +1,193 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The audit log records every mutating action with who did it, from where,
+// and a short summary of what changed: admin actions with their reason, and
+// users' own publishes, edits, deletes, logins and token issuance.
+
+// Audit actions.
+const (
+	auditTagRule            = "tag_rule.set"
+	auditTagRuleLift        = "tag_rule.delete"
+	auditCategorySet        = "category.set"
+	auditCategoryDelete     = "category.delete"
+	auditTemplateSet        = "template.set"
+	auditTemplateDelete     = "template.delete"
+	auditLegalHold          = "legal_hold.set"
+	auditLegalLift          = "legal_hold.release"
+	auditCleanup            = "cleanup.run"
+	auditPromote            = "replication.promote"
+	auditUserRole           = "user.role."  // + the new role
+	auditUserTrust          = "user.trust." // + the new level, or "auto"
+	auditUserBan            = "user.ban"
+	auditUserUnban          = "user.unban"
+	auditUserDeactivate     = "user.deactivate"
+	auditUserReactivate     = "user.reactivate"
+	auditOIDCLink           = "user.oidc_link"
+	auditOIDCUnlink         = "user.oidc_unlink"
+	auditPackPublish        = "pack.publish"
+	auditPackDraft          = "pack.draft"
+	auditPackFork           = "pack.fork"
+	auditPackClone          = "pack.clone"
+	auditPackArchive        = "pack.archive"
+	auditPackUnarchive      = "pack.unarchive"
+	auditPackPin            = "pack.pin"
+	auditPackUnpin          = "pack.unpin"
+	auditPackFeature        = "pack.feature"
+	auditPackUnfeature      = "pack.unfeature"
+	auditPackImport         = "pack.import"
+	auditPackClaim          = "pack.claim"
+	auditPackEdit           = "pack.edit"
+	auditPackDelete         = "pack.delete"
+	auditPackEmbargo        = "pack.embargo"
+	auditCollaboratorAdd    = "pack.collaborator_add"
+	auditCollaboratorRemove = "pack.collaborator_remove"
+	auditShareCreate        = "pack.share_create"
+	auditShareRevoke        = "pack.share_revoke"
+	auditPackRelease        = "pack.embargo_release"
+	auditRegister           = "user.register"
+	auditInviteCreate       = "invite.create"
+	auditInviteRevoke       = "invite.revoke"
+	auditLogin              = "user.login"
+	auditLoginFailed        = "user.login_failed"
+	auditProfileEdit        = "user.profile"
+	auditTokenRotate        = "token.refresh"
+	auditAPIKeyCreate       = "api_key.create"
+	auditAPIKeyRevoke       = "api_key.revoke"
+	auditServiceCreate      = "service_account.create"
+	auditServiceDelete      = "service_account.delete"
+	auditServiceToken       = "service_account.token_create"
+	auditServiceRevoke      = "service_account.token_revoke"
+	auditSessionEnd         = "session.revoke"
+)
+
+// recordAudit logs an action by actor. Failures are logged, not returned:
+// the action itself already happened.
+func recordAudit(r *http.Request, actor *User, action, targetKind, targetID, reason string) {
+	writeAudit(r, actor, &AuditEntry{Action: action, TargetKind: targetKind, TargetID: targetID, Reason: reason})
+}
+
+// writeAudit fills in the actor, IP and time of e and stores it.
+func writeAudit(r *http.Request, actor *User, e *AuditEntry) {
+	e.ID = newID()
+	e.ActorID, e.ActorName, e.Via = actor.ID, actor.Username, actor.ServiceAccount
+	e.IP = remoteIP(r)
+	e.CreatedAt = nowISO()
+	if err := store.InsertAuditEntry(e); err != nil {
+		log.Printf("audit %s %s/%s: %v", e.Action, e.TargetKind, e.TargetID, err)
+	}
+}
+
+// packSummary describes a newly published pack for the audit log.
+func packSummary(p *MemoPack) string {
+	return fmt.Sprintf("%q %s: %d rules, %d memos", p.Name, p.Version, len(p.Rules), len(p.Memos))
+}
+
+// packDiff summarizes what an update changed, e.g.
+// `version 1.0.0→1.1.0; rules 3→4; description`.
+func packDiff(before, after *MemoPack) string {
+	var changes []string
+	change := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%s %s→%s", field, from, to))
+		}
+	}
+	edited := func(field string, from, to any) {
+		a, _ := json.Marshal(from)
+		b, _ := json.Marshal(to)
+		if string(a) != string(b) {
+			changes = append(changes, field)
+		}
+	}
+	change("name", fmt.Sprintf("%q", before.Name), fmt.Sprintf("%q", after.Name))
+	change("version", before.Version, after.Version)
+	change("channel", before.Channel, after.Channel)
+	if len(before.Rules) != len(after.Rules) {
+		change("rules", fmt.Sprint(len(before.Rules)), fmt.Sprint(len(after.Rules)))
+	} else {
+		edited("rules", before.Rules, after.Rules)
+	}
+	if len(before.Memos) != len(after.Memos) {
+		change("memos", fmt.Sprint(len(before.Memos)), fmt.Sprint(len(after.Memos)))
+	} else {
+		edited("memos", before.Memos, after.Memos)
+	}
+	edited("evals", before.Evals, after.Evals)
+	edited("description", before.Description, after.Description)
+	edited("system_prompt", before.SystemPrompt, after.SystemPrompt)
+	edited("homepage", before.Homepage, after.Homepage)
+	edited("repository", before.Repository, after.Repository)
+	edited("contact", before.Contact, after.Contact)
+	change("require_auth", fmt.Sprint(before.RequireAuth), fmt.Sprint(after.RequireAuth))
+	change("language", before.Language, after.Language)
+	change("variant_of", before.VariantOf, after.VariantOf)
+	if len(changes) == 0 {
+		return "no changes"
+	}
+	return strings.Join(changes, "; ")
+}
+
+// parseAuditTime reads a since/until filter: a timestamp, or a date meaning
+// the start of that day, or its end when endOfDay is set.
+func parseAuditTime(s string, endOfDay bool) (string, error) {
+	if t, err := time.Parse("2006-01-02T15:04:05", s); err == nil {
+		return t.Format("2006-01-02T15:04:05"), nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Second)
+		}
+		return t.Format("2006-01-02T15:04:05"), nil
+	}
+	return "", fmt.Errorf("must be a date (2006-01-02) or timestamp (2006-01-02T15:04:05)")
+}
+
+// GET /api/admin/audit — recorded actions, newest first (admin only).
+// Filters: actor (user ID or name), action (exact, or a prefix ending in a
+// dot such as "pack."), target_kind, target_id, ip, since and until
+// (inclusive).
+func handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	lq := parseListQuery(r)
+	params := r.URL.Query()
+	q := AuditQuery{
+		Actor:      params.Get("actor"),
+		Action:     params.Get("action"),
+		TargetKind: params.Get("target_kind"),
+		TargetID:   params.Get("target_id"),
+		IP:         params.Get("ip"),
+		Page:       lq.Page,
+		Limit:      lq.Limit,
+	}
+	for _, f := range []struct {
+		name  string
+		dst   *string
+		until bool
+	}{{"since", &q.Since, false}, {"until", &q.Until, true}} {
+		if v := params.Get(f.name); v != "" {
+			t, err := parseAuditTime(v, f.until)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: f.name + " " + err.Error()})
+				return
+			}
+			*f.dst = t
+		}
+	}
+	entries, total, err := store.ListAuditEntries(q)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list audit log"})
+		return
+	}
+	writeJSON(w, http.StatusOK, ListResponse{Items: entries, Total: total, Page: q.Page, Limit: q.Limit})
+}
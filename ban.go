@@ -0,0 +1,96 @@
+package memomarket
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Admins can suspend an account, indefinitely or until a date. While the
+// suspension lasts its tokens and API keys are refused, logging in returns
+// the reason instead of a session, and its packs drop out of listings.
+// Packs stay reachable by ID so installs pinned to them keep working.
+
+// errAccountSuspended is returned by authenticate for suspended accounts.
+var errAccountSuspended = errors.New("account suspended")
+
+// writeSuspended answers a request from, or a login to, a suspended account.
+func writeSuspended(w http.ResponseWriter, ban *UserBan) {
+	writeJSON(w, http.StatusForbidden, SuspendedResponse{Error: "account suspended", Reason: ban.Reason, Until: ban.Until})
+}
+
+// POST /api/admin/users/{id}/ban — suspend an account; a reason is required
+// and until, a date or timestamp, is optional (admin only). DELETE lifts the
+// suspension.
+func handleAdminUserBan(w http.ResponseWriter, r *http.Request, id string) {
+	admin := currentUser(r)
+	user, err := store.GetUserByID(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user not found"})
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		var req BanReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if err := validateAdminReason(req.Reason); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		ban := &UserBan{Reason: req.Reason, BannedBy: admin.Username, BannedAt: nowISO()}
+		if req.Until != "" {
+			t, ok := parseTimeParam(strings.TrimSpace(req.Until))
+			if !ok {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "until must be a date or timestamp"})
+				return
+			}
+			if ban.Until = t.Format("2006-01-02T15:04:05"); ban.Until <= ban.BannedAt {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "until must be in the future"})
+				return
+			}
+		}
+		if user.ID == admin.ID {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "you can't suspend yourself"})
+			return
+		}
+		if user.ID == anonymousUserID {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "the anonymous user can't be suspended"})
+			return
+		}
+		if isAdmin(user) {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "admins can't be suspended; remove the admin role first"})
+			return
+		}
+		if err := store.BanUser(user.ID, ban); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to suspend user"})
+			return
+		}
+		detail := "indefinitely"
+		if ban.Until != "" {
+			detail = "until " + ban.Until
+		}
+		writeAudit(r, admin, &AuditEntry{Action: auditUserBan, TargetKind: "user", TargetID: user.ID, Reason: req.Reason, Diff: detail})
+		notifyModeration(user.ID, "", "", fmt.Sprintf("Your account was suspended %s.", detail), req.Reason)
+		writeJSON(w, http.StatusOK, ban)
+	case http.MethodDelete:
+		found, err := store.UnbanUser(user.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to lift suspension"})
+			return
+		}
+		if !found {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user is not suspended"})
+			return
+		}
+		recordAudit(r, admin, auditUserUnban, "user", user.ID, "")
+		notify(user.ID, "moderation", "", "Your account's suspension was lifted.")
+		writeJSON(w, http.StatusOK, map[string]string{"status": "lifted"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
@@ -0,0 +1,143 @@
+package memomarket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// A batch publish takes an array of packs, each in the body a single POST
+// /api/memo-packs takes, and stores them all in one transaction, so bulk
+// importers make one request instead of hundreds against the single SQLite
+// writer. Every pack is checked first with the same rules as a single
+// publish. If any is refused, none is stored and the results say what was
+// wrong with each.
+
+const maxBatchPacks = 100
+
+// A batch body may be this many times MaxRequestBytes.
+const batchBodyFactor = 10
+
+// itemRecorder captures the error response a single publish would have
+// written for one batch item.
+type itemRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *itemRecorder) Header() http.Header         { return rec.header }
+func (rec *itemRecorder) Write(b []byte) (int, error) { return rec.body.Write(b) }
+func (rec *itemRecorder) WriteHeader(status int)      { rec.status = status }
+
+// batchAllowance is how many more packs user may create today under the
+// trust level and quotas, or -1 without a limit.
+func batchAllowance(user *User) (int, error) {
+	left := -1
+	limit := func(n int) {
+		if left < 0 || n < left {
+			left = max(n, 0)
+		}
+	}
+	st, err := userTrust(user)
+	if err != nil {
+		return 0, err
+	}
+	if st.Limits.DailyPublishes > 0 {
+		limit(st.Limits.DailyPublishes - st.PublishedToday)
+	}
+	if quotaExempt(user) {
+		return left, nil
+	}
+	q, err := userQuota(user)
+	if err != nil {
+		return 0, err
+	}
+	if q.Packs.Limit > 0 {
+		limit(q.Packs.Limit - q.Packs.Used)
+	}
+	if q.PublishedToday.Limit > 0 {
+		limit(q.PublishedToday.Limit - q.PublishedToday.Used)
+	}
+	return left, nil
+}
+
+// POST /api/memo-packs/batch — publish an array of packs in one transaction
+// (auth required). The answer is 201 with one result per pack, in order, or
+// 422 with the error of each refused pack when none was stored. The body and
+// the answer may be gob instead of JSON (see gobContentType).
+func handleBatchPublish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	if contentLimits.MaxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(contentLimits.MaxRequestBytes)*batchBodyFactor)
+	}
+	var reqs []PublishMemoPackReq
+	if err := decodeRecords(r, &reqs); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(reqs) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "send an array of at least one pack"})
+		return
+	}
+	if len(reqs) > maxBatchPacks {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("a batch holds at most %d packs", maxBatchPacks)})
+		return
+	}
+	// Each pack is checked against the daily limits on its own, so the
+	// batch as a whole must fit in what is left of them.
+	left, err := batchAllowance(user)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to check quota"})
+		return
+	}
+	if left >= 0 && len(reqs) > left {
+		writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: fmt.Sprintf("you may create %d more packs today", left)})
+		return
+	}
+
+	results := make([]BatchResult, len(reqs))
+	packs := make([]*MemoPack, len(reqs))
+	viewers := make([][]string, len(reqs))
+	failed := false
+	for i := range reqs {
+		rec := &itemRecorder{header: http.Header{}}
+		results[i] = BatchResult{Index: i, Name: reqs[i].Name}
+		packs[i], viewers[i] = newPackFromReq(rec, user, &reqs[i])
+		if packs[i] == nil {
+			failed = true
+			results[i].Status = rec.status
+			results[i].Error = json.RawMessage(bytes.TrimSpace(rec.body.Bytes()))
+		}
+	}
+	if failed {
+		writeRecords(w, r, http.StatusUnprocessableEntity, BatchResponse{Error: "no packs were stored; see the errors of each", Results: results})
+		return
+	}
+
+	if err := store.InsertMemoPacks(packs); err != nil {
+		log.Printf("batch publish by %s: %v", user.ID, err)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to publish"})
+		return
+	}
+	for i, pack := range packs {
+		announcePack(r, user, pack, viewers[i])
+		results[i].Status = http.StatusCreated
+		results[i].ID, results[i].Slug, results[i].PackStatus = pack.ID, pack.Slug, pack.Status
+		results[i].DuplicateOf = pack.DuplicateOf
+	}
+	writeRecords(w, r, http.StatusCreated, BatchResponse{Results: results})
+}
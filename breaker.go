@@ -0,0 +1,226 @@
+package memomarket
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Circuit breakers around outbound calls: the LLM provider, SMTP, GitHub,
+// the replication primary, S3 and each webhook host. After breakerThreshold
+// consecutive failures a breaker opens and calls fail at once for
+// breakerCooldown; then one trial call is let through, and its outcome
+// closes the breaker or opens it again. A dead dependency so costs callers an
+// error instead of a timeout. States are reported by /readyz and the admin
+// metrics.
+
+const (
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+// Breaker names.
+const (
+	breakerLLM      = "llm"
+	breakerSMTP     = "smtp"
+	breakerGitHub   = "github"
+	breakerOIDC     = "oidc"
+	breakerPrimary  = "primary"
+	breakerS3       = "s3"
+	breakerWebhooks = "webhook" // + ":" + host
+)
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+type circuitBreaker struct {
+	mu          sync.Mutex
+	name        string
+	state       string
+	failures    int
+	openedAt    time.Time
+	trial       bool // a half-open trial call is in flight
+	trips       int
+	lastError   string
+	lastFailure string
+}
+
+var breakers = struct {
+	sync.Mutex
+	byName map[string]*circuitBreaker
+}{byName: map[string]*circuitBreaker{}}
+
+// breaker returns the breaker for a dependency, creating it on first use.
+func breaker(name string) *circuitBreaker {
+	breakers.Lock()
+	defer breakers.Unlock()
+	b, ok := breakers.byName[name]
+	if !ok {
+		b = &circuitBreaker{name: name, state: breakerClosed}
+		breakers.byName[name] = b
+	}
+	return b
+}
+
+// errCircuitOpen is returned, wrapped, for calls refused by an open breaker.
+var errCircuitOpen = fmt.Errorf("circuit open")
+
+// allow reports whether a call may go ahead.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		wait := breakerCooldown - time.Since(b.openedAt)
+		if wait > 0 {
+			return fmt.Errorf("%s is unavailable, retry in %ds: %w", b.name, int(wait.Seconds())+1, errCircuitOpen)
+		}
+		b.state = breakerHalfOpen
+		b.trial = true
+		return nil
+	case breakerHalfOpen:
+		if b.trial {
+			return fmt.Errorf("%s is unavailable, retry shortly: %w", b.name, errCircuitOpen)
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// done records the outcome of an allowed call.
+func (b *circuitBreaker) done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if err == nil {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	b.lastError = err.Error()
+	b.lastFailure = nowISO()
+	if b.state == breakerHalfOpen || b.failures >= breakerThreshold {
+		if b.state != breakerOpen {
+			b.trips++
+		}
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// call runs fn through the breaker.
+func (b *circuitBreaker) call(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.done(err)
+	return err
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := BreakerStatus{
+		Name:        b.name,
+		State:       b.state,
+		Failures:    b.failures,
+		Trips:       b.trips,
+		LastError:   b.lastError,
+		LastFailure: b.lastFailure,
+	}
+	if b.state == breakerOpen && time.Since(b.openedAt) >= breakerCooldown {
+		st.State = breakerHalfOpen
+	}
+	return st
+}
+
+// breakerStatuses lists every breaker used so far, by name.
+func breakerStatuses() []BreakerStatus {
+	breakers.Lock()
+	list := make([]*circuitBreaker, 0, len(breakers.byName))
+	for _, b := range breakers.byName {
+		list = append(list, b)
+	}
+	breakers.Unlock()
+	out := make([]BreakerStatus, 0, len(list))
+	for _, b := range list {
+		out = append(out, b.status())
+	}
+	slices.SortFunc(out, func(a, b BreakerStatus) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// breakerTransport sends requests through the breaker named name, or with
+// perHost set, through one breaker per destination host (name:host).
+// Transport errors and 5xx responses count as failures.
+type breakerTransport struct {
+	name    string
+	perHost bool
+	next    http.RoundTripper
+}
+
+func newBreakerTransport(name string, perHost bool) *breakerTransport {
+	return &breakerTransport{name: name, perHost: perHost, next: http.DefaultTransport}
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := t.name
+	if t.perHost {
+		name += ":" + req.URL.Host
+	}
+	b := breaker(name)
+	if err := b.allow(); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		b.done(err)
+	case resp.StatusCode >= 500:
+		b.done(fmt.Errorf("%s returned %s", req.URL.Host, resp.Status))
+	default:
+		b.done(nil)
+	}
+	return resp, err
+}
+
+// GET /readyz — readiness: 503 when the database can't be reached, otherwise
+// 200, "degraded" while a dependency's breaker is not closed (public). Only
+// states are shown; webhook hosts belong to users and are left out, see the
+// admin metrics for details.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	rd := Readiness{Status: "ok", Database: "ok", Dependencies: map[string]string{}}
+	for _, d := range breakerStatuses() {
+		if strings.HasPrefix(d.Name, breakerWebhooks+":") {
+			continue
+		}
+		rd.Dependencies[d.Name] = d.State
+		if d.State != breakerClosed {
+			rd.Status = "degraded"
+		}
+	}
+	status := http.StatusOK
+	if err := store.Ping(); err != nil {
+		log.Printf("readyz: %v", err)
+		rd.Status, rd.Database = "unavailable", "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, rd)
+}
@@ -0,0 +1,75 @@
+package memomarket
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// HTTP caching for public reads. Each endpoint belongs to a route class with
+// its own max-age; responses that depend on who is asking are never stored
+// by shared caches.
+
+const (
+	cacheListing   = "listing"   // pack lists, versions, reviews, stats
+	cachePack      = "pack"      // a single pack or its latest download
+	cacheImmutable = "immutable" // downloads pinned to an exact version
+	cacheInfo      = "info"      // server info and capabilities
+)
+
+var defaultCacheTTLs = map[string]int{
+	cacheListing:   60,
+	cachePack:      300,
+	cacheImmutable: 365 * 24 * 60 * 60,
+	cacheInfo:      3600,
+}
+
+// cacheTTLs holds the effective max-age in seconds per route class.
+var cacheTTLs = map[string]int{}
+
+func loadCacheConfig(cfg *CacheConfig) {
+	cacheTTLs = map[string]int{}
+	for class, ttl := range defaultCacheTTLs {
+		cacheTTLs[class] = ttl
+	}
+	if cfg == nil {
+		return
+	}
+	for class, ttl := range map[string]*int{
+		cacheListing:   cfg.ListingSeconds,
+		cachePack:      cfg.PackSeconds,
+		cacheImmutable: cfg.ImmutableSeconds,
+		cacheInfo:      cfg.InfoSeconds,
+	} {
+		if ttl != nil && *ttl >= 0 {
+			cacheTTLs[class] = *ttl
+		}
+	}
+}
+
+func init() {
+	loadCacheConfig(nil)
+}
+
+// setCacheHeaders marks a successful read as cacheable for its route class.
+// Private responses, such as auth-only packs, may only be kept by the
+// caller's browser and must be revalidated. Responses vary on Cookie as well
+// as Authorization, since a session cookie alone signs the caller in.
+func setCacheHeaders(w http.ResponseWriter, class string, private bool) {
+	h := w.Header()
+	h.Add("Vary", "Authorization")
+	h.Add("Vary", "Cookie")
+	if private {
+		h.Set("Cache-Control", "private, no-cache")
+		return
+	}
+	ttl := cacheTTLs[class]
+	if ttl == 0 {
+		h.Set("Cache-Control", "no-cache")
+		return
+	}
+	cc := "public, max-age=" + strconv.Itoa(ttl)
+	if class == cacheImmutable {
+		cc += ", immutable"
+	}
+	h.Set("Cache-Control", cc)
+}
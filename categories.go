@@ -0,0 +1,124 @@
+package memomarket
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Categories are a short, curated list that admins maintain, unlike the
+// free-form tags on rules and memos. A pack may be filed under one of them
+// with "category", and listings filter on ?category=. New databases start
+// with defaultCategories.
+
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+const (
+	maxCategoryNameChars        = 64
+	maxCategoryDescriptionChars = 280
+)
+
+var defaultCategories = []Category{
+	{Slug: "coding", Name: "Coding"},
+	{Slug: "writing", Name: "Writing"},
+	{Slug: "roleplay", Name: "Roleplay"},
+	{Slug: "productivity", Name: "Productivity"},
+	{Slug: "research", Name: "Research"},
+	{Slug: "education", Name: "Education"},
+	{Slug: "other", Name: "Other"},
+}
+
+// validatePackCategory normalizes the category of a publish or update; it
+// must be one of the listed categories.
+func validatePackCategory(req *PublishMemoPackReq) error {
+	req.Category = strings.ToLower(strings.TrimSpace(req.Category))
+	if req.Category == "" {
+		return nil
+	}
+	if _, err := store.GetCategory(req.Category); err != nil {
+		return fmt.Errorf("unknown category %q; see GET /api/categories", req.Category)
+	}
+	return nil
+}
+
+// GET /api/categories — the category list with the number of public packs
+// in each (public).
+func handleCategories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	cats, err := store.ListCategories()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list categories"})
+		return
+	}
+	setCacheHeaders(w, cacheListing, false)
+	writeJSON(w, http.StatusOK, cats)
+}
+
+// PUT /api/admin/categories/{slug} — add or rename a category. DELETE removes
+// it, moving its packs to ?into= or leaving them uncategorized (admin only).
+func handleAdminCategory(w http.ResponseWriter, r *http.Request) {
+	admin := currentUser(r)
+	slug := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/admin/categories/")))
+	switch r.Method {
+	case http.MethodPut:
+		if !categorySlugPattern.MatchString(slug) {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "category slugs are 1-32 lowercase letters, digits and hyphens"})
+			return
+		}
+		var req CategoryReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		c := &Category{Slug: slug, Name: strings.TrimSpace(req.Name), Description: strings.TrimSpace(req.Description), Position: req.Position}
+		if c.Name == "" {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "name is required"})
+			return
+		}
+		if utf8.RuneCountInString(c.Name) > maxCategoryNameChars || utf8.RuneCountInString(c.Description) > maxCategoryDescriptionChars {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("name is limited to %d characters and description to %d", maxCategoryNameChars, maxCategoryDescriptionChars)})
+			return
+		}
+		if err := store.SaveCategory(c); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save category"})
+			return
+		}
+		recordAudit(r, admin, auditCategorySet, "category", slug, "")
+	case http.MethodDelete:
+		into := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("into")))
+		if into == slug {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "into must be another category"})
+			return
+		}
+		if into != "" {
+			if _, err := store.GetCategory(into); err != nil {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("unknown category %q", into)})
+				return
+			}
+		}
+		found, err := store.DeleteCategory(slug, into)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete category"})
+			return
+		}
+		if !found {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "category not found"})
+			return
+		}
+		recordAudit(r, admin, auditCategoryDelete, "category", slug, "")
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	cats, err := store.ListCategories()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list categories"})
+		return
+	}
+	writeJSON(w, http.StatusOK, cats)
+}
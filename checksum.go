@@ -0,0 +1,36 @@
+package memomarket
+
+import "net/http"
+
+// Every pack response carries checksum, the SHA-256 of the pack's canonical
+// JSON: its name, description, system prompt, rules and memos, marshaled in
+// that order (see packContentChecksum). It is computed when the pack is
+// saved, before any filtering a download applies, and matches the checksum
+// in install receipts. A tool can recompute it over a download to verify it,
+// or poll GET /api/memo-packs/{id}/checksum to notice edits, even ones that
+// keep the version number.
+
+// GET /api/memo-packs/{id}/checksum — the pack's content checksum, or that of
+// ?version= (public).
+func handlePackChecksum(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	sum := PackChecksum{PackID: pack.ID, Version: pack.Version, Revision: pack.Revision, Checksum: pack.Checksum, UpdatedAt: pack.UpdatedAt}
+	if version := r.URL.Query().Get("version"); version != "" && version != pack.Version {
+		v, err := store.GetMemoPackVersion(pack.ID, version)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "version not found"})
+			return
+		}
+		sum = PackChecksum{PackID: pack.ID, Version: v.Version, Checksum: v.Checksum, UpdatedAt: v.UpdatedAt}
+	}
+	setCacheHeaders(w, cachePack, privateRead(pack))
+	serveJSONContent(w, r, sum, parseISO(sum.UpdatedAt))
+}
@@ -0,0 +1,104 @@
+package memomarket
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Cloning copies one of my own packs into a new draft, as a starting point
+// for a variant such as a version tuned for another model. Unlike a fork,
+// the clone doesn't point back at the original or count towards its
+// fork_count: both packs are the author's own. The clone takes the pack's
+// current content and settings, starts over at version 1.0.0 on the stable
+// channel, and gets its own copies of the attachments.
+
+// POST /api/memo-packs/{id}/clone — copy my pack into a new draft named
+// "<name> (copy)"; the body may name it (owner only).
+func handleClonePack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	orig, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, orig) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if orig.AuthorID != user.ID {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "only the author can clone a pack"})
+		return
+	}
+	var req ClonePackReq
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = orig.Name + " (copy)"
+	}
+	if err := checkPackNamePolicy(name, user.ID); err != nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !checkTrust(w, user, true, orig.Description) {
+		return
+	}
+
+	now := nowISO()
+	pack := &MemoPack{
+		ID:           newPackID(),
+		Name:         name,
+		Description:  orig.Description,
+		AuthorID:     user.ID,
+		AuthorName:   user.Username,
+		SystemPrompt: orig.SystemPrompt,
+		Rules:        orig.Rules,
+		Memos:        slices.Clone(orig.Memos),
+		Evals:        orig.Evals,
+		Version:      "1.0.0",
+		Language:     orig.Language,
+		Category:     orig.Category,
+		Visibility:   orig.Visibility,
+		Homepage:     orig.Homepage,
+		Repository:   orig.Repository,
+		Contact:      orig.Contact,
+		RequireAuth:  orig.RequireAuth,
+		Status:       packStatus(false),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if pack.Rules == nil {
+		pack.Rules = []MemoRule{}
+	}
+	if pack.Memos == nil {
+		pack.Memos = []Memo{}
+	}
+	if pack.Evals == nil {
+		pack.Evals = []PackEval{}
+	}
+	if !checkQuota(w, user, pack, true) {
+		return
+	}
+	if err := copyAttachments(pack, user.ID); err != nil {
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "failed to copy attachments"})
+		return
+	}
+	if err := store.InsertMemoPack(pack); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to clone pack"})
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackClone, TargetKind: "pack", TargetID: pack.ID, Diff: "from " + orig.ID})
+	writeJSON(w, http.StatusCreated, pack)
+}
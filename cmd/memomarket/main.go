@@ -0,0 +1,8 @@
+// Command memomarket runs the MemoMarket backend.
+package main
+
+import "github.com/n0n4we/memomarket"
+
+func main() {
+	memomarket.Main()
+}
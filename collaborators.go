@@ -0,0 +1,131 @@
+package memomarket
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Collaborators share the maintenance of a pack: they can update it, edit
+// its evals, promote versions and re-sync it from GitHub, but only the
+// author can delete it, manage its webhooks or embargo, or change who
+// collaborates.
+
+const maxCollaborators = 20
+
+// canEditPack reports whether user may change pack's content.
+func canEditPack(user *User, pack *MemoPack) bool {
+	return pack.AuthorID == user.ID || store.IsCollaborator(pack.ID, user.ID)
+}
+
+// checkPackEditor is checkPackOwner for content changes, also letting
+// collaborators through.
+func checkPackEditor(w http.ResponseWriter, r *http.Request, user *User, pack *MemoPack) (moderated bool, reason string, ok bool) {
+	if store.IsCollaborator(pack.ID, user.ID) {
+		return false, "", true
+	}
+	return checkPackOwner(w, r, user, pack)
+}
+
+// GET /api/memo-packs/{id}/collaborators — list collaborators (author and
+// collaborators). POST {"username"} adds one (author only).
+// DELETE /api/memo-packs/{id}/collaborators/{username} removes one; the
+// author can remove anyone and a collaborator can leave.
+func handlePackCollaborators(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	_, name, _ := strings.Cut(r.URL.Path, "/collaborators")
+	name = strings.Trim(name, "/")
+
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		if !canEditPack(user, pack) && !isAdmin(user) {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+			return
+		}
+	case r.Method == http.MethodPost && name == "":
+		if !requireWritable(w, user) {
+			return
+		}
+		if pack.AuthorID != user.ID {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "only the author can add collaborators"})
+			return
+		}
+		var req CollaboratorReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		other, err := store.GetUserByUsername(strings.TrimSpace(req.Username))
+		if err != nil || other.ID == anonymousUserID {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user not found"})
+			return
+		}
+		if other.ID == pack.AuthorID {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "the author is not a collaborator"})
+			return
+		}
+		list, err := store.ListCollaborators(pack.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to add collaborator"})
+			return
+		}
+		if len(list) >= maxCollaborators {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("a pack has at most %d collaborators", maxCollaborators)})
+			return
+		}
+		added, err := store.AddCollaborator(pack.ID, other.ID, user.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to add collaborator"})
+			return
+		}
+		if added {
+			recordAudit(r, user, auditCollaboratorAdd, "pack", pack.ID, other.Username)
+			notify(other.ID, "collaborator", pack.ID, fmt.Sprintf("%s added you as a collaborator on %s", user.Username, pack.Name))
+		}
+	case r.Method == http.MethodDelete && name != "":
+		if !requireWritable(w, user) {
+			return
+		}
+		other, err := store.GetUserByUsername(name)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "collaborator not found"})
+			return
+		}
+		if pack.AuthorID != user.ID && other.ID != user.ID {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "only the author can remove collaborators"})
+			return
+		}
+		removed, err := store.RemoveCollaborator(pack.ID, other.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to remove collaborator"})
+			return
+		}
+		if !removed {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "collaborator not found"})
+			return
+		}
+		recordAudit(r, user, auditCollaboratorRemove, "pack", pack.ID, other.Username)
+		if other.ID != user.ID {
+			notify(other.ID, "collaborator", pack.ID, fmt.Sprintf("%s removed you as a collaborator on %s", user.Username, pack.Name))
+		}
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+
+	list, err := store.ListCollaborators(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list collaborators"})
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
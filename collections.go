@@ -0,0 +1,448 @@
+package memomarket
+
+import (
+	"archive/zip"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Collections group packs, each optionally pinned to a version or version
+// constraint, and download as one zip bundle. Bundles of up to
+// bundleSyncItems packs are built while the client waits; larger ones are
+// built by a job and kept on disk until the resolved versions change.
+
+const (
+	maxCollectionItems       = 200
+	maxCollectionNameChars   = 100
+	maxCollectionDescription = 2000
+	bundleSyncItems          = 20
+)
+
+const JobCollectionBundle = "collection_bundle"
+
+// bundleDir holds generated collection bundles.
+var bundleDir = "./data/bundles"
+
+// validateCollection checks a create or update request. Every item must
+// name a live pack and, when pinned, a version it has.
+func validateCollection(req *CollectionReq) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if utf8.RuneCountInString(req.Name) > maxCollectionNameChars {
+		return fmt.Errorf("name must be at most %d characters", maxCollectionNameChars)
+	}
+	if utf8.RuneCountInString(req.Description) > maxCollectionDescription {
+		return fmt.Errorf("description must be at most %d characters", maxCollectionDescription)
+	}
+	if len(req.Items) > maxCollectionItems {
+		return fmt.Errorf("a collection holds at most %d packs", maxCollectionItems)
+	}
+	seen := map[string]bool{}
+	for i := range req.Items {
+		item := &req.Items[i]
+		item.Version = strings.TrimSpace(item.Version)
+		item.PackID = resolvePackID(strings.TrimSpace(item.PackID))
+		if seen[item.PackID] {
+			return fmt.Errorf("items[%d]: pack %s is already in the collection", i, item.PackID)
+		}
+		seen[item.PackID] = true
+		pack, err := store.GetMemoPack(item.PackID)
+		if err != nil || !packPublic(pack) {
+			return fmt.Errorf("items[%d]: pack %q not found", i, item.PackID)
+		}
+		if item.Version != "" {
+			if _, err := resolvePackVersion(pack, item.Version, ""); err != nil {
+				return fmt.Errorf("items[%d]: %v", i, err)
+			}
+		}
+	}
+	if req.Items == nil {
+		req.Items = []CollectionItem{}
+	}
+	return nil
+}
+
+// resolveCollection loads each item's pack at its pinned version, or the
+// latest stable one. Items that no longer resolve are listed with an error.
+func resolveCollection(c *Collection) ([]*MemoPack, []BundleEntry) {
+	var packs []*MemoPack
+	entries := make([]BundleEntry, 0, len(c.Items))
+	for _, item := range c.Items {
+		entry := BundleEntry{PackID: item.PackID, Pin: item.Version}
+		pack, err := store.GetMemoPack(item.PackID)
+		if err != nil || !packPublic(pack) {
+			entry.Error = "pack not found"
+			entries = append(entries, entry)
+			continue
+		}
+		var v *MemoPackVersion
+		if item.Version != "" {
+			v, err = resolvePackVersion(pack, item.Version, "")
+		} else {
+			v, err = channelVersion(pack, "")
+		}
+		if err != nil {
+			entry.Name = pack.Name
+			entry.Error = err.Error()
+			entries = append(entries, entry)
+			continue
+		}
+		if v != nil {
+			applyPackVersion(pack, v)
+		}
+		entry.Name, entry.Version = pack.Name, pack.Version
+		entry.File = "packs/" + pack.ID + ".json"
+		packs = append(packs, pack)
+		entries = append(entries, entry)
+	}
+	return packs, entries
+}
+
+// bundleKey identifies a bundle's contents, so a stored bundle is reused
+// until a pack it contains changes.
+func bundleKey(c *Collection, packs []*MemoPack) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", c.ID, c.UpdatedAt)
+	for _, p := range packs {
+		fmt.Fprintf(h, "%s@%s@%s\n", p.ID, p.Version, p.UpdatedAt)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func bundlePath(collectionID, key string) string {
+	return filepath.Join(bundleDir, collectionID+"-"+key+".zip")
+}
+
+// writeBundle writes the zip: collection.json describing the bundle, and
+// each pack as packs/{id}.json in the form the pack download returns.
+func writeBundle(w io.Writer, c *Collection, packs []*MemoPack, entries []BundleEntry) error {
+	zw := zip.NewWriter(w)
+	index := CollectionBundleIndex{
+		ID:          c.ID,
+		Name:        c.Name,
+		Description: c.Description,
+		Owner:       c.OwnerName,
+		Server:      serverName,
+		GeneratedAt: nowISO(),
+		Packs:       entries,
+	}
+	files := []struct {
+		name string
+		body any
+	}{{"collection.json", index}}
+	for _, p := range packs {
+		files = append(files, struct {
+			name string
+			body any
+		}{"packs/" + p.ID + ".json", p})
+	}
+	now := time.Now()
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// runCollectionBundle builds a large collection's bundle on disk and removes
+// the collection's older bundles.
+func runCollectionBundle(j *Job) (string, error) {
+	var payload CollectionBundlePayload
+	if err := json.Unmarshal([]byte(j.Payload), &payload); err != nil {
+		return "", fmt.Errorf("bad payload: %v", err)
+	}
+	c, err := store.GetCollection(payload.CollectionID)
+	if err != nil {
+		return "collection deleted; skipped", nil
+	}
+	packs, entries := resolveCollection(c)
+	path := bundlePath(c.ID, bundleKey(c, packs))
+	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+		return "", err
+	}
+	// Write then rename so a download never sees a partial file.
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	if err := writeBundle(f, c, packs, entries); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	removeBundles(c.ID, path)
+	return "/api/collections/" + c.ID + "/download?format=zip", nil
+}
+
+// removeBundles deletes the stored bundles of a collection except keep.
+func removeBundles(collectionID, keep string) {
+	old, _ := filepath.Glob(filepath.Join(bundleDir, collectionID+"-*.zip"))
+	for _, p := range old {
+		if p != keep {
+			os.Remove(p)
+		}
+	}
+}
+
+// bundleStatus reports whether c's current bundle is on disk, and otherwise
+// the state of the job building it.
+func bundleStatus(c *Collection, packs []*MemoPack) *BundleStatus {
+	if _, err := os.Stat(bundlePath(c.ID, bundleKey(c, packs))); err == nil {
+		return &BundleStatus{Status: "done", DownloadURL: "/api/collections/" + c.ID + "/download?format=zip"}
+	}
+	if c.BundleJobID != "" {
+		if job, err := store.GetJob(c.BundleJobID); err == nil {
+			return &BundleStatus{Status: job.Status, Job: job}
+		}
+	}
+	return &BundleStatus{Status: "none"}
+}
+
+// countBundleDownload counts a bundle download as a download of each pack
+// in it, under the same per-pack cap as single downloads.
+func countBundleDownload(r *http.Request, limitKey string, packs []*MemoPack) {
+	if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		return
+	}
+	var userID string
+	if user := currentUser(r); user != nil {
+		userID = user.ID
+	}
+	cid := currentClientID(r)
+	for _, p := range packs {
+		if !packDownloadAllowed(limitKey, p.ID) {
+			continue
+		}
+		store.IncrementMemoPackDownloads(p.ID, p.Version)
+		if cid != "" {
+			store.RecordDownloadEvent(p.ID, p.Version, cid, userID)
+		}
+	}
+}
+
+// POST /api/collections — create a collection (auth required).
+func handleCreateCollection(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	var req CollectionReq
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if err := validateCollection(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	now := nowISO()
+	c := &Collection{
+		ID:          newID(),
+		OwnerID:     user.ID,
+		OwnerName:   user.Username,
+		Name:        req.Name,
+		Description: req.Description,
+		Items:       req.Items,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := store.InsertCollection(c); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create collection"})
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// GET /api/me/collections — list my collections (auth required).
+func handleMyCollections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	list, err := store.ListUserCollections(user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list collections"})
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// GET /api/collections/{id} — get a collection (public). PUT replaces it and
+// DELETE removes it (owner only).
+func handleCollection(w http.ResponseWriter, r *http.Request) {
+	c, err := store.GetCollection(extractID(r.URL.Path, "/api/collections/"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "collection not found"})
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, c)
+		return
+	}
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	if c.OwnerID != user.ID {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your collection"})
+		return
+	}
+	if r.Method == http.MethodDelete {
+		if err := store.DeleteCollection(c.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete collection"})
+			return
+		}
+		removeBundles(c.ID, "")
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+		return
+	}
+	var req CollectionReq
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if err := validateCollection(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.Name, c.Description, c.Items = req.Name, req.Description, req.Items
+	c.UpdatedAt = nowISO()
+	if err := store.UpdateCollection(c); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update collection"})
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// GET /api/collections/{id}/download?format=zip — every pack in the
+// collection as one zip (public; sign-in needed when a pack requires it).
+// Large collections answer 202 with the bundle status until the bundle is
+// built; poll GET /api/collections/{id}/bundle.
+func handleCollectionDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	limitKey := rateLimitKey(r)
+	if ok, wait := downloadLimiter.allow(limitKey); !ok {
+		writeRateLimited(w, wait)
+		return
+	}
+	if format := cmp.Or(r.URL.Query().Get("format"), "zip"); format != "zip" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "format must be zip"})
+		return
+	}
+	c, err := store.GetCollection(extractID(r.URL.Path, "/api/collections/"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "collection not found"})
+		return
+	}
+	packs, entries := resolveCollection(c)
+	for _, p := range packs {
+		if p.RequireAuth && currentUser(r) == nil {
+			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "sign in to download this collection"})
+			return
+		}
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="collection-%s.zip"`, c.ID))
+
+	if len(c.Items) <= bundleSyncItems {
+		countBundleDownload(r, limitKey, packs)
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method == http.MethodHead {
+			return
+		}
+		writeBundle(limitBandwidth(w), c, packs, entries)
+		return
+	}
+
+	path := bundlePath(c.ID, bundleKey(c, packs))
+	if info, err := os.Stat(path); err == nil {
+		countBundleDownload(r, limitKey, packs)
+		if err := serveFile(limitBandwidth(w), r, "application/zip", path, info.ModTime()); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to read bundle"})
+		}
+		return
+	}
+	w.Header().Del("Content-Disposition")
+	st := bundleStatus(c, packs)
+	if st.Status != "pending" && st.Status != "running" {
+		job, err := enqueueJob(JobCollectionBundle, c.OwnerID, CollectionBundlePayload{CollectionID: c.ID})
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to queue bundle"})
+			return
+		}
+		if err := store.SetCollectionBundleJob(c.ID, job.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to queue bundle"})
+			return
+		}
+		st = &BundleStatus{Status: job.Status, Job: job}
+	}
+	w.Header().Set("Location", "/api/collections/"+c.ID+"/bundle")
+	writeJSON(w, http.StatusAccepted, st)
+}
+
+// GET /api/collections/{id}/bundle — whether the collection's zip bundle is
+// ready: none, pending, running, done or failed (public).
+func handleCollectionBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	c, err := store.GetCollection(extractID(r.URL.Path, "/api/collections/"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "collection not found"})
+		return
+	}
+	if len(c.Items) <= bundleSyncItems {
+		writeJSON(w, http.StatusOK, BundleStatus{Status: "done", DownloadURL: "/api/collections/" + c.ID + "/download?format=zip"})
+		return
+	}
+	packs, _ := resolveCollection(c)
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, bundleStatus(c, packs))
+}
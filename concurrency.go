@@ -0,0 +1,88 @@
+package memomarket
+
+import (
+	"net/http"
+)
+
+// Concurrency limits for expensive endpoints. Each route group has its own
+// cap and all groups share a global one; a request that can't get a slot is
+// rejected with 429 straight away instead of queueing behind the SQLite writer.
+var defaultConcurrency = map[string]int{
+	"import": 2,
+	"export": 2,
+	"evals":  2,
+	"etl":    1,
+}
+
+const defaultGlobalConcurrency = 6
+
+var (
+	routeSlots  = map[string]chan struct{}{}
+	globalSlots chan struct{}
+)
+
+func loadConcurrencyLimits(cfg *ConcurrencyConfig) {
+	global := defaultGlobalConcurrency
+	caps := map[string]int{}
+	for group, n := range defaultConcurrency {
+		caps[group] = n
+	}
+	if cfg != nil {
+		if cfg.Global > 0 {
+			global = cfg.Global
+		}
+		for group, n := range cfg.Routes {
+			if _, ok := caps[group]; ok && n > 0 {
+				caps[group] = n
+			}
+		}
+	}
+	globalSlots = make(chan struct{}, global)
+	routeSlots = map[string]chan struct{}{}
+	for group, n := range caps {
+		routeSlots[group] = make(chan struct{}, n)
+	}
+}
+
+func init() {
+	loadConcurrencyLimits(nil)
+}
+
+// acquireSlots takes a slot in the group's and the global semaphore without
+// waiting. On success the caller must call release when the work is done.
+func acquireSlots(group string) (release func(), ok bool) {
+	slots := routeSlots[group]
+	select {
+	case slots <- struct{}{}:
+	default:
+		return nil, false
+	}
+	select {
+	case globalSlots <- struct{}{}:
+	default:
+		<-slots
+		return nil, false
+	}
+	return func() {
+		<-globalSlots
+		<-slots
+	}, true
+}
+
+// limitConcurrency wraps an expensive handler in the group's and the global semaphore.
+func limitConcurrency(group string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, ok := acquireSlots(group)
+		if !ok {
+			writeBusy(w)
+			return
+		}
+		defer release()
+		next(w, r)
+	}
+}
+
+func writeBusy(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: "server busy, try again shortly"})
+}
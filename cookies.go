@@ -0,0 +1,155 @@
+package memomarket
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Browsers can hold a login in cookies instead of handling tokens: logging
+// in with "cookie": true puts the access token in an HttpOnly cookie and the
+// refresh token in another scoped to the refresh endpoint. Requests carrying
+// the session cookie that change anything must also send the CSRF token, in
+// the X-CSRF-Token header; it is returned at login and kept in a cookie
+// scripts can read. Bearer tokens work as before and need no CSRF token.
+
+const (
+	sessionCookie = "mm_session"
+	refreshCookie = "mm_refresh"
+	csrfCookie    = "mm_csrf"
+	csrfHeader    = "X-CSRF-Token"
+)
+
+// cookieConfig holds the effective cookie settings.
+var cookieConfig CookieConfig
+
+func loadCookieConfig(cfg *CookieConfig) {
+	c := CookieConfig{SameSite: "lax"}
+	if cfg != nil {
+		c.Domain = cfg.Domain
+		c.Secure = cfg.Secure
+		if cfg.SameSite != "" {
+			c.SameSite = strings.ToLower(cfg.SameSite)
+		}
+	}
+	cookieConfig = c
+}
+
+func init() {
+	loadCookieConfig(nil)
+}
+
+func (c CookieConfig) sameSite() http.SameSite {
+	switch c.SameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// secure reports whether cookies for r get the Secure flag: as configured,
+// otherwise when r came over HTTPS. SameSite=None requires it.
+func (c CookieConfig) secure(r *http.Request) bool {
+	if c.Secure != nil {
+		return *c.Secure
+	}
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" || c.SameSite == "none"
+}
+
+// requestToken returns the bearer token of r, or failing that its session
+// cookie, and whether it came from the cookie.
+func requestToken(r *http.Request) (token string, fromCookie bool) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token, false
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		return c.Value, true
+	}
+	return "", false
+}
+
+// validCSRF reports whether r may act with its session cookie: safe methods
+// always may, others need the CSRF header to match the CSRF cookie.
+func validCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(c.Value)) == 1
+}
+
+func newCSRFToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// setSessionCookies moves u's freshly issued tokens from the response body
+// into cookies and gives u a new CSRF token in their place.
+func setSessionCookies(w http.ResponseWriter, r *http.Request, u *User) {
+	u.CSRFToken = newCSRFToken()
+	writeSessionCookies(w, r, u.Token, u.RefreshToken, u.RefreshExpiresAt, u.CSRFToken)
+	u.Token, u.RefreshToken = "", ""
+}
+
+func writeSessionCookies(w http.ResponseWriter, r *http.Request, token, refresh, refreshExpiresAt, csrf string) {
+	for _, c := range sessionCookies(r, token, refresh, csrf) {
+		c.Expires = parseISO(refreshExpiresAt)
+		http.SetCookie(w, c)
+	}
+}
+
+func clearSessionCookies(w http.ResponseWriter, r *http.Request) {
+	for _, c := range sessionCookies(r, "", "", "") {
+		c.Expires, c.MaxAge = time.Unix(0, 0), -1
+		http.SetCookie(w, c)
+	}
+}
+
+func sessionCookies(r *http.Request, token, refresh, csrf string) []*http.Cookie {
+	cookies := []*http.Cookie{
+		{Name: sessionCookie, Value: token, Path: "/", HttpOnly: true},
+		{Name: refreshCookie, Value: refresh, Path: "/api/token/refresh", HttpOnly: true},
+		{Name: csrfCookie, Value: csrf, Path: "/"},
+	}
+	for _, c := range cookies {
+		c.Domain = cookieConfig.Domain
+		c.Secure = cookieConfig.secure(r)
+		c.SameSite = cookieConfig.sameSite()
+	}
+	return cookies
+}
+
+// POST /api/logout — end the current login and clear the session cookies
+// (auth required).
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireLogin(w, user) {
+		return
+	}
+	if _, err := store.DeleteSession(user.SessionID, user.ID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to end session"})
+		return
+	}
+	recordAudit(r, user, auditSessionEnd, "session", user.SessionID, "")
+	clearSessionCookies(w, r)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "logged out"})
+}
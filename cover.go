@@ -0,0 +1,225 @@
+package memomarket
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A pack may have a cover image, uploaded as the raw body of
+// PUT /api/memo-packs/{id}/cover. Covers are PNG, JPEG or GIF images of at
+// most maxCoverBytes and maxCoverSide pixels a side, stored under the data
+// dir as {pack}-{hash}.{ext}. The pack's cover column keeps "{hash}.{ext}",
+// and pack responses link it as cover_url with the hash as a cache buster.
+
+const (
+	maxCoverBytes = 1 << 20
+	maxCoverSide  = 2048
+)
+
+// coverDir holds pack cover images.
+var coverDir = "./data/covers"
+
+// coverTypes maps the content types accepted for covers to file extensions.
+var coverTypes = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/gif":  "gif",
+}
+
+func coverPath(packID, cover string) string {
+	return filepath.Join(coverDir, packID+"-"+cover)
+}
+
+// coverURL is where pack's cover is served, or "" without one.
+func coverURL(packID, cover string) string {
+	if cover == "" {
+		return ""
+	}
+	hash, _, _ := strings.Cut(cover, ".")
+	return "/api/memo-packs/" + packID + "/cover?v=" + hash
+}
+
+// validateCover checks an uploaded image and returns its file extension.
+func validateCover(data []byte) (string, error) {
+	ext, ok := coverTypes[http.DetectContentType(data)]
+	if !ok {
+		return "", fmt.Errorf("cover must be a PNG, JPEG or GIF image")
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("cover image can't be read: %v", err)
+	}
+	if cfg.Width < 1 || cfg.Height < 1 || cfg.Width > maxCoverSide || cfg.Height > maxCoverSide {
+		return "", fmt.Errorf("cover must be at most %dx%d pixels", maxCoverSide, maxCoverSide)
+	}
+	return ext, nil
+}
+
+// removeCovers deletes the stored covers of a pack except keep.
+func removeCovers(packID, keep string) {
+	old, _ := filepath.Glob(filepath.Join(coverDir, packID+"-*"))
+	for _, p := range old {
+		if p != keep {
+			os.Remove(p)
+		}
+	}
+}
+
+// removeOrphanCovers deletes the covers of purged packs, returning how many
+// it removed.
+func removeOrphanCovers() int {
+	files, _ := filepath.Glob(filepath.Join(coverDir, "*"))
+	n := 0
+	for _, p := range files {
+		name := filepath.Base(p)
+		i := strings.LastIndex(name, "-")
+		if i < 0 || store.PackRowExists(name[:i]) {
+			continue
+		}
+		if os.Remove(p) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// GET /api/memo-packs/{id}/cover — the pack's cover image (public). PUT
+// uploads one as the request body and DELETE removes it (owner, collaborator
+// or admin with ?reason=).
+func handlePackCover(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		serveCover(w, r)
+	case http.MethodPut, http.MethodDelete:
+		changeCover(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+func serveCover(w http.ResponseWriter, r *http.Request) {
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if pack.Cover == "" {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack has no cover"})
+		return
+	}
+	f, err := os.Open(coverPath(pack.ID, pack.Cover))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack has no cover"})
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to read cover"})
+		return
+	}
+	hash, ext, _ := strings.Cut(pack.Cover, ".")
+	for ct, e := range coverTypes {
+		if e == ext {
+			w.Header().Set("Content-Type", ct)
+		}
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	// A cover URL names its content, so it can be cached for good; only
+	// privately when the pack isn't open to everyone, so access revoked
+	// later isn't outlived by a shared cache.
+	class := cachePack
+	if r.URL.Query().Get("v") == hash {
+		class = cacheImmutable
+	}
+	setCacheHeaders(w, class, privateRead(pack))
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+func changeCover(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	moderated, reason, ok := checkPackEditor(w, r, user, pack)
+	if !ok {
+		return
+	}
+	if !moderated && rejectArchived(w, pack) {
+		return
+	}
+
+	var cover, path, diff string
+	if r.Method == http.MethodPut {
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCoverBytes))
+		if err != nil {
+			if _, ok := err.(*http.MaxBytesError); !ok {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "failed to read cover"})
+				return
+			}
+			writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("cover must be at most %d KiB", maxCoverBytes>>10)})
+			return
+		}
+		ext, err := validateCover(data)
+		if err != nil {
+			writeJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{Error: err.Error()})
+			return
+		}
+		sum := sha256.Sum256(data)
+		cover = hex.EncodeToString(sum[:])[:16] + "." + ext
+		path = coverPath(pack.ID, cover)
+		if err := os.MkdirAll(coverDir, 0755); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save cover"})
+			return
+		}
+		// Write then rename so a reader never sees a partial file.
+		if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save cover"})
+			return
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			os.Remove(path + ".tmp")
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save cover"})
+			return
+		}
+		diff = "cover: " + cover
+	} else {
+		if pack.Cover == "" {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack has no cover"})
+			return
+		}
+		diff = "cover removed"
+	}
+	if err := store.SetPackCover(pack.ID, cover); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save cover"})
+		return
+	}
+	removeCovers(pack.ID, path)
+	writeAudit(r, user, &AuditEntry{Action: auditPackEdit, TargetKind: "pack", TargetID: pack.ID, Reason: reason, Diff: diff})
+	if moderated {
+		notifyModeration(pack.AuthorID, pack.ID, pack.Contact, fmt.Sprintf("An admin changed the cover of your pack %q.", pack.Name), reason)
+	}
+	pack.Cover, pack.CoverURL = cover, coverURL(pack.ID, cover)
+	writeJSON(w, http.StatusOK, pack)
+}
@@ -1,38 +1,24 @@
-package main
+package memomarket
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var db *sql.DB
-
-func InitDB(dataDir string) {
-	os.MkdirAll(dataDir, 0755)
-	dbPath := filepath.Join(dataDir, "memomarket.db")
-
-	var err error
-	db, err = sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_foreign_keys=ON")
-	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
-	}
-
-	db.SetMaxOpenConns(1) // SQLite single-writer
-	db.SetMaxIdleConns(2)
-	db.SetConnMaxLifetime(0)
-
-	migrate()
-}
-
-func migrate() {
+func (s *SQLiteStore) migrate() {
 	schema := `
 	CREATE TABLE IF NOT EXISTS users (
 		id TEXT PRIMARY KEY,
@@ -60,11 +46,690 @@ func migrate() {
 
 	CREATE INDEX IF NOT EXISTS idx_memo_packs_author ON memo_packs(author_id);
 	CREATE INDEX IF NOT EXISTS idx_memo_packs_published ON memo_packs(published);
+
+	CREATE TABLE IF NOT EXISTS eval_runs (
+		id TEXT PRIMARY KEY,
+		pack_id TEXT NOT NULL,
+		pack_version TEXT NOT NULL,
+		model TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'running',
+		passed INTEGER NOT NULL DEFAULT 0,
+		failed INTEGER NOT NULL DEFAULT 0,
+		results TEXT NOT NULL DEFAULT '[]',
+		error TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		finished_at TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_eval_runs_pack ON eval_runs(pack_id);
+
+	CREATE TABLE IF NOT EXISTS memo_pack_versions (
+		pack_id TEXT NOT NULL,
+		version TEXT NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		system_prompt TEXT NOT NULL DEFAULT '',
+		rules TEXT NOT NULL DEFAULT '[]',
+		memos TEXT NOT NULL DEFAULT '[]',
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+		PRIMARY KEY (pack_id, version),
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS download_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		pack_id TEXT NOT NULL,
+		version TEXT NOT NULL DEFAULT '',
+		client_id TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_download_events_pack ON download_events(pack_id, client_id);
+	CREATE INDEX IF NOT EXISTS idx_download_events_client ON download_events(client_id, pack_id);
+
+	CREATE TABLE IF NOT EXISTS tag_rules (
+		tag TEXT PRIMARY KEY,
+		action TEXT NOT NULL,
+		into_tag TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT (datetime('now'))
+	);
+
+	CREATE TABLE IF NOT EXISTS install_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		pack_id TEXT NOT NULL,
+		version TEXT NOT NULL DEFAULT '',
+		client_id TEXT NOT NULL DEFAULT '',
+		client TEXT NOT NULL DEFAULT '',
+		client_version TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_install_events_pack ON install_events(pack_id, client_id);
+
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id TEXT PRIMARY KEY,
+		owner_id TEXT NOT NULL DEFAULT '',
+		url TEXT NOT NULL,
+		kind TEXT NOT NULL DEFAULT 'generic',
+		events TEXT NOT NULL DEFAULT '[]',
+		created_at TEXT NOT NULL DEFAULT (datetime('now'))
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhooks_owner ON webhooks(owner_id);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id TEXT PRIMARY KEY,
+		webhook_id TEXT NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TEXT NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		delivered_at TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+
+	CREATE TABLE IF NOT EXISTS subscriptions (
+		user_id TEXT NOT NULL,
+		pack_id TEXT NOT NULL,
+		last_version TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		PRIMARY KEY (user_id, pack_id),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_subscriptions_pack ON subscriptions(pack_id);
+
+	CREATE TABLE IF NOT EXISTS notifications (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		pack_id TEXT NOT NULL DEFAULT '',
+		message TEXT NOT NULL,
+		read INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS reviews (
+		pack_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		username TEXT NOT NULL DEFAULT '',
+		rating INTEGER NOT NULL,
+		body TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+		PRIMARY KEY (pack_id, user_id),
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS jobs (
+		id TEXT PRIMARY KEY,
+		kind TEXT NOT NULL,
+		user_id TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending',
+		payload TEXT NOT NULL DEFAULT '',
+		result TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		run_at TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		finished_at TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(status, run_at);
+	CREATE INDEX IF NOT EXISTS idx_jobs_user ON jobs(user_id, kind, created_at);
+
+	CREATE TABLE IF NOT EXISTS pack_blobs (
+		pack_id TEXT NOT NULL,
+		field TEXT NOT NULL,
+		data TEXT NOT NULL,
+		PRIMARY KEY (pack_id, field),
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS stars (
+		pack_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		PRIMARY KEY (pack_id, user_id),
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS verifications (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		token TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		verified_at TEXT NOT NULL DEFAULT '',
+		UNIQUE (user_id, kind, subject),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_verifications_subject ON verifications(kind, subject);
+
+	CREATE TABLE IF NOT EXISTS vacations (
+		user_id TEXT PRIMARY KEY,
+		starts_at TEXT NOT NULL,
+		ends_at TEXT NOT NULL,
+		message TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS milestone_prefs (
+		user_id TEXT PRIMARY KEY,
+		downloads TEXT,
+		new_clients INTEGER NOT NULL DEFAULT 1,
+		notify INTEGER NOT NULL DEFAULT 1,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		token TEXT UNIQUE NOT NULL,
+		token_expires_at TEXT NOT NULL,
+		refresh_token TEXT UNIQUE NOT NULL,
+		refresh_expires_at TEXT NOT NULL,
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		last_used_at TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT UNIQUE NOT NULL,
+		scopes TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		last_used_at TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
+
+	CREATE TABLE IF NOT EXISTS service_accounts (
+		id TEXT PRIMARY KEY,
+		name TEXT UNIQUE NOT NULL,
+		author_id TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id TEXT PRIMARY KEY,
+		actor_id TEXT NOT NULL,
+		actor_name TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target_kind TEXT NOT NULL DEFAULT '',
+		target_id TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT (datetime('now'))
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS pack_claims (
+		pack_id TEXT PRIMARY KEY,
+		token_hash TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS embargo_viewers (
+		pack_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		PRIMARY KEY (pack_id, user_id),
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS pack_collaborators (
+		pack_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		added_by TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		PRIMARY KEY (pack_id, user_id),
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS share_links (
+		id TEXT PRIMARY KEY,
+		pack_id TEXT NOT NULL,
+		token_hash TEXT UNIQUE NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		expires_at TEXT NOT NULL DEFAULT '',
+		created_by TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		FOREIGN KEY (pack_id) REFERENCES memo_packs(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_share_links_pack ON share_links(pack_id);
+
+	CREATE TABLE IF NOT EXISTS invites (
+		code TEXT PRIMARY KEY,
+		note TEXT NOT NULL DEFAULT '',
+		max_uses INTEGER NOT NULL DEFAULT 1,
+		uses INTEGER NOT NULL DEFAULT 0,
+		expires_at TEXT NOT NULL DEFAULT '',
+		created_by TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now'))
+	);
+
+	CREATE TABLE IF NOT EXISTS oidc_identities (
+		issuer TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		PRIMARY KEY (issuer, subject)
+	);
+	CREATE INDEX IF NOT EXISTS idx_oidc_identities_user ON oidc_identities(issuer, user_id);
+
+	CREATE TABLE IF NOT EXISTS pack_slugs (
+		slug TEXT PRIMARY KEY,
+		pack_id TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now'))
+	);
+	CREATE INDEX IF NOT EXISTS idx_pack_slugs_pack ON pack_slugs(pack_id);
+
+	CREATE TABLE IF NOT EXISTS attachments (
+		id TEXT PRIMARY KEY,
+		pack_id TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		sha256 TEXT NOT NULL,
+		uploader_id TEXT NOT NULL,
+		created_at TEXT NOT NULL DEFAULT (datetime('now'))
+	);
+	CREATE INDEX IF NOT EXISTS idx_attachments_pack ON attachments(pack_id);
+
+	CREATE TABLE IF NOT EXISTS categories (
+		slug TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		position INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS pack_templates (
+		slug TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		position INTEGER NOT NULL DEFAULT 0,
+		pack TEXT NOT NULL DEFAULT '{}',
+		updated_at TEXT NOT NULL DEFAULT (datetime('now'))
+	);
+
+	CREATE TABLE IF NOT EXISTS collections (
+		id TEXT PRIMARY KEY,
+		owner_id TEXT NOT NULL,
+		owner_name TEXT NOT NULL,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		items TEXT NOT NULL DEFAULT '[]',
+		bundle_job_id TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL DEFAULT (datetime('now')),
+		updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+		FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_collections_owner ON collections(owner_id);
 	`
-	_, err := db.Exec(schema)
+	var hasCategories bool
+	s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'categories')`).Scan(&hasCategories)
+	_, err := s.db.Exec(schema)
 	if err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
+	if !hasCategories {
+		for i, c := range defaultCategories {
+			c.Position = i
+			if err := s.SaveCategory(&c); err != nil {
+				log.Fatalf("Failed to seed categories: %v", err)
+			}
+		}
+	}
+
+	// Columns added after the initial schema.
+	s.addColumn("memo_packs", "version", "TEXT NOT NULL DEFAULT '1.0.0'")
+	s.addColumn("memo_packs", "evals", "TEXT NOT NULL DEFAULT '[]'")
+	s.addColumn("memo_packs", "provenance", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "rule_count", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "memo_count", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "system_prompt_chars", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "total_chars", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "content_updated_at", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "rating_avg", "REAL NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "rating_count", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "star_count", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "homepage", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "repository", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "contact", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "require_auth", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "external_fields", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "revision", "INTEGER NOT NULL DEFAULT 1")
+	s.addColumn("memo_packs", "channel", "TEXT NOT NULL DEFAULT 'stable'")
+	s.addColumn("memo_packs", "language", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "variant_of", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "forked_from", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "fork_count", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "cover", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "category", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "slug", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "content_hash", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "content_simhash", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "checksum", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "visibility", "TEXT NOT NULL DEFAULT 'public'")
+	s.addColumn("memo_packs", "embargo_until", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "archived_at", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "pinned_at", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "featured_at", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_pack_versions", "channel", "TEXT NOT NULL DEFAULT 'stable'")
+	s.addColumn("memo_pack_versions", "downloads", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("subscriptions", "channel", "TEXT NOT NULL DEFAULT 'stable'")
+	s.backfillContentInfo()
+	s.checkUsernameConflicts()
+	s.addColumn("webhooks", "pack_id", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("webhooks", "secret", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "name_skeleton", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("download_events", "user_id", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "deleted_at", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "legal_hold", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("memo_packs", "legal_hold_reason", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "legal_hold", "INTEGER NOT NULL DEFAULT 0")
+	s.addColumn("users", "legal_hold_reason", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("memo_packs", "name_skeleton", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "token_expires_at", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "refresh_token", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "refresh_expires_at", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "role", "TEXT NOT NULL DEFAULT 'user'")
+	s.addColumn("audit_log", "diff", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "display_name", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "bio", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("audit_log", "ip", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "website", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "avatar_url", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "trust_level", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "active", "INTEGER NOT NULL DEFAULT 1")
+	s.addColumn("users", "deactivated_at", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "deactivated_by", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "banned_at", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "banned_until", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "ban_reason", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("users", "banned_by", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("api_keys", "service_account_id", "TEXT NOT NULL DEFAULT ''")
+	s.addColumn("audit_log", "via", "TEXT NOT NULL DEFAULT ''")
+	s.migrateSessions()
+	s.backfillNameSkeletons()
+	s.backfillSlugs()
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_users_skeleton ON users(name_skeleton);
+		CREATE INDEX IF NOT EXISTS idx_memo_packs_skeleton ON memo_packs(name_skeleton);
+		CREATE INDEX IF NOT EXISTS idx_memo_packs_forked_from ON memo_packs(forked_from);
+		CREATE INDEX IF NOT EXISTS idx_memo_packs_category ON memo_packs(category);
+		CREATE INDEX IF NOT EXISTS idx_memo_packs_content_hash ON memo_packs(content_hash)`); err != nil {
+		log.Fatalf("Failed to create skeleton indexes: %v", err)
+	}
+
+	// Packs published before version history existed get their current content as a snapshot.
+	_, err = s.db.Exec(`INSERT OR IGNORE INTO memo_pack_versions (pack_id, version, name, description, system_prompt, rules, memos, created_at, updated_at)
+		SELECT id, version, name, description, system_prompt, rules, memos, updated_at, updated_at FROM memo_packs`)
+	if err != nil {
+		log.Fatalf("Failed to backfill version history: %v", err)
+	}
+	s.externalizeLargeBodies()
+	s.backfillFingerprints()
+	s.backfillChecksums()
+	s.migrateSearchIndex()
+}
+
+// backfillFingerprints computes the duplicate detection fingerprints of
+// packs stored before they existed.
+func (s *SQLiteStore) backfillFingerprints() {
+	rows, err := s.db.Query(`SELECT id FROM memo_packs WHERE content_hash = '' AND (system_prompt != '' OR rules != '[]' OR memos != '[]')`)
+	if err != nil {
+		log.Fatalf("Failed to backfill fingerprints: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	for _, id := range ids {
+		mp, err := scanMemoPack(s.db.QueryRow(`SELECT `+memoPackColumns+` FROM memo_packs WHERE id=?`, id))
+		if err != nil {
+			continue
+		}
+		if err := s.loadPackBlobs(mp); err != nil {
+			log.Fatalf("Failed to backfill fingerprints: %v", err)
+		}
+		hash, sim := packFingerprint(mp)
+		if _, err := s.db.Exec(`UPDATE memo_packs SET content_hash=?, content_simhash=? WHERE id=?`, hash, sim, id); err != nil {
+			log.Fatalf("Failed to backfill fingerprints: %v", err)
+		}
+	}
+}
+
+// backfillChecksums computes the content checksums of packs stored before
+// they existed.
+func (s *SQLiteStore) backfillChecksums() {
+	rows, err := s.db.Query(`SELECT id FROM memo_packs WHERE checksum = ''`)
+	if err != nil {
+		log.Fatalf("Failed to backfill checksums: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	for _, id := range ids {
+		mp, err := scanMemoPack(s.db.QueryRow(`SELECT `+memoPackColumns+` FROM memo_packs WHERE id=?`, id))
+		if err != nil {
+			continue
+		}
+		if err := s.loadPackBlobs(mp); err != nil {
+			log.Fatalf("Failed to backfill checksums: %v", err)
+		}
+		if _, err := s.db.Exec(`UPDATE memo_packs SET checksum=? WHERE id=?`, packContentChecksum(mp), id); err != nil {
+			log.Fatalf("Failed to backfill checksums: %v", err)
+		}
+	}
+}
+
+// externalizeLargeBodies moves bodies stored inline before they exceeded
+// packBlobThreshold (or before the threshold was lowered) into pack_blobs.
+func (s *SQLiteStore) externalizeLargeBodies() {
+	rows, err := s.db.Query(
+		`SELECT id FROM memo_packs WHERE
+		   (instr(external_fields, 'system_prompt') = 0 AND length(CAST(system_prompt AS BLOB)) > ?1) OR
+		   (instr(external_fields, 'rules') = 0 AND length(CAST(rules AS BLOB)) > ?1) OR
+		   (instr(external_fields, 'memos') = 0 AND length(CAST(memos AS BLOB)) > ?1)`,
+		packBlobThreshold,
+	)
+	if err != nil {
+		log.Fatalf("Failed to externalize pack bodies: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		mp, err := scanMemoPack(s.db.QueryRow(`SELECT `+memoPackColumns+` FROM memo_packs WHERE id=?`, id))
+		if err == nil {
+			err = s.loadPackBlobs(mp)
+		}
+		if err == nil {
+			err = s.savePackBodies(mp)
+		}
+		if err != nil {
+			log.Fatalf("Failed to externalize pack %s: %v", id, err)
+		}
+	}
+}
+
+// checkUsernameConflicts reports accounts that predate username validation:
+// names differing only by case and names that are now invalid or reserved.
+// The case-insensitive unique index is only created once no duplicates remain.
+func (s *SQLiteStore) checkUsernameConflicts() {
+	rows, err := s.db.Query(`SELECT username FROM users`)
+	if err != nil {
+		log.Fatalf("Failed to check usernames: %v", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+
+	seen := map[string]string{}
+	duplicates := false
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if other, ok := seen[key]; ok {
+			log.Printf("WARNING: usernames %q and %q differ only by case", other, name)
+			duplicates = true
+		}
+		seen[key] = name
+		if err := validateUsername(name); err != nil {
+			log.Printf("WARNING: existing user %q: %v", name, err)
+		}
+	}
+	if duplicates {
+		log.Printf("WARNING: skipping case-insensitive username index until duplicates are resolved")
+		return
+	}
+	if _, err := s.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_nocase ON users(username COLLATE NOCASE)`); err != nil {
+		log.Fatalf("Failed to create username index: %v", err)
+	}
+}
+
+// migrateSessions moves tokens kept on users rows into sessions, one per
+// user, and leaves a placeholder in users.token, which can't be dropped.
+// Tokens issued before expiry existed stay valid for one refresh period.
+func (s *SQLiteStore) migrateSessions() {
+	later := time.Now().UTC().Add(refreshTokenTTL).Format("2006-01-02T15:04:05")
+	if _, err := s.db.Exec(
+		`INSERT INTO sessions (id, user_id, token, token_expires_at, refresh_token, refresh_expires_at, created_at)
+		 SELECT lower(hex(randomblob(16))), id, token,
+		        CASE WHEN token_expires_at = '' THEN ? ELSE token_expires_at END,
+		        CASE WHEN refresh_token = '' THEN lower(hex(randomblob(16))) ELSE refresh_token END,
+		        CASE WHEN refresh_expires_at = '' THEN ? ELSE refresh_expires_at END,
+		        created_at
+		 FROM users WHERE token NOT LIKE '-%'`, later, later); err != nil {
+		log.Fatalf("Failed to migrate sessions: %v", err)
+	}
+	if _, err := s.db.Exec(`UPDATE users SET token = '-' || id, token_expires_at = '', refresh_token = '', refresh_expires_at = '' WHERE token NOT LIKE '-%'`); err != nil {
+		log.Fatalf("Failed to migrate sessions: %v", err)
+	}
+}
+
+// backfillNameSkeletons fills in confusable-folded names for rows that lack them.
+func (s *SQLiteStore) backfillNameSkeletons() {
+	for _, table := range []string{"users", "memo_packs"} {
+		col := "name"
+		if table == "users" {
+			col = "username"
+		}
+		rows, err := s.db.Query(`SELECT id, ` + col + ` FROM ` + table + ` WHERE name_skeleton = ''`)
+		if err != nil {
+			log.Fatalf("Failed to backfill name skeletons: %v", err)
+		}
+		names := map[string]string{}
+		for rows.Next() {
+			var id, name string
+			if rows.Scan(&id, &name) == nil {
+				names[id] = name
+			}
+		}
+		rows.Close()
+		for id, name := range names {
+			if _, err := s.db.Exec(`UPDATE `+table+` SET name_skeleton=? WHERE id=?`, nameSkeleton(name), id); err != nil {
+				log.Fatalf("Failed to backfill name skeletons: %v", err)
+			}
+		}
+	}
+}
+
+// backfillContentInfo computes size metadata for packs stored before it existed.
+func (s *SQLiteStore) backfillContentInfo() {
+	rows, err := s.db.Query(`SELECT ` + memoPackColumns + ` FROM memo_packs WHERE content_updated_at = ''`)
+	if err != nil {
+		log.Fatalf("Failed to backfill content info: %v", err)
+	}
+	var packs []*MemoPack
+	for rows.Next() {
+		if mp, err := scanMemoPack(rows); err == nil {
+			packs = append(packs, mp)
+		}
+	}
+	rows.Close()
+
+	for _, mp := range packs {
+		info := computeContentInfo(mp)
+		_, err := s.db.Exec(
+			`UPDATE memo_packs SET rule_count=?, memo_count=?, system_prompt_chars=?, total_chars=?, content_updated_at=? WHERE id=?`,
+			info.RuleCount, info.MemoCount, info.SystemPromptChars, info.TotalChars, mp.UpdatedAt, mp.ID,
+		)
+		if err != nil {
+			log.Fatalf("Failed to backfill content info: %v", err)
+		}
+	}
+}
+
+// addColumn adds a column to an existing table unless it is already present.
+func (s *SQLiteStore) addColumn(table, column, def string) {
+	rows, err := s.db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		log.Fatalf("Failed to inspect table %s: %v", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			log.Fatalf("Failed to inspect table %s: %v", table, err)
+		}
+		if name == column {
+			return
+		}
+	}
+	rows.Close()
+	if _, err := s.db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + def); err != nil {
+		log.Fatalf("Failed to add column %s.%s: %v", table, column, err)
+	}
 }
 
 func nowISO() string {
@@ -77,14 +742,20 @@ func newID() string {
 
 // ---- User DB operations ----
 
-func CreateUser(username, passwordHash string) (*User, error) {
+func (s *SQLiteStore) CreateUser(username, passwordHash string) (*User, error) {
+	var exists int
+	s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ? COLLATE NOCASE`, username).Scan(&exists)
+	if exists > 0 {
+		return nil, fmt.Errorf("username already taken")
+	}
+
 	id := newID()
-	token := uuid.New().String()
 	now := nowISO()
-
-	_, err := db.Exec(
-		`INSERT INTO users (id, username, password_hash, token, created_at) VALUES (?, ?, ?, ?, ?)`,
-		id, username, passwordHash, token, now,
+	// Tokens live in sessions; users.token only has to stay unique.
+	_, err := s.db.Exec(
+		`INSERT INTO users (id, username, password_hash, token, created_at, name_skeleton)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		id, username, passwordHash, "-"+id, now, nameSkeleton(username),
 	)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint") {
@@ -92,141 +763,3083 @@ func CreateUser(username, passwordHash string) (*User, error) {
 		}
 		return nil, fmt.Errorf("failed to create user: %v", err)
 	}
-	return &User{ID: id, Username: username, Token: token, CreatedAt: now}, nil
+	return &User{ID: id, Username: username, Role: roleUser, CreatedAt: now}, nil
 }
 
-func GetUserByToken(token string) (*User, error) {
+// errTokenExpired is returned for access tokens past their expiry; clients
+// should refresh rather than log in again.
+var errTokenExpired = errors.New("token expired")
+
+// sessionTouchInterval limits how often last_used_at is written for a session.
+const sessionTouchInterval = 5 * time.Minute
+
+func (s *SQLiteStore) GetUserByToken(token string) (*User, error) {
 	var u User
-	err := db.QueryRow(
-		`SELECT id, username, token, created_at FROM users WHERE token = ?`, token,
-	).Scan(&u.ID, &u.Username, &u.Token, &u.CreatedAt)
+	var lastUsed string
+	err := s.db.QueryRow(
+		`SELECT u.id, u.username, u.role, s.token, s.token_expires_at, u.created_at, s.id, s.last_used_at
+		 FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.token = ?`, token,
+	).Scan(&u.ID, &u.Username, &u.Role, &u.Token, &u.TokenExpiresAt, &u.CreatedAt, &u.SessionID, &lastUsed)
 	if err != nil {
 		return nil, err
 	}
+	if u.TokenExpiresAt <= nowISO() {
+		return nil, errTokenExpired
+	}
+	if now := time.Now().UTC(); lastUsed < now.Add(-sessionTouchInterval).Format("2006-01-02T15:04:05") {
+		s.db.Exec(`UPDATE sessions SET last_used_at=? WHERE id=?`, now.Format("2006-01-02T15:04:05"), u.SessionID)
+	}
 	return &u, nil
 }
 
-func GetUserByID(id string) (*User, error) {
-	var u User
-	err := db.QueryRow(
-		`SELECT id, username, '', created_at FROM users WHERE id = ?`, id,
-	).Scan(&u.ID, &u.Username, &u.Token, &u.CreatedAt)
+// CreateSession stores a new session for sess.UserID.
+func (s *SQLiteStore) CreateSession(sess *Session) error {
+	_, err := s.db.Exec(
+		`INSERT INTO sessions (id, user_id, token, token_expires_at, refresh_token, refresh_expires_at, user_agent, created_at, last_used_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sess.ID, sess.UserID, sess.Token, sess.ExpiresAt, sess.RefreshToken, sess.RefreshExpiresAt, sess.UserAgent, sess.CreatedAt, sess.LastUsedAt,
+	)
+	return err
+}
+
+// GetSessionByRefreshToken returns the session holding an unexpired refresh
+// token.
+func (s *SQLiteStore) GetSessionByRefreshToken(refreshToken string) (*Session, error) {
+	var sess Session
+	err := s.db.QueryRow(
+		`SELECT id, user_id, created_at FROM sessions WHERE refresh_token = ? AND refresh_expires_at > ?`, refreshToken, nowISO(),
+	).Scan(&sess.ID, &sess.UserID, &sess.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
-	return &u, nil
+	return &sess, nil
 }
 
-func GetUserByUsername(username string) (*User, error) {
-	var u User
-	err := db.QueryRow(
-		`SELECT id, username, password_hash, token, created_at FROM users WHERE username = ?`, username,
-	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Token, &u.CreatedAt)
+// RotateSessionTokens replaces a session's token pair if its refresh token
+// is still oldRefreshToken. It reports false when another refresh got there
+// first, so each refresh token is redeemed once.
+func (s *SQLiteStore) RotateSessionTokens(sess *Session, oldRefreshToken string) (bool, error) {
+	res, err := s.db.Exec(
+		`UPDATE sessions SET token=?, token_expires_at=?, refresh_token=?, refresh_expires_at=?, last_used_at=? WHERE id=? AND refresh_token=?`,
+		sess.Token, sess.ExpiresAt, sess.RefreshToken, sess.RefreshExpiresAt, sess.LastUsedAt, sess.ID, oldRefreshToken,
+	)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	return &u, nil
+	n, _ := res.RowsAffected()
+	return n > 0, nil
 }
 
-// ---- MemoPack DB operations ----
-
-func InsertMemoPack(mp *MemoPack) error {
-	_, err := db.Exec(
-		`INSERT INTO memo_packs (id, name, description, author_id, author_name, system_prompt, rules, memos, downloads, published, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		mp.ID, mp.Name, mp.Description, mp.AuthorID, mp.AuthorName,
-		mp.SystemPrompt, MarshalRules(mp.Rules), MarshalMemos(mp.Memos),
-		mp.Downloads, boolToInt(mp.Published), mp.CreatedAt, mp.UpdatedAt,
+// ListSessions returns userID's sessions that can still be used or
+// refreshed, most recently used first.
+func (s *SQLiteStore) ListSessions(userID string) ([]Session, error) {
+	rows, err := s.db.Query(
+		`SELECT id, user_agent, created_at, last_used_at, token_expires_at, refresh_expires_at FROM sessions
+		 WHERE user_id = ? AND refresh_expires_at > ?
+		 ORDER BY max(last_used_at, created_at) DESC`, userID, nowISO(),
 	)
-	return err
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &s.RefreshExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
 }
 
-func UpdateMemoPack(mp *MemoPack) error {
-	_, err := db.Exec(
-		`UPDATE memo_packs SET name=?, description=?, system_prompt=?, rules=?, memos=?, published=?, updated_at=?
-		 WHERE id=? AND author_id=?`,
-		mp.Name, mp.Description, mp.SystemPrompt,
-		MarshalRules(mp.Rules), MarshalMemos(mp.Memos), boolToInt(mp.Published), nowISO(),
-		mp.ID, mp.AuthorID,
-	)
-	return err
+// DeleteSession revokes one of userID's sessions, reporting whether it existed.
+func (s *SQLiteStore) DeleteSession(id, userID string) (bool, error) {
+	n, err := s.execCount(`DELETE FROM sessions WHERE id = ? AND user_id = ?`, id, userID)
+	return n > 0, err
 }
 
-func DeleteMemoPack(id, authorID string) error {
-	_, err := db.Exec(`DELETE FROM memo_packs WHERE id=? AND author_id=?`, id, authorID)
-	return err
+// PurgeSessions deletes sessions whose refresh token expired before cutoff.
+func (s *SQLiteStore) PurgeSessions(cutoff string) (int, error) {
+	return s.execCount(`DELETE FROM sessions WHERE refresh_expires_at < ?`, cutoff)
 }
 
-func GetMemoPack(id string) (*MemoPack, error) {
-	var mp MemoPack
-	var rulesJSON, memosJSON string
-	var published int
-	err := db.QueryRow(
-		`SELECT id, name, description, author_id, author_name, system_prompt, rules, memos, downloads, published, created_at, updated_at
-		 FROM memo_packs WHERE id=?`, id,
-	).Scan(&mp.ID, &mp.Name, &mp.Description, &mp.AuthorID, &mp.AuthorName,
-		&mp.SystemPrompt, &rulesJSON, &memosJSON, &mp.Downloads, &published, &mp.CreatedAt, &mp.UpdatedAt)
+// GetUserByAPIKey returns the owner of the key hashed to keyHash, with the
+// key's ID and scopes set.
+func (s *SQLiteStore) GetUserByAPIKey(keyHash string) (*User, error) {
+	var u User
+	var scopes, lastUsed string
+	err := s.db.QueryRow(
+		`SELECT u.id, u.username, u.role, u.created_at, k.id, k.scopes, k.last_used_at, COALESCE(sa.name, '')
+		 FROM api_keys k JOIN users u ON u.id = k.user_id LEFT JOIN service_accounts sa ON sa.id = k.service_account_id
+		 WHERE k.key_hash = ? AND u.active = 1`, keyHash,
+	).Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt, &u.APIKeyID, &scopes, &lastUsed, &u.ServiceAccount)
+	if err != nil {
+		return nil, err
+	}
+	u.Scopes = strings.Split(scopes, ",")
+	if now := time.Now().UTC(); lastUsed < now.Add(-sessionTouchInterval).Format("2006-01-02T15:04:05") {
+		s.db.Exec(`UPDATE api_keys SET last_used_at=? WHERE id=?`, now.Format("2006-01-02T15:04:05"), u.APIKeyID)
+	}
+	return &u, nil
+}
+
+func (s *SQLiteStore) CreateAPIKey(k *APIKey, keyHash string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO api_keys (id, user_id, service_account_id, name, prefix, key_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		k.ID, k.UserID, k.ServiceAccountID, k.Name, k.Prefix, keyHash, strings.Join(k.Scopes, ","), k.CreatedAt,
+	)
+	return err
+}
+
+// ListAPIKeys returns userID's own keys, leaving out the tokens of service
+// accounts publishing as the user.
+func (s *SQLiteStore) ListAPIKeys(userID string) ([]APIKey, error) {
+	return s.listAPIKeys(`user_id = ? AND service_account_id = ''`, userID)
+}
+
+func (s *SQLiteStore) ListServiceTokens(accountID string) ([]APIKey, error) {
+	return s.listAPIKeys(`service_account_id = ?`, accountID)
+}
+
+func (s *SQLiteStore) listAPIKeys(where string, arg string) ([]APIKey, error) {
+	rows, err := s.db.Query(
+		`SELECT id, name, prefix, scopes, created_at, last_used_at FROM api_keys WHERE `+where+` ORDER BY created_at DESC`, arg,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		var scopes string
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &k.CreatedAt, &k.LastUsedAt); err != nil {
+			return nil, err
+		}
+		k.Scopes = strings.Split(scopes, ",")
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// DeleteAPIKey revokes one of userID's keys, reporting whether it existed.
+func (s *SQLiteStore) DeleteAPIKey(id, userID string) (bool, error) {
+	n, err := s.execCount(`DELETE FROM api_keys WHERE id = ? AND user_id = ? AND service_account_id = ''`, id, userID)
+	return n > 0, err
+}
+
+func (s *SQLiteStore) DeleteServiceToken(id, accountID string) (bool, error) {
+	n, err := s.execCount(`DELETE FROM api_keys WHERE id = ? AND service_account_id = ?`, id, accountID)
+	return n > 0, err
+}
+
+func (s *SQLiteStore) InsertServiceAccount(sa *ServiceAccount) error {
+	_, err := s.db.Exec(
+		`INSERT INTO service_accounts (id, name, author_id, created_by, created_at) VALUES (?, ?, ?, ?, ?)`,
+		sa.ID, sa.Name, sa.AuthorID, sa.CreatedBy, sa.CreatedAt,
+	)
+	if err != nil && strings.Contains(err.Error(), "UNIQUE") {
+		return fmt.Errorf("a service account named %q already exists", sa.Name)
+	}
+	return err
+}
+
+const serviceAccountColumns = `sa.id, sa.name, sa.author_id, u.username, COALESCE(c.username, ''), sa.created_at
+	FROM service_accounts sa JOIN users u ON u.id = sa.author_id LEFT JOIN users c ON c.id = sa.created_by`
+
+func (s *SQLiteStore) GetServiceAccount(id string) (*ServiceAccount, error) {
+	var sa ServiceAccount
+	err := s.db.QueryRow(`SELECT `+serviceAccountColumns+` WHERE sa.id = ?`, id).
+		Scan(&sa.ID, &sa.Name, &sa.AuthorID, &sa.Author, &sa.CreatedBy, &sa.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &sa, nil
+}
+
+func (s *SQLiteStore) ListServiceAccounts() ([]ServiceAccount, error) {
+	rows, err := s.db.Query(`SELECT ` + serviceAccountColumns + ` ORDER BY sa.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []ServiceAccount{}
+	for rows.Next() {
+		var sa ServiceAccount
+		if err := rows.Scan(&sa.ID, &sa.Name, &sa.AuthorID, &sa.Author, &sa.CreatedBy, &sa.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, sa)
+	}
+	return list, rows.Err()
+}
+
+// DeleteServiceAccount removes a service account and revokes its tokens.
+func (s *SQLiteStore) DeleteServiceAccount(id string) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM api_keys WHERE service_account_id = ?`, id); err != nil {
+		return false, err
+	}
+	res, err := tx.Exec(`DELETE FROM service_accounts WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, tx.Commit()
+}
+
+func (s *SQLiteStore) GetUserByID(id string) (*User, error) {
+	var u User
+	err := s.db.QueryRow(
+		`SELECT id, username, '', role, created_at, deactivated_by FROM users WHERE id = ?`, id,
+	).Scan(&u.ID, &u.Username, &u.Token, &u.Role, &u.CreatedAt, &u.DeactivatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// FindUserBySkeleton returns the username whose folded form equals skeleton.
+func (s *SQLiteStore) FindUserBySkeleton(skeleton string) (string, error) {
+	var name string
+	err := s.db.QueryRow(`SELECT username FROM users WHERE name_skeleton = ? LIMIT 1`, skeleton).Scan(&name)
+	return name, err
+}
+
+func (s *SQLiteStore) GetUserByUsername(username string) (*User, error) {
+	var u User
+	err := s.db.QueryRow(
+		`SELECT id, username, password_hash, role, created_at, deactivated_by FROM users WHERE username = ?`, username,
+	).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.DeactivatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUserProfile returns the public profile of username, matched without
+// regard to case, with totals over the user's published packs.
+func (s *SQLiteStore) GetUserProfile(username string) (*UserProfile, error) {
+	var p UserProfile
+	err := s.db.QueryRow(
+		`SELECT u.id, u.username, u.display_name, u.bio, u.website, u.avatar_url, u.created_at,
+		        COUNT(m.id), COALESCE(SUM(m.downloads), 0)
+		 FROM users u LEFT JOIN memo_packs m ON m.author_id = u.id AND m.published = 1 AND m.visibility = 'public' AND m.deleted_at = ''
+		 WHERE u.username = ? COLLATE NOCASE AND u.active = 1 GROUP BY u.id`, username,
+	).Scan(&p.ID, &p.Username, &p.DisplayName, &p.Bio, &p.Website, &p.AvatarURL, &p.JoinedAt,
+		&p.PackCount, &p.TotalDownloads)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetUserActivity returns the figures a user's trust level is based on.
+func (s *SQLiteStore) GetUserActivity(userID, since string) (*UserActivity, error) {
+	var a UserActivity
+	err := s.db.QueryRow(
+		`SELECT u.created_at, u.trust_level,
+		        (SELECT COUNT(*) FROM memo_packs WHERE author_id = u.id AND published = 1 AND deleted_at = ''),
+		        (SELECT COUNT(*) FROM memo_packs WHERE author_id = u.id AND deleted_at = ''),
+		        (SELECT COALESCE(SUM(downloads), 0) FROM memo_packs WHERE author_id = u.id AND published = 1 AND deleted_at = ''),
+		        (SELECT COUNT(*) FROM memo_packs WHERE author_id = u.id AND created_at >= ?)
+		 FROM users u WHERE u.id = ?`, since, userID,
+	).Scan(&a.CreatedAt, &a.TrustLevel, &a.Packs, &a.LivePacks, &a.Downloads, &a.PublishedSince)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *SQLiteStore) SetUserTrustLevel(id, level string) (bool, error) {
+	n, err := s.execCount(`UPDATE users SET trust_level = ? WHERE id = ?`, level, id)
+	return n > 0, err
+}
+
+// activeBanSQL matches users whose suspension is in force; it takes the
+// current time as its one argument.
+const activeBanSQL = `banned_at != '' AND (banned_until = '' OR banned_until > ?)`
+
+// GetUserBan returns the user's suspension, or sql.ErrNoRows when none is in
+// force.
+func (s *SQLiteStore) GetUserBan(userID string) (*UserBan, error) {
+	var b UserBan
+	err := s.db.QueryRow(
+		`SELECT ban_reason, banned_until, banned_by, banned_at FROM users WHERE id = ? AND `+activeBanSQL,
+		userID, nowISO(),
+	).Scan(&b.Reason, &b.Until, &b.BannedBy, &b.BannedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *SQLiteStore) BanUser(userID string, b *UserBan) error {
+	_, err := s.db.Exec(
+		`UPDATE users SET banned_at = ?, banned_until = ?, ban_reason = ?, banned_by = ? WHERE id = ?`,
+		b.BannedAt, b.Until, b.Reason, b.BannedBy, userID,
+	)
+	return err
+}
+
+// UnbanUser lifts a suspension in force, reporting whether there was one.
+func (s *SQLiteStore) UnbanUser(userID string) (bool, error) {
+	n, err := s.execCount(
+		`UPDATE users SET banned_at = '', banned_until = '', ban_reason = '', banned_by = '' WHERE id = ? AND `+activeBanSQL,
+		userID, nowISO(),
+	)
+	return n > 0, err
+}
+
+// DeactivateUser deactivates an active account on behalf of by and ends
+// its sessions, reporting whether it was active.
+func (s *SQLiteStore) DeactivateUser(userID, by string) (bool, error) {
+	n, err := s.execCount(
+		`UPDATE users SET active = 0, deactivated_at = ?, deactivated_by = ? WHERE id = ? AND active = 1`,
+		nowISO(), by, userID,
+	)
+	if err != nil || n == 0 {
+		return false, err
+	}
+	_, err = s.db.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+	return true, err
+}
+
+// ReactivateUser restores a deactivated account, reporting whether it was
+// deactivated.
+func (s *SQLiteStore) ReactivateUser(userID string) (bool, error) {
+	n, err := s.execCount(
+		`UPDATE users SET active = 1, deactivated_at = '', deactivated_by = '' WHERE id = ? AND active = 0`, userID,
+	)
+	return n > 0, err
+}
+
+func (s *SQLiteStore) IsUserDeactivated(userID string) bool {
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE id = ? AND active = 0`, userID).Scan(&n)
+	return n > 0
+}
+
+func (s *SQLiteStore) UpdateUserProfile(userID string, req *ProfileReq) error {
+	_, err := s.db.Exec(
+		`UPDATE users SET display_name = ?, bio = ?, website = ?, avatar_url = ? WHERE id = ?`,
+		req.DisplayName, req.Bio, req.Website, req.AvatarURL, userID,
+	)
+	return err
+}
+
+// ListUsers returns a page of users, oldest first, optionally only those
+// with role.
+func (s *SQLiteStore) ListUsers(role string, page, limit int) ([]User, int, error) {
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE ? = '' OR role = ?`, role, role).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(
+		`SELECT id, username, role, created_at FROM users WHERE ? = '' OR role = ?
+		 ORDER BY created_at, id LIMIT ? OFFSET ?`, role, role, limit, (page-1)*limit,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+	return users, total, rows.Err()
+}
+
+// SetUserRole changes a user's role, reporting whether the user exists.
+func (s *SQLiteStore) SetUserRole(id, role string) (bool, error) {
+	n, err := s.execCount(`UPDATE users SET role = ? WHERE id = ?`, role, id)
+	return n > 0, err
+}
+
+// ---- MemoPack DB operations ----
+
+const memoPackColumns = `id, name, description, author_id, author_name, system_prompt, rules, memos, downloads, published, created_at, updated_at, version, evals, provenance,
+	rule_count, memo_count, system_prompt_chars, total_chars, content_updated_at, rating_avg, rating_count, star_count,
+	homepage, repository, contact, require_auth, external_fields, revision, channel, language, variant_of, embargo_until, archived_at,
+	forked_from, fork_count, cover, category, slug, checksum, visibility, pinned_at, featured_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// dbConn is a *sql.DB or a *sql.Tx, for writes that may run inside a
+// larger transaction.
+type dbConn interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func scanMemoPack(row rowScanner) (*MemoPack, error) {
+	var mp MemoPack
+	var rulesJSON, memosJSON, evalsJSON, provenanceJSON, external string
+	var published, requireAuth int
+	err := row.Scan(&mp.ID, &mp.Name, &mp.Description, &mp.AuthorID, &mp.AuthorName,
+		&mp.SystemPrompt, &rulesJSON, &memosJSON, &mp.Downloads, &published, &mp.CreatedAt, &mp.UpdatedAt,
+		&mp.Version, &evalsJSON, &provenanceJSON,
+		&mp.Content.RuleCount, &mp.Content.MemoCount, &mp.Content.SystemPromptChars, &mp.Content.TotalChars,
+		&mp.Content.ContentUpdatedAt, &mp.Ratings.Average, &mp.Ratings.Count, &mp.Ratings.Stars,
+		&mp.Homepage, &mp.Repository, &mp.Contact, &requireAuth, &external, &mp.Revision, &mp.Channel,
+		&mp.Language, &mp.VariantOf, &mp.EmbargoUntil, &mp.ArchivedAt,
+		&mp.ForkedFrom, &mp.ForkCount, &mp.Cover, &mp.Category, &mp.Slug, &mp.Checksum, &mp.Visibility, &mp.PinnedAt, &mp.FeaturedAt)
 	if err != nil {
 		return nil, err
 	}
+	if external != "" {
+		mp.externalFields = strings.Split(external, ",")
+		mp.ContentOmitted = true
+		if slices.Contains(mp.externalFields, "system_prompt") {
+			mp.SystemPrompt = ""
+		}
+	}
 	mp.Rules = UnmarshalRules(rulesJSON)
 	mp.Memos = UnmarshalMemos(memosJSON)
+	mp.Evals = UnmarshalEvals(evalsJSON)
+	mp.Provenance = UnmarshalProvenance(provenanceJSON)
 	mp.Published = published == 1
+	mp.Status = packStatus(mp.Published)
+	mp.Archived = mp.ArchivedAt != ""
+	mp.Pinned = mp.PinnedAt != ""
+	mp.Featured = mp.FeaturedAt != ""
+	mp.CoverURL = coverURL(mp.ID, mp.Cover)
+	mp.RequireAuth = requireAuth == 1
 	return &mp, nil
 }
 
-func ListMemoPacks(q ListQuery) ([]MemoPack, int, error) {
-	where := []string{"published = 1"}
-	args := []any{}
+func (s *SQLiteStore) InsertMemoPack(mp *MemoPack) error {
+	return s.insertMemoPack(s.db, mp)
+}
 
-	if q.Search != "" {
-		where = append(where, "(name LIKE ? OR description LIKE ? OR author_name LIKE ?)")
-		s := "%" + q.Search + "%"
-		args = append(args, s, s, s)
+// InsertMemoPacks inserts packs in one transaction: all of them or none.
+func (s *SQLiteStore) InsertMemoPacks(packs []*MemoPack) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
 	}
-	if q.Author != "" {
-		where = append(where, "author_id = ?")
-		args = append(args, q.Author)
+	defer tx.Rollback()
+	for _, mp := range packs {
+		if err := s.insertMemoPack(tx, mp); err != nil {
+			return fmt.Errorf("pack %q: %w", mp.Name, err)
+		}
 	}
+	return tx.Commit()
+}
 
-	whereClause := strings.Join(where, " AND ")
+func (s *SQLiteStore) insertMemoPack(db dbConn, mp *MemoPack) error {
+	mp.Revision = 1
+	if mp.Channel == "" {
+		mp.Channel = channelStable
+	}
+	if mp.Visibility == "" {
+		mp.Visibility = visibilityPublic
+	}
+	mp.Content = computeContentInfo(mp)
+	mp.Content.ContentUpdatedAt = mp.UpdatedAt
+	bodies, blobs := splitPackBodies(mp)
+	hash, sim := packFingerprint(mp)
+	mp.Checksum = packContentChecksum(mp)
+	_, err := db.Exec(
+		`INSERT INTO memo_packs (id, name, description, author_id, author_name, system_prompt, rules, memos, downloads, published, created_at, updated_at, version, evals, provenance,
+		   rule_count, memo_count, system_prompt_chars, total_chars, content_updated_at, name_skeleton, homepage, repository, contact, require_auth, external_fields, channel,
+		   language, variant_of, embargo_until, forked_from, category, content_hash, content_simhash, checksum, visibility)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		mp.ID, mp.Name, mp.Description, mp.AuthorID, mp.AuthorName,
+		bodies.systemPrompt, bodies.rules, bodies.memos,
+		mp.Downloads, boolToInt(mp.Published), mp.CreatedAt, mp.UpdatedAt,
+		mp.Version, MarshalEvals(mp.Evals), MarshalProvenance(mp.Provenance),
+		mp.Content.RuleCount, mp.Content.MemoCount, mp.Content.SystemPromptChars, mp.Content.TotalChars,
+		mp.Content.ContentUpdatedAt, nameSkeleton(mp.Name), mp.Homepage, mp.Repository, mp.Contact, boolToInt(mp.RequireAuth),
+		bodies.external, mp.Channel, mp.Language, mp.VariantOf, mp.EmbargoUntil, mp.ForkedFrom, mp.Category, hash, sim, mp.Checksum, mp.Visibility,
+	)
+	if err != nil {
+		return err
+	}
+	if err := replacePackBlobs(db, mp.ID, blobs); err != nil {
+		return err
+	}
+	if err := refreshForkCount(db, mp.ID); err != nil {
+		return err
+	}
+	if mp.Slug, err = assignSlug(db, mp.ID, mp.Name); err != nil {
+		return err
+	}
+	return saveMemoPackVersion(db, mp)
+}
 
-	var total int
-	err := db.QueryRow("SELECT COUNT(*) FROM memo_packs WHERE "+whereClause, args...).Scan(&total)
+// errStaleRevision is returned by UpdateMemoPack when the pack changed since
+// it was read.
+var errStaleRevision = errors.New("pack was modified by another update")
+
+// UpdateMemoPack saves mp if its revision is still current, then bumps the
+// revision.
+func (s *SQLiteStore) UpdateMemoPack(mp *MemoPack) error {
+	mp.UpdatedAt = nowISO()
+	info := computeContentInfo(mp)
+	bodies, blobs := splitPackBodies(mp)
+	hash, sim := packFingerprint(mp)
+	checksum := packContentChecksum(mp)
+	// content_updated_at only moves when the prompt, rules or memos actually
+	// change. Externalized bodies are compared by their hash stubs.
+	res, err := s.db.Exec(
+		`UPDATE memo_packs SET
+		   content_updated_at = CASE WHEN system_prompt IS ? AND rules IS ? AND memos IS ? THEN content_updated_at ELSE ? END,
+		   name=?, description=?, system_prompt=?, rules=?, memos=?, published=?, updated_at=?, version=?, evals=?, provenance=?,
+		   rule_count=?, memo_count=?, system_prompt_chars=?, total_chars=?, name_skeleton=?,
+		   homepage=?, repository=?, contact=?, require_auth=?, external_fields=?, channel=?,
+		   language=?, variant_of=?, category=?, content_hash=?, content_simhash=?, checksum=?, visibility=?, revision=revision+1
+		 WHERE id=? AND author_id=? AND revision=?`,
+		bodies.systemPrompt, bodies.rules, bodies.memos, mp.UpdatedAt,
+		mp.Name, mp.Description, bodies.systemPrompt,
+		bodies.rules, bodies.memos, boolToInt(mp.Published), mp.UpdatedAt,
+		mp.Version, MarshalEvals(mp.Evals), MarshalProvenance(mp.Provenance),
+		info.RuleCount, info.MemoCount, info.SystemPromptChars, info.TotalChars, nameSkeleton(mp.Name),
+		mp.Homepage, mp.Repository, mp.Contact, boolToInt(mp.RequireAuth), bodies.external, mp.Channel,
+		mp.Language, mp.VariantOf, mp.Category, hash, sim, checksum, mp.Visibility,
+		mp.ID, mp.AuthorID, mp.Revision,
+	)
 	if err != nil {
-		return nil, 0, err
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errStaleRevision
+	}
+	mp.Revision++
+	mp.Checksum = checksum
+	if err := replacePackBlobs(s.db, mp.ID, blobs); err != nil {
+		return err
+	}
+	if mp.Slug, err = assignSlug(s.db, mp.ID, mp.Name); err != nil {
+		return err
+	}
+	// A change of visibility can add or drop a public fork.
+	if err := refreshForkCount(s.db, mp.ID); err != nil {
+		return err
+	}
+	if err := s.db.QueryRow(`SELECT content_updated_at FROM memo_packs WHERE id=?`, mp.ID).Scan(&info.ContentUpdatedAt); err != nil {
+		return err
+	}
+	mp.Content = info
+	return s.SaveMemoPackVersion(mp)
+}
+
+// computeContentInfo derives the size metadata stored alongside a pack.
+func computeContentInfo(mp *MemoPack) PackContentInfo {
+	info := PackContentInfo{
+		RuleCount:         len(mp.Rules),
+		MemoCount:         len(mp.Memos),
+		SystemPromptChars: utf8.RuneCountInString(mp.SystemPrompt),
+	}
+	info.TotalChars = info.SystemPromptChars
+	for _, rule := range mp.Rules {
+		info.TotalChars += utf8.RuneCountInString(rule.Title) + utf8.RuneCountInString(rule.UpdateRule)
+	}
+	for _, memo := range mp.Memos {
+		info.TotalChars += utf8.RuneCountInString(memo.Title) + utf8.RuneCountInString(memo.Content)
 	}
+	return info
+}
 
-	offset := (q.Page - 1) * q.Limit
-	rows, err := db.Query(
-		"SELECT id, name, description, author_id, author_name, system_prompt, rules, memos, downloads, published, created_at, updated_at FROM memo_packs WHERE "+whereClause+" ORDER BY updated_at DESC LIMIT ? OFFSET ?",
-		append(args, q.Limit, offset)...,
+// DeleteMemoPack soft-deletes a pack: it disappears immediately and is purged
+// by the cleanup job once the deleted-content retention period has passed.
+// updated_at moves too so ETL consumers see the deletion.
+func (s *SQLiteStore) DeleteMemoPack(id, authorID string) error {
+	now := nowISO()
+	_, err := s.db.Exec(`UPDATE memo_packs SET deleted_at=?, updated_at=? WHERE id=? AND author_id=? AND deleted_at = ''`, now, now, id, authorID)
+	if err != nil {
+		return err
+	}
+	return refreshForkCount(s.db, id)
+}
+
+// refreshForkCount recomputes the denormalized fork count of the pack id
+// was forked from, if any. Only published, live forks count.
+func refreshForkCount(db dbConn, id string) error {
+	_, err := db.Exec(
+		`UPDATE memo_packs SET fork_count = (SELECT COUNT(*) FROM memo_packs f WHERE f.forked_from = memo_packs.id AND f.published = 1 AND f.visibility = 'public' AND f.deleted_at = '')
+		 WHERE id = (SELECT forked_from FROM memo_packs WHERE id = ? AND forked_from != '')`, id,
 	)
+	return err
+}
+
+// GetMemoPack loads a pack with its full content, including externalized bodies.
+func (s *SQLiteStore) GetMemoPack(id string) (*MemoPack, error) {
+	mp, err := scanMemoPack(s.db.QueryRow(`SELECT `+memoPackColumns+` FROM memo_packs WHERE id=? AND deleted_at = ''`, id))
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+	if err := s.loadPackBlobs(mp); err != nil {
+		return nil, err
+	}
+	return mp, nil
+}
+
+// PackIDExists reports whether id belongs, or belonged, to a pack. Purged
+// packs still count while their versions or download history remain.
+func (s *SQLiteStore) PackIDExists(id string) bool {
+	var exists bool
+	s.db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM memo_packs WHERE id = ?)
+		     OR EXISTS(SELECT 1 FROM memo_pack_versions WHERE pack_id = ?)
+		     OR EXISTS(SELECT 1 FROM download_events WHERE pack_id = ?)`, id, id, id,
+	).Scan(&exists)
+	return exists
+}
+
+// PackRowExists reports whether id's pack is stored, soft-deleted or not.
+// Unlike PackIDExists, purged packs don't count.
+func (s *SQLiteStore) PackRowExists(id string) bool {
+	var exists bool
+	s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM memo_packs WHERE id = ?)`, id).Scan(&exists)
+	return exists
+}
+
+// SetPackCover sets the cover file of a pack, or clears it when cover is
+// empty.
+func (s *SQLiteStore) SetPackCover(id, cover string) error {
+	_, err := s.db.Exec(`UPDATE memo_packs SET cover = ? WHERE id = ?`, cover, id)
+	return err
+}
+
+// SetPackArchived archives a pack as of at, or unarchives it when at is
+// empty.
+func (s *SQLiteStore) SetPackArchived(id, at string) error {
+	_, err := s.db.Exec(`UPDATE memo_packs SET archived_at = ? WHERE id = ?`, at, id)
+	return err
+}
+
+// PinMemoPack pins a pack to its author's profile, reporting false when
+// the author already has limit packs pinned.
+func (s *SQLiteStore) PinMemoPack(id, authorID string, limit int) (bool, error) {
+	n, err := s.execCount(
+		`UPDATE memo_packs SET pinned_at = ? WHERE id = ?
+		   AND (SELECT COUNT(*) FROM memo_packs WHERE author_id = ? AND pinned_at != '' AND deleted_at = '') < ?`,
+		nowISO(), id, authorID, limit,
+	)
+	return n > 0, err
+}
+
+// UnpinMemoPack unpins a pack.
+func (s *SQLiteStore) UnpinMemoPack(id string) error {
+	_, err := s.db.Exec(`UPDATE memo_packs SET pinned_at = '' WHERE id = ?`, id)
+	return err
+}
+
+// SetPackFeatured features a pack as of at, or unfeatures it when at is
+// empty.
+func (s *SQLiteStore) SetPackFeatured(id, at string) error {
+	_, err := s.db.Exec(`UPDATE memo_packs SET featured_at = ? WHERE id = ?`, at, id)
+	return err
+}
+
+// ListFeaturedPacks returns the featured packs that aren't deleted, the most
+// recently featured first.
+func (s *SQLiteStore) ListFeaturedPacks() ([]MemoPack, error) {
+	rows, err := s.db.Query(`SELECT ` + memoPackColumns + ` FROM memo_packs WHERE featured_at != '' AND deleted_at = '' ORDER BY featured_at DESC, id`)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
+	packs := []MemoPack{}
+	for rows.Next() {
+		mp, err := scanMemoPack(rows)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, *mp)
+	}
+	return packs, rows.Err()
+}
 
-	var packs []MemoPack
+// PublishMemoPack publishes a draft, reporting false when the pack isn't
+// one.
+func (s *SQLiteStore) PublishMemoPack(id string) (bool, error) {
+	n, err := s.execCount(`UPDATE memo_packs SET published = 1, updated_at = ? WHERE id = ? AND published = 0 AND deleted_at = ''`, nowISO(), id)
+	if err != nil || n == 0 {
+		return false, err
+	}
+	return true, refreshForkCount(s.db, id)
+}
+
+// ---- Slug DB operations ----
+
+// ResolvePackSlug returns the pack a current or former slug points at, and
+// its current slug. A pack whose ID is slug takes precedence, so it isn't
+// resolved.
+func (s *SQLiteStore) ResolvePackSlug(slug string) (id, current string, err error) {
+	err = s.db.QueryRow(
+		`SELECT p.id, p.slug FROM pack_slugs ps JOIN memo_packs p ON p.id = ps.pack_id
+		 WHERE ps.slug = ? AND p.deleted_at = '' AND NOT EXISTS (SELECT 1 FROM memo_packs WHERE id = ?)`, slug, slug,
+	).Scan(&id, &current)
+	return id, current, err
+}
+
+// assignSlug gives pack id a slug for name, keeping its current one while
+// that still fits, reusing one of its former ones, or claiming the first
+// free base, base-2, base-3, .... It returns the pack's slug.
+func assignSlug(db dbConn, id, name string) (string, error) {
+	base := slugify(name)
+	var current string
+	db.QueryRow(`SELECT slug FROM memo_packs WHERE id = ?`, id).Scan(&current)
+	if current == base || strings.HasPrefix(current, base+"-") && isDigits(current[len(base)+1:]) {
+		return current, nil
+	}
+	slug := ""
+	for n := 1; slug == ""; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, n)
+		}
+		if reservedSlugs[candidate] {
+			continue
+		}
+		var owner string
+		err := db.QueryRow(`SELECT pack_id FROM pack_slugs WHERE slug = ?`, candidate).Scan(&owner)
+		switch {
+		case err == nil && owner == id:
+			slug = candidate
+		case err == sql.ErrNoRows:
+			var taken bool
+			db.QueryRow(`SELECT EXISTS(SELECT 1 FROM memo_packs WHERE id = ?)`, candidate).Scan(&taken)
+			if taken {
+				continue
+			}
+			if _, err := db.Exec(`INSERT OR IGNORE INTO pack_slugs (slug, pack_id, created_at) VALUES (?, ?, ?)`, candidate, id, nowISO()); err != nil {
+				return "", err
+			}
+			// Another writer may have claimed it first; check again.
+			if db.QueryRow(`SELECT pack_id FROM pack_slugs WHERE slug = ?`, candidate).Scan(&owner) == nil && owner == id {
+				slug = candidate
+			}
+		case err != nil:
+			return "", err
+		}
+	}
+	_, err := db.Exec(`UPDATE memo_packs SET slug = ? WHERE id = ?`, slug, id)
+	return slug, err
+}
+
+// backfillSlugs gives packs stored before slugs existed one, oldest first so
+// they get the plain slugs.
+func (s *SQLiteStore) backfillSlugs() {
+	rows, err := s.db.Query(`SELECT id, name FROM memo_packs WHERE slug = '' ORDER BY created_at, id`)
+	if err != nil {
+		log.Fatalf("Failed to backfill slugs: %v", err)
+	}
+	var ids, names []string
 	for rows.Next() {
-		var mp MemoPack
-		var rulesJSON, memosJSON string
-		var published int
-		rows.Scan(&mp.ID, &mp.Name, &mp.Description, &mp.AuthorID, &mp.AuthorName,
-			&mp.SystemPrompt, &rulesJSON, &memosJSON, &mp.Downloads, &published, &mp.CreatedAt, &mp.UpdatedAt)
-		mp.Rules = UnmarshalRules(rulesJSON)
-		mp.Memos = UnmarshalMemos(memosJSON)
-		mp.Published = published == 1
-		packs = append(packs, mp)
+		var id, name string
+		if rows.Scan(&id, &name) == nil {
+			ids, names = append(ids, id), append(names, name)
+		}
 	}
-	if packs == nil {
-		packs = []MemoPack{}
+	rows.Close()
+	for i, id := range ids {
+		if _, err := assignSlug(s.db, id, names[i]); err != nil {
+			log.Fatalf("Failed to backfill slugs: %v", err)
+		}
 	}
-	return packs, total, nil
 }
 
-func IncrementMemoPackDownloads(id string) error {
-	_, err := db.Exec(`UPDATE memo_packs SET downloads = downloads + 1 WHERE id = ?`, id)
+// ---- Attachment DB operations ----
+
+const attachmentColumns = `id, pack_id, filename, content_type, size, sha256, uploader_id, created_at`
+
+func scanAttachment(row interface{ Scan(...any) error }) (*Attachment, error) {
+	a := &Attachment{}
+	if err := row.Scan(&a.ID, &a.PackID, &a.Filename, &a.ContentType, &a.Size, &a.SHA256, &a.UploaderID, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *SQLiteStore) queryAttachments(query string, args ...any) ([]Attachment, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQLiteStore) InsertAttachment(a *Attachment) error {
+	_, err := s.db.Exec(`INSERT INTO attachments (`+attachmentColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.ID, a.PackID, a.Filename, a.ContentType, a.Size, a.SHA256, a.UploaderID, a.CreatedAt)
+	return err
+}
+
+func (s *SQLiteStore) GetAttachment(id string) (*Attachment, error) {
+	return scanAttachment(s.db.QueryRow(`SELECT `+attachmentColumns+` FROM attachments WHERE id = ?`, id))
+}
+
+// ListAttachments returns a pack's attachments, oldest first.
+func (s *SQLiteStore) ListAttachments(packID string) ([]Attachment, error) {
+	return s.queryAttachments(`SELECT `+attachmentColumns+` FROM attachments WHERE pack_id = ? ORDER BY created_at, id`, packID)
+}
+
+func (s *SQLiteStore) DeleteAttachment(id string) error {
+	_, err := s.db.Exec(`DELETE FROM attachments WHERE id = ?`, id)
+	return err
+}
+
+// ListOrphanAttachments returns the attachments of purged packs.
+func (s *SQLiteStore) ListOrphanAttachments() ([]Attachment, error) {
+	return s.queryAttachments(`SELECT ` + attachmentColumns + ` FROM attachments WHERE pack_id NOT IN (SELECT id FROM memo_packs)`)
+}
+
+// ---- Pack blob DB operations ----
+
+// packBlobThreshold is the size in bytes above which a system prompt, rule
+// list or memo list is stored in pack_blobs instead of the memo_packs row,
+// keeping list queries small. Listings omit externalized bodies.
+var packBlobThreshold = 64 * 1024
+
+type packBodies struct {
+	systemPrompt, rules, memos string
+	external                   string
+}
+
+// splitPackBodies returns the column values for a pack's bodies. Bodies over
+// the threshold are replaced by a hash stub and returned in blobs.
+func splitPackBodies(mp *MemoPack) (packBodies, map[string]string) {
+	b := packBodies{systemPrompt: mp.SystemPrompt, rules: MarshalRules(mp.Rules), memos: MarshalMemos(mp.Memos)}
+	blobs := map[string]string{}
+	var external []string
+	for _, f := range []struct {
+		name string
+		val  *string
+	}{
+		{"system_prompt", &b.systemPrompt},
+		{"rules", &b.rules},
+		{"memos", &b.memos},
+	} {
+		if len(*f.val) <= packBlobThreshold {
+			continue
+		}
+		blobs[f.name] = *f.val
+		sum := sha256.Sum256([]byte(*f.val))
+		*f.val = "sha256:" + hex.EncodeToString(sum[:])
+		external = append(external, f.name)
+	}
+	b.external = strings.Join(external, ",")
+	return b, blobs
+}
+
+// savePackBodies rewrites only the body columns and blobs of a pack.
+func (s *SQLiteStore) savePackBodies(mp *MemoPack) error {
+	bodies, blobs := splitPackBodies(mp)
+	_, err := s.db.Exec(
+		`UPDATE memo_packs SET system_prompt=?, rules=?, memos=?, external_fields=? WHERE id=?`,
+		bodies.systemPrompt, bodies.rules, bodies.memos, bodies.external, mp.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return replacePackBlobs(s.db, mp.ID, blobs)
+}
+
+func replacePackBlobs(db dbConn, packID string, blobs map[string]string) error {
+	if _, err := db.Exec(`DELETE FROM pack_blobs WHERE pack_id=?`, packID); err != nil {
+		return err
+	}
+	for field, data := range blobs {
+		if _, err := db.Exec(`INSERT INTO pack_blobs (pack_id, field, data) VALUES (?, ?, ?)`, packID, field, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPackBlobs fills in the bodies of a pack that were stored externally.
+func (s *SQLiteStore) loadPackBlobs(mp *MemoPack) error {
+	if len(mp.externalFields) == 0 {
+		return nil
+	}
+	rows, err := s.db.Query(`SELECT field, data FROM pack_blobs WHERE pack_id=?`, mp.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var field, data string
+		if err := rows.Scan(&field, &data); err != nil {
+			return err
+		}
+		switch field {
+		case "system_prompt":
+			mp.SystemPrompt = data
+		case "rules":
+			mp.Rules = UnmarshalRules(data)
+		case "memos":
+			mp.Memos = UnmarshalMemos(data)
+		}
+	}
+	mp.externalFields = nil
+	mp.ContentOmitted = false
+	return rows.Err()
+}
+
+func (s *SQLiteStore) ListMemoPacks(q ListQuery) ([]MemoPack, int, error) {
+	now := nowISO()
+	where := []string{"published = 1", "deleted_at = ''", "embargo_until <= ?",
+		"author_id NOT IN (SELECT id FROM users WHERE active = 0 OR " + activeBanSQL + ")"}
+	args := []any{now, now}
+	if q.Author != "" && q.Viewer != "" {
+		where = append(where, "("+listedSQL+" OR author_id = ? OR id IN (SELECT pack_id FROM pack_collaborators WHERE user_id = ?))")
+		args = append(args, q.Viewer, q.Viewer)
+	} else {
+		where = append(where, listedSQL)
+	}
+
+	// Search matches the full-text index or, for fragments the tokenizer
+	// can't see, a substring of the name.
+	var ftsQuery string
+	if q.Search != "" {
+		ftsQuery = ftsMatchQuery(q.Search)
+		if ftsQuery != "" {
+			where = append(where, "(rowid IN (SELECT docid FROM memo_packs_fts WHERE memo_packs_fts MATCH ?) OR name LIKE ?)")
+			args = append(args, ftsQuery, "%"+q.Search+"%")
+		} else {
+			where = append(where, "(name LIKE ? OR description LIKE ? OR author_name LIKE ?)")
+			s := "%" + q.Search + "%"
+			args = append(args, s, s, s)
+		}
+	}
+	if q.Author != "" {
+		where = append(where, "author_id = ?")
+		args = append(args, q.Author)
+	}
+	if q.Category != "" {
+		where = append(where, "category = ?")
+		args = append(args, q.Category)
+	}
+	if q.Pinned {
+		where = append(where, "pinned_at != ''")
+	}
+	if q.Featured {
+		where = append(where, "featured_at != ''")
+	}
+	if q.Archived != nil {
+		if *q.Archived {
+			where = append(where, "archived_at != ''")
+		} else {
+			where = append(where, "archived_at = ''")
+		}
+	}
+	for _, f := range []struct {
+		cond string
+		val  *int
+	}{
+		{"rule_count >= ?", q.MinRules},
+		{"rule_count <= ?", q.MaxRules},
+		{"memo_count >= ?", q.MinMemos},
+		{"memo_count <= ?", q.MaxMemos},
+		{"total_chars <= ?", q.MaxChars},
+	} {
+		if f.val != nil {
+			where = append(where, f.cond)
+			args = append(args, *f.val)
+		}
+	}
+
+	// Outside an author's own listing, variants of one pack are collapsed
+	// to the best match for the caller's languages.
+	if q.Author == "" {
+		cond, condArgs := variantGroupFilter(q.Languages)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+	langRank, langArgs := languageRankSQL("memo_packs", q.Languages)
+	langOrder := ""
+	if len(q.Languages) > 0 {
+		langOrder = langRank + ", "
+	}
+	// An author's listing starts with their pinned packs, the most recently
+	// pinned first.
+	if q.Author != "" {
+		langOrder = "pinned_at DESC, " + langOrder
+	}
+
+	whereClause := strings.Join(where, " AND ")
+
+	var total int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM memo_packs WHERE "+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset := (q.Page - 1) * q.Limit
+	// Searches without an explicit sort are ranked by relevance.
+	ranked := q.Search != "" && (q.Sort == "" || q.Sort == "relevance")
+	var rows *sql.Rows
+	if ranked {
+		rows, err = s.db.Query(
+			"SELECT "+memoPackColumns+", "+relevanceScore+" AS score FROM memo_packs"+
+				" LEFT JOIN (SELECT docid, fts_rank(matchinfo(memo_packs_fts, 'pcx')) AS fts FROM memo_packs_fts WHERE memo_packs_fts MATCH ?) f ON f.docid = memo_packs.rowid"+
+				" WHERE "+whereClause+" ORDER BY "+langOrder+"score DESC, downloads DESC, id LIMIT ? OFFSET ?",
+			append(append(append([]any{q.Search, q.Search + "%", ftsQuery}, args...), langArgs...), q.Limit, offset)...,
+		)
+	} else {
+		rows, err = s.db.Query(
+			"SELECT "+memoPackColumns+", 0 AS score FROM memo_packs WHERE "+whereClause+" ORDER BY "+langOrder+listOrderBy(q.Sort)+" LIMIT ? OFFSET ?",
+			append(append(slices.Clone(args), langArgs...), q.Limit, offset)...,
+		)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var packs []MemoPack
+	for rows.Next() {
+		var score float64
+		mp, err := scanMemoPack(scoreScanner{rows, &score})
+		if err != nil {
+			continue
+		}
+		if ranked && q.DebugScore {
+			mp.Score = &score
+		}
+		packs = append(packs, *mp)
+	}
+	if packs == nil {
+		packs = []MemoPack{}
+	}
+	return packs, total, nil
+}
+
+// HasPackVariants reports whether any live pack names id as its original.
+func (s *SQLiteStore) HasPackVariants(id string) bool {
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM memo_packs WHERE variant_of = ? AND deleted_at = ''`, id).Scan(&n)
+	return n > 0
+}
+
+// ListPackVariants returns the published packs in the variant group rooted
+// at origID, the original included.
+func (s *SQLiteStore) ListPackVariants(origID string) ([]PackVariant, error) {
+	rows, err := s.db.Query(
+		`SELECT id, name, language FROM memo_packs WHERE (id = ? OR variant_of = ?) AND published = 1 AND `+listedSQL+` AND deleted_at = '' AND embargo_until <= ?
+		 ORDER BY variant_of != '', language, id`, origID, origID, nowISO(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var variants []PackVariant
+	for rows.Next() {
+		var v PackVariant
+		if err := rows.Scan(&v.ID, &v.Name, &v.Language); err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+// FindPopularPackBySkeleton returns the name of another author's published pack
+// with at least minDownloads whose folded name equals skeleton.
+func (s *SQLiteStore) FindPopularPackBySkeleton(skeleton, authorID string, minDownloads int) (string, error) {
+	var name string
+	err := s.db.QueryRow(
+		`SELECT name FROM memo_packs WHERE name_skeleton = ? AND author_id != ? AND published = 1 AND deleted_at = '' AND downloads >= ?
+		 ORDER BY downloads DESC LIMIT 1`, skeleton, authorID, minDownloads,
+	).Scan(&name)
+	return name, err
+}
+
+// FindDuplicatePack returns the pack, public or authorID's own, whose
+// content hash is hash, or else the one whose simhash is closest to sim
+// within maxDistance bits. It returns nil without a match.
+func (s *SQLiteStore) FindDuplicatePack(hash string, sim int64, maxDistance int, authorID, excludeID string) (*DuplicateMatch, error) {
+	const visible = `deleted_at = '' AND id != ? AND (author_id = ? OR (published = 1 AND ` + listedSQL + ` AND embargo_until <= ?))`
+	m := DuplicateMatch{Exact: true}
+	err := s.db.QueryRow(`SELECT id, name, author_name FROM memo_packs WHERE content_hash = ? AND `+visible+` ORDER BY created_at LIMIT 1`,
+		hash, excludeID, authorID, nowISO()).Scan(&m.ID, &m.Name, &m.AuthorName)
+	if err == nil {
+		return &m, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+	if sim == 0 || maxDistance == 0 {
+		return nil, nil
+	}
+	rows, err := s.db.Query(`SELECT id, name, author_name, content_simhash FROM memo_packs WHERE content_simhash != 0 AND `+visible,
+		excludeID, authorID, nowISO())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var best *DuplicateMatch
+	bestDistance := maxDistance + 1
+	for rows.Next() {
+		var c DuplicateMatch
+		var other int64
+		if err := rows.Scan(&c.ID, &c.Name, &c.AuthorName, &other); err != nil {
+			return nil, err
+		}
+		if d := simhashDistance(sim, other); d < bestDistance {
+			best, bestDistance = &c, d
+		}
+	}
+	return best, rows.Err()
+}
+
+// ListRelatedCandidates returns the packs anyone can find in listings,
+// other than id, to pick related packs from.
+func (s *SQLiteStore) ListRelatedCandidates(id string) ([]MemoPack, error) {
+	now := nowISO()
+	rows, err := s.db.Query(
+		`SELECT `+memoPackColumns+` FROM memo_packs
+		 WHERE id != ? AND published = 1 AND deleted_at = '' AND embargo_until <= ? AND `+listedSQL+`
+		   AND author_id NOT IN (SELECT id FROM users WHERE active = 0 OR `+activeBanSQL+`)`,
+		id, now, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var packs []MemoPack
+	for rows.Next() {
+		mp, err := scanMemoPack(rows)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, *mp)
+	}
+	return packs, rows.Err()
+}
+
+// CoDownloadCounts counts, for each other pack, the clients that downloaded
+// both it and packID.
+func (s *SQLiteStore) CoDownloadCounts(packID string) (map[string]int, error) {
+	rows, err := s.db.Query(
+		`SELECT b.pack_id, COUNT(DISTINCT b.client_id) FROM download_events a
+		 JOIN download_events b ON b.client_id = a.client_id AND b.pack_id != a.pack_id
+		 WHERE a.pack_id = ? GROUP BY b.pack_id`, packID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var id string
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		counts[id] = n
+	}
+	return counts, rows.Err()
+}
+
+// listSortColumns maps ?sort= values to ORDER BY clauses; unknown values use "updated".
+var listSortColumns = map[string]string{
+	"updated":         "updated_at DESC",
+	"created":         "created_at DESC",
+	"downloads":       "downloads DESC",
+	"name":            "name COLLATE NOCASE ASC",
+	"rules":           "rule_count DESC",
+	"memos":           "memo_count DESC",
+	"size":            "total_chars DESC",
+	"content_updated": "content_updated_at DESC",
+	"rating":          "rating_avg DESC, rating_count DESC",
+	"stars":           "star_count DESC",
+	"featured":        "featured_at DESC",
+}
+
+func listOrderBy(sort string) string {
+	order, ok := listSortColumns[sort]
+	if !ok {
+		order = listSortColumns["updated"]
+	}
+	return order + ", id"
+}
+
+// IncrementMemoPackDownloads counts a download of version of a pack.
+func (s *SQLiteStore) IncrementMemoPackDownloads(id, version string) error {
+	if _, err := s.db.Exec(`UPDATE memo_packs SET downloads = downloads + 1 WHERE id = ?`, id); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`UPDATE memo_pack_versions SET downloads = downloads + 1 WHERE pack_id = ? AND version = ?`, id, version)
+	return err
+}
+
+// ---- Download metrics DB operations ----
+
+// RecordDownloadEvent logs a download; userID is empty for anonymous downloads.
+func (s *SQLiteStore) RecordDownloadEvent(packID, version, clientID, userID string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO download_events (pack_id, version, client_id, user_id, created_at) VALUES (?, ?, ?, ?, ?)`,
+		packID, version, clientID, userID, nowISO(),
+	)
+	return err
+}
+
+// RecordInstallEvent logs a completed install reported by a client. A client
+// reporting the same version of a pack again is only counted once; it returns
+// false for such repeats.
+func (s *SQLiteStore) RecordInstallEvent(ev *InstallEvent) (bool, error) {
+	res, err := s.db.Exec(
+		`INSERT INTO install_events (pack_id, version, client_id, client, client_version, created_at)
+		 SELECT ?, ?, ?, ?, ?, ?
+		 WHERE ? = '' OR NOT EXISTS (SELECT 1 FROM install_events WHERE pack_id=? AND version=? AND client_id=?)`,
+		ev.PackID, ev.Version, ev.ClientID, ev.Client, ev.ClientVersion, nowISO(),
+		ev.ClientID, ev.PackID, ev.Version, ev.ClientID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// CountClientInstalls counts the installs of a pack reported by client.
+func (s *SQLiteStore) CountClientInstalls(packID, client string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM install_events WHERE pack_id=? AND client=?`, packID, client).Scan(&n)
+	return n, err
+}
+
+// GetPackStats aggregates anonymous download and install events for a pack.
+// Returning clients are those that downloaded on more than one day.
+func (s *SQLiteStore) GetPackStats(packID string) (*PackStats, error) {
+	stats := PackStats{PackID: packID}
+	err := s.db.QueryRow(
+		`SELECT COUNT(*), COUNT(DISTINCT client_id) FROM download_events WHERE pack_id = ?`, packID,
+	).Scan(&stats.TrackedDownloads, &stats.UniqueDownloads)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.QueryRow(
+		`SELECT COUNT(*) FROM (
+			SELECT client_id FROM download_events WHERE pack_id = ?
+			GROUP BY client_id HAVING COUNT(DISTINCT substr(created_at, 1, 10)) > 1
+		)`, packID,
+	).Scan(&stats.ReturningClients)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.QueryRow(
+		`SELECT COUNT(*), COUNT(DISTINCT NULLIF(client_id, '')) FROM install_events WHERE pack_id = ?`, packID,
+	).Scan(&stats.Installs, &stats.UniqueInstalls)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(
+		`SELECT client, COUNT(*) FROM install_events WHERE pack_id = ? AND client != '' GROUP BY client`, packID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats.InstallsByClient = map[string]int{}
+	for rows.Next() {
+		var client string
+		var n int
+		if err := rows.Scan(&client, &n); err != nil {
+			return nil, err
+		}
+		stats.InstallsByClient[client] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	vrows, err := s.db.Query(`SELECT version, downloads FROM memo_pack_versions WHERE pack_id = ?`, packID)
+	if err != nil {
+		return nil, err
+	}
+	defer vrows.Close()
+	stats.DownloadsByVersion = map[string]int{}
+	for vrows.Next() {
+		var version string
+		var n int
+		if err := vrows.Scan(&version, &n); err != nil {
+			return nil, err
+		}
+		stats.DownloadsByVersion[version] = n
+	}
+	return &stats, vrows.Err()
+}
+
+// ---- Review and star DB operations ----
+
+// SaveReview creates or replaces a user's review and refreshes the pack's
+// rating aggregate in the same transaction.
+func (s *SQLiteStore) SaveReview(rv *Review) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(
+		`INSERT INTO reviews (pack_id, user_id, username, rating, body, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(pack_id, user_id) DO UPDATE SET username=excluded.username, rating=excluded.rating,
+		   body=excluded.body, updated_at=excluded.updated_at`,
+		rv.PackID, rv.UserID, rv.Username, rv.Rating, rv.Body, rv.CreatedAt, rv.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if err := refreshRatings(tx, rv.PackID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) DeleteReview(packID, userID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM reviews WHERE pack_id=? AND user_id=?`, packID, userID); err != nil {
+		return err
+	}
+	if err := refreshRatings(tx, packID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) ListReviews(packID string, limit, offset int) ([]Review, int, error) {
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM reviews WHERE pack_id=?`, packID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(
+		`SELECT pack_id, user_id, username, rating, body, created_at, updated_at
+		 FROM reviews WHERE pack_id=? ORDER BY updated_at DESC LIMIT ? OFFSET ?`, packID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reviews := []Review{}
+	for rows.Next() {
+		var rv Review
+		if err := rows.Scan(&rv.PackID, &rv.UserID, &rv.Username, &rv.Rating, &rv.Body, &rv.CreatedAt, &rv.UpdatedAt); err != nil {
+			continue
+		}
+		reviews = append(reviews, rv)
+	}
+	return reviews, total, nil
+}
+
+// SetStar stars or unstars a pack for a user and refreshes the pack's star count.
+func (s *SQLiteStore) SetStar(packID, userID string, starred bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if starred {
+		_, err = tx.Exec(`INSERT OR IGNORE INTO stars (pack_id, user_id, created_at) VALUES (?, ?, ?)`, packID, userID, nowISO())
+	} else {
+		_, err = tx.Exec(`DELETE FROM stars WHERE pack_id=? AND user_id=?`, packID, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if err := refreshRatings(tx, packID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// refreshRatings recomputes the denormalized rating and star columns of a pack.
+func refreshRatings(tx *sql.Tx, packID string) error {
+	_, err := tx.Exec(
+		`UPDATE memo_packs SET
+		   rating_avg = COALESCE((SELECT ROUND(AVG(rating), 2) FROM reviews WHERE pack_id = ?), 0),
+		   rating_count = (SELECT COUNT(*) FROM reviews WHERE pack_id = ?),
+		   star_count = (SELECT COUNT(*) FROM stars WHERE pack_id = ?)
+		 WHERE id = ?`, packID, packID, packID, packID,
+	)
+	return err
+}
+
+// ---- Version history DB operations ----
+
+// SaveMemoPackVersion snapshots the pack's content under its current version.
+// Saving again without bumping the version overwrites that snapshot.
+func (s *SQLiteStore) SaveMemoPackVersion(mp *MemoPack) error {
+	return saveMemoPackVersion(s.db, mp)
+}
+
+func saveMemoPackVersion(db dbConn, mp *MemoPack) error {
+	_, err := db.Exec(
+		`INSERT INTO memo_pack_versions (pack_id, version, name, description, system_prompt, rules, memos, created_at, updated_at, channel)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(pack_id, version) DO UPDATE SET name=excluded.name, description=excluded.description,
+		   system_prompt=excluded.system_prompt, rules=excluded.rules, memos=excluded.memos, updated_at=excluded.updated_at,
+		   channel=excluded.channel`,
+		mp.ID, mp.Version, mp.Name, mp.Description, mp.SystemPrompt,
+		MarshalRules(mp.Rules), MarshalMemos(mp.Memos), mp.UpdatedAt, mp.UpdatedAt, mp.Channel,
+	)
+	return err
+}
+
+const memoPackVersionColumns = `pack_id, version, name, description, system_prompt, rules, memos, created_at, updated_at, channel, downloads`
+
+func scanMemoPackVersion(row rowScanner) (*MemoPackVersion, error) {
+	var v MemoPackVersion
+	var rulesJSON, memosJSON string
+	err := row.Scan(&v.PackID, &v.Version, &v.Name, &v.Description, &v.SystemPrompt,
+		&rulesJSON, &memosJSON, &v.CreatedAt, &v.UpdatedAt, &v.Channel, &v.Downloads)
+	if err != nil {
+		return nil, err
+	}
+	v.Rules = UnmarshalRules(rulesJSON)
+	v.Memos = UnmarshalMemos(memosJSON)
+	v.Checksum = packContentChecksum(&MemoPack{Name: v.Name, Description: v.Description, SystemPrompt: v.SystemPrompt, Rules: v.Rules, Memos: v.Memos})
+	return &v, nil
+}
+
+func (s *SQLiteStore) GetMemoPackVersion(packID, version string) (*MemoPackVersion, error) {
+	return scanMemoPackVersion(s.db.QueryRow(
+		`SELECT `+memoPackVersionColumns+` FROM memo_pack_versions WHERE pack_id=? AND version=?`, packID, version,
+	))
+}
+
+// VersionAsOf returns the newest version of a pack published at or before
+// at. Content edited in place since then is returned as it is now.
+func (s *SQLiteStore) VersionAsOf(packID, at string) (*MemoPackVersion, error) {
+	return scanMemoPackVersion(s.db.QueryRow(
+		`SELECT `+memoPackVersionColumns+` FROM memo_pack_versions WHERE pack_id=? AND created_at <= ?
+		 ORDER BY created_at DESC LIMIT 1`, packID, at,
+	))
+}
+
+func (s *SQLiteStore) ListMemoPackVersions(packID string) ([]MemoPackVersion, error) {
+	rows, err := s.db.Query(`SELECT `+memoPackVersionColumns+` FROM memo_pack_versions WHERE pack_id=?`, packID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []MemoPackVersion{}
+	for rows.Next() {
+		v, err := scanMemoPackVersion(rows)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, *v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i].Version, versions[j].Version) > 0
+	})
+	return versions, nil
+}
+
+// LatestStableVersion returns the highest version of a pack published to or
+// promoted to the stable channel.
+func (s *SQLiteStore) LatestStableVersion(packID string) (*MemoPackVersion, error) {
+	versions, err := s.ListMemoPackVersions(packID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i].Channel == channelStable {
+			return &versions[i], nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// PromoteMemoPackVersion moves a version to the stable channel, and the
+// pack itself when that version is its newest.
+func (s *SQLiteStore) PromoteMemoPackVersion(packID, version string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := nowISO()
+	if _, err := tx.Exec(`UPDATE memo_pack_versions SET channel='stable', updated_at=? WHERE pack_id=? AND version=?`, now, packID, version); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`UPDATE memo_packs SET channel='stable', updated_at=?, revision=revision+1 WHERE id=? AND version=? AND channel != 'stable'`,
+		now, packID, version,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ---- Eval run DB operations ----
+
+func (s *SQLiteStore) InsertEvalRun(run *EvalRun) error {
+	_, err := s.db.Exec(
+		`INSERT INTO eval_runs (id, pack_id, pack_version, model, status, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		run.ID, run.PackID, run.PackVersion, run.Model, run.Status, run.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) FinishEvalRun(run *EvalRun) error {
+	results, _ := json.Marshal(run.Results)
+	_, err := s.db.Exec(
+		`UPDATE eval_runs SET status=?, passed=?, failed=?, results=?, error=?, finished_at=? WHERE id=?`,
+		run.Status, run.Passed, run.Failed, string(results), run.Error, run.FinishedAt, run.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) ListEvalRuns(packID string) ([]EvalRun, error) {
+	rows, err := s.db.Query(
+		`SELECT id, pack_id, pack_version, model, status, passed, failed, results, error, created_at, finished_at
+		 FROM eval_runs WHERE pack_id = ? ORDER BY created_at DESC LIMIT 50`, packID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []EvalRun{}
+	for rows.Next() {
+		var run EvalRun
+		var results string
+		if err := rows.Scan(&run.ID, &run.PackID, &run.PackVersion, &run.Model, &run.Status,
+			&run.Passed, &run.Failed, &results, &run.Error, &run.CreatedAt, &run.FinishedAt); err != nil {
+			continue
+		}
+		json.Unmarshal([]byte(results), &run.Results)
+		if run.Results == nil {
+			run.Results = []EvalResult{}
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// ---- Subscription DB operations ----
+
+func (s *SQLiteStore) Subscribe(userID, packID, version, channel string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO subscriptions (user_id, pack_id, last_version, channel, created_at) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(user_id, pack_id) DO UPDATE SET last_version = excluded.last_version, channel = excluded.channel`,
+		userID, packID, version, channel, nowISO(),
+	)
+	return err
+}
+
+func (s *SQLiteStore) Unsubscribe(userID, packID string) error {
+	_, err := s.db.Exec(`DELETE FROM subscriptions WHERE user_id=? AND pack_id=?`, userID, packID)
+	return err
+}
+
+// MarkSubscriptionDownloaded records the version a subscriber last pulled.
+// It is a no-op for users not subscribed to the pack.
+func (s *SQLiteStore) MarkSubscriptionDownloaded(userID, packID, version string) error {
+	_, err := s.db.Exec(`UPDATE subscriptions SET last_version=? WHERE user_id=? AND pack_id=?`, version, userID, packID)
+	return err
+}
+
+// ListSubscriberIDs returns the users following a pack on channel. Beta
+// subscribers also hear about stable releases.
+func (s *SQLiteStore) ListSubscriberIDs(packID, channel string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT user_id FROM subscriptions WHERE pack_id=? AND (channel=? OR ?='stable')`, packID, channel, channel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *SQLiteStore) ListPackUpdates(userID string) ([]PackUpdate, error) {
+	rows, err := s.db.Query(
+		`SELECT p.id, p.name, s.last_version, p.version, p.updated_at, s.channel, p.channel
+		 FROM subscriptions s JOIN memo_packs p ON p.id = s.pack_id
+		 WHERE s.user_id = ? AND p.deleted_at = '' ORDER BY p.updated_at DESC`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var all []PackUpdate
+	var onBeta []bool
+	for rows.Next() {
+		var u PackUpdate
+		var packChannel string
+		if err := rows.Scan(&u.PackID, &u.Name, &u.InstalledVersion, &u.LatestVersion, &u.UpdatedAt, &u.Channel, &packChannel); err != nil {
+			continue
+		}
+		all = append(all, u)
+		onBeta = append(onBeta, packChannel == channelBeta)
+	}
+	rows.Close()
+
+	updates := []PackUpdate{}
+	for i, u := range all {
+		// Stable subscribers are offered the newest stable version.
+		if onBeta[i] && u.Channel == channelStable {
+			v, err := s.LatestStableVersion(u.PackID)
+			if err != nil {
+				continue
+			}
+			u.LatestVersion = v.Version
+		}
+		if u.InstalledVersion == "" || compareVersions(u.LatestVersion, u.InstalledVersion) > 0 {
+			updates = append(updates, u)
+		}
+	}
+	return updates, nil
+}
+
+// ---- Webhook DB operations ----
+
+const webhookColumns = `id, owner_id, pack_id, url, kind, events, secret, created_at`
+
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	var wh Webhook
+	var events string
+	if err := row.Scan(&wh.ID, &wh.OwnerID, &wh.PackID, &wh.URL, &wh.Kind, &events, &wh.Secret, &wh.CreatedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(events), &wh.Events)
+	return &wh, nil
+}
+
+func (s *SQLiteStore) queryWebhooks(query string, args ...any) ([]Webhook, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hooks := []Webhook{}
+	for rows.Next() {
+		if wh, err := scanWebhook(rows); err == nil {
+			hooks = append(hooks, *wh)
+		}
+	}
+	return hooks, nil
+}
+
+func (s *SQLiteStore) InsertWebhook(wh *Webhook) error {
+	events, _ := json.Marshal(wh.Events)
+	_, err := s.db.Exec(
+		`INSERT INTO webhooks (id, owner_id, pack_id, url, kind, events, secret, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		wh.ID, wh.OwnerID, wh.PackID, wh.URL, wh.Kind, string(events), wh.Secret, wh.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetWebhook(id string) (*Webhook, error) {
+	return scanWebhook(s.db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id=?`, id))
+}
+
+// ListUserWebhooks returns an author's account-wide webhooks (not per-pack ones).
+func (s *SQLiteStore) ListUserWebhooks(ownerID string) ([]Webhook, error) {
+	return s.queryWebhooks(`SELECT `+webhookColumns+` FROM webhooks WHERE owner_id=? AND pack_id='' ORDER BY created_at`, ownerID)
+}
+
+func (s *SQLiteStore) ListPackWebhooks(packID string) ([]Webhook, error) {
+	return s.queryWebhooks(`SELECT `+webhookColumns+` FROM webhooks WHERE pack_id=? ORDER BY created_at`, packID)
+}
+
+func (s *SQLiteStore) DeleteWebhook(id, ownerID string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM webhooks WHERE id=? AND owner_id=?`, id, ownerID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ReplaceChannelWebhooks makes the owner-less webhooks match hooks exactly.
+func (s *SQLiteStore) ReplaceChannelWebhooks(hooks []Webhook) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	keep := []any{}
+	for _, wh := range hooks {
+		events, _ := json.Marshal(wh.Events)
+		_, err := tx.Exec(
+			`INSERT INTO webhooks (id, owner_id, url, kind, events, created_at) VALUES (?, '', ?, ?, ?, ?)
+			 ON CONFLICT(id) DO UPDATE SET url=excluded.url, kind=excluded.kind, events=excluded.events`,
+			wh.ID, wh.URL, wh.Kind, string(events), wh.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		keep = append(keep, wh.ID)
+	}
+	query := `DELETE FROM webhooks WHERE owner_id = ''`
+	if len(keep) > 0 {
+		query += ` AND id NOT IN (?` + strings.Repeat(", ?", len(keep)-1) + `)`
+	}
+	if _, err := tx.Exec(query, keep...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListWebhooksForEvent returns the channel webhooks, the author's account-wide
+// webhooks and the pack's own webhooks that subscribe to event.
+func (s *SQLiteStore) ListWebhooksForEvent(event string, pack *MemoPack) ([]Webhook, error) {
+	hooks, err := s.queryWebhooks(
+		`SELECT `+webhookColumns+` FROM webhooks WHERE (owner_id = '' OR owner_id = ?) AND (pack_id = '' OR pack_id = ?)`,
+		pack.AuthorID, pack.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	matching := []Webhook{}
+	for _, wh := range hooks {
+		for _, e := range wh.Events {
+			if e == event {
+				matching = append(matching, wh)
+				break
+			}
+		}
+	}
+	return matching, nil
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, last_error, created_at, delivered_at`
+
+func (s *SQLiteStore) InsertWebhookDelivery(d *WebhookDelivery) error {
+	_, err := s.db.Exec(
+		`INSERT INTO webhook_deliveries (`+webhookDeliveryColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.ID, d.WebhookID, d.Event, d.Payload, d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.CreatedAt, d.DeliveredAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) UpdateWebhookDelivery(d *WebhookDelivery) error {
+	_, err := s.db.Exec(
+		`UPDATE webhook_deliveries SET status=?, attempts=?, next_attempt_at=?, last_error=?, delivered_at=? WHERE id=?`,
+		d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.DeliveredAt, d.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) queryWebhookDeliveries(query string, args ...any) ([]WebhookDelivery, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.LastError, &d.CreatedAt, &d.DeliveredAt); err == nil {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+func (s *SQLiteStore) ListDueWebhookDeliveries(now string, limit int) ([]WebhookDelivery, error) {
+	return s.queryWebhookDeliveries(
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		 WHERE status = 'pending' AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?`, now, limit,
+	)
+}
+
+func (s *SQLiteStore) ListWebhookDeliveries(webhookID string) ([]WebhookDelivery, error) {
+	return s.queryWebhookDeliveries(
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE webhook_id = ? ORDER BY created_at DESC LIMIT 50`, webhookID,
+	)
+}
+
+// ---- Notification DB operations ----
+
+func (s *SQLiteStore) InsertNotification(n *Notification) error {
+	_, err := s.db.Exec(
+		`INSERT INTO notifications (id, user_id, kind, pack_id, message, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		n.ID, n.UserID, n.Kind, n.PackID, n.Message, n.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) ListNotifications(userID string, unreadOnly bool) ([]Notification, error) {
+	query := `SELECT id, user_id, kind, pack_id, message, read, created_at FROM notifications WHERE user_id = ?`
+	if unreadOnly {
+		query += ` AND read = 0`
+	}
+	rows, err := s.db.Query(query+` ORDER BY created_at DESC LIMIT 100`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []Notification{}
+	for rows.Next() {
+		var n Notification
+		var read int
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.PackID, &n.Message, &read, &n.CreatedAt); err != nil {
+			continue
+		}
+		n.Read = read == 1
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
+
+// ListAllNotifications returns every notification for a user, oldest first.
+func (s *SQLiteStore) ListAllNotifications(userID string) ([]Notification, error) {
+	rows, err := s.db.Query(
+		`SELECT id, user_id, kind, pack_id, message, read, created_at FROM notifications WHERE user_id = ? ORDER BY created_at`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []Notification{}
+	for rows.Next() {
+		var n Notification
+		var read int
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.PackID, &n.Message, &read, &n.CreatedAt); err != nil {
+			continue
+		}
+		n.Read = read == 1
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
+
+func (s *SQLiteStore) MarkNotificationsRead(userID string) error {
+	_, err := s.db.Exec(`UPDATE notifications SET read = 1 WHERE user_id = ?`, userID)
+	return err
+}
+
+// ---- Job DB operations ----
+
+const jobColumns = `id, kind, user_id, status, payload, result, error, attempts, run_at, created_at, finished_at`
+
+func scanJob(row rowScanner) (*Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Kind, &j.UserID, &j.Status, &j.Payload, &j.Result, &j.Error,
+		&j.Attempts, &j.RunAt, &j.CreatedAt, &j.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (s *SQLiteStore) InsertJob(j *Job) error {
+	_, err := s.db.Exec(
+		`INSERT INTO jobs (id, kind, user_id, status, payload, run_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		j.ID, j.Kind, j.UserID, j.Status, j.Payload, j.RunAt, j.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) UpdateJob(j *Job) error {
+	_, err := s.db.Exec(
+		`UPDATE jobs SET status=?, result=?, error=?, attempts=?, run_at=?, finished_at=? WHERE id=?`,
+		j.Status, j.Result, j.Error, j.Attempts, j.RunAt, j.FinishedAt, j.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) GetJob(id string) (*Job, error) {
+	return scanJob(s.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+}
+
+// LatestUserJob returns the user's most recent job of kind.
+func (s *SQLiteStore) LatestUserJob(userID, kind string) (*Job, error) {
+	return scanJob(s.db.QueryRow(
+		`SELECT `+jobColumns+` FROM jobs WHERE user_id=? AND kind=? ORDER BY created_at DESC, rowid DESC LIMIT 1`, userID, kind,
+	))
+}
+
+func (s *SQLiteStore) ListDueJobs(now string, limit int) ([]Job, error) {
+	rows, err := s.db.Query(
+		`SELECT `+jobColumns+` FROM jobs WHERE status = 'pending' AND run_at <= ? ORDER BY run_at LIMIT ?`, now, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []Job{}
+	for rows.Next() {
+		if j, err := scanJob(rows); err == nil {
+			jobs = append(jobs, *j)
+		}
+	}
+	return jobs, nil
+}
+
+// RequeueRunningJobs puts jobs interrupted by a restart back in the queue.
+func (s *SQLiteStore) RequeueRunningJobs() error {
+	_, err := s.db.Exec(`UPDATE jobs SET status = 'pending' WHERE status = 'running'`)
+	return err
+}
+
+// ---- Legal hold and retention DB operations ----
+
+// SetLegalHold places or releases a hold on a pack or user (table is
+// "memo_packs" or "users"). It reports whether the row exists.
+func (s *SQLiteStore) SetLegalHold(table, id string, held bool, reason string) (bool, error) {
+	if !held {
+		reason = ""
+	}
+	res, err := s.db.Exec(`UPDATE `+table+` SET legal_hold=?, legal_hold_reason=? WHERE id=?`, boolToInt(held), reason, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// IsPackOnHold reports whether a pack or its author is under legal hold.
+func (s *SQLiteStore) IsPackOnHold(packID string) bool {
+	var held int
+	s.db.QueryRow(
+		`SELECT p.legal_hold OR COALESCE(u.legal_hold, 0) FROM memo_packs p LEFT JOIN users u ON u.id = p.author_id WHERE p.id=?`, packID,
+	).Scan(&held)
+	return held == 1
+}
+
+// ---- Audit log DB operations ----
+
+func (s *SQLiteStore) InsertAuditEntry(e *AuditEntry) error {
+	_, err := s.db.Exec(
+		`INSERT INTO audit_log (id, actor_id, actor_name, via, action, target_kind, target_id, reason, diff, ip, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.ActorID, e.ActorName, e.Via, e.Action, e.TargetKind, e.TargetID, e.Reason, e.Diff, e.IP, e.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) ListAuditEntries(q AuditQuery) ([]AuditEntry, int, error) {
+	where := []string{"1 = 1"}
+	args := []any{}
+	if q.Actor != "" {
+		where = append(where, "(actor_id = ? OR actor_name = ?)")
+		args = append(args, q.Actor, q.Actor)
+	}
+	if prefix, ok := strings.CutSuffix(q.Action, "."); ok {
+		where = append(where, "substr(action, 1, ?) = ?")
+		args = append(args, len(prefix)+1, prefix+".")
+	} else if q.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, q.Action)
+	}
+	for _, f := range []struct {
+		cond string
+		val  string
+	}{
+		{"target_kind = ?", q.TargetKind},
+		{"target_id = ?", q.TargetID},
+		{"ip = ?", q.IP},
+		{"created_at >= ?", q.Since},
+		{"created_at <= ?", q.Until},
+	} {
+		if f.val != "" {
+			where = append(where, f.cond)
+			args = append(args, f.val)
+		}
+	}
+	whereClause := strings.Join(where, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM audit_log WHERE `+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.Query(
+		`SELECT id, actor_id, actor_name, via, action, target_kind, target_id, reason, diff, ip, created_at FROM audit_log
+		 WHERE `+whereClause+` ORDER BY created_at DESC, rowid DESC LIMIT ? OFFSET ?`,
+		append(args, q.Limit, (q.Page-1)*q.Limit)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.ActorName, &e.Via, &e.Action, &e.TargetKind, &e.TargetID, &e.Reason, &e.Diff, &e.IP, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// PurgeAuditEntries removes audit entries before cutoff, except those by or
+// about held users and those about held packs.
+func (s *SQLiteStore) PurgeAuditEntries(cutoff string) (int, error) {
+	return s.execCount(
+		`DELETE FROM audit_log WHERE created_at < ? AND actor_id NOT IN (`+heldUserIDs+`)
+		 AND NOT (target_kind = 'user' AND target_id IN (`+heldUserIDs+`))
+		 AND NOT (target_kind = 'pack' AND target_id IN (`+heldPackIDs+`))`, cutoff,
+	)
+}
+
+func (s *SQLiteStore) ListLegalHolds() ([]LegalHold, error) {
+	rows, err := s.db.Query(
+		`SELECT 'pack', id, name, legal_hold_reason FROM memo_packs WHERE legal_hold = 1
+		 UNION ALL
+		 SELECT 'user', id, username, legal_hold_reason FROM users WHERE legal_hold = 1`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	holds := []LegalHold{}
+	for rows.Next() {
+		var h LegalHold
+		if rows.Scan(&h.Type, &h.ID, &h.Name, &h.Reason) == nil {
+			holds = append(holds, h)
+		}
+	}
+	return holds, nil
+}
+
+// Subqueries matching rows that belong to held packs or users.
+const (
+	heldPackIDs = `SELECT p.id FROM memo_packs p LEFT JOIN users u ON u.id = p.author_id WHERE p.legal_hold = 1 OR u.legal_hold = 1`
+	heldUserIDs = `SELECT id FROM users WHERE legal_hold = 1`
+)
+
+// PurgeDeletedPacks permanently removes packs soft-deleted before cutoff,
+// along with their webhooks. Held packs are kept.
+func (s *SQLiteStore) PurgeDeletedPacks(cutoff string) (int, error) {
+	rows, err := s.db.Query(
+		`SELECT id FROM memo_packs WHERE deleted_at != '' AND deleted_at < ? AND id NOT IN (`+heldPackIDs+`)`, cutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if _, err := s.db.Exec(`DELETE FROM memo_packs WHERE id=?`, id); err != nil {
+			return 0, err
+		}
+		if _, err := s.db.Exec(`DELETE FROM webhooks WHERE pack_id=?`, id); err != nil {
+			return 0, err
+		}
+		if _, err := s.db.Exec(`DELETE FROM pack_slugs WHERE pack_id=?`, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// PurgeDownloadEvents removes download events before cutoff, except those
+// of held packs or held users.
+func (s *SQLiteStore) PurgeDownloadEvents(cutoff string) (int, error) {
+	return s.execCount(
+		`DELETE FROM download_events WHERE created_at < ? AND pack_id NOT IN (`+heldPackIDs+`) AND user_id NOT IN (`+heldUserIDs+`)`, cutoff,
+	)
+}
+
+// PurgeInstallEvents removes install events before cutoff, except those of
+// held packs. Install events carry no user.
+func (s *SQLiteStore) PurgeInstallEvents(cutoff string) (int, error) {
+	return s.execCount(
+		`DELETE FROM install_events WHERE created_at < ? AND pack_id NOT IN (`+heldPackIDs+`)`, cutoff,
+	)
+}
+
+// PurgeWebhookDeliveries removes finished deliveries created before cutoff.
+func (s *SQLiteStore) PurgeWebhookDeliveries(cutoff string) (int, error) {
+	return s.execCount(`DELETE FROM webhook_deliveries WHERE status != 'pending' AND created_at < ?`, cutoff)
+}
+
+// PurgeJobs removes finished jobs created before cutoff.
+func (s *SQLiteStore) PurgeJobs(cutoff string) (int, error) {
+	return s.execCount(`DELETE FROM jobs WHERE status IN ('done', 'failed') AND created_at < ?`, cutoff)
+}
+
+func (s *SQLiteStore) execCount(query string, args ...any) (int, error) {
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// ---- Category DB operations ----
+
+// ListCategories returns the categories in display order, each with its
+// number of public packs.
+func (s *SQLiteStore) ListCategories() ([]Category, error) {
+	rows, err := s.db.Query(
+		`SELECT c.slug, c.name, c.description, c.position,
+		        (SELECT COUNT(*) FROM memo_packs p WHERE p.category = c.slug AND p.published = 1 AND p.visibility = 'public' AND p.deleted_at = '' AND p.embargo_until <= ?)
+		 FROM categories c ORDER BY c.position, c.slug`, nowISO(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cats := []Category{}
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.Slug, &c.Name, &c.Description, &c.Position, &c.PackCount); err != nil {
+			return nil, err
+		}
+		cats = append(cats, c)
+	}
+	return cats, rows.Err()
+}
+
+func (s *SQLiteStore) GetCategory(slug string) (*Category, error) {
+	var c Category
+	err := s.db.QueryRow(`SELECT slug, name, description, position FROM categories WHERE slug = ?`, slug).
+		Scan(&c.Slug, &c.Name, &c.Description, &c.Position)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *SQLiteStore) SaveCategory(c *Category) error {
+	_, err := s.db.Exec(
+		`INSERT INTO categories (slug, name, description, position) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(slug) DO UPDATE SET name=excluded.name, description=excluded.description, position=excluded.position`,
+		c.Slug, c.Name, c.Description, c.Position,
+	)
+	return err
+}
+
+// DeleteCategory removes a category, moving its packs into another one, or
+// out of any when into is empty.
+func (s *SQLiteStore) DeleteCategory(slug, into string) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM categories WHERE slug = ?`, slug)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec(`UPDATE memo_packs SET category = ? WHERE category = ?`, into, slug); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ---- Template DB operations ----
+
+const packTemplateColumns = `slug, name, description, position, pack, updated_at`
+
+func scanPackTemplate(row rowScanner) (*PackTemplate, error) {
+	var t PackTemplate
+	var pack string
+	if err := row.Scan(&t.Slug, &t.Name, &t.Description, &t.Position, &pack, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(pack), &t.Pack); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListPackTemplates returns the templates in display order.
+func (s *SQLiteStore) ListPackTemplates() ([]PackTemplate, error) {
+	rows, err := s.db.Query(`SELECT ` + packTemplateColumns + ` FROM pack_templates ORDER BY position, slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	templates := []PackTemplate{}
+	for rows.Next() {
+		t, err := scanPackTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	return templates, rows.Err()
+}
+
+func (s *SQLiteStore) GetPackTemplate(slug string) (*PackTemplate, error) {
+	return scanPackTemplate(s.db.QueryRow(`SELECT `+packTemplateColumns+` FROM pack_templates WHERE slug = ?`, slug))
+}
+
+func (s *SQLiteStore) SavePackTemplate(t *PackTemplate) error {
+	pack, err := json.Marshal(t.Pack)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO pack_templates (slug, name, description, position, pack, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(slug) DO UPDATE SET name=excluded.name, description=excluded.description,
+		 position=excluded.position, pack=excluded.pack, updated_at=excluded.updated_at`,
+		t.Slug, t.Name, t.Description, t.Position, string(pack), nowISO(),
+	)
+	return err
+}
+
+// SeedPackTemplate adds t unless a template with its slug exists, reporting
+// whether it did.
+func (s *SQLiteStore) SeedPackTemplate(t *PackTemplate) (bool, error) {
+	pack, err := json.Marshal(t.Pack)
+	if err != nil {
+		return false, err
+	}
+	res, err := s.db.Exec(
+		`INSERT OR IGNORE INTO pack_templates (slug, name, description, position, pack, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		t.Slug, t.Name, t.Description, t.Position, string(pack), nowISO(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *SQLiteStore) DeletePackTemplate(slug string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM pack_templates WHERE slug = ?`, slug)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ---- Tag policy DB operations ----
+
+func (s *SQLiteStore) ListTagRules() ([]TagRule, error) {
+	rows, err := s.db.Query(`SELECT tag, action, into_tag, reason, created_at FROM tag_rules ORDER BY tag`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := []TagRule{}
+	for rows.Next() {
+		var t TagRule
+		if err := rows.Scan(&t.Tag, &t.Action, &t.Into, &t.Reason, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, t)
+	}
+	return rules, rows.Err()
+}
+
+func (s *SQLiteStore) SaveTagRule(t *TagRule) error {
+	_, err := s.db.Exec(
+		`INSERT INTO tag_rules (tag, action, into_tag, reason, created_at) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(tag) DO UPDATE SET action=excluded.action, into_tag=excluded.into_tag, reason=excluded.reason, created_at=excluded.created_at`,
+		t.Tag, t.Action, t.Into, t.Reason, t.CreatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) DeleteTagRule(tag string) (bool, error) {
+	n, err := s.execCount(`DELETE FROM tag_rules WHERE tag=?`, tag)
+	return n > 0, err
+}
+
+// ListLivePackIDs returns the IDs of all packs that are not deleted.
+func (s *SQLiteStore) ListLivePackIDs() ([]string, error) {
+	rows, err := s.db.Query(`SELECT id FROM memo_packs WHERE deleted_at = '' ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SaveRetaggedPack stores rule and memo tags rewritten by the retag job,
+// including in the snapshot of the pack's current version so receipts for
+// it still verify. The revision moves so open editors reload first.
+func (s *SQLiteStore) SaveRetaggedPack(mp *MemoPack) error {
+	return s.SaveRetaggedPacks([]*MemoPack{mp})
+}
+
+// SaveRetaggedPacks rewrites the bodies and current version of several
+// packs in one transaction.
+func (s *SQLiteStore) SaveRetaggedPacks(packs []*MemoPack) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := nowISO()
+	for _, mp := range packs {
+		bodies, blobs := splitPackBodies(mp)
+		mp.Checksum = packContentChecksum(mp)
+		if _, err := tx.Exec(
+			`UPDATE memo_packs SET system_prompt=?, rules=?, memos=?, external_fields=?, checksum=?, updated_at=?, revision=revision+1 WHERE id=?`,
+			bodies.systemPrompt, bodies.rules, bodies.memos, bodies.external, mp.Checksum, now, mp.ID,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM pack_blobs WHERE pack_id=?`, mp.ID); err != nil {
+			return err
+		}
+		for field, data := range blobs {
+			if _, err := tx.Exec(`INSERT INTO pack_blobs (pack_id, field, data) VALUES (?, ?, ?)`, mp.ID, field, data); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(
+			`UPDATE memo_pack_versions SET rules=?, memos=?, updated_at=? WHERE pack_id=? AND version=?`,
+			MarshalRules(mp.Rules), MarshalMemos(mp.Memos), now, mp.ID, mp.Version,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// HasPendingJob reports whether a job of kind is queued or running.
+func (s *SQLiteStore) HasPendingJob(kind string) bool {
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM jobs WHERE kind=? AND status IN ('pending', 'running')`, kind).Scan(&n)
+	return n > 0
+}
+
+// ---- ETL DB operations ----
+
+// ListETLPacks returns packs (including deleted ones) ordered by
+// (updated_at, id), starting after the given position.
+func (s *SQLiteStore) ListETLPacks(afterUpdated, afterID string, limit int) ([]ETLPackRecord, error) {
+	rows, err := s.db.Query(
+		`SELECT id, name, author_id, author_name, version, published, deleted_at, downloads, rule_count, memo_count, total_chars,
+		   rating_avg, rating_count, star_count, created_at, updated_at, content_updated_at
+		 FROM memo_packs WHERE updated_at > ? OR (updated_at = ? AND id > ?)
+		 ORDER BY updated_at, id LIMIT ?`, afterUpdated, afterUpdated, afterID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	records := []ETLPackRecord{}
+	for rows.Next() {
+		var rec ETLPackRecord
+		var published int
+		err := rows.Scan(&rec.ID, &rec.Name, &rec.AuthorID, &rec.AuthorName, &rec.Version, &published, &rec.DeletedAt,
+			&rec.Downloads, &rec.RuleCount, &rec.MemoCount, &rec.TotalChars, &rec.RatingAverage, &rec.RatingCount,
+			&rec.Stars, &rec.CreatedAt, &rec.UpdatedAt, &rec.ContentUpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rec.Published = published == 1
+		rec.Deleted = rec.DeletedAt != ""
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// ListETLEvents returns download events with an id greater than afterID.
+func (s *SQLiteStore) ListETLEvents(afterID int64, limit int) ([]ETLEventRecord, []int64, error) {
+	rows, err := s.db.Query(
+		`SELECT id, pack_id, version, client_id, user_id, created_at FROM download_events WHERE id > ? ORDER BY id LIMIT ?`,
+		afterID, limit,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	records := []ETLEventRecord{}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		rec := ETLEventRecord{Type: "download"}
+		if err := rows.Scan(&id, &rec.PackID, &rec.Version, &rec.ClientID, &rec.UserID, &rec.CreatedAt); err != nil {
+			return nil, nil, err
+		}
+		records = append(records, rec)
+		ids = append(ids, id)
+	}
+	return records, ids, rows.Err()
+}
+
+// ---- Account export DB operations ----
+
+// ListAuthorPacks returns all of an author's packs, including unpublished
+// ones and, when includeDeleted is set, deleted ones awaiting purge.
+func (s *SQLiteStore) ListAuthorPacks(authorID string, includeDeleted bool) ([]MemoPack, error) {
+	rows, err := s.db.Query(`SELECT `+memoPackColumns+` FROM memo_packs WHERE author_id=? AND (? OR deleted_at = '') ORDER BY created_at`, authorID, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	packs := []MemoPack{}
+	for rows.Next() {
+		if mp, err := scanMemoPack(rows); err == nil {
+			packs = append(packs, *mp)
+		}
+	}
+	rows.Close()
+	for i := range packs {
+		if err := s.loadPackBlobs(&packs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return packs, nil
+}
+
+func (s *SQLiteStore) ListUserReviews(userID string) ([]Review, error) {
+	rows, err := s.db.Query(
+		`SELECT pack_id, user_id, username, rating, body, created_at, updated_at FROM reviews WHERE user_id=? ORDER BY created_at`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reviews := []Review{}
+	for rows.Next() {
+		var rv Review
+		if err := rows.Scan(&rv.PackID, &rv.UserID, &rv.Username, &rv.Rating, &rv.Body, &rv.CreatedAt, &rv.UpdatedAt); err == nil {
+			reviews = append(reviews, rv)
+		}
+	}
+	return reviews, nil
+}
+
+func (s *SQLiteStore) ListUserStars(userID string) ([]StarRecord, error) {
+	rows, err := s.db.Query(`SELECT pack_id, created_at FROM stars WHERE user_id=? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stars := []StarRecord{}
+	for rows.Next() {
+		var s StarRecord
+		if rows.Scan(&s.PackID, &s.CreatedAt) == nil {
+			stars = append(stars, s)
+		}
+	}
+	return stars, nil
+}
+
+func (s *SQLiteStore) ListUserSubscriptions(userID string) ([]SubscriptionInfo, error) {
+	rows, err := s.db.Query(`SELECT pack_id, last_version, created_at FROM subscriptions WHERE user_id=? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	subs := []SubscriptionInfo{}
+	for rows.Next() {
+		var s SubscriptionInfo
+		if rows.Scan(&s.PackID, &s.LastVersion, &s.CreatedAt) == nil {
+			subs = append(subs, s)
+		}
+	}
+	return subs, nil
+}
+
+// ListLastDownloads returns the most recent download of each pack a user has
+// downloaded, newest first.
+func (s *SQLiteStore) ListLastDownloads(userID string) ([]DownloadRecord, error) {
+	rows, err := s.db.Query(
+		`SELECT pack_id, version, created_at FROM download_events
+		 WHERE id IN (SELECT MAX(id) FROM download_events WHERE user_id=? GROUP BY pack_id) ORDER BY id DESC`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	downloads := []DownloadRecord{}
+	for rows.Next() {
+		var d DownloadRecord
+		if err := rows.Scan(&d.PackID, &d.Version, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		downloads = append(downloads, d)
+	}
+	return downloads, rows.Err()
+}
+
+func (s *SQLiteStore) ListUserDownloads(userID string) ([]DownloadRecord, error) {
+	rows, err := s.db.Query(`SELECT pack_id, version, created_at FROM download_events WHERE user_id=? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	downloads := []DownloadRecord{}
+	for rows.Next() {
+		var d DownloadRecord
+		if rows.Scan(&d.PackID, &d.Version, &d.CreatedAt) == nil {
+			downloads = append(downloads, d)
+		}
+	}
+	return downloads, nil
+}
+
+// ---- Metrics ----
+
+// CountByPeriod counts table rows created since from, grouped by the first
+// keyLen characters of created_at (13 for hours, 10 for days).
+func (s *SQLiteStore) CountByPeriod(table, from string, keyLen int) (map[string]float64, error) {
+	rows, err := s.db.Query(`SELECT substr(replace(created_at, ' ', 'T'), 1, ?) AS period, COUNT(*)
+		FROM `+table+` WHERE replace(created_at, ' ', 'T') >= ? GROUP BY period`, keyLen, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]float64{}
+	for rows.Next() {
+		var period string
+		var n int
+		if rows.Scan(&period, &n) == nil {
+			counts[period] = float64(n)
+		}
+	}
+	return counts, nil
+}
+
+// QueueDepth counts background work waiting to run: pending and running
+// jobs plus undelivered webhooks.
+func (s *SQLiteStore) QueueDepth() (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM jobs WHERE status IN ('pending', 'running')) +
+		(SELECT COUNT(*) FROM webhook_deliveries WHERE status = 'pending')`).Scan(&n)
+	return n, err
+}
+
+// ---- Vacations ----
+
+func (s *SQLiteStore) GetVacation(userID string) (*Vacation, error) {
+	var v Vacation
+	err := s.db.QueryRow(`SELECT starts_at, ends_at, message FROM vacations WHERE user_id=?`, userID).Scan(&v.StartsAt, &v.EndsAt, &v.Message)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (s *SQLiteStore) SaveVacation(userID string, v *Vacation) error {
+	_, err := s.db.Exec(`INSERT INTO vacations (user_id, starts_at, ends_at, message) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET starts_at=excluded.starts_at, ends_at=excluded.ends_at, message=excluded.message`,
+		userID, v.StartsAt, v.EndsAt, v.Message)
+	return err
+}
+
+func (s *SQLiteStore) DeleteVacation(userID string) error {
+	_, err := s.db.Exec(`DELETE FROM vacations WHERE user_id=?`, userID)
+	return err
+}
+
+// GetMilestonePrefs returns userID's milestone settings. Downloads is nil
+// when the author keeps the default milestones.
+func (s *SQLiteStore) GetMilestonePrefs(userID string) (*MilestonePrefs, error) {
+	var p MilestonePrefs
+	var downloads sql.NullString
+	err := s.db.QueryRow(`SELECT downloads, new_clients, notify FROM milestone_prefs WHERE user_id=?`, userID).Scan(&downloads, &p.NewClients, &p.Notify)
+	if err != nil {
+		return nil, err
+	}
+	if downloads.Valid {
+		p.Downloads = []int{}
+		json.Unmarshal([]byte(downloads.String), &p.Downloads)
+	}
+	return &p, nil
+}
+
+func (s *SQLiteStore) SaveMilestonePrefs(userID string, p *MilestonePrefs) error {
+	var downloads sql.NullString
+	if p.Downloads != nil {
+		b, _ := json.Marshal(p.Downloads)
+		downloads = sql.NullString{String: string(b), Valid: true}
+	}
+	_, err := s.db.Exec(`INSERT INTO milestone_prefs (user_id, downloads, new_clients, notify) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET downloads=excluded.downloads, new_clients=excluded.new_clients, notify=excluded.notify`,
+		userID, downloads, boolToInt(p.NewClients), boolToInt(p.Notify))
+	return err
+}
+
+// ListActiveVacations returns the vacations in effect now for any of userIDs.
+func (s *SQLiteStore) ListActiveVacations(userIDs []string) (map[string]*Vacation, error) {
+	out := map[string]*Vacation{}
+	if len(userIDs) == 0 {
+		return out, nil
+	}
+	now := nowISO()
+	args := []any{now, now}
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+	rows, err := s.db.Query(`SELECT user_id, starts_at, ends_at, message FROM vacations
+		WHERE starts_at <= ? AND ends_at > ? AND user_id IN (?`+strings.Repeat(",?", len(userIDs)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		v := &Vacation{Active: true}
+		if rows.Scan(&userID, &v.StartsAt, &v.EndsAt, &v.Message) == nil {
+			out[userID] = v
+		}
+	}
+	return out, nil
+}
+
+// ---- Verifications ----
+
+const verificationColumns = `id, user_id, kind, subject, token, status, created_at, verified_at`
+
+func scanVerification(row rowScanner) (*Verification, error) {
+	var v Verification
+	err := row.Scan(&v.ID, &v.UserID, &v.Kind, &v.Subject, &v.Token, &v.Status, &v.CreatedAt, &v.VerifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// InsertVerification stores a new claim, or returns the existing one for the
+// same user, kind and subject.
+func (s *SQLiteStore) InsertVerification(v *Verification) (*Verification, error) {
+	_, err := s.db.Exec(`INSERT INTO verifications (id, user_id, kind, subject, token, status, created_at)
+		VALUES (?, ?, ?, ?, ?, 'pending', ?) ON CONFLICT (user_id, kind, subject) DO NOTHING`,
+		v.ID, v.UserID, v.Kind, v.Subject, v.Token, v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return scanVerification(s.db.QueryRow(`SELECT `+verificationColumns+` FROM verifications WHERE user_id=? AND kind=? AND subject=?`,
+		v.UserID, v.Kind, v.Subject))
+}
+
+func (s *SQLiteStore) GetVerification(id string) (*Verification, error) {
+	return scanVerification(s.db.QueryRow(`SELECT `+verificationColumns+` FROM verifications WHERE id=?`, id))
+}
+
+func (s *SQLiteStore) ListUserVerifications(userID string) ([]Verification, error) {
+	rows, err := s.db.Query(`SELECT `+verificationColumns+` FROM verifications WHERE user_id=? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	vs := []Verification{}
+	for rows.Next() {
+		if v, err := scanVerification(rows); err == nil {
+			vs = append(vs, *v)
+		}
+	}
+	return vs, nil
+}
+
+// VerifiedSubjectOwner returns the user who has proven kind/subject, if any.
+func (s *SQLiteStore) VerifiedSubjectOwner(kind, subject string) (string, error) {
+	var userID string
+	err := s.db.QueryRow(`SELECT user_id FROM verifications WHERE kind=? AND subject=? AND status='verified'`, kind, subject).Scan(&userID)
+	return userID, err
+}
+
+func (s *SQLiteStore) MarkVerified(id string) error {
+	_, err := s.db.Exec(`UPDATE verifications SET status='verified', verified_at=? WHERE id=?`, nowISO(), id)
+	return err
+}
+
+func (s *SQLiteStore) DeleteVerification(id, userID string) error {
+	_, err := s.db.Exec(`DELETE FROM verifications WHERE id=? AND user_id=?`, id, userID)
+	return err
+}
+
+// ListVerifiedIdentities returns the proven identities of each of userIDs.
+func (s *SQLiteStore) ListVerifiedIdentities(userIDs []string) (map[string][]VerifiedIdentity, error) {
+	out := map[string][]VerifiedIdentity{}
+	if len(userIDs) == 0 {
+		return out, nil
+	}
+	args := make([]any, len(userIDs))
+	for i, id := range userIDs {
+		args[i] = id
+	}
+	rows, err := s.db.Query(`SELECT user_id, kind, subject, verified_at FROM verifications
+		WHERE status='verified' AND user_id IN (?`+strings.Repeat(",?", len(userIDs)-1)+`) ORDER BY kind, subject`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		var vi VerifiedIdentity
+		if rows.Scan(&userID, &vi.Kind, &vi.Subject, &vi.VerifiedAt) == nil {
+			vi.URL = identityURL(vi.Kind, vi.Subject)
+			out[userID] = append(out[userID], vi)
+		}
+	}
+	return out, nil
+}
+
+// ---- Anonymous publishing ----
+
+// EnsureAnonymousUser returns the system user that owns anonymous packs,
+// creating it on first use. It has no password and can't log in.
+func (s *SQLiteStore) EnsureAnonymousUser() (*User, error) {
+	_, err := s.db.Exec(
+		`INSERT OR IGNORE INTO users (id, username, password_hash, token, role, created_at, name_skeleton)
+		 VALUES (?, ?, '', ?, ?, ?, ?)`,
+		anonymousUserID, anonymousUserID, "-"+anonymousUserID, roleAnonymous, nowISO(), nameSkeleton(anonymousUserID),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetUserByID(anonymousUserID)
+}
+
+func (s *SQLiteStore) CreatePackClaim(packID, tokenHash string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO pack_claims (pack_id, token_hash, created_at) VALUES (?, ?, ?)`, packID, tokenHash, nowISO(),
+	)
+	return err
+}
+
+// ClaimPack hands an anonymous pack to user when tokenHash matches its claim,
+// which is used up. It reports whether the claim matched.
+func (s *SQLiteStore) ClaimPack(packID, tokenHash string, user *User) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`DELETE FROM pack_claims WHERE pack_id = ? AND token_hash = ?`, packID, tokenHash)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err := tx.Exec(
+		`UPDATE memo_packs SET author_id = ?, author_name = ?, updated_at = ?, revision = revision + 1 WHERE id = ? AND author_id = ?`,
+		user.ID, user.Username, nowISO(), packID, anonymousUserID,
+	); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ---- Embargo DB operations ----
+
+// SetPackEmbargo sets when a pack becomes public and who may see it before
+// then, replacing any earlier viewers. An empty until lifts the embargo.
+func (s *SQLiteStore) SetPackEmbargo(packID, until string, viewerIDs []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE memo_packs SET embargo_until = ? WHERE id = ?`, until, packID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM embargo_viewers WHERE pack_id = ?`, packID); err != nil {
+		return err
+	}
+	for _, id := range viewerIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO embargo_viewers (pack_id, user_id) VALUES (?, ?)`, packID, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) IsEmbargoViewer(packID, userID string) bool {
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM embargo_viewers WHERE pack_id = ? AND user_id = ?`, packID, userID).Scan(&n)
+	return n > 0
+}
+
+// ListEmbargoViewers returns the usernames who may see an embargoed pack.
+func (s *SQLiteStore) ListEmbargoViewers(packID string) ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT u.username FROM embargo_viewers e JOIN users u ON u.id = e.user_id WHERE e.pack_id = ? ORDER BY u.username`, packID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			names = append(names, name)
+		}
+	}
+	return names, rows.Err()
+}
+
+// ---- Collaborator DB operations ----
+
+// AddCollaborator reports whether userID was newly added.
+func (s *SQLiteStore) AddCollaborator(packID, userID, addedBy string) (bool, error) {
+	n, err := s.execCount(
+		`INSERT OR IGNORE INTO pack_collaborators (pack_id, user_id, added_by, created_at) VALUES (?, ?, ?, ?)`,
+		packID, userID, addedBy, nowISO(),
+	)
+	return n > 0, err
+}
+
+func (s *SQLiteStore) RemoveCollaborator(packID, userID string) (bool, error) {
+	n, err := s.execCount(`DELETE FROM pack_collaborators WHERE pack_id = ? AND user_id = ?`, packID, userID)
+	return n > 0, err
+}
+
+func (s *SQLiteStore) IsCollaborator(packID, userID string) bool {
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM pack_collaborators WHERE pack_id = ? AND user_id = ?`, packID, userID).Scan(&n)
+	return n > 0
+}
+
+// ListCollaborators returns a pack's collaborators in the order they were
+// added.
+func (s *SQLiteStore) ListCollaborators(packID string) ([]Collaborator, error) {
+	rows, err := s.db.Query(
+		`SELECT c.user_id, u.username, COALESCE(a.username, ''), c.created_at
+		 FROM pack_collaborators c JOIN users u ON u.id = c.user_id LEFT JOIN users a ON a.id = c.added_by
+		 WHERE c.pack_id = ? ORDER BY c.created_at, u.username`, packID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Collaborator{}
+	for rows.Next() {
+		var c Collaborator
+		if err := rows.Scan(&c.UserID, &c.Username, &c.AddedBy, &c.AddedAt); err == nil {
+			list = append(list, c)
+		}
+	}
+	return list, rows.Err()
+}
+
+// ---- Share link DB operations ----
+
+func (s *SQLiteStore) InsertShareLink(l *ShareLink, tokenHash string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO share_links (id, pack_id, token_hash, note, expires_at, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		l.ID, l.PackID, tokenHash, l.Note, l.ExpiresAt, l.CreatedBy, l.CreatedAt,
+	)
+	return err
+}
+
+// ListShareLinks returns a pack's share links, expired ones included,
+// newest first.
+func (s *SQLiteStore) ListShareLinks(packID string) ([]ShareLink, error) {
+	rows, err := s.db.Query(
+		`SELECT l.id, l.pack_id, l.note, l.expires_at, COALESCE(u.username, ''), l.created_at
+		 FROM share_links l LEFT JOIN users u ON u.id = l.created_by
+		 WHERE l.pack_id = ? ORDER BY l.created_at DESC, l.id`, packID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []ShareLink{}
+	for rows.Next() {
+		var l ShareLink
+		if err := rows.Scan(&l.ID, &l.PackID, &l.Note, &l.ExpiresAt, &l.CreatedBy, &l.CreatedAt); err == nil {
+			list = append(list, l)
+		}
+	}
+	return list, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteShareLink(packID, id string) (bool, error) {
+	n, err := s.execCount(`DELETE FROM share_links WHERE pack_id = ? AND id = ?`, packID, id)
+	return n > 0, err
+}
+
+// ShareLinkValid reports whether tokenHash belongs to an unexpired share
+// link of the pack.
+func (s *SQLiteStore) ShareLinkValid(packID, tokenHash string) bool {
+	var n int
+	s.db.QueryRow(
+		`SELECT COUNT(*) FROM share_links WHERE pack_id = ? AND token_hash = ? AND (expires_at = '' OR expires_at > ?)`,
+		packID, tokenHash, nowISO(),
+	).Scan(&n)
+	return n > 0
+}
+
+// ---- Invite DB operations ----
+
+func (s *SQLiteStore) InsertInvite(inv *Invite) error {
+	_, err := s.db.Exec(
+		`INSERT INTO invites (code, note, max_uses, uses, expires_at, created_by, created_at) VALUES (?, ?, ?, 0, ?, ?, ?)`,
+		inv.Code, inv.Note, inv.MaxUses, inv.ExpiresAt, inv.CreatedBy, inv.CreatedAt,
+	)
+	return err
+}
+
+// ListInvites returns every invite, newest first.
+func (s *SQLiteStore) ListInvites() ([]Invite, error) {
+	rows, err := s.db.Query(
+		`SELECT i.code, i.note, i.max_uses, i.uses, i.expires_at, COALESCE(u.username, ''), i.created_at
+		 FROM invites i LEFT JOIN users u ON u.id = i.created_by ORDER BY i.created_at DESC, i.code`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Invite{}
+	for rows.Next() {
+		var inv Invite
+		if err := rows.Scan(&inv.Code, &inv.Note, &inv.MaxUses, &inv.Uses, &inv.ExpiresAt, &inv.CreatedBy, &inv.CreatedAt); err == nil {
+			list = append(list, inv)
+		}
+	}
+	return list, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteInvite(code string) (bool, error) {
+	n, err := s.execCount(`DELETE FROM invites WHERE code = ?`, code)
+	return n > 0, err
+}
+
+// UseInvite takes one use of code, reporting false when it doesn't exist,
+// has expired or is used up.
+func (s *SQLiteStore) UseInvite(code string) (bool, error) {
+	n, err := s.execCount(
+		`UPDATE invites SET uses = uses + 1
+		 WHERE code = ? AND (max_uses = 0 OR uses < max_uses) AND (expires_at = '' OR expires_at > ?)`,
+		code, nowISO(),
+	)
+	return n > 0, err
+}
+
+// ReleaseInvite gives back a use taken by a registration that failed.
+func (s *SQLiteStore) ReleaseInvite(code string) error {
+	_, err := s.db.Exec(`UPDATE invites SET uses = uses - 1 WHERE code = ? AND uses > 0`, code)
+	return err
+}
+
+// ---- OIDC identity DB operations ----
+
+// GetOIDCUser returns the ID of the user linked to subject at issuer.
+func (s *SQLiteStore) GetOIDCUser(issuer, subject string) (string, error) {
+	var id string
+	err := s.db.QueryRow(`SELECT user_id FROM oidc_identities WHERE issuer = ? AND subject = ?`, issuer, subject).Scan(&id)
+	return id, err
+}
+
+func (s *SQLiteStore) LinkOIDCIdentity(issuer, subject, userID string) error {
+	_, err := s.db.Exec(`INSERT INTO oidc_identities (issuer, subject, user_id, created_at) VALUES (?, ?, ?, ?)`,
+		issuer, subject, userID, nowISO())
+	return err
+}
+
+// UnlinkOIDCIdentity removes userID's links at issuer, reporting whether it
+// had any.
+func (s *SQLiteStore) UnlinkOIDCIdentity(issuer, userID string) (bool, error) {
+	n, err := s.execCount(`DELETE FROM oidc_identities WHERE issuer = ? AND user_id = ?`, issuer, userID)
+	return n > 0, err
+}
+
+// ---- Collection DB operations ----
+
+const collectionColumns = `id, owner_id, owner_name, name, description, items, bundle_job_id, created_at, updated_at`
+
+func scanCollection(row rowScanner) (*Collection, error) {
+	var c Collection
+	var items string
+	if err := row.Scan(&c.ID, &c.OwnerID, &c.OwnerName, &c.Name, &c.Description, &items,
+		&c.BundleJobID, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	c.Items = []CollectionItem{}
+	json.Unmarshal([]byte(items), &c.Items)
+	return &c, nil
+}
+
+func (s *SQLiteStore) InsertCollection(c *Collection) error {
+	items, _ := json.Marshal(c.Items)
+	_, err := s.db.Exec(
+		`INSERT INTO collections (id, owner_id, owner_name, name, description, items, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ID, c.OwnerID, c.OwnerName, c.Name, c.Description, string(items), c.CreatedAt, c.UpdatedAt,
+	)
+	return err
+}
+
+func (s *SQLiteStore) UpdateCollection(c *Collection) error {
+	items, _ := json.Marshal(c.Items)
+	_, err := s.db.Exec(
+		`UPDATE collections SET name=?, description=?, items=?, updated_at=? WHERE id=?`,
+		c.Name, c.Description, string(items), c.UpdatedAt, c.ID,
+	)
+	return err
+}
+
+func (s *SQLiteStore) DeleteCollection(id string) error {
+	_, err := s.db.Exec(`DELETE FROM collections WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLiteStore) GetCollection(id string) (*Collection, error) {
+	return scanCollection(s.db.QueryRow(`SELECT `+collectionColumns+` FROM collections WHERE id = ?`, id))
+}
+
+// ListUserCollections returns a user's collections, most recently updated
+// first.
+func (s *SQLiteStore) ListUserCollections(ownerID string) ([]Collection, error) {
+	rows, err := s.db.Query(
+		`SELECT `+collectionColumns+` FROM collections WHERE owner_id = ? ORDER BY updated_at DESC, rowid DESC`, ownerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Collection{}
+	for rows.Next() {
+		if c, err := scanCollection(rows); err == nil {
+			list = append(list, *c)
+		}
+	}
+	return list, nil
+}
+
+// SetCollectionBundleJob records the job building a collection's bundle.
+func (s *SQLiteStore) SetCollectionBundleJob(id, jobID string) error {
+	_, err := s.db.Exec(`UPDATE collections SET bundle_job_id = ? WHERE id = ?`, jobID, id)
 	return err
 }
 
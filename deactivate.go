@@ -0,0 +1,125 @@
+package memomarket
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Deactivating an account takes it out of sight without deleting anything.
+// It is logged out everywhere, its API keys stop working, its profile and
+// packs answer 404 to everyone but admins, and nobody can log in to it.
+// Owners who deactivated their own account restore it by logging in with
+// "reactivate": true; one an admin deactivated only an admin can restore.
+
+// writeDeactivated answers a login to a deactivated account.
+func writeDeactivated(w http.ResponseWriter, user *User) {
+	msg := "account deactivated by an admin"
+	if user.DeactivatedBy == user.ID {
+		msg = `account deactivated; log in with "reactivate": true to restore it`
+	}
+	writeJSON(w, http.StatusForbidden, ErrorResponse{Error: msg})
+}
+
+// POST /api/me/deactivate — deactivate my account; accounts with a password
+// confirm it with {password} (auth required).
+func handleMyDeactivate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireLogin(w, user) {
+		return
+	}
+	var req DeactivateReq
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	account, err := store.GetUserByUsername(user.Username)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to deactivate account"})
+		return
+	}
+	// Accounts made by single sign-on have no password to confirm.
+	if account.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(req.Password)) != nil {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "password is incorrect"})
+		return
+	}
+	if _, err := store.DeactivateUser(user.ID, user.ID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to deactivate account"})
+		return
+	}
+	recordAudit(r, user, auditUserDeactivate, "user", user.ID, "")
+	clearSessionCookies(w, r)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deactivated"})
+}
+
+// POST /api/admin/users/{id}/deactivate — deactivate an account; a reason is
+// required (admin only). DELETE reactivates it, whoever deactivated it.
+func handleAdminUserDeactivate(w http.ResponseWriter, r *http.Request, id string) {
+	admin := currentUser(r)
+	user, err := store.GetUserByID(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user not found"})
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		var req DeactivateReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if err := validateAdminReason(req.Reason); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if user.ID == admin.ID {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "use /api/me/deactivate to deactivate your own account"})
+			return
+		}
+		if user.ID == anonymousUserID {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "the anonymous user can't be deactivated"})
+			return
+		}
+		if isAdmin(user) {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "admins can't be deactivated; remove the admin role first"})
+			return
+		}
+		found, err := store.DeactivateUser(user.ID, admin.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to deactivate user"})
+			return
+		}
+		if !found {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "user is already deactivated"})
+			return
+		}
+		recordAudit(r, admin, auditUserDeactivate, "user", user.ID, req.Reason)
+		notifyModeration(user.ID, "", "", "Your account was deactivated.", req.Reason)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deactivated"})
+	case http.MethodDelete:
+		found, err := store.ReactivateUser(user.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to reactivate user"})
+			return
+		}
+		if !found {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user is not deactivated"})
+			return
+		}
+		recordAudit(r, admin, auditUserReactivate, "user", user.ID, "")
+		notify(user.ID, "moderation", "", "Your account was reactivated.")
+		writeJSON(w, http.StatusOK, map[string]string{"status": "reactivated"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package memomarket
+
+func diskFree(path string) (uint64, bool) {
+	return 0, false
+}
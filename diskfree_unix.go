@@ -0,0 +1,14 @@
+//go:build unix
+
+package memomarket
+
+import "syscall"
+
+// diskFree reports the bytes available to unprivileged users under path.
+func diskFree(path string) (uint64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, false
+	}
+	return st.Bavail * uint64(st.Bsize), true
+}
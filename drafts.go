@@ -0,0 +1,116 @@
+package memomarket
+
+import (
+	"log"
+	"net/http"
+)
+
+// Publishing with "draft": true saves the pack without publishing it, as
+// do GitHub imports. A draft is seen only by its editors and admins: it is
+// left out of listings, collections and the MCP tools, and its pages
+// answer 404 to everyone else. Editors keep changing it with PUT, then
+// publish it with POST /api/memo-packs/{id}/publish; only then do the
+// publish webhooks go out.
+
+const (
+	packStatusDraft     = "draft"
+	packStatusPublished = "published"
+)
+
+func packStatus(published bool) string {
+	if published {
+		return packStatusPublished
+	}
+	return packStatusDraft
+}
+
+// packPublic reports whether p can be seen and listed by everyone:
+// published, not under embargo and public.
+func packPublic(p *MemoPack) bool {
+	return packReachable(p) && p.Visibility == visibilityPublic
+}
+
+// POST /api/memo-packs/{id}/publish — publish a draft (auth required). The
+// body may carry an "embargo" to publish it under one.
+func handlePublishDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if !canEditPack(user, pack) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+		return
+	}
+	var req PublishDraftReq
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+	}
+	var embargoUntil string
+	var embargoViewers []string
+	if req.Embargo != nil {
+		if embargoUntil, embargoViewers, err = validateEmbargo(req.Embargo); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	published, err := store.PublishMemoPack(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to publish"})
+		return
+	}
+	if !published {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is already published"})
+		return
+	}
+	pack.Published, pack.Status, pack.UpdatedAt = true, packStatusPublished, nowISO()
+	writeAudit(r, user, &AuditEntry{Action: auditPackPublish, TargetKind: "pack", TargetID: pack.ID, Diff: packSummary(pack)})
+	if embargoUntil != "" {
+		if err := startEmbargo(pack, embargoUntil, embargoViewers); err != nil {
+			log.Printf("embargo of %s: %v", pack.ID, err)
+		}
+	} else {
+		emitPackEvent(EventPackPublished, pack, nil)
+	}
+	writeJSON(w, http.StatusOK, pack)
+}
+
+// GET /api/me/drafts — my unpublished packs (auth required).
+func handleMyDrafts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	packs, err := store.ListAuthorPacks(user.ID, false)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list drafts"})
+		return
+	}
+	drafts := []MemoPack{}
+	for _, p := range packs {
+		if !p.Published {
+			drafts = append(drafts, p)
+		}
+	}
+	writeJSON(w, http.StatusOK, drafts)
+}
@@ -0,0 +1,135 @@
+package memomarket
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"net/http"
+	"strings"
+)
+
+// Duplicate detection: every pack stores a hash of its normalized content
+// (system prompt, rules and memos, lowercased with whitespace collapsed) and
+// a simhash of its word shingles. Publishing a pack whose hash matches, or
+// whose simhash is within maxDistance bits of, a public pack or one of the
+// publisher's own is answered with a duplicate_of warning, or rejected with
+// 409 in reject mode. Forks are exempt; they are copies on purpose.
+
+const (
+	duplicatesWarn   = "warn"
+	duplicatesReject = "reject"
+	duplicatesOff    = "off"
+)
+
+// Near-duplicates are only looked for in packs with at least this many
+// words; a simhash of a few words matches too much.
+const minSimhashWords = 20
+
+const simhashShingle = 3
+
+var duplicates = struct {
+	mode        string
+	maxDistance int
+}{mode: duplicatesWarn, maxDistance: 6}
+
+func loadDuplicatesConfig(cfg *DuplicatesConfig) {
+	duplicates.mode, duplicates.maxDistance = duplicatesWarn, 6
+	if cfg == nil {
+		return
+	}
+	switch cfg.Mode {
+	case duplicatesReject, duplicatesOff:
+		duplicates.mode = cfg.Mode
+	}
+	if cfg.MaxDistance != nil && *cfg.MaxDistance >= 0 && *cfg.MaxDistance <= 16 {
+		duplicates.maxDistance = *cfg.MaxDistance
+	}
+}
+
+// validateDuplicatesConfig reports settings loadDuplicatesConfig would
+// ignore.
+func validateDuplicatesConfig(cfg *DuplicatesConfig) error {
+	switch cfg.Mode {
+	case "", duplicatesWarn, duplicatesReject, duplicatesOff:
+	default:
+		return fmt.Errorf("mode must be warn, reject or off")
+	}
+	if cfg.MaxDistance != nil && (*cfg.MaxDistance < 0 || *cfg.MaxDistance > 16) {
+		return fmt.Errorf("max_distance must be 0-16")
+	}
+	return nil
+}
+
+// packFingerprint returns the content hash and simhash of mp. Both are empty
+// for a pack without content, and the simhash is 0 when it is too short.
+func packFingerprint(mp *MemoPack) (string, int64) {
+	parts := []string{mp.SystemPrompt}
+	for _, r := range mp.Rules {
+		parts = append(parts, r.Title, r.UpdateRule)
+	}
+	for _, m := range mp.Memos {
+		parts = append(parts, m.Title, m.Content)
+	}
+	words := strings.Fields(strings.ToLower(strings.Join(parts, "\n")))
+	if len(words) == 0 {
+		return "", 0
+	}
+	sum := sha256.Sum256([]byte(strings.Join(words, " ")))
+	if len(words) < minSimhashWords {
+		return hex.EncodeToString(sum[:]), 0
+	}
+	var weights [64]int
+	for i := 0; i+simhashShingle <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+simhashShingle], " ")))
+		v := h.Sum64()
+		for b := range weights {
+			if v&(1<<b) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+	var sim uint64
+	for b, w := range weights {
+		if w > 0 {
+			sim |= 1 << b
+		}
+	}
+	return hex.EncodeToString(sum[:]), int64(sim)
+}
+
+// simhashDistance is the number of bits two simhashes differ in.
+func simhashDistance(a, b int64) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+// checkDuplicate looks for an existing copy of pack among the packs its
+// author can see. In reject mode it writes the 409 and returns false;
+// otherwise it sets pack.DuplicateOf on a match.
+func checkDuplicate(w http.ResponseWriter, pack *MemoPack) bool {
+	if duplicates.mode == duplicatesOff {
+		return true
+	}
+	hash, sim := packFingerprint(pack)
+	if hash == "" {
+		return true
+	}
+	match, err := store.FindDuplicatePack(hash, sim, duplicates.maxDistance, pack.AuthorID, pack.ID)
+	if err != nil || match == nil {
+		return true
+	}
+	if duplicates.mode == duplicatesReject {
+		msg := "a pack with nearly the same content already exists"
+		if match.Exact {
+			msg = "a pack with the same content already exists"
+		}
+		writeJSON(w, http.StatusConflict, DuplicateConflict{Error: msg, DuplicateOf: match})
+		return false
+	}
+	pack.DuplicateOf = match
+	return true
+}
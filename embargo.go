@@ -0,0 +1,184 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// An embargo keeps a published pack out of sight until a set time, except
+// for its author, collaborators, admins and the users it names, so partner teams can
+// prepare a coordinated release. While it lasts the pack is left out of
+// listings and its pages answer 404 to everyone else. A job lifts it when
+// it ends, and only then are the publish webhooks sent.
+
+const JobEmbargoRelease = "embargo_release"
+
+const maxEmbargoViewers = 100
+
+// embargoed reports whether p is still under embargo.
+func embargoed(p *MemoPack) bool {
+	return p.EmbargoUntil != "" && p.EmbargoUntil > nowISO()
+}
+
+// packHidden reports whether p is a draft or private pack the caller can't
+// edit and holds no share link for, under an embargo that doesn't include
+// the caller, or by a deactivated account and the caller isn't an admin.
+// Handlers answer "pack not found" for hidden packs.
+func packHidden(r *http.Request, p *MemoPack) bool {
+	user := currentUser(r)
+	if store.IsUserDeactivated(p.AuthorID) {
+		return user == nil || !isAdmin(user)
+	}
+	if packReachable(p) || shareGrants(r, p) {
+		return false
+	}
+	if user == nil {
+		return true
+	}
+	if canEditPack(user, p) || isAdmin(user) {
+		return false
+	}
+	return !p.Published || p.Visibility == visibilityPrivate || !store.IsEmbargoViewer(p.ID, user.ID)
+}
+
+// validateEmbargo checks an embargo request, returning its end in nowISO
+// format and the viewers' user IDs.
+func validateEmbargo(req *EmbargoReq) (string, []string, error) {
+	t, ok := parseTimeParam(strings.TrimSpace(req.Until))
+	if !ok {
+		return "", nil, fmt.Errorf("embargo until must be a date or timestamp")
+	}
+	until := t.Format("2006-01-02T15:04:05")
+	if until <= nowISO() {
+		return "", nil, fmt.Errorf("embargo until must be in the future")
+	}
+	if len(req.Viewers) > maxEmbargoViewers {
+		return "", nil, fmt.Errorf("an embargo names at most %d viewers", maxEmbargoViewers)
+	}
+	var ids []string
+	for _, name := range req.Viewers {
+		u, err := store.GetUserByUsername(strings.TrimSpace(name))
+		if err != nil {
+			return "", nil, fmt.Errorf("embargo viewer %q not found", name)
+		}
+		ids = append(ids, u.ID)
+	}
+	return until, ids, nil
+}
+
+// startEmbargo stores an embargo on pack and schedules its release.
+func startEmbargo(pack *MemoPack, until string, viewerIDs []string) error {
+	if err := store.SetPackEmbargo(pack.ID, until, viewerIDs); err != nil {
+		return err
+	}
+	pack.EmbargoUntil = until
+	_, err := enqueueJobAt(JobEmbargoRelease, pack.AuthorID, EmbargoPayload{PackID: pack.ID, Until: until}, until)
+	return err
+}
+
+// runEmbargoRelease lifts an embargo that ended and announces the pack.
+// Embargoes changed or lifted since the job was queued are left alone.
+func runEmbargoRelease(j *Job) (string, error) {
+	var payload EmbargoPayload
+	if err := json.Unmarshal([]byte(j.Payload), &payload); err != nil {
+		return "", fmt.Errorf("bad payload: %v", err)
+	}
+	pack, err := store.GetMemoPack(payload.PackID)
+	if err != nil {
+		return "pack deleted; skipped", nil
+	}
+	if pack.EmbargoUntil != payload.Until {
+		return "embargo changed; skipped", nil
+	}
+	if err := releaseEmbargo(pack); err != nil {
+		return "", err
+	}
+	return "released", nil
+}
+
+// releaseEmbargo makes pack public and sends the publish webhooks held back
+// by the embargo.
+func releaseEmbargo(pack *MemoPack) error {
+	if err := store.SetPackEmbargo(pack.ID, "", nil); err != nil {
+		return err
+	}
+	pack.EmbargoUntil = ""
+	emitPackEvent(EventPackPublished, pack, nil)
+	return nil
+}
+
+// GET /api/memo-packs/{id}/embargo — the pack's embargo and who can see it
+// (owner only). PUT moves its end or changes the viewers; DELETE releases
+// the pack now. Embargoes are started by publishing with "embargo".
+func handlePackEmbargo(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if pack.AuthorID != user.ID && !isAdmin(user) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if !requireWritable(w, user) {
+			return
+		}
+		var req EmbargoReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		// A public pack can't be taken back; embargoes start at publish.
+		if !embargoed(pack) {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is already public"})
+			return
+		}
+		until, viewers, err := validateEmbargo(&req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if err := startEmbargo(pack, until, viewers); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to set embargo"})
+			return
+		}
+		recordAudit(r, user, auditPackEmbargo, "pack", pack.ID, "until "+until)
+	case http.MethodDelete:
+		if !requireWritable(w, user) {
+			return
+		}
+		if !embargoed(pack) {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is not under embargo"})
+			return
+		}
+		if err := releaseEmbargo(pack); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to release embargo"})
+			return
+		}
+		recordAudit(r, user, auditPackRelease, "pack", pack.ID, "")
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	e := PackEmbargo{Viewers: []string{}}
+	if embargoed(pack) {
+		e.Until = pack.EmbargoUntil
+		viewers, err := store.ListEmbargoViewers(pack.ID)
+		if err != nil {
+			log.Printf("embargo viewers of %s: %v", pack.ID, err)
+		}
+		e.Viewers = append(e.Viewers, viewers...)
+	}
+	writeJSON(w, http.StatusOK, e)
+}
@@ -0,0 +1,64 @@
+package memomarket
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Admins feature packs to showcase on the marketplace homepage.
+// GET /api/memo-packs?featured=true lists the featured packs anyone can see,
+// the most recently featured first unless ?sort= says otherwise. Only
+// published public packs can be featured; one that is later made unlisted or
+// private drops out of the listing but stays featured until an admin
+// unfeatures it.
+
+// GET /api/admin/featured — every featured pack, whatever its visibility now
+// (admin only).
+func handleAdminFeaturedList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	packs, err := store.ListFeaturedPacks()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list featured packs"})
+		return
+	}
+	writeJSON(w, http.StatusOK, packs)
+}
+
+// PUT /api/admin/featured/{id} — feature a pack. DELETE unfeatures it
+// (admin only).
+func handleAdminFeatured(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	pack, err := store.GetMemoPack(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/featured/"), "/"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	action, at := auditPackUnfeature, ""
+	if r.Method == http.MethodPut {
+		if !pack.Published || pack.Visibility != visibilityPublic || embargoed(pack) {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "only published public packs can be featured"})
+			return
+		}
+		if pack.Featured {
+			writeJSON(w, http.StatusOK, pack)
+			return
+		}
+		action, at = auditPackFeature, nowISO()
+	} else if !pack.Featured {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is not featured"})
+		return
+	}
+	if err := store.SetPackFeatured(pack.ID, at); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update pack"})
+		return
+	}
+	recordAudit(r, currentUser(r), action, "pack", pack.ID, "")
+	pack.FeaturedAt, pack.Featured = at, at != ""
+	writeJSON(w, http.StatusOK, pack)
+}
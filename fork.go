@@ -0,0 +1,122 @@
+package memomarket
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Forking copies someone else's public pack into the caller's account as a
+// new pack at version 1.0.0 whose forked_from names the original. The fork
+// takes the content a plain download serves, so the stable version of a
+// pack on the beta channel. The original shows how many published forks it
+// has as fork_count, and its author is notified of each one.
+
+// POST /api/memo-packs/{id}/fork — copy a public pack into my account; the
+// body may rename it or keep it as a draft (auth required).
+func handleForkPack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	if user.ID == anonymousUserID {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "forks need an account"})
+		return
+	}
+	orig, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, orig) || !packReachable(orig) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if orig.AuthorID == user.ID {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "you can't fork your own pack"})
+		return
+	}
+	var req ForkPackReq
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+	}
+	v, err := channelVersion(orig, "")
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if v != nil {
+		applyPackVersion(orig, v)
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = orig.Name
+	}
+	if err := checkPackNamePolicy(name, user.ID); err != nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !checkTrust(w, user, true, orig.Description) {
+		return
+	}
+
+	now := nowISO()
+	pack := &MemoPack{
+		ID:           newPackID(),
+		Name:         name,
+		Description:  orig.Description,
+		AuthorID:     user.ID,
+		AuthorName:   user.Username,
+		SystemPrompt: orig.SystemPrompt,
+		Rules:        orig.Rules,
+		Memos:        orig.Memos,
+		Evals:        orig.Evals,
+		Version:      "1.0.0",
+		Language:     orig.Language,
+		Category:     orig.Category,
+		RequireAuth:  orig.RequireAuth,
+		ForkedFrom:   orig.ID,
+		Published:    !req.Draft,
+		Status:       packStatus(!req.Draft),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if pack.Rules == nil {
+		pack.Rules = []MemoRule{}
+	}
+	if pack.Memos == nil {
+		pack.Memos = []Memo{}
+	}
+	if pack.Evals == nil {
+		pack.Evals = []PackEval{}
+	}
+	normalizeItemTags(pack)
+	if !checkQuota(w, user, pack, true) {
+		return
+	}
+	// The fork gets its own copies, so the original's author can still
+	// remove theirs.
+	pack.Memos = slices.Clone(pack.Memos)
+	if err := copyAttachments(pack, user.ID); err != nil {
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "failed to copy attachments"})
+		return
+	}
+	if err := store.InsertMemoPack(pack); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to fork pack"})
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackFork, TargetKind: "pack", TargetID: pack.ID, Diff: "from " + orig.ID})
+	if !req.Draft {
+		emitPackEvent(EventPackPublished, pack, nil)
+		notify(orig.AuthorID, "fork", orig.ID, fmt.Sprintf("%s forked %s", user.Username, orig.Name))
+	}
+	writeJSON(w, http.StatusCreated, pack)
+}
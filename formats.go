@@ -0,0 +1,116 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Packs can be read and written as YAML or TOML as well as JSON. GET
+// /api/memo-packs/{id} and the download answer in the format ?format= names,
+// or else the one the Accept header asks for. Publishes and full updates take
+// a body in the format its Content-Type names. Either way the document has
+// the same fields as the JSON.
+
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatTOML = "toml"
+)
+
+var formatContentTypes = map[string]string{
+	formatJSON: "application/json",
+	formatYAML: "application/yaml",
+	formatTOML: "application/toml",
+}
+
+// bodyFormats maps the media types a pack body is accepted in to their
+// format.
+var bodyFormats = map[string]string{
+	"application/yaml":   formatYAML,
+	"application/x-yaml": formatYAML,
+	"text/yaml":          formatYAML,
+	"application/toml":   formatTOML,
+}
+
+// responseFormat picks the format of a pack response from ?format=, or the
+// Accept header when that names YAML or TOML.
+func responseFormat(r *http.Request) (string, error) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		if _, ok := formatContentTypes[f]; !ok {
+			return "", errors.New("format must be json, yaml or toml")
+		}
+		return f, nil
+	}
+	for _, mediaType := range []string{"application/yaml", "application/x-yaml", "text/yaml", "application/toml"} {
+		if acceptsMediaType(r, mediaType) {
+			return bodyFormats[mediaType], nil
+		}
+	}
+	return formatJSON, nil
+}
+
+// servePackFormat serves v in format, like serveJSONContent does JSON.
+func servePackFormat(w http.ResponseWriter, r *http.Request, format string, v any, modTime time.Time) {
+	var data []byte
+	var err error
+	switch format {
+	case formatYAML:
+		data, err = encodeYAML(v)
+	case formatTOML:
+		data, err = encodeTOML(v)
+	default:
+		serveJSONContent(w, r, v, modTime)
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to encode response"})
+		return
+	}
+	serveBytes(w, r, formatContentTypes[format], data, modTime)
+}
+
+// formatError is a pack body that isn't valid in the format it was sent in.
+type formatError struct {
+	format string
+	err    error
+}
+
+func (e *formatError) Error() string {
+	return "invalid " + strings.ToUpper(e.format) + ": " + strings.TrimPrefix(e.err.Error(), "json: ")
+}
+
+// decodePackBody decodes a pack write into v from JSON, or from YAML or
+// TOML when the Content-Type names one of them.
+func decodePackBody(r *http.Request, v any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	format, ok := bodyFormats[mediaType]
+	if !ok {
+		return decodeJSON(r, v)
+	}
+	defer r.Body.Close()
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if format == formatYAML {
+		doc, err = parseYAML(data)
+	} else {
+		doc, err = parseTOML(data)
+	}
+	if err != nil {
+		return &formatError{format, err}
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return &formatError{format, err}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return &formatError{format, err}
+	}
+	return nil
+}
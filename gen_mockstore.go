@@ -0,0 +1,111 @@
+//go:build ignore
+
+// gen_mockstore writes mockstore.go from the Store interface in store.go.
+// Run it with go generate after changing Store.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "store.go", nil, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var store *ast.InterfaceType
+	ast.Inspect(f, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok && ts.Name.Name == "Store" {
+			store, _ = ts.Type.(*ast.InterfaceType)
+		}
+		return store == nil
+	})
+	if store == nil {
+		log.Fatal("store.go: no Store interface")
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`// Code generated by gen_mockstore.go; DO NOT EDIT.
+
+package memomarket
+
+// MockStore is a Store for tests. Each method calls its Func field when it
+// is set and the embedded Store otherwise, so a test can fake or fail the
+// calls it cares about over a real store:
+//
+//	st, _ := memomarket.NewMemoryStore()
+//	mock := &memomarket.MockStore{Store: st}
+//	mock.GetMemoPackFunc = func(id string) (*memomarket.MemoPack, error) {
+//		return nil, errors.New("disk full")
+//	}
+//	srv, _ := memomarket.NewServer(mock, memomarket.ServerOptions{})
+//
+// With no Store, a call without a Func panics. Set the Func fields before
+// the server uses the mock.
+type MockStore struct {
+	Store
+
+`)
+	var methods bytes.Buffer
+	for _, m := range store.Methods.List {
+		fn, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) != 1 {
+			log.Fatalf("Store: unexpected member %s", expr(fset, m.Type))
+		}
+		name := m.Names[0].Name
+		sig := strings.TrimPrefix(expr(fset, fn), "func")
+		fmt.Fprintf(&b, "\t%sFunc func%s\n", name, sig)
+
+		var args []string
+		for _, p := range fn.Params.List {
+			for _, n := range p.Names {
+				arg := n.Name
+				if _, ok := p.Type.(*ast.Ellipsis); ok {
+					arg += "..."
+				}
+				args = append(args, arg)
+			}
+			if len(p.Names) == 0 {
+				log.Fatalf("Store.%s: unnamed parameter", name)
+			}
+		}
+		call := strings.Join(args, ", ")
+		ret := "return "
+		if fn.Results == nil {
+			ret = ""
+		}
+		fmt.Fprintf(&methods, "\nfunc (m *MockStore) %s%s {\n", name, sig)
+		fmt.Fprintf(&methods, "\tif m.%sFunc != nil {\n\t\t%sm.%sFunc(%s)\n", name, ret, name, call)
+		if ret == "" {
+			methods.WriteString("\t\treturn\n")
+		}
+		fmt.Fprintf(&methods, "\t}\n\t%sm.Store.%s(%s)\n}\n", ret, name, call)
+	}
+	b.WriteString("}\n")
+	b.Write(methods.Bytes())
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalf("format: %v\n%s", err, b.Bytes())
+	}
+	if err := os.WriteFile("mockstore.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func expr(fset *token.FileSet, n ast.Node) string {
+	var b bytes.Buffer
+	if err := format.Node(&b, fset, n); err != nil {
+		log.Fatal(err)
+	}
+	return b.String()
+}
@@ -0,0 +1,228 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// A GitHub import normally reads memopack.json, or else .cursorrules,
+// CLAUDE.md and AGENTS.md. A mapping instead names which files to read and
+// what they become, so prompt collections in any layout can be onboarded:
+//
+//	[{"pattern": "prompts/**/*.md", "target": "memos", "split": "file"},
+//	 {"pattern": ".cursorrules", "target": "rules"}]
+//
+// Patterns are matched against the repository tree below the import path,
+// and "**" spans any number of directories. A file goes to the first
+// mapping that matches it. The mapping is kept in the pack's provenance so
+// a sync reads the same files.
+
+const (
+	mappingSystemPrompt = "system_prompt"
+	mappingRules        = "rules"
+	mappingMemos        = "memos"
+)
+
+const (
+	mappingSplitHeadings = "headings"
+	mappingSplitFile     = "file"
+)
+
+const (
+	maxImportMappings = 20
+	maxImportFiles    = 100
+)
+
+// validateImportMapping checks and normalizes a mapping.
+func validateImportMapping(mapping []ImportFileMapping) error {
+	if len(mapping) > maxImportMappings {
+		return fmt.Errorf("a mapping has at most %d entries", maxImportMappings)
+	}
+	for i := range mapping {
+		m := &mapping[i]
+		m.Pattern = strings.Trim(strings.TrimSpace(m.Pattern), "/")
+		if m.Pattern == "" {
+			return fmt.Errorf("mapping[%d]: pattern is required", i)
+		}
+		if _, err := path.Match(m.Pattern, ""); err != nil {
+			return fmt.Errorf("mapping[%d]: invalid pattern %q", i, m.Pattern)
+		}
+		switch m.Target {
+		case mappingSystemPrompt, mappingRules, mappingMemos:
+		default:
+			return fmt.Errorf("mapping[%d]: target must be system_prompt, rules or memos", i)
+		}
+		switch m.Split {
+		case "":
+			m.Split = mappingSplitHeadings
+		case mappingSplitHeadings, mappingSplitFile:
+		default:
+			return fmt.Errorf("mapping[%d]: split must be headings or file", i)
+		}
+		m.Tags = normalizeTags(m.Tags)
+	}
+	return nil
+}
+
+// matchGlob reports whether name matches pattern, where a "**" segment
+// matches any number of directories.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// listGitHubTree returns the paths of the files in repo at commit below
+// dir, relative to dir.
+func listGitHubTree(repo, commit, dir string) ([]string, error) {
+	req, _ := http.NewRequest(http.MethodGet, githubAPIBase+"/repos/"+repo+"/git/trees/"+commit+"?recursive=1", nil)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := githubClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("github request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github returned %s listing files", resp.Status)
+	}
+	var out struct {
+		Tree []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+		} `json:"tree"`
+		Truncated bool `json:"truncated"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 16*maxImportFileSize)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("could not read the repository tree")
+	}
+	if out.Truncated {
+		return nil, fmt.Errorf("repository is too large to list; import a subdirectory with path")
+	}
+	var files []string
+	for _, e := range out.Tree {
+		if e.Type != "blob" {
+			continue
+		}
+		if dir == "" {
+			files = append(files, e.Path)
+		} else if rel, ok := strings.CutPrefix(e.Path, dir+"/"); ok {
+			files = append(files, rel)
+		}
+	}
+	return files, nil
+}
+
+// fetchMappedGitHubPack reads the files prov.Mapping names at commit.
+// prov.Files is filled in.
+func fetchMappedGitHubPack(prov *PackProvenance, commit string) (*PublishMemoPackReq, error) {
+	tree, err := listGitHubTree(prov.Repo, commit, prov.Path)
+	if err != nil {
+		return nil, err
+	}
+	type mappedFile struct {
+		name string
+		m    *ImportFileMapping
+	}
+	var files []mappedFile
+	for _, name := range tree {
+		for i := range prov.Mapping {
+			if matchGlob(prov.Mapping[i].Pattern, name) {
+				files = append(files, mappedFile{name, &prov.Mapping[i]})
+				break
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files in the repository match the mapping")
+	}
+	if len(files) > maxImportFiles {
+		return nil, fmt.Errorf("the mapping matches %d files; at most %d can be imported", len(files), maxImportFiles)
+	}
+	content := &PublishMemoPackReq{}
+	for _, f := range files {
+		data, ok, err := fetchGitHubFile(prov.Repo, commit, path.Join(prov.Path, f.name))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		prov.Files = append(prov.Files, f.name)
+		applyFileMapping(content, f.m, f.name, string(data))
+	}
+	return content, nil
+}
+
+// applyFileMapping adds one file to content as m says.
+func applyFileMapping(content *PublishMemoPackReq, m *ImportFileMapping, name, text string) {
+	text = strings.TrimSpace(text)
+	if m.Target == mappingSystemPrompt {
+		if text != "" {
+			if content.SystemPrompt != "" {
+				content.SystemPrompt += "\n\n"
+			}
+			content.SystemPrompt += text
+		}
+		return
+	}
+	var sections []markdownSection
+	if m.Split == mappingSplitFile {
+		sections = []markdownSection{{title: fileTitle(name, text), body: text}}
+	} else {
+		preamble, found := splitMarkdownSections(text)
+		if preamble != "" {
+			sections = append(sections, markdownSection{title: fileTitle(name, ""), body: preamble})
+		}
+		sections = append(sections, found...)
+	}
+	for _, sec := range sections {
+		if m.Target == mappingRules {
+			content.Rules = append(content.Rules, MemoRule{Title: sec.title, UpdateRule: sec.body, Tags: slices.Clone(m.Tags)})
+		} else {
+			content.Memos = append(content.Memos, Memo{Title: sec.title, Content: sec.body, Tags: slices.Clone(m.Tags)})
+		}
+	}
+}
+
+// fileTitle is the first level 1 heading of text, or else the file name
+// without its extension, with dashes and underscores as spaces.
+func fileTitle(name, text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if t, ok := strings.CutPrefix(strings.TrimSpace(line), "# "); ok {
+			return strings.TrimSpace(t)
+		}
+	}
+	base := path.Base(name)
+	if ext := path.Ext(base); ext != base {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return strings.TrimSpace(strings.NewReplacer("-", " ", "_", " ").Replace(base))
+}
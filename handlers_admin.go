@@ -0,0 +1,243 @@
+package memomarket
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// User roles. Admins can edit and delete any pack and manage users. The
+// anonymous role belongs only to the system user owning anonymous packs.
+const (
+	roleUser      = "user"
+	roleAdmin     = "admin"
+	roleAnonymous = "anonymous"
+)
+
+// Usernames of channel operators, from config.json. They are admins whatever
+// their role, so a channel always has a way in.
+var adminUsernames []string
+
+// adminIDs holds the IDs of the accounts adminUsernames named when the
+// server started. Matching by ID keeps a configured name that had no
+// account yet from making whoever registers it first an admin.
+var adminIDs map[string]bool
+
+// resolveAdmins looks up the accounts of adminUsernames, warning about
+// names nobody has registered. Those become admins at the next start after
+// they register.
+func resolveAdmins() {
+	adminIDs = make(map[string]bool, len(adminUsernames))
+	for _, name := range adminUsernames {
+		user, err := store.GetUserByUsername(name)
+		if err != nil {
+			log.Printf("Warning: admin %q has no account; register it and restart to grant admin", name)
+			continue
+		}
+		adminIDs[user.ID] = true
+	}
+}
+
+func isAdmin(user *User) bool {
+	return user != nil && (user.Role == roleAdmin || adminIDs[user.ID])
+}
+
+// configuredAdminName reports whether config.json lists username as an
+// admin. It grants nothing by itself; see resolveAdmins.
+func configuredAdminName(username string) bool {
+	return slices.ContainsFunc(adminUsernames, func(name string) bool {
+		return strings.EqualFold(name, username)
+	})
+}
+
+// adminMiddleware is authMiddleware for admin-only routes.
+func adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		next(w, r)
+	})
+}
+
+// requireAdmin writes a 401/403 and returns false unless the caller is an admin.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return false
+	}
+	if !isAdmin(user) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "admin only"})
+		return false
+	}
+	return true
+}
+
+// GET /api/admin/legal-holds — list packs and users under legal hold (admin only).
+func handleLegalHolds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	holds, err := store.ListLegalHolds()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list legal holds"})
+		return
+	}
+	writeJSON(w, http.StatusOK, holds)
+}
+
+// GET /api/admin/users — list users, optionally ?role=admin (admin only).
+func handleAdminUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	q := parseListQuery(r)
+	users, total, err := store.ListUsers(r.URL.Query().Get("role"), q.Page, q.Limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list users"})
+		return
+	}
+	writeJSON(w, http.StatusOK, ListResponse{Items: users, Total: total, Page: q.Page, Limit: q.Limit})
+}
+
+// /api/admin/users/{id}/... — manage one user (admin only).
+func handleAdminUser(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/users/"), "/")
+	switch action {
+	case "role":
+		handleAdminUserRole(w, r, id)
+	case "trust":
+		handleAdminUserTrust(w, r, id)
+	case "ban":
+		handleAdminUserBan(w, r, id)
+	case "deactivate":
+		handleAdminUserDeactivate(w, r, id)
+	case "oidc":
+		handleAdminUserOIDC(w, r, id)
+	default:
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+	}
+}
+
+// PUT /api/admin/users/{id}/role — make a user an admin or take it away; a
+// reason is required (admin only).
+func handleAdminUserRole(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	admin := currentUser(r)
+	var req UserRoleReq
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if req.Role != roleUser && req.Role != roleAdmin {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "role must be user or admin"})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := validateAdminReason(req.Reason); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if id == admin.ID {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "you can't change your own role"})
+		return
+	}
+	if id == anonymousUserID {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "the anonymous user's role can't change"})
+		return
+	}
+	found, err := store.SetUserRole(id, req.Role)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update role"})
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user not found"})
+		return
+	}
+	recordAudit(r, admin, auditUserRole+req.Role, "user", id, req.Reason)
+	user, err := store.GetUserByID(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load user"})
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// PUT /api/admin/legal-holds/{packs|users}/{id} — place a legal hold (admin only).
+// DELETE releases it. Held packs, and all packs of held users, cannot be
+// deleted and are skipped by the cleanup job. Holds are audited but the
+// owner is not notified: a hold may be confidential.
+func handleLegalHold(w http.ResponseWriter, r *http.Request) {
+	kind, id, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/legal-holds/"), "/")
+	table := map[string]string{"packs": "memo_packs", "users": "users"}[kind]
+	if table == "" || id == "" {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+		return
+	}
+
+	var held bool
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	switch r.Method {
+	case http.MethodPut:
+		held = true
+		if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+	case http.MethodDelete:
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	found, err := store.SetLegalHold(table, id, held, strings.TrimSpace(req.Reason))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update legal hold"})
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: strings.TrimSuffix(kind, "s") + " not found"})
+		return
+	}
+	action := auditLegalLift
+	if held {
+		action = auditLegalHold
+	}
+	recordAudit(r, currentUser(r), action, strings.TrimSuffix(kind, "s"), id, strings.TrimSpace(req.Reason))
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "legal_hold": held})
+}
+
+// POST /api/admin/cleanup — queue a retention cleanup run now; a reason is
+// required (admin only).
+func handleAdminCleanup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	reason, err := adminReason(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if err := validateAdminReason(reason); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	job, err := enqueueJob(JobCleanup, "", nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to queue cleanup"})
+		return
+	}
+	recordAudit(r, currentUser(r), auditCleanup, "job", job.ID, reason)
+	writeJSON(w, http.StatusAccepted, job)
+}
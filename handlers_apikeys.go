@@ -0,0 +1,214 @@
+package memomarket
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// API keys let bots act for a user without the account's login tokens. A key
+// carries scopes: read for GET requests, publish for writing packs, and
+// manage for everything else (deleting packs, webhooks, account settings,
+// admin endpoints).
+
+const (
+	scopeRead    = "read"
+	scopePublish = "publish"
+	scopeManage  = "manage"
+)
+
+var apiKeyScopes = []string{scopeRead, scopePublish, scopeManage}
+
+// apiKeyPrefix marks bearer tokens that are API keys rather than session tokens.
+const apiKeyPrefix = "mmk_"
+
+const (
+	maxAPIKeysPerUser   = 20
+	maxAPIKeyNameLength = 100
+)
+
+func newAPIKeyValue() string {
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	return apiKeyPrefix + hex.EncodeToString(secret)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// hasScope reports whether u may act with scope. Session logins hold every
+// scope.
+func (u *User) hasScope(scope string) bool {
+	return u.APIKeyID == "" || slices.Contains(u.Scopes, scope)
+}
+
+// managedPackPaths are the pack routes that change who can reach or own a
+// pack rather than its content; writing to them takes the manage scope.
+var managedPackPaths = []string{"/collaborators", "/share-links", "/embargo", "/claim", "/archive"}
+
+// requiredScope is the scope an API key needs for r.
+func requiredScope(r *http.Request) string {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/admin/"):
+		return scopeManage
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return scopeRead
+	case strings.HasPrefix(r.URL.Path, "/api/memo-packs/") && slices.ContainsFunc(managedPackPaths, func(p string) bool {
+		return strings.Contains(r.URL.Path, p)
+	}):
+		return scopeManage
+	case strings.HasPrefix(r.URL.Path, "/api/memo-packs") && r.Method != http.MethodDelete && !strings.Contains(r.URL.Path, "/webhooks"),
+		strings.HasPrefix(r.URL.Path, "/api/me/memo-packs/"):
+		return scopePublish
+	default:
+		return scopeManage
+	}
+}
+
+// authenticate resolves a bearer token, which is either a session's access
+// token or an API key. Tokens of suspended accounts give
+// errAccountSuspended along with the user.
+func authenticate(token string) (*User, error) {
+	var user *User
+	var err error
+	if strings.HasPrefix(token, apiKeyPrefix) {
+		user, err = store.GetUserByAPIKey(hashAPIKey(token))
+	} else {
+		user, err = store.GetUserByToken(token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := store.GetUserBan(user.ID); err == nil {
+		return user, errAccountSuspended
+	}
+	return user, nil
+}
+
+// requireLogin rejects requests made with an API key, so a leaked key can't
+// mint more keys or revoke the owner's logins.
+func requireLogin(w http.ResponseWriter, user *User) bool {
+	if user.APIKeyID != "" {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not allowed with an API key"})
+		return false
+	}
+	return true
+}
+
+// validateAPIKeyReq normalizes the requested name and scopes.
+func validateAPIKeyReq(req *APIKeyReq) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "name is required"
+	}
+	if utf8.RuneCountInString(req.Name) > maxAPIKeyNameLength {
+		return "name is too long"
+	}
+	if len(req.Scopes) == 0 {
+		return "at least one scope is required"
+	}
+	var scopes []string
+	for _, s := range req.Scopes {
+		if !slices.Contains(apiKeyScopes, s) {
+			return "unknown scope " + s + "; use read, publish or manage"
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	req.Scopes = scopes
+	return ""
+}
+
+// GET /api/me/api-keys — list my API keys; POST creates one and returns the
+// key once (login required, not usable with an API key).
+func handleMyAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireLogin(w, user) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := store.ListAPIKeys(user.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list API keys"})
+			return
+		}
+		writeJSON(w, http.StatusOK, keys)
+	case http.MethodPost:
+		var req APIKeyReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		if msg := validateAPIKeyReq(&req); msg != "" {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: msg})
+			return
+		}
+		existing, err := store.ListAPIKeys(user.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create API key"})
+			return
+		}
+		if len(existing) >= maxAPIKeysPerUser {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "too many API keys; revoke one first"})
+			return
+		}
+		key := newAPIKeyValue()
+		k := &APIKey{
+			ID:        newID(),
+			UserID:    user.ID,
+			Name:      req.Name,
+			Prefix:    key[:len(apiKeyPrefix)+8],
+			Scopes:    req.Scopes,
+			CreatedAt: nowISO(),
+		}
+		if err := store.CreateAPIKey(k, hashAPIKey(key)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create API key"})
+			return
+		}
+		recordAudit(r, user, auditAPIKeyCreate, "api_key", k.ID, "")
+		k.Key = key
+		writeJSON(w, http.StatusCreated, k)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// DELETE /api/me/api-keys/{id} — revoke an API key (login required).
+func handleMyAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireLogin(w, user) {
+		return
+	}
+	id := extractID(r.URL.Path, "/api/me/api-keys/")
+	deleted, err := store.DeleteAPIKey(id, user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to revoke API key"})
+		return
+	}
+	if !deleted {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "API key not found"})
+		return
+	}
+	recordAudit(r, user, auditAPIKeyRevoke, "api_key", id, "")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
@@ -0,0 +1,36 @@
+package memomarket
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method, path, want string
+	}{
+		{"GET", "/api/memo-packs", scopeRead},
+		{"GET", "/api/memo-packs/p1/collaborators", scopeRead},
+		{"HEAD", "/api/memo-packs/p1/download", scopeRead},
+		{"POST", "/api/memo-packs", scopePublish},
+		{"PUT", "/api/memo-packs/p1", scopePublish},
+		{"PATCH", "/api/memo-packs/p1", scopePublish},
+		{"PUT", "/api/memo-packs/p1/evals", scopePublish},
+		{"PUT", "/api/me/memo-packs/p1/tags", scopePublish},
+		{"DELETE", "/api/memo-packs/p1", scopeManage},
+		{"POST", "/api/memo-packs/p1/webhooks", scopeManage},
+		{"PUT", "/api/memo-packs/p1/collaborators/bob", scopeManage},
+		{"POST", "/api/memo-packs/p1/share-links", scopeManage},
+		{"PUT", "/api/memo-packs/p1/embargo", scopeManage},
+		{"POST", "/api/memo-packs/p1/claim", scopeManage},
+		{"POST", "/api/memo-packs/p1/archive", scopeManage},
+		{"GET", "/api/admin/users", scopeManage},
+		{"POST", "/api/me/keys", scopeManage},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := requiredScope(r); got != tt.want {
+			t.Errorf("%s %s: scope %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
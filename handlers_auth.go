@@ -1,11 +1,55 @@
-package main
+package memomarket
 
 import (
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Access tokens are short-lived; the refresh token issued alongside them
+// obtains new ones without the password.
+const (
+	accessTokenTTL  = time.Hour
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// maxUserAgentChars bounds the client description stored with a session.
+const maxUserAgentChars = 200
+
+func tokenExpiry(ttl time.Duration) string {
+	return time.Now().UTC().Add(ttl).Format("2006-01-02T15:04:05")
+}
+
+// newSessionTokens gives s a fresh access and refresh token.
+func newSessionTokens(s *Session) {
+	s.Token = uuid.New().String()
+	s.ExpiresAt = tokenExpiry(accessTokenTTL)
+	s.RefreshToken = uuid.New().String()
+	s.RefreshExpiresAt = tokenExpiry(refreshTokenTTL)
+}
+
+// issueSession starts a new session for u, so every login holds its own
+// token and can be revoked on its own, and puts the tokens on u.
+func issueSession(u *User, r *http.Request) error {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentChars {
+		ua = strings.ToValidUTF8(ua[:maxUserAgentChars], "")
+	}
+	now := nowISO()
+	s := &Session{ID: newID(), UserID: u.ID, UserAgent: ua, CreatedAt: now, LastUsedAt: now}
+	newSessionTokens(s)
+	if err := store.CreateSession(s); err != nil {
+		return err
+	}
+	u.Token, u.TokenExpiresAt = s.Token, s.ExpiresAt
+	u.RefreshToken, u.RefreshExpiresAt = s.RefreshToken, s.RefreshExpiresAt
+	return nil
+}
+
 // POST /api/register — create a new user with username/password, returns token.
 func handleRegister(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -22,6 +66,14 @@ func handleRegister(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "username is required"})
 		return
 	}
+	if err := validateUsername(req.Username); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := checkUsernamePolicy(req.Username); err != nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
 	if req.Password == "" {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "password is required"})
 		return
@@ -33,11 +85,32 @@ func handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := CreateUser(req.Username, string(hash))
+	invite := ""
+	if inviteOnly && !configuredAdminName(req.Username) {
+		if invite = strings.TrimSpace(req.InviteCode); !useInvite(w, invite) {
+			return
+		}
+	}
+	user, err := store.CreateUser(req.Username, string(hash))
 	if err != nil {
+		if invite != "" {
+			store.ReleaseInvite(invite)
+		}
 		writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
 		return
 	}
+	if err := issueSession(user, r); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to issue token"})
+		return
+	}
+	entry := &AuditEntry{Action: auditRegister, TargetKind: "user", TargetID: user.ID}
+	if invite != "" {
+		entry.Diff = "invite " + invite
+	}
+	writeAudit(r, user, entry)
+	if req.Cookie {
+		setSessionCookies(w, r, user)
+	}
 	writeJSON(w, http.StatusCreated, user)
 }
 
@@ -58,22 +131,111 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := GetUserByUsername(req.Username)
+	user, err := store.GetUserByUsername(req.Username)
 	if err != nil {
 		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid username or password"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		recordAudit(r, user, auditLoginFailed, "user", user.ID, "")
 		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid username or password"})
 		return
 	}
+	if ban, err := store.GetUserBan(user.ID); err == nil {
+		writeSuspended(w, ban)
+		return
+	}
+	if user.DeactivatedBy != "" {
+		if !req.Reactivate || user.DeactivatedBy != user.ID {
+			writeDeactivated(w, user)
+			return
+		}
+		if _, err := store.ReactivateUser(user.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to reactivate account"})
+			return
+		}
+		user.DeactivatedBy = ""
+		recordAudit(r, user, auditUserReactivate, "user", user.ID, "")
+	}
+
+	if err := issueSession(user, r); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to issue token"})
+		return
+	}
+	recordAudit(r, user, auditLogin, "user", user.ID, "")
+	if req.Cookie {
+		setSessionCookies(w, r, user)
+	}
 
 	// Clear hash before responding
 	user.PasswordHash = ""
 	writeJSON(w, http.StatusOK, user)
 }
 
+// POST /api/token/refresh — exchange a refresh token for a fresh access token
+// (public). Logins kept in cookies send no body; their refresh cookie is used
+// and both cookies are renewed.
+func handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	var req RefreshTokenReq
+	if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	fromCookie := false
+	if c, err := r.Cookie(refreshCookie); err == nil && c.Value != "" && req.RefreshToken == "" {
+		if !validCSRF(r) {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "missing or invalid CSRF token"})
+			return
+		}
+		req.RefreshToken, fromCookie = c.Value, true
+	}
+	if req.RefreshToken == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "refresh_token is required"})
+		return
+	}
+	// Both tokens rotate, so a leaked refresh token works at most once.
+	session, err := store.GetSessionByRefreshToken(req.RefreshToken)
+	if err != nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid or expired refresh token"})
+		return
+	}
+	if ban, err := store.GetUserBan(session.UserID); err == nil {
+		writeSuspended(w, ban)
+		return
+	}
+	newSessionTokens(session)
+	session.LastUsedAt = nowISO()
+	rotated, err := store.RotateSessionTokens(session, req.RefreshToken)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to issue token"})
+		return
+	}
+	if !rotated {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid or expired refresh token"})
+		return
+	}
+	if owner, err := store.GetUserByID(session.UserID); err == nil {
+		recordAudit(r, owner, auditTokenRotate, "session", session.ID, "")
+	}
+	resp := TokenResponse{
+		Token:            session.Token,
+		TokenExpiresAt:   session.ExpiresAt,
+		RefreshToken:     session.RefreshToken,
+		RefreshExpiresAt: session.RefreshExpiresAt,
+	}
+	if fromCookie {
+		resp.CSRFToken = newCSRFToken()
+		writeSessionCookies(w, r, resp.Token, resp.RefreshToken, resp.RefreshExpiresAt, resp.CSRFToken)
+		resp.Token, resp.RefreshToken = "", ""
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
 // GET /api/me — get current user info.
 func handleMe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -85,5 +247,12 @@ func handleMe(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
 		return
 	}
+	if verified, err := store.ListVerifiedIdentities([]string{user.ID}); err == nil {
+		user.Verified = verified[user.ID]
+	}
+	if v, err := store.GetVacation(user.ID); err == nil && v.EndsAt > nowISO() {
+		v.Active = v.active()
+		user.Vacation = v
+	}
 	writeJSON(w, http.StatusOK, user)
 }
@@ -0,0 +1,147 @@
+package memomarket
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+)
+
+// apiVersion is bumped when response shapes change incompatibly.
+const apiVersion = "1"
+
+// GET /api/capabilities — features, formats and limits enabled on this node (public).
+func handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	setCacheHeaders(w, cacheInfo, false)
+	writeJSON(w, http.StatusOK, buildCapabilities())
+}
+
+func buildCapabilities() Capabilities {
+	// "relevance" applies to searches only and is their default.
+	sorts := []string{"relevance"}
+	for s := range listSortColumns {
+		sorts = append(sorts, s)
+	}
+	sort.Strings(sorts)
+
+	return Capabilities{
+		APIVersion: apiVersion,
+		Features: map[string]bool{
+			"versions":             true,
+			"version_ranges":       true,
+			"channels":             true,
+			"deprecations":         true,
+			"localization":         true,
+			"subscriptions":        true,
+			"notifications":        true,
+			"webhooks":             true,
+			"github_import":        true,
+			"evals":                true,
+			"eval_runs":            llmConfig.APIURL != "",
+			"download_stats":       true,
+			"install_stats":        true,
+			"milestones":           true,
+			"range_requests":       true,
+			"streaming":            true,
+			"naming_policy":        namingPolicy.usernames.active() || namingPolicy.packs.active(),
+			"mcp":                  true,
+			"account_export":       true,
+			"library_search":       true,
+			"reviews":              true,
+			"stars":                true,
+			"verification":         true,
+			"receipts":             true,
+			"vacation_mode":        true,
+			"tag_policy":           true,
+			"audit_log":            true,
+			"user_roles":           true,
+			"short_ids":            idConfig.strategy == idStrategyShort,
+			"manifest":             true,
+			"anonymous_publishing": anonPublishing.Enabled,
+			"collections":          true,
+			"trust_levels":         true,
+			"embargoes":            true,
+			"collaborators":        true,
+			"gob_streams":          true,
+			"user_bans":            true,
+			"deactivation":         true,
+			"drafts":               true,
+			"archiving":            true,
+			"forks":                true,
+			"covers":               true,
+			"categories":           true,
+			"duplicate_detection":  duplicates.mode != duplicatesOff,
+			"attachments":          true,
+			"visibility":           true,
+			"share_links":          true,
+			"clone":                true,
+			"batch_publish":        true,
+			"merge_patch":          true,
+			"url_import":           true,
+			"zip_export":           true,
+			"yaml_toml":            true,
+			"rule_file_export":     true,
+			"prompt_export":        true,
+			"templates":            true,
+			"pinned_packs":         true,
+			"featured_packs":       true,
+			"related_packs":        true,
+			"invite_only":          inviteOnly,
+			"service_accounts":     true,
+			"quotas":               quotas != QuotaConfig{},
+			"oidc":                 oidcConfig != nil,
+			"email_notices":        smtpConfig != nil,
+			"federation":           false,
+		},
+		Formats: capabilityFormats(),
+		Auth:    capabilityAuth(),
+		Sorts:   sorts,
+		Webhooks: WebhookCaps{
+			Kinds:  slices.Clone(webhookKinds),
+			Events: slices.Clone(webhookEvents),
+		},
+		RuleSchema: RuleSchemaCaps{
+			Version:     ruleSchemaVersion,
+			Triggers:    slices.Clone(ruleTriggers),
+			Actions:     slices.Clone(ruleActions),
+			MaxPriority: maxRulePriority,
+		},
+		Limits: CapabilityLimits{
+			MaxPageSize:            maxPageSize,
+			DownloadsPerMinute:     max(rateLimits.DownloadsPerMinute, 0),
+			DownloadBurst:          max(rateLimits.DownloadBurst, 0),
+			PackDownloadsPerHour:   max(rateLimits.PackDownloadsPerHour, 0),
+			DownloadBytesPerSecond: rateLimits.DownloadBytesPerSecond,
+			RequestsPerMinute:      max(rateLimits.RequestsPerMinute, 0),
+			AuthRequestsPerMinute:  max(rateLimits.AuthRequestsPerMinute, 0),
+			Content:                contentLimits,
+		},
+	}
+}
+
+// capabilityFormats lists every format a pack can be read in, from the
+// response and export registries.
+func capabilityFormats() []string {
+	var formats []string
+	for f := range formatContentTypes {
+		formats = append(formats, f)
+	}
+	formats = append(formats, packExportFormats...)
+	for f := range ruleFileFormats {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return slices.Compact(formats)
+}
+
+// capabilityAuth lists the ways clients can sign in.
+func capabilityAuth() []string {
+	auth := []string{"bearer", "refresh_token", "sessions", "api_key", "cookie"}
+	if oidcConfig != nil {
+		auth = append(auth, "oidc")
+	}
+	return auth
+}
@@ -0,0 +1,166 @@
+package memomarket
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETL rows are read in batches so the single SQLite connection is never held
+// for the length of a stream.
+const etlBatchSize = 500
+
+// gobContentType is the compact encoding of the bulk endpoints. The ETL
+// exports send it instead of NDJSON when asked for with Accept: the same
+// records written by a single gob.Encoder, without line breaks, to be read
+// with a single gob.Decoder. Batch publishes take it as a request body and
+// answer in it on request. Field names are sent once rather than on every
+// record, so long streams are much smaller and cheaper to parse.
+const gobContentType = "application/x-gob"
+
+// recordEncoder writes one record of a stream.
+type recordEncoder interface {
+	Encode(v any) error
+}
+
+// GET /api/admin/etl/packs?since= — stream pack metadata as NDJSON, or gob
+// with "Accept: application/x-gob" (admin only).
+// since is a timestamp or the cursor of the last line already loaded; each
+// line carries its own cursor. Deleted packs are included with deleted=true.
+func handleETLPacks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	afterUpdated, afterID, _ := strings.Cut(r.URL.Query().Get("since"), "~")
+
+	enc := startRecords(w, r)
+	for {
+		records, err := store.ListETLPacks(afterUpdated, afterID, etlBatchSize)
+		if err != nil || len(records) == 0 {
+			return
+		}
+		for i := range records {
+			records[i].Cursor = records[i].UpdatedAt + "~" + records[i].ID
+			if enc.Encode(records[i]) != nil {
+				return
+			}
+		}
+		last := records[len(records)-1]
+		afterUpdated, afterID = last.UpdatedAt, last.ID
+		flush(w)
+	}
+}
+
+// GET /api/admin/etl/events?since= — stream download events as NDJSON, or
+// gob with "Accept: application/x-gob" (admin only).
+// since is the cursor of the last line already loaded.
+func handleETLEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	var after int64
+	if s := r.URL.Query().Get("since"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "since must be an event cursor"})
+			return
+		}
+		after = n
+	}
+
+	enc := startRecords(w, r)
+	for {
+		records, ids, err := store.ListETLEvents(after, etlBatchSize)
+		if err != nil || len(records) == 0 {
+			return
+		}
+		for i := range records {
+			records[i].Cursor = strconv.FormatInt(ids[i], 10)
+			if enc.Encode(records[i]) != nil {
+				return
+			}
+		}
+		after = ids[len(ids)-1]
+		flush(w)
+	}
+}
+
+// startRecords starts a record stream in the encoding the client accepts.
+func startRecords(w http.ResponseWriter, r *http.Request) recordEncoder {
+	w.Header().Add("Vary", "Accept")
+	if acceptsMediaType(r, gobContentType) {
+		w.Header().Set("Content-Type", gobContentType)
+		w.WriteHeader(http.StatusOK)
+		return gob.NewEncoder(w)
+	}
+	return startNDJSON(w)
+}
+
+// decodeRecords decodes a bulk request body into v: gob when its
+// Content-Type is gobContentType, JSON otherwise.
+func decodeRecords(r *http.Request, v any) error {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != gobContentType {
+		return decodeJSON(r, v)
+	}
+	defer r.Body.Close()
+	if err := gob.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return &formatError{"gob", err}
+	}
+	return nil
+}
+
+// writeRecords is writeJSON for bulk responses, which are gob-encoded for
+// clients that accept it.
+func writeRecords(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Add("Vary", "Accept")
+	if !acceptsMediaType(r, gobContentType) {
+		writeJSON(w, status, v)
+		return
+	}
+	w.Header().Set("Content-Type", gobContentType)
+	w.WriteHeader(status)
+	gob.NewEncoder(w).Encode(v)
+}
+
+// acceptsMediaType reports whether the Accept header names mediaType
+// explicitly with a non-zero quality. Wildcards don't count: the compact
+// encodings are only sent to clients that ask for them.
+func acceptsMediaType(r *http.Request, mediaType string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), mediaType) {
+			continue
+		}
+		for _, p := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok && k == "q" {
+				if q, err := strconv.ParseFloat(v, 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func startNDJSON(w http.ResponseWriter) *json.Encoder {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w)
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,274 @@
+package memomarket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// GET /api/memo-packs/{id}/evals — list a pack's evals (public).
+func handleGetEvals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if restrictContent(r, pack) {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "sign in to view this pack's evals"})
+		return
+	}
+	setCacheHeaders(w, cachePack, privateRead(pack))
+	writeJSON(w, http.StatusOK, pack.Evals)
+}
+
+// PUT /api/memo-packs/{id}/evals — replace a pack's evals (auth required).
+func handlePutEvals(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if !canEditPack(user, pack) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+		return
+	}
+	if rejectArchived(w, pack) {
+		return
+	}
+
+	var evals []PackEval
+	if err := decodeJSON(r, &evals); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if err := validateEvals(evals); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if evals == nil {
+		evals = []PackEval{}
+	}
+
+	before := *pack
+	pack.Evals = evals
+	if err := store.UpdateMemoPack(pack); err != nil {
+		writeUpdateError(w, pack.ID, err)
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackEdit, TargetKind: "pack", TargetID: pack.ID, Diff: packDiff(&before, pack)})
+	writeJSON(w, http.StatusOK, pack.Evals)
+}
+
+// GET /api/memo-packs/{id}/evals/runs — list recent eval runs (public;
+// sign-in needed when the pack requires it).
+func handleListEvalRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if restrictContent(r, pack) {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "sign in to view this pack's eval runs"})
+		return
+	}
+	runs, err := store.ListEvalRuns(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list runs"})
+		return
+	}
+	setCacheHeaders(w, cachePack, privateRead(pack))
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// POST /api/memo-packs/{id}/evals/runs — run evals against the configured LLM (auth required).
+func handleRunEvals(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if llmConfig.APIURL == "" {
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "no LLM provider configured"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if !canEditPack(user, pack) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+		return
+	}
+	if len(pack.Evals) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "pack has no evals"})
+		return
+	}
+	// The slot is held until the background run finishes.
+	release, ok := acquireSlots("evals")
+	if !ok {
+		writeBusy(w)
+		return
+	}
+
+	run := &EvalRun{
+		ID:          newID(),
+		PackID:      pack.ID,
+		PackVersion: pack.Version,
+		Model:       llmConfig.Model,
+		Status:      "running",
+		Results:     []EvalResult{},
+		CreatedAt:   nowISO(),
+	}
+	if err := store.InsertEvalRun(run); err != nil {
+		release()
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to start run"})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run)
+	go func() {
+		defer release()
+		executeEvalRun(run, pack)
+	}()
+}
+
+// executeEvalRun runs every eval in the pack sequentially and stores the results.
+func executeEvalRun(run *EvalRun, pack *MemoPack) {
+	system := composeSystemPrompt(pack)
+	for _, e := range pack.Evals {
+		output, err := llmComplete(system, e.Input)
+		if err != nil {
+			run.Status = "error"
+			run.Error = err.Error()
+			break
+		}
+		res := EvalResult{Name: e.Name, Output: output, Failures: checkAssertions(output, e.Expect)}
+		res.Passed = len(res.Failures) == 0
+		if res.Passed {
+			run.Passed++
+		} else {
+			run.Failed++
+		}
+		run.Results = append(run.Results, res)
+	}
+	if run.Status == "running" {
+		run.Status = "completed"
+	}
+	run.FinishedAt = nowISO()
+	if err := store.FinishEvalRun(run); err != nil {
+		log.Printf("eval run %s: failed to store results: %v", run.ID, err)
+	}
+}
+
+func checkAssertions(output string, expect []EvalAssertion) []string {
+	var failures []string
+	for _, a := range expect {
+		ok := false
+		switch a.Type {
+		case "contains":
+			ok = strings.Contains(output, a.Value)
+		case "not_contains":
+			ok = !strings.Contains(output, a.Value)
+		case "equals":
+			ok = strings.TrimSpace(output) == strings.TrimSpace(a.Value)
+		case "regex":
+			// Publishing validates patterns, but evals stored before that
+			// check may still hold a bad one; it fails rather than panics.
+			re, err := regexp.Compile(a.Value)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s %q: invalid pattern", a.Type, a.Value))
+				continue
+			}
+			ok = re.MatchString(output)
+		}
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s %q", a.Type, a.Value))
+		}
+	}
+	return failures
+}
+
+// composeSystemPrompt flattens a pack into the system prompt an agent would see.
+func composeSystemPrompt(pack *MemoPack) string {
+	var b strings.Builder
+	b.WriteString(pack.SystemPrompt)
+	if len(pack.Rules) > 0 {
+		b.WriteString("\n\n## Rules\n")
+		for _, rule := range pack.Rules {
+			fmt.Fprintf(&b, "\n### %s\n%s\n", rule.Title, rule.UpdateRule)
+		}
+	}
+	if len(pack.Memos) > 0 {
+		b.WriteString("\n\n## Memos\n")
+		for _, memo := range pack.Memos {
+			fmt.Fprintf(&b, "\n### %s\n%s\n", memo.Title, memo.Content)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+var llmClient = &http.Client{Timeout: 2 * time.Minute, Transport: newBreakerTransport(breakerLLM, false)}
+
+// llmComplete sends a single-turn chat completion to the configured provider.
+func llmComplete(system, input string) (string, error) {
+	body, _ := json.Marshal(map[string]any{
+		"model": llmConfig.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": system},
+			{"role": "user", "content": input},
+		},
+	})
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(llmConfig.APIURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if llmConfig.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+llmConfig.APIKey)
+	}
+
+	resp, err := llmClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("llm request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("llm provider returned %s", resp.Status)
+	}
+
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("invalid llm response: %v", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", fmt.Errorf("llm response had no choices")
+	}
+	return out.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,23 @@
+package memomarket
+
+import "testing"
+
+func TestCheckAssertions(t *testing.T) {
+	tests := []struct {
+		output   string
+		expect   EvalAssertion
+		failures int
+	}{
+		{"use gofmt", EvalAssertion{Type: "contains", Value: "gofmt"}, 0},
+		{"use gofmt", EvalAssertion{Type: "not_contains", Value: "gofmt"}, 1},
+		{" ok \n", EvalAssertion{Type: "equals", Value: "ok"}, 0},
+		{"v1.2.3", EvalAssertion{Type: "regex", Value: `^v\d+\.\d+\.\d+$`}, 0},
+		{"v1.2", EvalAssertion{Type: "regex", Value: `^v\d+\.\d+\.\d+$`}, 1},
+		{"anything", EvalAssertion{Type: "regex", Value: `(unclosed`}, 1},
+	}
+	for _, tt := range tests {
+		if got := checkAssertions(tt.output, []EvalAssertion{tt.expect}); len(got) != tt.failures {
+			t.Errorf("%s %q on %q: failures %q, want %d", tt.expect.Type, tt.expect.Value, tt.output, got, tt.failures)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package memomarket
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// exportDir holds generated account exports, a JSON file and a ZIP archive
+// per user.
+var exportDir = "./data/exports"
+
+// exportActivityPage is how many audit entries are read at a time.
+const exportActivityPage = 500
+
+// GET /api/me/export — status of the caller's latest account export (auth required).
+// POST queues a new export; the user is notified when it is ready.
+func handleMyExport(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		job, err := store.LatestUserJob(user.ID, JobAccountExport)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "no export requested"})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	case http.MethodPost:
+		// One export at a time per user.
+		if job, err := store.LatestUserJob(user.ID, JobAccountExport); err == nil && (job.Status == "pending" || job.Status == "running") {
+			writeJSON(w, http.StatusAccepted, job)
+			return
+		}
+		job, err := enqueueJob(JobAccountExport, user.ID, nil)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to queue export"})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, job)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// GET /api/me/export/download — download the latest finished export as one
+// JSON document, or with ?format=zip as an archive with a file per pack
+// version and per kind of record (auth required).
+func handleMyExportDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	job, err := store.LatestUserJob(user.ID, JobAccountExport)
+	if err != nil || job.Status != "done" {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "no finished export"})
+		return
+	}
+	ext, contentType := "json", "application/json"
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+	case "zip":
+		ext, contentType = "zip", "application/zip"
+	default:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "format must be json or zip"})
+		return
+	}
+	path := exportPath(user.ID, ext)
+	if _, err := os.Stat(path); err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "export file is no longer available"})
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="memomarket-export-%s.%s"`, user.Username, ext))
+	if err := serveFile(limitBandwidth(w), r, contentType, path, parseISO(job.FinishedAt)); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to read export"})
+	}
+}
+
+func exportPath(userID, ext string) string {
+	return filepath.Join(exportDir, userID+"."+ext)
+}
+
+// writeExportZip lays export out as an archive: profile.json, a file per
+// pack and per version, and one for each other kind of record.
+func writeExportZip(path string, export *AccountExport) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	now := time.Now()
+	add := func(name string, body any) error {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		return enc.Encode(body)
+	}
+	if err := add("profile.json", map[string]any{
+		"exported_at": export.ExportedAt, "server": export.Server,
+		"account": export.Profile, "public_profile": export.PublicProfile,
+	}); err != nil {
+		return err
+	}
+	for _, p := range export.Packs {
+		if err := add("packs/"+p.ID+".json", p); err != nil {
+			return err
+		}
+	}
+	for _, v := range export.Versions {
+		if err := add("versions/"+v.PackID+"/"+v.Version+".json", v); err != nil {
+			return err
+		}
+	}
+	for _, rec := range []struct {
+		name string
+		body any
+	}{
+		{"reviews.json", export.Reviews},
+		{"stars.json", export.Stars},
+		{"subscriptions.json", export.Subscriptions},
+		{"downloads.json", export.Downloads},
+		{"notifications.json", export.Notifications},
+		{"webhooks.json", export.Webhooks},
+		{"collections.json", export.Collections},
+		{"sessions.json", export.Sessions},
+		{"api_keys.json", export.APIKeys},
+		{"activity.json", export.Activity},
+	} {
+		if err := add(rec.name, rec.body); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// runAccountExport gathers everything stored about the job's user and
+// writes it to the user's export file.
+func runAccountExport(j *Job) (string, error) {
+	user, err := store.GetUserByID(j.UserID)
+	if err != nil {
+		return "", fmt.Errorf("load user: %v", err)
+	}
+	user.Token = ""
+	export := AccountExport{ExportedAt: nowISO(), Server: serverName, Profile: *user, Versions: []MemoPackVersion{}}
+
+	if export.Packs, err = store.ListAuthorPacks(user.ID, true); err != nil {
+		return "", fmt.Errorf("load packs: %v", err)
+	}
+	for _, p := range export.Packs {
+		versions, err := store.ListMemoPackVersions(p.ID)
+		if err != nil {
+			return "", fmt.Errorf("load versions: %v", err)
+		}
+		export.Versions = append(export.Versions, versions...)
+	}
+	if export.Reviews, err = store.ListUserReviews(user.ID); err != nil {
+		return "", fmt.Errorf("load reviews: %v", err)
+	}
+	if export.Stars, err = store.ListUserStars(user.ID); err != nil {
+		return "", fmt.Errorf("load stars: %v", err)
+	}
+	if export.Subscriptions, err = store.ListUserSubscriptions(user.ID); err != nil {
+		return "", fmt.Errorf("load subscriptions: %v", err)
+	}
+	if export.Downloads, err = store.ListUserDownloads(user.ID); err != nil {
+		return "", fmt.Errorf("load downloads: %v", err)
+	}
+	if export.Notifications, err = store.ListAllNotifications(user.ID); err != nil {
+		return "", fmt.Errorf("load notifications: %v", err)
+	}
+	if export.Webhooks, err = store.ListUserWebhooks(user.ID); err != nil {
+		return "", fmt.Errorf("load webhooks: %v", err)
+	}
+	for _, p := range export.Packs {
+		hooks, err := store.ListPackWebhooks(p.ID)
+		if err != nil {
+			return "", fmt.Errorf("load webhooks: %v", err)
+		}
+		export.Webhooks = append(export.Webhooks, hooks...)
+	}
+	hideWebhookSecrets(export.Webhooks)
+	if export.PublicProfile, err = store.GetUserProfile(user.Username); err != nil {
+		return "", fmt.Errorf("load profile: %v", err)
+	}
+	if export.Collections, err = store.ListUserCollections(user.ID); err != nil {
+		return "", fmt.Errorf("load collections: %v", err)
+	}
+	if export.Sessions, err = store.ListSessions(user.ID); err != nil {
+		return "", fmt.Errorf("load sessions: %v", err)
+	}
+	if export.APIKeys, err = store.ListAPIKeys(user.ID); err != nil {
+		return "", fmt.Errorf("load API keys: %v", err)
+	}
+	export.Activity = []AuditEntry{}
+	for page := 1; ; page++ {
+		entries, total, err := store.ListAuditEntries(AuditQuery{Actor: user.ID, Page: page, Limit: exportActivityPage})
+		if err != nil {
+			return "", fmt.Errorf("load activity: %v", err)
+		}
+		export.Activity = append(export.Activity, entries...)
+		if len(entries) == 0 || len(export.Activity) >= total {
+			break
+		}
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(exportDir, 0700); err != nil {
+		return "", err
+	}
+	// Write then rename so a download never sees a partial file.
+	tmp := exportPath(user.ID, "json") + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, exportPath(user.ID, "json")); err != nil {
+		return "", err
+	}
+	tmp = exportPath(user.ID, "zip") + ".tmp"
+	if err := writeExportZip(tmp, &export); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, exportPath(user.ID, "zip")); err != nil {
+		return "", err
+	}
+
+	notify(user.ID, "export_ready", "", fmt.Sprintf("Your account export (%d bytes) is ready to download", len(data)))
+	return "/api/me/export/download", nil
+}
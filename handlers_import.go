@@ -0,0 +1,423 @@
+package memomarket
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// GitHub endpoints, overridable for GitHub Enterprise.
+var githubAPIBase = "https://api.github.com"
+var githubRawBase = "https://raw.githubusercontent.com"
+
+// Files looked for in an imported repository, in priority order.
+// A manifest (memopack.json) wins outright; otherwise the rule/instruction
+// files are merged.
+var githubRuleFiles = []string{".cursorrules", "CLAUDE.md", "AGENTS.md"}
+
+const maxImportFileSize = 1 << 20
+
+var githubClient = &http.Client{Timeout: 30 * time.Second, Transport: newBreakerTransport(breakerGitHub, false)}
+
+// POST /api/memo-packs/import-github — import a repo's rule files as a draft pack (auth required).
+func handleImportGitHub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+
+	var req ImportGitHubReq
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	prov, err := parseGitHubSource(req.Repo, req.Ref, req.Path)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validateImportMapping(req.Mapping); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	prov.Mapping = req.Mapping
+
+	content, err := fetchGitHubPack(prov)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	now := nowISO()
+	pack := &MemoPack{
+		ID:         newPackID(),
+		AuthorID:   user.ID,
+		AuthorName: user.Username,
+		Version:    "1.0.0",
+		Evals:      []PackEval{},
+		Published:  false,
+		Status:     packStatusDraft,
+		Provenance: prov,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	applyImportedContent(pack, content)
+	if pack.Name == "" {
+		pack.Name = prov.Repo
+	}
+	if pack.Repository == "" {
+		pack.Repository = "https://github.com/" + prov.Repo
+	}
+	if err := checkPackNamePolicy(pack.Name, user.ID); err != nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validateRules(pack.Rules); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !enforceContentLimits(w, http.StatusUnprocessableEntity, pack.SystemPrompt, pack.Rules, pack.Memos) {
+		return
+	}
+	if err := validateDeprecations(pack.Rules, pack.Memos); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validateMemoOrder(pack.Memos); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validateMemoAttachments("", pack.Memos); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !checkTrust(w, user, true, pack.Description) {
+		return
+	}
+	if !checkQuota(w, user, pack, true) {
+		return
+	}
+
+	if err := store.InsertMemoPack(pack); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to import"})
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackImport, TargetKind: "pack", TargetID: pack.ID, Diff: packSummary(pack) + " from " + pack.Provenance.Repo})
+	writeJSON(w, http.StatusCreated, pack)
+}
+
+// POST /api/memo-packs/{id}/sync-github — re-import a pack from its source repo (auth required).
+func handleSyncGitHub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if !canEditPack(user, pack) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+		return
+	}
+	if rejectArchived(w, pack) {
+		return
+	}
+	if pack.Provenance == nil || pack.Provenance.Type != "github" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "pack was not imported from GitHub"})
+		return
+	}
+
+	prov := *pack.Provenance
+	content, err := fetchGitHubPack(&prov)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if prov.Commit != "" && prov.Commit == pack.Provenance.Commit {
+		writeJSON(w, http.StatusOK, map[string]any{"status": "up_to_date", "pack": pack})
+		return
+	}
+
+	oldVersion := pack.Version
+	before := *pack
+	applyImportedContent(pack, content)
+	pack.Provenance = &prov
+	if err := validateRules(pack.Rules); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !enforceContentLimits(w, http.StatusUnprocessableEntity, pack.SystemPrompt, pack.Rules, pack.Memos) {
+		return
+	}
+	if err := validateDeprecations(pack.Rules, pack.Memos); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validateMemoOrder(pack.Memos); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validateMemoAttachments(pack.ID, pack.Memos); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !checkQuota(w, user, pack, false) {
+		return
+	}
+	if err := store.UpdateMemoPack(pack); err != nil {
+		writeUpdateError(w, pack.ID, err)
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackEdit, TargetKind: "pack", TargetID: pack.ID, Diff: packDiff(&before, pack) + " (synced from " + prov.Commit + ")"})
+	notifyNewVersion(pack, oldVersion)
+	writeJSON(w, http.StatusOK, map[string]any{"status": "updated", "pack": pack})
+}
+
+// parseGitHubSource accepts "owner/repo" or a github.com URL (optionally with /tree/{ref}/{path}).
+func parseGitHubSource(repo, ref, dir string) (*PackProvenance, error) {
+	repo = strings.TrimSpace(repo)
+	if repo == "" {
+		return nil, fmt.Errorf("repo is required")
+	}
+	if strings.Contains(repo, "://") {
+		u, err := url.Parse(repo)
+		if err != nil || u.Host != "github.com" {
+			return nil, fmt.Errorf("repo must be owner/name or a github.com URL")
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("repo must be owner/name or a github.com URL")
+		}
+		repo = parts[0] + "/" + strings.TrimSuffix(parts[1], ".git")
+		if len(parts) >= 4 && parts[2] == "tree" {
+			if ref == "" {
+				ref = parts[3]
+			}
+			if dir == "" {
+				dir = strings.Join(parts[4:], "/")
+			}
+		}
+	}
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("repo must be owner/name or a github.com URL")
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	return &PackProvenance{Type: "github", Repo: repo, Ref: ref, Path: dir}, nil
+}
+
+// fetchGitHubPack resolves prov.Ref to a commit and reads the pack files at that commit,
+// or the files prov.Mapping names. prov.Commit, prov.Files and prov.SyncedAt are filled
+// in on success.
+func fetchGitHubPack(prov *PackProvenance) (*PublishMemoPackReq, error) {
+	commit, err := resolveGitHubCommit(prov.Repo, prov.Ref)
+	if err != nil {
+		return nil, err
+	}
+	prov.Commit = commit
+	prov.SyncedAt = nowISO()
+	prov.Files = nil
+	if len(prov.Mapping) > 0 {
+		return fetchMappedGitHubPack(prov, commit)
+	}
+
+	if data, ok, err := fetchGitHubFile(prov.Repo, commit, path.Join(prov.Path, manifestFile)); err != nil {
+		return nil, err
+	} else if ok {
+		manifest, _, err := parseManifest(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", manifestFile, err)
+		}
+		if manifest.ManifestVersion > manifestVersion {
+			return nil, fmt.Errorf("%s has manifest_version %d; this server reads up to %d", manifestFile, manifest.ManifestVersion, manifestVersion)
+		}
+		prov.Files = []string{manifestFile}
+		return &manifest.PublishMemoPackReq, nil
+	}
+
+	content := &PublishMemoPackReq{}
+	for _, name := range githubRuleFiles {
+		data, ok, err := fetchGitHubFile(prov.Repo, commit, path.Join(prov.Path, name))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		prov.Files = append(prov.Files, name)
+		mergeRuleFile(content, name, string(data))
+	}
+	if len(prov.Files) == 0 {
+		return nil, fmt.Errorf("no %s, %s found in repository", manifestFile, strings.Join(githubRuleFiles, ", "))
+	}
+	return content, nil
+}
+
+// mergeRuleFile converts one instruction file into pack content. .cursorrules
+// sections become rules; markdown instruction files become memos, with any
+// text before the first heading appended to the system prompt.
+func mergeRuleFile(content *PublishMemoPackReq, name, text string) {
+	preamble, sections := splitMarkdownSections(text)
+	// A .cursorrules file without headings is a single rule.
+	if name == ".cursorrules" && len(sections) == 0 {
+		sections = []markdownSection{{title: name, body: preamble}}
+		preamble = ""
+	}
+	if preamble != "" {
+		if content.SystemPrompt != "" {
+			content.SystemPrompt += "\n\n"
+		}
+		content.SystemPrompt += preamble
+	}
+	for _, sec := range sections {
+		if name == ".cursorrules" {
+			content.Rules = append(content.Rules, MemoRule{Title: sec.title, UpdateRule: sec.body})
+		} else {
+			content.Memos = append(content.Memos, Memo{Title: sec.title, Content: sec.body})
+		}
+	}
+}
+
+type markdownSection struct {
+	title string
+	body  string
+}
+
+// splitMarkdownSections splits text on level 1-2 headings.
+func splitMarkdownSections(text string) (string, []markdownSection) {
+	var preamble strings.Builder
+	var sections []markdownSection
+	var cur *markdownSection
+	var body strings.Builder
+
+	flush := func() {
+		if cur != nil {
+			cur.body = strings.TrimSpace(body.String())
+			sections = append(sections, *cur)
+		}
+		body.Reset()
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "# ") || strings.HasPrefix(trimmed, "## ") {
+			flush()
+			cur = &markdownSection{title: strings.TrimSpace(strings.TrimLeft(trimmed, "#"))}
+			continue
+		}
+		if cur == nil {
+			preamble.WriteString(line + "\n")
+		} else {
+			body.WriteString(line + "\n")
+		}
+	}
+	flush()
+	return strings.TrimSpace(preamble.String()), sections
+}
+
+// applyImportedContent copies imported content onto a pack, keeping its identity.
+func applyImportedContent(pack *MemoPack, content *PublishMemoPackReq) {
+	if content.Name != "" {
+		pack.Name = content.Name
+	}
+	pack.Description = content.Description
+	pack.SystemPrompt = content.SystemPrompt
+	pack.Rules = content.Rules
+	pack.Memos = content.Memos
+	if content.Version != "" && validateVersion(content.Version) == nil {
+		pack.Version = content.Version
+	}
+	if content.Evals != nil && validateEvals(content.Evals) == nil {
+		pack.Evals = content.Evals
+	}
+	// Links from a manifest are only taken when they validate, and never clear existing ones.
+	if validatePackLinks(content) == nil {
+		pack.Homepage = cmp.Or(content.Homepage, pack.Homepage)
+		pack.Repository = cmp.Or(content.Repository, pack.Repository)
+		pack.Contact = cmp.Or(content.Contact, pack.Contact)
+	}
+	if pack.Rules == nil {
+		pack.Rules = []MemoRule{}
+	}
+	if pack.Memos == nil {
+		pack.Memos = []Memo{}
+	}
+	normalizeItemTags(pack)
+}
+
+func resolveGitHubCommit(repo, ref string) (string, error) {
+	req, _ := http.NewRequest(http.MethodGet, githubAPIBase+"/repos/"+repo+"/commits/"+url.PathEscape(ref), nil)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := githubClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("github request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("repository or ref not found")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("github returned %s", resp.Status)
+	}
+	var out struct {
+		SHA string `json:"sha"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxImportFileSize)).Decode(&out); err != nil || out.SHA == "" {
+		return "", fmt.Errorf("could not resolve ref %q", ref)
+	}
+	return out.SHA, nil
+}
+
+// fetchGitHubFile returns the file at commit, or ok=false if it does not exist.
+func fetchGitHubFile(repo, commit, file string) ([]byte, bool, error) {
+	resp, err := githubClient.Get(githubRawBase + "/" + repo + "/" + commit + "/" + file)
+	if err != nil {
+		return nil, false, fmt.Errorf("github request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("github returned %s for %s", resp.Status, file)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportFileSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	if len(data) > maxImportFileSize {
+		return nil, false, fmt.Errorf("%s is larger than %d bytes", file, maxImportFileSize)
+	}
+	return data, true, nil
+}
@@ -0,0 +1,140 @@
+package memomarket
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Library search: finds where a phrase lives across the caller's own packs,
+// published or not, and the packs they have downloaded. Downloaded packs are
+// searched at the version last downloaded, which is what they have installed.
+
+const librarySnippetRunes = 60
+
+// libraryItem is one searchable piece of a pack.
+type libraryItem struct {
+	field, title, text string
+	index              int
+}
+
+func packLibraryItems(mp *MemoPack) []libraryItem {
+	items := []libraryItem{
+		{field: "name", text: mp.Name},
+		{field: "description", text: mp.Description},
+		{field: "system_prompt", text: mp.SystemPrompt},
+	}
+	for i, rule := range mp.Rules {
+		items = append(items, libraryItem{field: "rule", index: i, title: rule.Title,
+			text: rule.Title + "\n" + rule.UpdateRule + "\n" + strings.Join(rule.Tags, " ")})
+	}
+	for i, memo := range mp.Memos {
+		items = append(items, libraryItem{field: "memo", index: i, title: memo.Title,
+			text: memo.Title + "\n" + memo.Content + "\n" + strings.Join(memo.Tags, " ")})
+	}
+	return items
+}
+
+// searchPack returns the items of mp containing every term, case-insensitively.
+func searchPack(mp *MemoPack, source string, terms []string) []LibraryHit {
+	var hits []LibraryHit
+	for _, item := range packLibraryItems(mp) {
+		lower := strings.ToLower(item.text)
+		matched := true
+		for _, t := range terms {
+			if !strings.Contains(lower, t) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		hit := LibraryHit{
+			PackID:   mp.ID,
+			PackName: mp.Name,
+			Version:  mp.Version,
+			Source:   source,
+			Field:    item.field,
+			Title:    item.title,
+			Snippet:  snippetAround(item.text, lower, terms[0]),
+		}
+		if item.field == "rule" || item.field == "memo" {
+			idx := item.index
+			hit.Index = &idx
+		}
+		hits = append(hits, hit)
+	}
+	return hits
+}
+
+// snippetAround cuts text down to a window around the first occurrence of
+// term, found in lower, the lowercased text.
+func snippetAround(text, lower, term string) string {
+	at := utf8.RuneCountInString(lower[:strings.Index(lower, term)])
+	runes := []rune(text)
+	if len(runes) != utf8.RuneCountInString(lower) {
+		// Lowercasing changed the length; fall back to the start of the text.
+		at = 0
+	}
+	start := max(at-librarySnippetRunes, 0)
+	end := min(at+utf8.RuneCountInString(term)+librarySnippetRunes, len(runes))
+	s := strings.Join(strings.Fields(string(runes[start:end])), " ")
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(runes) {
+		s += "…"
+	}
+	return s
+}
+
+// GET /api/me/search?q= — search my packs, including unpublished ones, and
+// the packs I've downloaded (auth required).
+func handleMySearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	terms := strings.Fields(strings.ToLower(r.URL.Query().Get("q")))
+	if len(terms) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "q is required"})
+		return
+	}
+	q := parseListQuery(r)
+
+	own, err := store.ListAuthorPacks(user.ID, false)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to search packs"})
+		return
+	}
+	hits := []LibraryHit{}
+	for i := range own {
+		hits = append(hits, searchPack(&own[i], "own", terms)...)
+	}
+	downloaded, err := store.ListLastDownloads(user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to search downloads"})
+		return
+	}
+	for _, d := range downloaded {
+		pack, err := store.GetMemoPack(d.PackID)
+		if err != nil || pack.AuthorID == user.ID {
+			continue
+		}
+		if v, err := store.GetMemoPackVersion(pack.ID, d.Version); err == nil {
+			applyPackVersion(pack, v)
+		}
+		hits = append(hits, searchPack(pack, "downloaded", terms)...)
+	}
+
+	total := len(hits)
+	start := min((q.Page-1)*q.Limit, total)
+	end := min(start+q.Limit, total)
+	writeJSON(w, http.StatusOK, ListResponse{Items: hits[start:end], Total: total, Page: q.Page, Limit: q.Limit})
+}
@@ -1,28 +1,51 @@
-package main
+package memomarket
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
-// GET /api/memo-packs — list published memo packs (public).
+// GET /api/memo-packs — list published public memo packs (public). An
+// ?author= listing also includes the caller's own unlisted and private packs.
+// Content of auth-only packs is omitted for anonymous callers. Packs in the
+// languages of Accept-Language, or ?lang=de,en, come first, and localized
+// variants are collapsed to the best match.
 func handleListMemoPacks(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
 		return
 	}
 	q := parseListQuery(r)
-	packs, total, err := ListMemoPacks(q)
+	if user := currentUser(r); user != nil && user.ID != anonymousUserID {
+		q.Viewer = user.ID
+	}
+	packs, total, err := store.ListMemoPacks(q)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list packs"})
 		return
 	}
+	for i := range packs {
+		restrictContent(r, &packs[i])
+		attachAuthorInfo(&packs[i])
+	}
+	setCacheHeaders(w, cacheListing, q.Author != "" && q.Viewer != "")
+	w.Header().Add("Vary", "Accept-Language")
 	writeJSON(w, http.StatusOK, ListResponse{Items: packs, Total: total, Page: q.Page, Limit: q.Limit})
 }
 
-// GET /api/memo-packs/{id} — get a single memo pack (public).
+// GET /api/memo-packs/{id} — get a single memo pack (public). HEAD is also supported.
+// With ?as_of=<date or timestamp> the pack is shown at the version that was
+// current then. ?format=yaml|toml or the Accept header picks the format (see
+// formats.go).
 func handleGetMemoPack(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
 		return
 	}
@@ -31,34 +54,236 @@ func handleGetMemoPack(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "missing pack id"})
 		return
 	}
-	pack, err := GetMemoPack(id)
+	format, err := responseFormat(r)
 	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	pack, err := store.GetMemoPack(id)
+	if err != nil || packHidden(r, pack) {
 		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
 		return
 	}
-	writeJSON(w, http.StatusOK, pack)
+	asOf := r.URL.Query().Get("as_of")
+	if asOf != "" {
+		at, ok := parseAsOf(asOf)
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "as_of must be a date or timestamp"})
+			return
+		}
+		v, err := store.VersionAsOf(pack.ID, at)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack had no published version as of " + asOf})
+			return
+		}
+		applyPackVersion(pack, v)
+		pack.Channel = v.Channel
+	}
+	restrictContent(r, pack)
+	attachAuthorInfo(pack)
+	if variants, err := store.ListPackVariants(cmp.Or(pack.VariantOf, pack.ID)); err == nil && len(variants) > 1 {
+		pack.Variants = variants
+	}
+	if pack.Channel == channelBeta && asOf == "" {
+		if v, err := store.LatestStableVersion(pack.ID); err == nil {
+			pack.StableVersion = v.Version
+		}
+	}
+	setCacheHeaders(w, cachePack, privateRead(pack))
+	w.Header().Add("Vary", "Accept")
+	servePackFormat(w, r, format, pack, parseISO(pack.UpdatedAt))
 }
 
 // GET /api/memo-packs/{id}/download — download (increment counter + return pack).
+// HEAD and Range requests are served without counting a download. With
+// ?receipt=true the pack is wrapped with a signed install receipt. Large packs
+// are streamed (see streamJSON). ?version= picks a stored version, as does
+// GET /api/memo-packs/{id}/versions/{version}/download; downloads are
+// counted per version too. YAML and TOML are served as for GET.
 func handleDownloadMemoPack(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
 		return
 	}
+	limitKey := rateLimitKey(r)
+	if ok, wait := downloadLimiter.allow(limitKey); !ok {
+		writeRateLimited(w, wait)
+		return
+	}
 	path := strings.TrimPrefix(r.URL.Path, "/api/memo-packs/")
 	id := strings.TrimSuffix(path, "/download")
+	spec := r.URL.Query().Get("version")
+	if packID, version, ok := strings.Cut(id, "/versions/"); ok {
+		id, spec = packID, version
+	}
 	if id == "" {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "missing pack id"})
 		return
 	}
-	pack, err := GetMemoPack(id)
+	format, err := responseFormat(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	pack, err := store.GetMemoPack(id)
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if pack.RequireAuth && currentUser(r) == nil && !shareGrants(r, pack) {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "sign in to download this pack"})
+		return
+	}
+	channel := r.URL.Query().Get("channel")
+	if err := validateChannel(channel); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	cacheClass := cachePack
+	if spec != "" {
+		v, err := resolvePackVersion(pack, spec, channel)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		applyPackVersion(pack, v)
+		// Published versions never change, so exact pins can be cached for good.
+		if validateVersion(spec) == nil {
+			cacheClass = cacheImmutable
+		}
+	} else {
+		// Without a version, downloads follow the stable channel unless the
+		// caller opts into beta.
+		v, err := channelVersion(pack, channel)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if v != nil {
+			applyPackVersion(pack, v)
+		}
+	}
+	// Resumed (ranged) and HEAD requests don't count as new downloads, nor do
+	// repeats past the per-pack cap.
+	if r.Method == http.MethodGet && r.Header.Get("Range") == "" && packDownloadAllowed(limitKey, id) {
+		store.IncrementMemoPackDownloads(id, pack.Version)
+		pack.Downloads++
+		var userID string
+		if user := currentUser(r); user != nil {
+			userID = user.ID
+			store.MarkSubscriptionDownloaded(user.ID, id, pack.Version)
+		}
+		if cid := currentClientID(r); cid != "" {
+			store.RecordDownloadEvent(id, pack.Version, cid, userID)
+		}
+		checkDownloadMilestone(pack)
+	}
+	var receipt *InstallReceipt
+	if r.URL.Query().Get("receipt") == "true" {
+		rc := issueReceipt(pack)
+		receipt = &rc
+	}
+	if tags := parseTagList(r.URL.Query().Get("include_memo_tags")); len(tags) > 0 {
+		filterByMemoTags(pack, tags)
+	}
+	if r.URL.Query().Get("include_deprecated") == "false" {
+		stripDeprecated(pack)
+	}
+	// Receipts are signed per request and must not be shared.
+	setCacheHeaders(w, cacheClass, privateRead(pack) || receipt != nil)
+	w.Header().Add("Vary", "Accept")
+	var body any = DownloadedPack{MemoPack: pack}
+	if receipt != nil {
+		body = DownloadWithReceipt{Pack: DownloadedPack{MemoPack: pack}, Receipt: *receipt}
+	}
+	w = limitBandwidth(w)
+	if format == formatJSON && shouldStream(r, pack.Content.TotalChars) {
+		streamJSON(w, body)
+		return
+	}
+	servePackFormat(w, r, format, body, parseISO(pack.UpdatedAt))
+}
+
+// clientLabelPattern limits client names and versions reported on install to
+// short identifiers, keeping free text out of public stats.
+var clientLabelPattern = regexp.MustCompile(`^[A-Za-z0-9._+-]{1,64}$`)
+
+// POST /api/memo-packs/{id}/installed — report a successful local install;
+// counted separately from downloads (public).
+func handleInstalled(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	if ok, wait := downloadLimiter.allow(rateLimitKey(r)); !ok {
+		writeRateLimited(w, wait)
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	var req InstalledReq
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+	}
+	for _, label := range []string{req.Client, req.ClientVersion} {
+		if label != "" && !clientLabelPattern.MatchString(label) {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "client and client_version must be up to 64 letters, digits or ._+-"})
+			return
+		}
+	}
+	version := pack.Version
+	if req.Version != "" {
+		if _, err := store.GetMemoPackVersion(pack.ID, req.Version); err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "version " + req.Version + " not found"})
+			return
+		}
+		version = req.Version
+	}
+	counted, err := store.RecordInstallEvent(&InstallEvent{
+		PackID:        pack.ID,
+		Version:       version,
+		ClientID:      currentClientID(r),
+		Client:        req.Client,
+		ClientVersion: req.ClientVersion,
+	})
 	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to record install"})
+		return
+	}
+	status := "recorded"
+	if !counted {
+		status = "already_recorded"
+	} else {
+		checkNewClient(pack, req.Client)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": status})
+}
+
+// GET /api/memo-packs/{id}/stats — download and install metrics for a pack (public).
+func handlePackStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
 		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
 		return
 	}
-	IncrementMemoPackDownloads(id)
-	pack.Downloads++
-	writeJSON(w, http.StatusOK, pack)
+	stats, err := store.GetPackStats(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load stats"})
+		return
+	}
+	stats.Downloads = pack.Downloads
+	setCacheHeaders(w, cacheListing, privateRead(pack))
+	writeJSON(w, http.StatusOK, stats)
 }
 
 // POST /api/memo-packs — publish a new memo pack (auth required).
@@ -72,20 +297,132 @@ func handlePublishMemoPack(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
 		return
 	}
+	if !requireWritable(w, user) {
+		return
+	}
+	if pack := publishMemoPack(w, r, user); pack != nil {
+		writeJSON(w, http.StatusCreated, pack)
+	}
+}
 
+// publishMemoPack validates the request body and publishes it as a new pack
+// by user, or saves it as a draft. It writes the error response and returns
+// nil on failure.
+func publishMemoPack(w http.ResponseWriter, r *http.Request, user *User) *MemoPack {
 	var req PublishMemoPackReq
-	if err := decodeJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
-		return
+	limitRequestBody(w, r)
+	if err := decodePackBody(r, &req); err != nil {
+		writeDecodeError(w, err)
+		return nil
+	}
+	pack, embargoViewers := newPackFromReq(w, user, &req)
+	if pack == nil {
+		return nil
 	}
+	if err := store.InsertMemoPack(pack); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to publish"})
+		return nil
+	}
+	announcePack(r, user, pack, embargoViewers)
+	return pack
+}
+
+// newPackFromReq validates req and builds the pack it publishes for user,
+// returning it with the users its embargo lets in. Nothing is stored. It
+// writes the error response and returns nil when req is refused.
+func newPackFromReq(w http.ResponseWriter, user *User, req *PublishMemoPackReq) (*MemoPack, []string) {
 	if req.Name == "" {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "name is required"})
-		return
+		return nil, nil
+	}
+	if err := checkPackNamePolicy(req.Name, user.ID); err != nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if err := validateRules(req.Rules); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if !enforceContentLimits(w, http.StatusBadRequest, req.SystemPrompt, req.Rules, req.Memos) {
+		return nil, nil
+	}
+	if err := validateDeprecations(req.Rules, req.Memos); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if err := validateMemoOrder(req.Memos); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if err := validateMemoAttachments("", req.Memos); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if req.Version == "" {
+		req.Version = "1.0.0"
+	}
+	if err := validateVersion(req.Version); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if err := validateChannel(req.Channel); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if err := validateEvals(req.Evals); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if err := validatePackLinks(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if !checkTrust(w, user, true, req.Description) {
+		return nil, nil
+	}
+	if user.ID == anonymousUserID && req.VariantOf != "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "variant_of needs an account"})
+		return nil, nil
+	}
+	if req.Draft {
+		if user.ID == anonymousUserID {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "drafts need an account"})
+			return nil, nil
+		}
+		if req.Embargo != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "drafts can't be embargoed; add the embargo when publishing"})
+			return nil, nil
+		}
+	}
+	var embargoUntil string
+	var embargoViewers []string
+	if req.Embargo != nil {
+		if user.ID == anonymousUserID {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "embargo needs an account"})
+			return nil, nil
+		}
+		var err error
+		if embargoUntil, embargoViewers, err = validateEmbargo(req.Embargo); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return nil, nil
+		}
+	}
+	if err := validatePackLocale(req, "", user.ID); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if err := validatePackCategory(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
+	}
+	if err := validatePackVisibility(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, nil
 	}
 
 	now := nowISO()
 	pack := &MemoPack{
-		ID:           newID(),
+		ID:           newPackID(),
 		Name:         req.Name,
 		Description:  req.Description,
 		AuthorID:     user.ID,
@@ -93,8 +430,21 @@ func handlePublishMemoPack(w http.ResponseWriter, r *http.Request) {
 		SystemPrompt: req.SystemPrompt,
 		Rules:        req.Rules,
 		Memos:        req.Memos,
+		Evals:        req.Evals,
+		Version:      req.Version,
+		Channel:      req.Channel,
+		Language:     req.Language,
+		VariantOf:    req.VariantOf,
+		Category:     req.Category,
+		Visibility:   cmp.Or(req.Visibility, visibilityPublic),
+		Homepage:     req.Homepage,
+		Repository:   req.Repository,
+		Contact:      req.Contact,
+		RequireAuth:  req.RequireAuth,
 		Downloads:    0,
-		Published:    true,
+		Published:    !req.Draft,
+		Status:       packStatus(!req.Draft),
+		EmbargoUntil: embargoUntil,
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
@@ -104,17 +454,42 @@ func handlePublishMemoPack(w http.ResponseWriter, r *http.Request) {
 	if pack.Memos == nil {
 		pack.Memos = []Memo{}
 	}
+	if pack.Evals == nil {
+		pack.Evals = []PackEval{}
+	}
+	normalizeItemTags(pack)
+	if !checkQuota(w, user, pack, true) {
+		return nil, nil
+	}
+	if !checkDuplicate(w, pack) {
+		return nil, nil
+	}
+	return pack, embargoViewers
+}
 
-	if err := InsertMemoPack(pack); err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to publish"})
+// announcePack audits a newly stored pack and, once it is published, starts
+// its embargo or sends the publish webhooks.
+func announcePack(r *http.Request, user *User, pack *MemoPack, embargoViewers []string) {
+	if !pack.Published {
+		writeAudit(r, user, &AuditEntry{Action: auditPackDraft, TargetKind: "pack", TargetID: pack.ID, Diff: packSummary(pack)})
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackPublish, TargetKind: "pack", TargetID: pack.ID, Diff: packSummary(pack)})
+	if pack.EmbargoUntil != "" {
+		// The publish webhooks go out when the embargo ends.
+		if err := startEmbargo(pack, pack.EmbargoUntil, embargoViewers); err != nil {
+			log.Printf("embargo of %s: %v", pack.ID, err)
+		}
 		return
 	}
-	writeJSON(w, http.StatusCreated, pack)
+	emitPackEvent(EventPackPublished, pack, nil)
 }
 
-// PUT /api/memo-packs/{id} — update own memo pack (auth required).
+// PUT /api/memo-packs/{id} — update own memo pack (auth required). Admins
+// can update any pack, giving a ?reason= that is sent to the author. PATCH
+// takes a merge patch instead of the whole pack; see patch.go.
 func handleUpdateMemoPack(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
 		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
 		return
 	}
@@ -123,44 +498,213 @@ func handleUpdateMemoPack(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
 		return
 	}
+	if !requireWritable(w, user) {
+		return
+	}
 
 	id := extractID(r.URL.Path, "/api/memo-packs/")
-	existing, err := GetMemoPack(id)
+	existing, err := store.GetMemoPack(id)
 	if err != nil {
 		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
 		return
 	}
-	if existing.AuthorID != user.ID {
-		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+	moderated, reason, ok := checkPackEditor(w, r, user, existing)
+	if !ok {
+		return
+	}
+	// Admins can still moderate archived packs.
+	if !moderated && rejectArchived(w, existing) {
+		return
+	}
+	if !checkIfMatch(w, r, existing) {
 		return
 	}
 
+	before := *existing
 	var req PublishMemoPackReq
-	if err := decodeJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+	limitRequestBody(w, r)
+	if r.Method == http.MethodPatch {
+		if !decodePackPatch(w, r, existing, &req) {
+			return
+		}
+	} else if err := decodePackBody(r, &req); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
+	if req.Name == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "name is required"})
+		return
+	}
+	if err := validateRules(req.Rules); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !enforceContentLimits(w, http.StatusBadRequest, req.SystemPrompt, req.Rules, req.Memos) {
+		return
+	}
+	if err := validateDeprecations(req.Rules, req.Memos); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validateMemoOrder(req.Memos); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validateMemoAttachments(existing.ID, req.Memos); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validatePackLinks(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validatePackLocale(&req, existing.ID, existing.AuthorID); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validatePackCategory(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := validatePackVisibility(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.Description != existing.Description && !checkTrust(w, user, false, req.Description) {
+		return
+	}
+	if req.Name != existing.Name {
+		if err := checkPackNamePolicy(req.Name, existing.AuthorID); err != nil {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+	if err := validateChannel(req.Channel); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	oldVersion := existing.Version
+	wasPublic := packPublic(existing)
+	promoted := false
+	if req.Version == "" || req.Version == existing.Version {
+		// Editing the current version keeps its channel unless it is being
+		// promoted; a stable version can't go back to beta.
+		if req.Channel == channelBeta && existing.Channel == channelStable {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "version " + existing.Version + " is already stable; publish a new version to beta"})
+			return
+		}
+		if req.Channel == channelStable && existing.Channel == channelBeta {
+			promoted = true
+		}
+		if req.Channel != "" {
+			existing.Channel = req.Channel
+		}
+	} else {
+		if err := validateVersion(req.Version); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		// Superseded versions are immutable so pinned installs stay reproducible.
+		if _, err := store.GetMemoPackVersion(existing.ID, req.Version); err == nil {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "version " + req.Version + " was already published"})
+			return
+		}
+		existing.Version = req.Version
+		existing.Channel = cmp.Or(req.Channel, channelStable)
+	}
+	// Evals have their own endpoint; only replace them when supplied.
+	if req.Evals != nil {
+		if err := validateEvals(req.Evals); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		existing.Evals = req.Evals
+	}
 
 	existing.Name = req.Name
 	existing.Description = req.Description
 	existing.SystemPrompt = req.SystemPrompt
 	existing.Rules = req.Rules
 	existing.Memos = req.Memos
+	existing.Homepage = req.Homepage
+	existing.Repository = req.Repository
+	existing.Contact = req.Contact
+	existing.RequireAuth = req.RequireAuth
+	existing.Language = cmp.Or(req.Language, existing.Language)
+	existing.VariantOf = cmp.Or(req.VariantOf, existing.VariantOf)
+	existing.Category = cmp.Or(req.Category, existing.Category)
+	existing.Visibility = cmp.Or(req.Visibility, existing.Visibility)
 	if existing.Rules == nil {
 		existing.Rules = []MemoRule{}
 	}
 	if existing.Memos == nil {
 		existing.Memos = []Memo{}
 	}
+	normalizeItemTags(existing)
+	if !checkQuota(w, user, existing, false) {
+		return
+	}
 
-	if err := UpdateMemoPack(existing); err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update"})
+	if err := store.UpdateMemoPack(existing); err != nil {
+		writeUpdateError(w, existing.ID, err)
 		return
 	}
+	notifyNewVersion(existing, oldVersion)
+	if promoted {
+		notifyPromoted(existing, existing.Version)
+	}
+	if !wasPublic && packPublic(existing) {
+		emitPackEvent(EventPackPublished, existing, nil)
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackEdit, TargetKind: "pack", TargetID: existing.ID, Reason: reason, Diff: packDiff(&before, existing)})
+	if moderated {
+		notifyModeration(existing.AuthorID, existing.ID, existing.Contact, fmt.Sprintf("An admin edited your pack %q.", existing.Name), reason)
+	}
 	writeJSON(w, http.StatusOK, existing)
 }
 
-// DELETE /api/memo-packs/{id} — delete own memo pack (auth required).
+// checkIfMatch requires the request's If-Match header to name the pack's
+// current revision, so concurrent editors can't overwrite each other.
+// "If-Match: *" updates regardless. It writes the error response and returns
+// false when the update must not go ahead.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, pack *MemoPack) bool {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		writeJSON(w, http.StatusPreconditionRequired, RevisionConflict{Error: "If-Match header with the pack revision is required", Revision: pack.Revision})
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	rev, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "If-Match must be a pack revision number"})
+		return false
+	}
+	if rev != pack.Revision {
+		writeJSON(w, http.StatusConflict, RevisionConflict{Error: "pack has been modified since revision " + strconv.Itoa(rev), Revision: pack.Revision})
+		return false
+	}
+	return true
+}
+
+// writeUpdateError reports a failed UpdateMemoPack, answering 409 with the
+// current revision when another update got there first.
+func writeUpdateError(w http.ResponseWriter, packID string, err error) {
+	if errors.Is(err, errStaleRevision) {
+		current, _ := store.GetMemoPack(packID)
+		conflict := RevisionConflict{Error: "pack has been modified by another update"}
+		if current != nil {
+			conflict.Revision = current.Revision
+		}
+		writeJSON(w, http.StatusConflict, conflict)
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update"})
+}
+
+// DELETE /api/memo-packs/{id} — delete own memo pack (auth required). Admins
+// can delete any pack with a ?reason=.
 func handleDeleteMemoPack(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
@@ -171,25 +715,70 @@ func handleDeleteMemoPack(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
 		return
 	}
+	if !requireWritable(w, user) {
+		return
+	}
 
 	id := extractID(r.URL.Path, "/api/memo-packs/")
-	existing, err := GetMemoPack(id)
+	existing, err := store.GetMemoPack(id)
 	if err != nil {
 		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
 		return
 	}
-	if existing.AuthorID != user.ID {
-		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+	moderated, reason, ok := checkPackOwner(w, r, user, existing)
+	if !ok {
+		return
+	}
+	if store.IsPackOnHold(id) {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is under legal hold and cannot be deleted"})
 		return
 	}
 
-	if err := DeleteMemoPack(id, user.ID); err != nil {
+	if err := store.DeleteMemoPack(id, existing.AuthorID); err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete"})
 		return
 	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackDelete, TargetKind: "pack", TargetID: id, Reason: reason, Diff: fmt.Sprintf("%q %s", existing.Name, existing.Version)})
+	if moderated {
+		notifyModeration(existing.AuthorID, id, existing.Contact, fmt.Sprintf("An admin deleted your pack %q.", existing.Name), reason)
+	}
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
+// checkPackOwner lets the author, or an admin giving a ?reason=, change
+// pack. moderated is set when an admin acts on someone else's pack. It
+// writes the error response and returns ok=false otherwise.
+func checkPackOwner(w http.ResponseWriter, r *http.Request, user *User, pack *MemoPack) (moderated bool, reason string, ok bool) {
+	if pack.AuthorID == user.ID {
+		return false, "", true
+	}
+	if !isAdmin(user) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+		return false, "", false
+	}
+	reason = strings.TrimSpace(r.URL.Query().Get("reason"))
+	if err := validateAdminReason(reason); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return false, "", false
+	}
+	return true, reason, true
+}
+
+// restrictContent strips the prompt content of an auth-only pack when the
+// caller is anonymous, keeping metadata and size info. It reports whether
+// anything was hidden.
+func restrictContent(r *http.Request, pack *MemoPack) bool {
+	if !pack.RequireAuth || currentUser(r) != nil || shareGrants(r, pack) {
+		return false
+	}
+	pack.SystemPrompt = ""
+	pack.Rules = []MemoRule{}
+	pack.Memos = []Memo{}
+	pack.Evals = []PackEval{}
+	pack.ContentRestricted = true
+	return true
+}
+
 func extractID(path, prefix string) string {
 	s := strings.TrimPrefix(path, prefix)
 	if idx := strings.Index(s, "/"); idx >= 0 {
@@ -197,3 +786,75 @@ func extractID(path, prefix string) string {
 	}
 	return s
 }
+
+// parseTagList splits a comma-separated tag list from a query parameter.
+func parseTagList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return applyTagPolicy(normalizeTags(strings.Split(s, ",")))
+}
+
+// normalizeTags lowercases, trims and de-duplicates tags, dropping empties.
+func normalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// normalizeItemTags normalizes the tags on every rule and memo in a pack and
+// applies the admin tag policy.
+func normalizeItemTags(mp *MemoPack) {
+	for i := range mp.Rules {
+		mp.Rules[i].Tags = applyTagPolicy(normalizeTags(mp.Rules[i].Tags))
+	}
+	for i := range mp.Memos {
+		mp.Memos[i].Tags = applyTagPolicy(normalizeTags(mp.Memos[i].Tags))
+	}
+}
+
+// stripDeprecated drops deprecated rules and memos, for consumers that only
+// want what the author still recommends.
+func stripDeprecated(mp *MemoPack) {
+	mp.Rules = slices.DeleteFunc(mp.Rules, func(rule MemoRule) bool { return rule.Deprecated != nil })
+	mp.Memos = slices.DeleteFunc(mp.Memos, func(memo Memo) bool { return memo.Deprecated != nil })
+}
+
+// filterByMemoTags keeps only the rules and memos carrying at least one of tags.
+func filterByMemoTags(mp *MemoPack, tags []string) {
+	want := map[string]bool{}
+	for _, t := range tags {
+		want[t] = true
+	}
+	hasAny := func(itemTags []string) bool {
+		for _, t := range itemTags {
+			if want[t] {
+				return true
+			}
+		}
+		return false
+	}
+
+	rules := []MemoRule{}
+	for _, rule := range mp.Rules {
+		if hasAny(rule.Tags) {
+			rules = append(rules, rule)
+		}
+	}
+	memos := []Memo{}
+	for _, memo := range mp.Memos {
+		if hasAny(memo.Tags) {
+			memos = append(memos, memo)
+		}
+	}
+	mp.Rules = rules
+	mp.Memos = memos
+}
@@ -0,0 +1,109 @@
+package memomarket
+
+import (
+	"cmp"
+	"net/http"
+	"strings"
+)
+
+// GET /api/memo-packs/{id}/reviews — list a pack's reviews, newest first (public).
+// PUT creates or replaces the caller's review; DELETE removes it (auth required).
+func handleReviews(w http.ResponseWriter, r *http.Request) {
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		q := parseListQuery(r)
+		reviews, total, err := store.ListReviews(pack.ID, q.Limit, (q.Page-1)*q.Limit)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list reviews"})
+			return
+		}
+		setCacheHeaders(w, cacheListing, false)
+		writeJSON(w, http.StatusOK, ListResponse{Items: reviews, Total: total, Page: q.Page, Limit: q.Limit})
+		return
+	}
+
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if pack.AuthorID == user.ID {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "you cannot review your own pack"})
+			return
+		}
+		var req ReviewReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		req.Body = strings.TrimSpace(req.Body)
+		if err := validateReview(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		now := nowISO()
+		rv := &Review{
+			PackID:    pack.ID,
+			UserID:    user.ID,
+			Username:  user.Username,
+			Rating:    req.Rating,
+			Body:      req.Body,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := store.SaveReview(rv); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save review"})
+			return
+		}
+		emitPackEvent(EventPackReview, pack, map[string]any{"reviewer": rv.Username, "rating": rv.Rating, "body": rv.Body})
+		if v := activeVacation(pack.AuthorID); v != nil {
+			rv.AutoReply = cmp.Or(v.Message, pack.AuthorName+" is away until "+v.EndsAt)
+		}
+		writeJSON(w, http.StatusOK, rv)
+	case http.MethodDelete:
+		if err := store.DeleteReview(pack.ID, user.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete review"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// PUT /api/memo-packs/{id}/star — star a pack (auth required).
+// DELETE on the same path removes the star.
+func handleStar(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	var starred bool
+	switch r.Method {
+	case http.MethodPut:
+		starred = true
+	case http.MethodDelete:
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	if err := store.SetStar(pack.ID, user.ID, starred); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update star"})
+		return
+	}
+	pack, _ = store.GetMemoPack(pack.ID)
+	writeJSON(w, http.StatusOK, map[string]any{"starred": starred, "stars": pack.Ratings.Stars})
+}
@@ -0,0 +1,57 @@
+package memomarket
+
+import "net/http"
+
+// GET /api/me/sessions — my active logins; the one making the request is
+// marked current (auth required).
+func handleMySessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireLogin(w, user) {
+		return
+	}
+	sessions, err := store.ListSessions(user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list sessions"})
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == user.SessionID
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// DELETE /api/me/sessions/{id} — revoke one login's tokens (auth required).
+func handleMySession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireLogin(w, user) {
+		return
+	}
+	id := extractID(r.URL.Path, "/api/me/sessions/")
+	deleted, err := store.DeleteSession(id, user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to revoke session"})
+		return
+	}
+	if !deleted {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "session not found"})
+		return
+	}
+	recordAudit(r, user, auditSessionEnd, "session", id, "")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
@@ -0,0 +1,153 @@
+package memomarket
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// PUT /api/memo-packs/{id}/subscribe — subscribe to a pack's updates (auth required).
+// DELETE on the same path unsubscribes.
+func handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		// The body is optional; without a version the subscriber is assumed
+		// to have the current one installed.
+		var req SubscribeReq
+		if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		if err := validateChannel(req.Channel); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if req.Channel == "" {
+			req.Channel = channelStable
+		}
+		if req.Version == "" {
+			req.Version = pack.Version
+			if v, err := channelVersion(pack, req.Channel); err == nil && v != nil {
+				req.Version = v.Version
+			}
+		} else if err := validateVersion(req.Version); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if err := store.Subscribe(user.ID, pack.ID, req.Version, req.Channel); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to subscribe"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "subscribed", "version": req.Version, "channel": req.Channel})
+	case http.MethodDelete:
+		if err := store.Unsubscribe(user.ID, pack.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to unsubscribe"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "unsubscribed"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// GET /api/me/updates — subscribed packs with a newer version than last downloaded.
+func handleMyUpdates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	updates, err := store.ListPackUpdates(user.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list updates"})
+		return
+	}
+	writeJSON(w, http.StatusOK, updates)
+}
+
+// GET /api/me/notifications — list notifications (?unread=true for unread only).
+// POST marks all notifications as read.
+func handleNotifications(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		notes, err := store.ListNotifications(user.ID, r.URL.Query().Get("unread") == "true")
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list notifications"})
+			return
+		}
+		writeJSON(w, http.StatusOK, notes)
+	case http.MethodPost:
+		if err := store.MarkNotificationsRead(user.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update notifications"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "read"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// notifyNewVersion tells subscribers when a pack's version moves forward.
+// Beta versions only reach subscribers of the beta channel.
+func notifyNewVersion(pack *MemoPack, oldVersion string) {
+	if compareVersions(pack.Version, oldVersion) <= 0 {
+		return
+	}
+	msg := fmt.Sprintf("%s was updated to version %s", pack.Name, pack.Version)
+	if pack.Channel == channelBeta {
+		msg = fmt.Sprintf("%s has a new beta version %s", pack.Name, pack.Version)
+	}
+	notifySubscribers(pack, pack.Channel, msg)
+}
+
+// notifyPromoted tells subscribers that a beta version is now stable.
+func notifyPromoted(pack *MemoPack, version string) {
+	notifySubscribers(pack, channelStable, fmt.Sprintf("%s version %s is now stable", pack.Name, version))
+}
+
+func notifySubscribers(pack *MemoPack, channel, msg string) {
+	ids, err := store.ListSubscriberIDs(pack.ID, channel)
+	if err != nil {
+		log.Printf("notify subscribers of %s: %v", pack.ID, err)
+		return
+	}
+	for _, id := range ids {
+		if id == pack.AuthorID {
+			continue
+		}
+		notify(id, "pack_update", pack.ID, msg)
+	}
+}
+
+// notify stores an in-app notification, logging rather than failing on error.
+func notify(userID, kind, packID, message string) {
+	// Notifications are paused while the user is on vacation.
+	if activeVacation(userID) != nil {
+		return
+	}
+	n := &Notification{ID: newID(), UserID: userID, Kind: kind, PackID: packID, Message: message, CreatedAt: nowISO()}
+	if err := store.InsertNotification(n); err != nil {
+		log.Printf("notify %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,407 @@
+package memomarket
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Tag policy: admins can block tags (spam, slurs) and merge synonyms into a
+// canonical tag (golang → go). The policy applies whenever rule and memo tags
+// are normalized, and changing it queues a retag job that rewrites existing
+// packs. Published version snapshots other than each pack's current one are
+// left as they were.
+
+const (
+	tagActionBlock = "block"
+	tagActionMerge = "merge"
+)
+
+const JobRetag = "retag"
+
+// Author retag actions, applied to the rules and memos of their own packs.
+const (
+	retagAdd    = "add"
+	retagRemove = "remove"
+	retagRename = "rename"
+)
+
+const maxTagChars = 64
+
+var tagPolicy = struct {
+	sync.RWMutex
+	blocked map[string]bool
+	merges  map[string]string
+}{blocked: map[string]bool{}, merges: map[string]string{}}
+
+// loadTagPolicy reads the tag rules from the database into memory.
+func loadTagPolicy() error {
+	rules, err := store.ListTagRules()
+	if err != nil {
+		return err
+	}
+	blocked := map[string]bool{}
+	merges := map[string]string{}
+	for _, rule := range rules {
+		switch rule.Action {
+		case tagActionBlock:
+			blocked[rule.Tag] = true
+		case tagActionMerge:
+			merges[rule.Tag] = rule.Into
+		}
+	}
+	tagPolicy.Lock()
+	tagPolicy.blocked = blocked
+	tagPolicy.merges = merges
+	tagPolicy.Unlock()
+	return nil
+}
+
+// applyTagPolicy maps merged tags to their canonical tag and drops blocked
+// ones. tags must already be normalized.
+func applyTagPolicy(tags []string) []string {
+	tagPolicy.RLock()
+	defer tagPolicy.RUnlock()
+	if len(tagPolicy.blocked) == 0 && len(tagPolicy.merges) == 0 {
+		return tags
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range tags {
+		if into, ok := tagPolicy.merges[t]; ok {
+			t = into
+		}
+		if tagPolicy.blocked[t] || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// validateTagRule checks a rule against the current policy. Merges only go
+// one level deep, so a merge target can't itself be merged or blocked.
+func validateTagRule(rule *TagRule) (int, error) {
+	if rule.Tag == "" || len(rule.Tag) > maxTagChars {
+		return http.StatusBadRequest, fmt.Errorf("tag must be 1-%d characters", maxTagChars)
+	}
+	tagPolicy.RLock()
+	defer tagPolicy.RUnlock()
+	switch rule.Action {
+	case tagActionBlock:
+		rule.Into = ""
+	case tagActionMerge:
+		if rule.Into == "" || len(rule.Into) > maxTagChars {
+			return http.StatusBadRequest, fmt.Errorf("into must be 1-%d characters", maxTagChars)
+		}
+		if rule.Into == rule.Tag {
+			return http.StatusBadRequest, fmt.Errorf("a tag can't be merged into itself")
+		}
+		if tagPolicy.blocked[rule.Into] {
+			return http.StatusConflict, fmt.Errorf("tag %q is blocked", rule.Into)
+		}
+		if _, ok := tagPolicy.merges[rule.Into]; ok {
+			return http.StatusConflict, fmt.Errorf("tag %q is itself merged into %q", rule.Into, tagPolicy.merges[rule.Into])
+		}
+		for from, into := range tagPolicy.merges {
+			if into == rule.Tag {
+				return http.StatusConflict, fmt.Errorf("tag %q is merged into %q; change that merge first", from, rule.Tag)
+			}
+		}
+	default:
+		return http.StatusBadRequest, fmt.Errorf("action must be block or merge")
+	}
+	return 0, nil
+}
+
+// queueRetag starts a retag job unless one is already waiting, which will
+// pick up the latest policy anyway.
+func queueRetag() (*Job, error) {
+	if store.HasPendingJob(JobRetag) {
+		return nil, nil
+	}
+	return enqueueJob(JobRetag, "", nil)
+}
+
+// runRetag applies the current tag policy to every live pack and tells the
+// authors of changed packs which tags were removed or merged, and why.
+func runRetag(j *Job) (string, error) {
+	ids, err := store.ListLivePackIDs()
+	if err != nil {
+		return "", err
+	}
+	tagRules, err := store.ListTagRules()
+	if err != nil {
+		return "", err
+	}
+	byTag := map[string]TagRule{}
+	for _, rule := range tagRules {
+		byTag[rule.Tag] = rule
+	}
+	changed := 0
+	for _, id := range ids {
+		mp, err := store.GetMemoPack(id)
+		if err != nil {
+			continue
+		}
+		rules, memos := MarshalRules(mp.Rules), MarshalMemos(mp.Memos)
+		before := packItemTags(mp)
+		normalizeItemTags(mp)
+		if MarshalRules(mp.Rules) == rules && MarshalMemos(mp.Memos) == memos {
+			continue
+		}
+		if err := store.SaveRetaggedPack(mp); err != nil {
+			return "", fmt.Errorf("retag pack %s: %v", id, err)
+		}
+		changed++
+		if changes, reasons := describeRetag(before, byTag); len(changes) > 0 {
+			notifyModeration(mp.AuthorID, mp.ID, mp.Contact,
+				fmt.Sprintf("Tags in your pack %q were changed: %s.", mp.Name, strings.Join(changes, "; ")),
+				strings.Join(reasons, "; "))
+		}
+	}
+	return fmt.Sprintf("%d of %d packs retagged", changed, len(ids)), nil
+}
+
+// packItemTags returns the normalized rule and memo tags of mp, sorted.
+func packItemTags(mp *MemoPack) []string {
+	var tags []string
+	for _, rule := range mp.Rules {
+		tags = append(tags, normalizeTags(rule.Tags)...)
+	}
+	for _, memo := range mp.Memos {
+		tags = append(tags, normalizeTags(memo.Tags)...)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// describeRetag lists what the tag policy did to tags, one entry per ruled
+// tag, along with the distinct reasons of those rules.
+func describeRetag(tags []string, byTag map[string]TagRule) (changes, reasons []string) {
+	for _, t := range tags {
+		rule, ok := byTag[t]
+		if !ok {
+			continue
+		}
+		if rule.Action == tagActionMerge {
+			changes = append(changes, fmt.Sprintf("%q merged into %q", t, rule.Into))
+		} else {
+			changes = append(changes, fmt.Sprintf("%q removed", t))
+		}
+		if rule.Reason != "" && !slices.Contains(reasons, rule.Reason) {
+			reasons = append(reasons, rule.Reason)
+		}
+	}
+	return changes, reasons
+}
+
+// GET /api/admin/tags — list blocked and merged tags (admin only).
+func handleAdminTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	rules, err := store.ListTagRules()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list tag rules"})
+		return
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// PUT /api/admin/tags/{tag} — block a tag or merge it into another; the
+// reason is required and passed on to affected authors. DELETE lifts the
+// rule, with an optional ?reason=. Either queues a retag of existing packs
+// (admin only).
+func handleAdminTag(w http.ResponseWriter, r *http.Request) {
+	admin := currentUser(r)
+	tag := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/admin/tags/")))
+	switch r.Method {
+	case http.MethodPut:
+		var req TagRuleReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		rule := &TagRule{
+			Tag:       tag,
+			Action:    req.Action,
+			Into:      strings.ToLower(strings.TrimSpace(req.Into)),
+			Reason:    strings.TrimSpace(req.Reason),
+			CreatedAt: nowISO(),
+		}
+		if err := validateAdminReason(rule.Reason); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if status, err := validateTagRule(rule); err != nil {
+			writeJSON(w, status, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if err := store.SaveTagRule(rule); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save tag rule"})
+			return
+		}
+		recordAudit(r, admin, auditTagRule, "tag", tag, rule.Reason)
+	case http.MethodDelete:
+		reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+		if utf8.RuneCountInString(reason) > maxAdminReasonChars {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("reason must be at most %d characters", maxAdminReasonChars)})
+			return
+		}
+		found, err := store.DeleteTagRule(tag)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete tag rule"})
+			return
+		}
+		if !found {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "no rule for this tag"})
+			return
+		}
+		recordAudit(r, admin, auditTagRuleLift, "tag", tag, reason)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	if err := loadTagPolicy(); err != nil {
+		log.Printf("tag policy: %v", err)
+	}
+	job, err := queueRetag()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to queue retag"})
+		return
+	}
+	rules, _ := store.ListTagRules()
+	writeJSON(w, http.StatusOK, TagPolicyUpdate{Rules: rules, Job: job})
+}
+
+// validateAuthorRetag normalizes an author's retag request.
+func validateAuthorRetag(req *AuthorRetagReq) error {
+	req.Tag = strings.ToLower(strings.TrimSpace(req.Tag))
+	req.To = strings.ToLower(strings.TrimSpace(req.To))
+	if req.Tag == "" {
+		return fmt.Errorf("tag is required")
+	}
+	if len(req.Tag) > maxTagChars || len(req.To) > maxTagChars {
+		return fmt.Errorf("tags are limited to %d characters", maxTagChars)
+	}
+	switch req.Action {
+	case retagAdd, retagRemove:
+		if req.To != "" {
+			return fmt.Errorf("to is only used with rename")
+		}
+	case retagRename:
+		if req.To == "" || req.To == req.Tag {
+			return fmt.Errorf("rename needs a different tag in to")
+		}
+	default:
+		return fmt.Errorf("action must be add, remove or rename")
+	}
+	return nil
+}
+
+// retagItem applies req to one rule's or memo's tags, then the tag policy.
+func retagItem(tags []string, req *AuthorRetagReq) []string {
+	switch req.Action {
+	case retagAdd:
+		tags = append(tags, req.Tag)
+	case retagRemove:
+		tags = slices.DeleteFunc(tags, func(t string) bool { return t == req.Tag })
+	case retagRename:
+		for i, t := range tags {
+			if t == req.Tag {
+				tags[i] = req.To
+			}
+		}
+	}
+	return applyTagPolicy(normalizeTags(tags))
+}
+
+// POST /api/me/memo-packs/retag — add, remove or rename a rule and memo tag
+// across all my packs, or those in pack_ids, in one transaction (auth required).
+func handleMyRetag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	var req AuthorRetagReq
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if err := validateAuthorRetag(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	own, err := store.ListAuthorPacks(user.ID, false)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list packs"})
+		return
+	}
+	ids := make([]string, 0, len(own))
+	var archived []string
+	for _, p := range own {
+		if p.Archived {
+			archived = append(archived, p.ID)
+			continue
+		}
+		ids = append(ids, p.ID)
+	}
+	if len(req.PackIDs) > 0 {
+		for i, id := range req.PackIDs {
+			id = resolvePackID(id)
+			req.PackIDs[i] = id
+			if slices.Contains(archived, id) {
+				writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is archived: " + id})
+				return
+			}
+			if !slices.Contains(ids, id) {
+				writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found: " + id})
+				return
+			}
+		}
+		ids = req.PackIDs
+	}
+
+	result := AuthorRetagResult{Packs: []string{}}
+	var changed []*MemoPack
+	for _, id := range ids {
+		mp, err := store.GetMemoPack(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load pack " + id})
+			return
+		}
+		rules, memos := MarshalRules(mp.Rules), MarshalMemos(mp.Memos)
+		for i := range mp.Rules {
+			mp.Rules[i].Tags = retagItem(mp.Rules[i].Tags, &req)
+		}
+		for i := range mp.Memos {
+			mp.Memos[i].Tags = retagItem(mp.Memos[i].Tags, &req)
+		}
+		if MarshalRules(mp.Rules) == rules && MarshalMemos(mp.Memos) == memos {
+			continue
+		}
+		changed = append(changed, mp)
+		result.Packs = append(result.Packs, mp.ID)
+	}
+	if err := store.SaveRetaggedPacks(changed); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to retag packs"})
+		return
+	}
+	result.Changed = len(changed)
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,105 @@
+package memomarket
+
+import (
+	"cmp"
+	"net/http"
+	"strings"
+)
+
+// GET /api/users/{username} — a user's public profile with pack totals and
+// pinned packs (public).
+func handleUserProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	username := extractID(r.URL.Path, "/api/users/")
+	if username == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "missing username"})
+		return
+	}
+	profile, err := store.GetUserProfile(username)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user not found"})
+		return
+	}
+	profile.DisplayName = cmp.Or(profile.DisplayName, profile.Username)
+	if verified, err := store.ListVerifiedIdentities([]string{profile.ID}); err == nil {
+		profile.Verified = verified[profile.ID]
+	}
+	if v := activeVacation(profile.ID); v != nil {
+		v.Active = true
+		profile.Away = v
+	}
+	profile.Pinned = pinnedPacks(r, profile.ID)
+	setCacheHeaders(w, cacheListing, false)
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// GET /api/me/profile — my public profile. PUT replaces the display name,
+// bio, website and avatar URL (auth required).
+func handleMyProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if r.Method == http.MethodPut {
+		if !requireWritable(w, user) {
+			return
+		}
+		var req ProfileReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		if err := validateProfile(&req, user); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		before, err := store.GetUserProfile(user.Username)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load profile"})
+			return
+		}
+		if err := store.UpdateUserProfile(user.ID, &req); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update profile"})
+			return
+		}
+		writeAudit(r, user, &AuditEntry{
+			Action: auditProfileEdit, TargetKind: "user", TargetID: user.ID,
+			Diff: profileDiff(before, &req),
+		})
+	}
+	profile, err := store.GetUserProfile(user.Username)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load profile"})
+		return
+	}
+	profile.DisplayName = cmp.Or(profile.DisplayName, profile.Username)
+	profile.Pinned = pinnedPacks(r, user.ID)
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// profileDiff names the profile fields an update changed.
+func profileDiff(before *UserProfile, after *ProfileReq) string {
+	var changed []string
+	for _, f := range []struct{ name, from, to string }{
+		{"display_name", before.DisplayName, after.DisplayName},
+		{"bio", before.Bio, after.Bio},
+		{"website", before.Website, after.Website},
+		{"avatar_url", before.AvatarURL, after.AvatarURL},
+	} {
+		if f.from != f.to {
+			changed = append(changed, f.name)
+		}
+	}
+	if len(changed) == 0 {
+		return "no changes"
+	}
+	return strings.Join(changed, "; ")
+}
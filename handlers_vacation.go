@@ -0,0 +1,129 @@
+package memomarket
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Vacation (read-only) mode: for a chosen window an author's account can't
+// publish, edit or delete packs, notifications are paused, and reviewers of
+// their packs get the author's away message as an auto-reply.
+
+const (
+	maxVacationDays         = 365
+	maxVacationMessageChars = 500
+)
+
+// activeVacation returns userID's vacation if it is in effect now.
+func activeVacation(userID string) *Vacation {
+	v, err := store.GetVacation(userID)
+	if err != nil || !v.active() {
+		return nil
+	}
+	return v
+}
+
+func (v *Vacation) active() bool {
+	now := nowISO()
+	return v.StartsAt <= now && now < v.EndsAt
+}
+
+// requireWritable rejects writes from an account in read-only mode, so stale
+// CI tokens can't change packs while the author is away. It writes the error
+// response and returns false when the request must stop.
+func requireWritable(w http.ResponseWriter, user *User) bool {
+	if v := activeVacation(user.ID); v != nil {
+		writeJSON(w, http.StatusLocked, ErrorResponse{Error: "account is in read-only mode until " + v.EndsAt})
+		return false
+	}
+	return true
+}
+
+// parseTimeParam accepts a nowISO timestamp or a plain date.
+func parseTimeParam(s string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// validateVacation checks the requested window and message, normalizing
+// times to nowISO format. A missing start means now.
+func validateVacation(req *VacationReq) (*Vacation, string) {
+	now := time.Now().UTC().Truncate(time.Second)
+	start := now
+	if req.StartsAt != "" {
+		t, ok := parseTimeParam(req.StartsAt)
+		if !ok {
+			return nil, "starts_at must be a date or timestamp"
+		}
+		start = t
+	}
+	end, ok := parseTimeParam(req.EndsAt)
+	if !ok {
+		return nil, "ends_at must be a date or timestamp"
+	}
+	if !end.After(start) || !end.After(now) {
+		return nil, "ends_at must be in the future and after starts_at"
+	}
+	if end.Sub(now) > maxVacationDays*24*time.Hour {
+		return nil, "vacation can't extend more than a year ahead"
+	}
+	msg := strings.TrimSpace(req.Message)
+	if utf8.RuneCountInString(msg) > maxVacationMessageChars {
+		return nil, "message is too long"
+	}
+	return &Vacation{
+		StartsAt: start.Format("2006-01-02T15:04:05"),
+		EndsAt:   end.Format("2006-01-02T15:04:05"),
+		Message:  msg,
+	}, ""
+}
+
+// GET /api/me/vacation — my read-only window; PUT sets it, DELETE ends it early (auth required).
+func handleMyVacation(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		v, err := store.GetVacation(user.ID)
+		if err != nil || v.EndsAt <= nowISO() {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "no vacation scheduled"})
+			return
+		}
+		v.Active = v.active()
+		writeJSON(w, http.StatusOK, v)
+	case http.MethodPut:
+		var req VacationReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		v, msg := validateVacation(&req)
+		if v == nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: msg})
+			return
+		}
+		if err := store.SaveVacation(user.ID, v); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save vacation"})
+			return
+		}
+		v.Active = v.active()
+		writeJSON(w, http.StatusOK, v)
+	case http.MethodDelete:
+		if err := store.DeleteVacation(user.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to end vacation"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
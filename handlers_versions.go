@@ -0,0 +1,172 @@
+package memomarket
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Release channels. A version published to beta is only served to callers
+// that ask for ?channel=beta until the author promotes it to stable.
+const (
+	channelStable = "stable"
+	channelBeta   = "beta"
+)
+
+// validateChannel accepts "", meaning the default, or a known channel.
+func validateChannel(channel string) error {
+	switch channel {
+	case "", channelStable, channelBeta:
+		return nil
+	}
+	return fmt.Errorf("channel must be stable or beta")
+}
+
+// GET /api/memo-packs/{id}/versions — list a pack's version history, newest first (public).
+// Content is omitted for anonymous callers when the pack requires auth.
+func handleListVersions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	versions, err := store.ListMemoPackVersions(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list versions"})
+		return
+	}
+	if restrictContent(r, pack) {
+		for i := range versions {
+			versions[i].SystemPrompt = ""
+			versions[i].Rules = []MemoRule{}
+			versions[i].Memos = []Memo{}
+		}
+	}
+	setCacheHeaders(w, cacheListing, privateRead(pack))
+	writeJSON(w, http.StatusOK, versions)
+}
+
+// resolvePackVersion picks the stored version matching spec: "latest", an exact
+// version, or a constraint such as "^1.2.0". Constraints select the highest
+// matching release and skip pre-releases; on the stable channel they also
+// skip beta versions, while beta also considers pre-releases.
+func resolvePackVersion(pack *MemoPack, spec, channel string) (*MemoPackVersion, error) {
+	if spec == "latest" {
+		if channel != channelBeta && pack.Channel == channelBeta {
+			return channelVersion(pack, channel)
+		}
+		spec = pack.Version
+	}
+	if validateVersion(spec) == nil {
+		v, err := store.GetMemoPackVersion(pack.ID, spec)
+		if err != nil {
+			return nil, fmt.Errorf("version %s not found", spec)
+		}
+		return v, nil
+	}
+
+	versions, err := store.ListMemoPackVersions(pack.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		v := &versions[i]
+		if channel != channelBeta && (v.Channel == channelBeta || isPrerelease(v.Version)) {
+			continue
+		}
+		if matchVersionConstraint(v.Version, spec) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("no version matches %q", spec)
+}
+
+// channelVersion returns the newest version of pack on channel, or nil when
+// the pack itself already is. Beta follows the pack's newest version.
+func channelVersion(pack *MemoPack, channel string) (*MemoPackVersion, error) {
+	if channel == channelBeta || pack.Channel != channelBeta {
+		return nil, nil
+	}
+	v, err := store.LatestStableVersion(pack.ID)
+	if err != nil {
+		return nil, fmt.Errorf("no stable version yet; use ?channel=beta")
+	}
+	return v, nil
+}
+
+// parseAsOf turns an as_of parameter into a nowISO timestamp. A plain date
+// means the end of that day.
+func parseAsOf(s string) (string, bool) {
+	t, ok := parseTimeParam(s)
+	if !ok {
+		return "", false
+	}
+	if len(s) == len("2006-01-02") {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t.Format("2006-01-02T15:04:05"), true
+}
+
+// POST /api/memo-packs/{id}/versions/{version}/promote — move a beta version
+// to the stable channel (auth required).
+func handlePromoteVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if !canEditPack(user, pack) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+		return
+	}
+	if rejectArchived(w, pack) {
+		return
+	}
+	_, rest, _ := strings.Cut(r.URL.Path, "/versions/")
+	version := strings.TrimSuffix(rest, "/promote")
+	v, err := store.GetMemoPackVersion(pack.ID, version)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "version " + version + " not found"})
+		return
+	}
+	if v.Channel == channelStable {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "version " + version + " is already stable"})
+		return
+	}
+	if err := store.PromoteMemoPackVersion(pack.ID, version); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to promote version"})
+		return
+	}
+	v.Channel = channelStable
+	notifyPromoted(pack, version)
+	writeJSON(w, http.StatusOK, v)
+}
+
+// applyPackVersion replaces a pack's content with a historical snapshot.
+func applyPackVersion(pack *MemoPack, v *MemoPackVersion) {
+	pack.Version = v.Version
+	pack.Name = v.Name
+	pack.Description = v.Description
+	pack.SystemPrompt = v.SystemPrompt
+	pack.Rules = v.Rules
+	pack.Memos = v.Memos
+	pack.UpdatedAt = v.UpdatedAt
+	pack.Checksum = v.Checksum
+}
@@ -0,0 +1,187 @@
+package memomarket
+
+import (
+	"net/http"
+	"strings"
+)
+
+// GET /api/me/webhooks — list my webhooks; POST creates one (auth required).
+func handleMyWebhooks(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		hooks, err := store.ListUserWebhooks(user.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list webhooks"})
+			return
+		}
+		writeJSON(w, http.StatusOK, hideWebhookSecrets(hooks))
+	case http.MethodPost:
+		var req CreateWebhookReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		wh := &Webhook{
+			ID:        newID(),
+			OwnerID:   user.ID,
+			URL:       req.URL,
+			Kind:      req.Kind,
+			Events:    req.Events,
+			Secret:    newWebhookSecret(),
+			CreatedAt: nowISO(),
+		}
+		if err := validateWebhook(wh); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if err := store.InsertWebhook(wh); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create webhook"})
+			return
+		}
+		writeJSON(w, http.StatusCreated, wh)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// DELETE /api/me/webhooks/{id} — remove one of my webhooks.
+// GET /api/me/webhooks/{id}/deliveries — recent delivery attempts (auth required).
+func handleMyWebhook(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	id := extractID(r.URL.Path, "/api/me/webhooks/")
+	wh, err := store.GetWebhook(id)
+	if err != nil || wh.OwnerID != user.ID {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "webhook not found"})
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/deliveries") && r.Method == http.MethodGet:
+		deliveries, err := store.ListWebhookDeliveries(wh.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list deliveries"})
+			return
+		}
+		writeJSON(w, http.StatusOK, deliveries)
+	case r.Method == http.MethodDelete:
+		if _, err := store.DeleteWebhook(wh.ID, user.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// GET /api/memo-packs/{id}/webhooks — list the pack's webhooks; POST adds one (author only).
+// DELETE /api/memo-packs/{id}/webhooks/{wid} removes one;
+// POST /api/memo-packs/{id}/webhooks/{wid}/test sends a signed test delivery.
+func handlePackWebhooks(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if pack.AuthorID != user.ID {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+		return
+	}
+
+	webhookID := extractID(r.URL.Path, "/api/memo-packs/"+pack.ID+"/webhooks/")
+	if webhookID == "" {
+		switch r.Method {
+		case http.MethodGet:
+			hooks, err := store.ListPackWebhooks(pack.ID)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list webhooks"})
+				return
+			}
+			writeJSON(w, http.StatusOK, hideWebhookSecrets(hooks))
+		case http.MethodPost:
+			var req CreateWebhookReq
+			if err := decodeJSON(r, &req); err != nil {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+				return
+			}
+			wh := &Webhook{
+				ID:        newID(),
+				OwnerID:   user.ID,
+				PackID:    pack.ID,
+				URL:       req.URL,
+				Kind:      req.Kind,
+				Events:    req.Events,
+				Secret:    newWebhookSecret(),
+				CreatedAt: nowISO(),
+			}
+			if err := validateWebhook(wh); err != nil {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+				return
+			}
+			if err := store.InsertWebhook(wh); err != nil {
+				writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create webhook"})
+				return
+			}
+			writeJSON(w, http.StatusCreated, wh)
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		}
+		return
+	}
+
+	wh, err := store.GetWebhook(webhookID)
+	if err != nil || wh.PackID != pack.ID {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "webhook not found"})
+		return
+	}
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/test") && r.Method == http.MethodPost:
+		d := &WebhookDelivery{
+			ID:            newID(),
+			WebhookID:     wh.ID,
+			Event:         EventPing,
+			Payload:       string(renderWebhookPayload(wh.Kind, EventPing, pack, nil)),
+			Status:        "delivered",
+			Attempts:      1,
+			NextAttemptAt: nowISO(),
+			CreatedAt:     nowISO(),
+		}
+		if err := postWebhook(wh, d); err != nil {
+			d.Status = "failed"
+			d.LastError = err.Error()
+		} else {
+			d.DeliveredAt = nowISO()
+		}
+		store.InsertWebhookDelivery(d)
+		writeJSON(w, http.StatusOK, d)
+	case r.Method == http.MethodDelete:
+		if _, err := store.DeleteWebhook(wh.ID, user.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+func hideWebhookSecrets(hooks []Webhook) []Webhook {
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks
+}
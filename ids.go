@@ -0,0 +1,87 @@
+package memomarket
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// Pack IDs are UUIDs unless config.json asks for short base58 IDs, which
+// read better in shared links and on the command line. Existing packs keep
+// their IDs, so both kinds are accepted everywhere.
+
+const (
+	idStrategyUUID  = "uuid"
+	idStrategyShort = "short"
+)
+
+// base58Alphabet leaves out 0, O, I and l, which are easily confused.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+const (
+	defaultShortIDLength = 10
+	minShortIDLength     = 8
+	maxShortIDLength     = 22
+)
+
+// shortIDAttempts is how many short IDs are tried before falling back to a
+// UUID, which can't collide with them.
+const shortIDAttempts = 5
+
+var idConfig = struct {
+	strategy string
+	length   int
+}{strategy: idStrategyUUID, length: defaultShortIDLength}
+
+func loadIDConfig(cfg *IDConfig) {
+	idConfig.strategy = idStrategyUUID
+	idConfig.length = defaultShortIDLength
+	if cfg == nil {
+		return
+	}
+	if cfg.Strategy == idStrategyShort {
+		idConfig.strategy = idStrategyShort
+	}
+	if cfg.Length >= minShortIDLength && cfg.Length <= maxShortIDLength {
+		idConfig.length = cfg.Length
+	}
+}
+
+// validateIDConfig reports settings loadIDConfig would ignore.
+func validateIDConfig(cfg *IDConfig) error {
+	if cfg.Strategy != "" && cfg.Strategy != idStrategyUUID && cfg.Strategy != idStrategyShort {
+		return fmt.Errorf("strategy must be uuid or short")
+	}
+	if cfg.Length != 0 && (cfg.Length < minShortIDLength || cfg.Length > maxShortIDLength) {
+		return fmt.Errorf("length must be %d-%d", minShortIDLength, maxShortIDLength)
+	}
+	return nil
+}
+
+// newPackID returns an ID for a new pack in the configured scheme.
+func newPackID() string {
+	if idConfig.strategy != idStrategyShort {
+		return newID()
+	}
+	for range shortIDAttempts {
+		id := shortID(idConfig.length)
+		if !store.PackIDExists(id) {
+			return id
+		}
+	}
+	return newID()
+}
+
+// shortID returns n random base58 characters.
+func shortID(n int) string {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(base58Alphabet)))
+	for i := range b {
+		k, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = base58Alphabet[k.Int64()]
+	}
+	return string(b)
+}
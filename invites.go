@@ -0,0 +1,123 @@
+package memomarket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// With invite_only set in config.json, POST /api/register needs an invite
+// code, so a private channel only takes the people it invited. Admins hand
+// out codes good for one registration or several. The usernames listed as
+// admins in config.json can register without one, so a new channel can
+// get its first admin.
+
+const inviteCodePrefix = "mmi_"
+
+const maxInviteNoteLength = 200
+
+// inviteOnly is set from config.json, reported by /api/capabilities.
+var inviteOnly bool
+
+func newInviteCode() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return inviteCodePrefix + hex.EncodeToString(b)
+}
+
+// useInvite takes one use of an invite code for a registration. It writes
+// the error response and returns false when the code is missing or can't be
+// used.
+func useInvite(w http.ResponseWriter, code string) bool {
+	if code == "" {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "registration requires an invite code"})
+		return false
+	}
+	ok, err := store.UseInvite(code)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to check invite code"})
+		return false
+	}
+	if !ok {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "invite code is invalid, expired or used up"})
+		return false
+	}
+	return true
+}
+
+// GET /api/admin/invites — list invite codes and how much they've been used
+// (admin only). POST creates one from {max_uses, expires_at, note}; max_uses
+// defaults to 1 and 0 is unlimited.
+func handleAdminInvites(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		invites, err := store.ListInvites()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list invites"})
+			return
+		}
+		writeJSON(w, http.StatusOK, invites)
+	case http.MethodPost:
+		var req InviteReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		admin := currentUser(r)
+		inv := &Invite{Code: newInviteCode(), Note: strings.TrimSpace(req.Note), MaxUses: 1, CreatedBy: admin.ID, CreatedAt: nowISO()}
+		if req.MaxUses != nil {
+			if *req.MaxUses < 0 {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "max_uses can't be negative"})
+				return
+			}
+			inv.MaxUses = *req.MaxUses
+		}
+		if utf8.RuneCountInString(inv.Note) > maxInviteNoteLength {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "note is too long"})
+			return
+		}
+		if req.ExpiresAt != "" {
+			t, ok := parseTimeParam(strings.TrimSpace(req.ExpiresAt))
+			if !ok {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "expires_at must be a date or timestamp"})
+				return
+			}
+			if inv.ExpiresAt = t.Format("2006-01-02T15:04:05"); inv.ExpiresAt <= inv.CreatedAt {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "expires_at must be in the future"})
+				return
+			}
+		}
+		if err := store.InsertInvite(inv); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create invite"})
+			return
+		}
+		recordAudit(r, admin, auditInviteCreate, "invite", inv.Code, inv.Note)
+		inv.CreatedBy = admin.Username
+		writeJSON(w, http.StatusCreated, inv)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// DELETE /api/admin/invites/{code} — revoke an invite code (admin only).
+// Accounts already registered with it are not affected.
+func handleAdminInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	code := strings.TrimPrefix(r.URL.Path, "/api/admin/invites/")
+	found, err := store.DeleteInvite(code)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to revoke invite"})
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "invite not found"})
+		return
+	}
+	recordAudit(r, currentUser(r), auditInviteRevoke, "invite", code, "")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+}
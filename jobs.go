@@ -0,0 +1,107 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Job kinds and their runners. A runner returns the job's result on success.
+const JobAccountExport = "account_export"
+
+var jobRunners = map[string]func(*Job) (string, error){
+	JobAccountExport:    runAccountExport,
+	JobCleanup:          runCleanup,
+	JobCollectionBundle: runCollectionBundle,
+	JobEmbargoRelease:   runEmbargoRelease,
+	JobRetag:            runRetag,
+	JobSendEmail:        runSendEmail,
+}
+
+const maxJobAttempts = 3
+
+// enqueueJob queues a job of kind for immediate execution.
+func enqueueJob(kind, userID string, payload any) (*Job, error) {
+	return enqueueJobAt(kind, userID, payload, nowISO())
+}
+
+// enqueueJobAt queues a job of kind to run at runAt, in nowISO format.
+func enqueueJobAt(kind, userID string, payload any, runAt string) (*Job, error) {
+	var raw string
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		raw = string(b)
+	}
+	j := &Job{ID: newID(), Kind: kind, UserID: userID, Status: "pending", Payload: raw, RunAt: runAt, CreatedAt: nowISO()}
+	if err := store.InsertJob(j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// runJobWorker runs queued jobs until the workers are stopped.
+func runJobWorker() {
+	if err := store.RequeueRunningJobs(); err != nil {
+		log.Printf("job worker: %v", err)
+	}
+	for {
+		runDueJobs()
+		if !idle(2 * time.Second) {
+			return
+		}
+	}
+}
+
+// runDueJobs runs a batch of the jobs that are due and returns how many ran.
+func runDueJobs() int {
+	jobs, err := store.ListDueJobs(nowISO(), 10)
+	if err != nil {
+		log.Printf("job worker: %v", err)
+	}
+	for i := range jobs {
+		runJob(&jobs[i])
+	}
+	return len(jobs)
+}
+
+func runJob(j *Job) {
+	run, ok := jobRunners[j.Kind]
+	if !ok {
+		j.Status = "failed"
+		j.Error = fmt.Sprintf("unknown job kind %q", j.Kind)
+		j.FinishedAt = nowISO()
+		store.UpdateJob(j)
+		return
+	}
+
+	j.Status = "running"
+	j.Attempts++
+	if err := store.UpdateJob(j); err != nil {
+		log.Printf("job worker: failed to start job %s: %v", j.ID, err)
+		return
+	}
+	result, err := run(j)
+	if err == nil {
+		j.Status = "done"
+		j.Result = result
+		j.Error = ""
+		j.FinishedAt = nowISO()
+	} else {
+		log.Printf("job %s (%s) attempt %d: %v", j.ID, j.Kind, j.Attempts, err)
+		j.Error = err.Error()
+		if j.Attempts >= maxJobAttempts {
+			j.Status = "failed"
+			j.FinishedAt = nowISO()
+		} else {
+			j.Status = "pending"
+			j.RunAt = time.Now().UTC().Add(time.Duration(j.Attempts) * time.Minute).Format("2006-01-02T15:04:05")
+		}
+	}
+	if err := store.UpdateJob(j); err != nil {
+		log.Printf("job worker: failed to update job %s: %v", j.ID, err)
+	}
+}
@@ -0,0 +1,147 @@
+package memomarket
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+// Content limits cap what a single pack may hold, so one publish can't
+// stuff megabytes into a row. They apply to publishes, updates and GitHub
+// imports, and a request breaking several of them gets all the violations
+// back at once, each naming the offending field.
+
+var contentLimits = defaultContentLimits
+
+var defaultContentLimits = ContentLimits{
+	MaxRules:             200,
+	MaxMemos:             500,
+	MaxSystemPromptChars: 50000,
+	MaxTitleChars:        200,
+	MaxMemoChars:         20000,
+	MaxTags:              20,
+	MaxRequestBytes:      5 << 20,
+	MaxAttachments:       20,
+	MaxAttachmentBytes:   10 << 20,
+}
+
+// maxLimitErrors caps how many violations are reported.
+const maxLimitErrors = 50
+
+// loadContentLimits applies cfg over the defaults: zero keeps a default and
+// a negative value lifts the limit.
+func loadContentLimits(cfg *ContentLimits) {
+	contentLimits = defaultContentLimits
+	if cfg == nil {
+		return
+	}
+	for _, f := range []struct {
+		dst *int
+		val int
+	}{
+		{&contentLimits.MaxRules, cfg.MaxRules},
+		{&contentLimits.MaxMemos, cfg.MaxMemos},
+		{&contentLimits.MaxSystemPromptChars, cfg.MaxSystemPromptChars},
+		{&contentLimits.MaxTitleChars, cfg.MaxTitleChars},
+		{&contentLimits.MaxMemoChars, cfg.MaxMemoChars},
+		{&contentLimits.MaxTags, cfg.MaxTags},
+		{&contentLimits.MaxRequestBytes, cfg.MaxRequestBytes},
+		{&contentLimits.MaxAttachments, cfg.MaxAttachments},
+		{&contentLimits.MaxAttachmentBytes, cfg.MaxAttachmentBytes},
+	} {
+		if f.val != 0 {
+			*f.dst = max(f.val, 0)
+		}
+	}
+}
+
+// checkContentLimits returns every way the content breaks the limits.
+func checkContentLimits(systemPrompt string, rules []MemoRule, memos []Memo) []FieldError {
+	l := contentLimits
+	var errs []FieldError
+	add := func(field, msg string, limit int) {
+		if len(errs) < maxLimitErrors {
+			errs = append(errs, FieldError{Field: field, Message: msg, Limit: limit})
+		}
+	}
+	over := func(s string, limit int) bool {
+		return limit > 0 && utf8.RuneCountInString(s) > limit
+	}
+	tags := func(field string, tags []string) {
+		if l.MaxTags > 0 && len(tags) > l.MaxTags {
+			add(field, fmt.Sprintf("at most %d tags", l.MaxTags), l.MaxTags)
+		}
+		for i, t := range tags {
+			if over(t, maxTagChars) {
+				add(fmt.Sprintf("%s[%d]", field, i), fmt.Sprintf("tags are limited to %d characters", maxTagChars), maxTagChars)
+			}
+		}
+	}
+
+	if over(systemPrompt, l.MaxSystemPromptChars) {
+		add("system_prompt", fmt.Sprintf("at most %d characters", l.MaxSystemPromptChars), l.MaxSystemPromptChars)
+	}
+	if l.MaxRules > 0 && len(rules) > l.MaxRules {
+		add("rules", fmt.Sprintf("at most %d rules", l.MaxRules), l.MaxRules)
+	}
+	for i, r := range rules {
+		if over(r.Title, l.MaxTitleChars) {
+			add(fmt.Sprintf("rules[%d].title", i), fmt.Sprintf("at most %d characters", l.MaxTitleChars), l.MaxTitleChars)
+		}
+		if over(r.UpdateRule, l.MaxMemoChars) {
+			add(fmt.Sprintf("rules[%d].update_rule", i), fmt.Sprintf("at most %d characters", l.MaxMemoChars), l.MaxMemoChars)
+		}
+		tags(fmt.Sprintf("rules[%d].tags", i), r.Tags)
+	}
+	if l.MaxMemos > 0 && len(memos) > l.MaxMemos {
+		add("memos", fmt.Sprintf("at most %d memos", l.MaxMemos), l.MaxMemos)
+	}
+	for i, m := range memos {
+		if over(m.Title, l.MaxTitleChars) {
+			add(fmt.Sprintf("memos[%d].title", i), fmt.Sprintf("at most %d characters", l.MaxTitleChars), l.MaxTitleChars)
+		}
+		if over(m.Content, l.MaxMemoChars) {
+			add(fmt.Sprintf("memos[%d].content", i), fmt.Sprintf("at most %d characters", l.MaxMemoChars), l.MaxMemoChars)
+		}
+		tags(fmt.Sprintf("memos[%d].tags", i), m.Tags)
+	}
+	return errs
+}
+
+// enforceContentLimits writes the violations with status and returns false
+// when the content breaks the limits.
+func enforceContentLimits(w http.ResponseWriter, status int, systemPrompt string, rules []MemoRule, memos []Memo) bool {
+	errs := checkContentLimits(systemPrompt, rules, memos)
+	if len(errs) == 0 {
+		return true
+	}
+	writeJSON(w, status, ValidationErrors{Error: "pack exceeds the content limits", Errors: errs})
+	return false
+}
+
+// limitRequestBody caps the body of a pack write at MaxRequestBytes.
+func limitRequestBody(w http.ResponseWriter, r *http.Request) {
+	if contentLimits.MaxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(contentLimits.MaxRequestBytes))
+	}
+}
+
+// writeDecodeError answers a pack write whose body couldn't be decoded,
+// telling a body over MaxRequestBytes apart from a malformed one.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	var badFormat *formatError
+	if errors.As(err, &badFormat) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: badFormat.Error()})
+		return
+	}
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ValidationErrors{
+			Error:  "request body is too large",
+			Errors: []FieldError{{Field: "body", Message: fmt.Sprintf("at most %d bytes", tooLarge.Limit), Limit: int(tooLarge.Limit)}},
+		})
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+}
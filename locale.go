@@ -0,0 +1,142 @@
+package memomarket
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Localized packs: a pack may declare the language it is written in, and a
+// translation may name the pack it localizes as variant_of. Listings rank
+// packs in the caller's languages first and show one pack per variant
+// group, the best match, so translations don't crowd each other out.
+
+// languageTagPattern accepts lowercased BCP 47 tags such as "en", "pt-br" or
+// "zh-hant".
+var languageTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// maxLanguagePrefs caps how many preferred languages a listing ranks by.
+const maxLanguagePrefs = 5
+
+// validatePackLocale normalizes the language and variant_of fields of a
+// publish or update of pack id by authorID. A variant must point at another
+// live pack by the same author that is not itself a variant.
+func validatePackLocale(req *PublishMemoPackReq, id, authorID string) error {
+	req.Language = strings.ToLower(strings.TrimSpace(req.Language))
+	if req.Language != "" && (len(req.Language) > 35 || !languageTagPattern.MatchString(req.Language)) {
+		return fmt.Errorf("language must be a language tag such as en or pt-BR")
+	}
+	req.VariantOf = resolvePackID(strings.TrimSpace(req.VariantOf))
+	if req.VariantOf == "" {
+		return nil
+	}
+	if req.VariantOf == id {
+		return fmt.Errorf("a pack can't be a variant of itself")
+	}
+	orig, err := store.GetMemoPack(req.VariantOf)
+	if err != nil || orig.AuthorID != authorID {
+		return fmt.Errorf("variant_of must be one of your packs")
+	}
+	if orig.VariantOf != "" {
+		return fmt.Errorf("variant_of must name the original pack, not another variant")
+	}
+	if id != "" && store.HasPackVariants(id) {
+		return fmt.Errorf("this pack has variants of its own and can't become a variant")
+	}
+	return nil
+}
+
+// primaryLanguage returns the primary subtag of a language tag.
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	return primary
+}
+
+// listLanguages returns the primary languages a listing should prefer, best
+// first: ?lang=de,en when given (?lang=any turns preference off), otherwise
+// the Accept-Language header.
+func listLanguages(r *http.Request) []string {
+	if r.URL.Query().Has("lang") {
+		var langs []string
+		for _, l := range strings.Split(r.URL.Query().Get("lang"), ",") {
+			if l = primaryLanguage(l); l != "any" && languageTagPattern.MatchString(l) && !slices.Contains(langs, l) {
+				langs = append(langs, l)
+			}
+		}
+		return langs[:min(len(langs), maxLanguagePrefs)]
+	}
+	return parseAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// parseAcceptLanguage orders the primary languages of an Accept-Language
+// header by quality, dropping the wildcard and q=0 entries.
+func parseAcceptLanguage(header string) []string {
+	type pref struct {
+		lang string
+		q    float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		lang := primaryLanguage(tag)
+		if lang == "" || lang == "*" || !languageTagPattern.MatchString(lang) {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, pref{lang, q})
+		}
+	}
+	slices.SortStableFunc(prefs, func(a, b pref) int { return cmp.Compare(b.q, a.q) })
+	var langs []string
+	for _, p := range prefs {
+		if !slices.Contains(langs, p.lang) {
+			langs = append(langs, p.lang)
+		}
+	}
+	return langs[:min(len(langs), maxLanguagePrefs)]
+}
+
+// languageRankSQL ranks rows of table by their position in langs; packs in
+// none of them, or with no language, rank last.
+func languageRankSQL(table string, langs []string) (string, []any) {
+	if len(langs) == 0 {
+		return "0", nil
+	}
+	var b strings.Builder
+	var args []any
+	b.WriteString("CASE")
+	for i, l := range langs {
+		fmt.Fprintf(&b, " WHEN %s.language = ? OR %s.language LIKE ? THEN %d", table, table, i)
+		args = append(args, l, l+"-%")
+	}
+	fmt.Fprintf(&b, " ELSE %d END", len(langs))
+	return b.String(), args
+}
+
+// variantGroupFilter keeps only the best pack of each variant group: the one
+// in the most preferred language, then the original, then the oldest ID.
+func variantGroupFilter(langs []string) (string, []any) {
+	outer, outerArgs := languageRankSQL("memo_packs", langs)
+	inner, innerArgs := languageRankSQL("v", langs)
+	cond := `NOT EXISTS (SELECT 1 FROM memo_packs v
+		WHERE v.published = 1 AND v.visibility = 'public' AND v.deleted_at = '' AND v.id != memo_packs.id
+		  AND COALESCE(NULLIF(v.variant_of, ''), v.id) = COALESCE(NULLIF(memo_packs.variant_of, ''), memo_packs.id)
+		  AND (` + inner + ` < ` + outer + `
+		    OR (` + inner + ` = ` + outer + ` AND (v.variant_of = '') > (memo_packs.variant_of = ''))
+		    OR (` + inner + ` = ` + outer + ` AND (v.variant_of = '') = (memo_packs.variant_of = '') AND v.id < memo_packs.id)))`
+	var args []any
+	for range 3 {
+		args = append(append(args, innerArgs...), outerArgs...)
+	}
+	return cond, args
+}
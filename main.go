@@ -1,4 +1,4 @@
-package main
+package memomarket
 
 import (
 	"encoding/json"
@@ -14,6 +14,9 @@ import (
 var serverName = "MemoMarket"
 var serverDescription = ""
 
+// LLM provider used by the eval runner; disabled when APIURL is empty.
+var llmConfig LLMConfig
+
 func loadServerConfig(dataDir string) {
 	configPath := filepath.Join(dataDir, "config.json")
 	data, err := os.ReadFile(configPath)
@@ -22,27 +25,72 @@ func loadServerConfig(dataDir string) {
 		saveServerConfig(dataDir)
 		return
 	}
-	var info ServerInfo
-	if err := json.Unmarshal(data, &info); err == nil {
-		if info.Name != "" {
-			serverName = info.Name
-		}
-		serverDescription = info.Description
+	var cfg ServerConfig
+	if err := json.Unmarshal(data, &cfg); err == nil {
+		applyServerConfig(&cfg)
+	}
+}
+
+// applyServerConfig puts the settings of cfg into effect.
+func applyServerConfig(cfg *ServerConfig) {
+	if cfg.Name != "" {
+		serverName = cfg.Name
+	}
+	serverDescription = cfg.Description
+	if cfg.LLM != nil {
+		llmConfig = *cfg.LLM
+	}
+	channelWebhooks = cfg.Webhooks
+	loadNamingPolicy(cfg.NamingPolicy)
+	loadRateLimits(cfg.RateLimits)
+	loadRetention(cfg.Retention)
+	adminUsernames = cfg.Admins
+	loadConcurrencyLimits(cfg.Concurrency)
+	loadStandby(cfg.Standby)
+	loadCacheConfig(cfg.Cache)
+	appealURL = cfg.AppealURL
+	if cfg.SMTP != nil && cfg.SMTP.Host != "" {
+		smtpConfig = cfg.SMTP
+	}
+	loadIDConfig(cfg.IDs)
+	loadAnonymousPublishing(cfg.AnonymousPublishing)
+	loadTrustLevels(cfg.TrustLevels)
+	inviteOnly = cfg.InviteOnly
+	loadQuotas(cfg.Quotas)
+	loadCookieConfig(cfg.Cookies)
+	loadOIDC(cfg.OIDC)
+	loadDuplicatesConfig(cfg.Duplicates)
+	loadContentLimits(cfg.Limits)
+	loadAttachmentsConfig(cfg.Attachments)
+	loadURLImportConfig(cfg.URLImport)
+	loadPromptExportConfig(cfg.PromptExport)
+	if cfg.PackBlobThreshold > 0 {
+		packBlobThreshold = cfg.PackBlobThreshold
 	}
 }
 
+// startBackgroundWorkers starts the workers that write to the database; a
+// standby runs them only once promoted.
+func startBackgroundWorkers() {
+	goWorker(runWebhookWorker)
+	goWorker(runJobWorker)
+	goWorker(runCleanupScheduler)
+}
+
 func saveServerConfig(dataDir string) {
 	configPath := filepath.Join(dataDir, "config.json")
-	data, _ := json.MarshalIndent(ServerInfo{Name: serverName, Description: serverDescription}, "", "  ")
+	data, _ := json.MarshalIndent(ServerConfig{Name: serverName, Description: serverDescription}, "", "  ")
 	os.WriteFile(configPath, data, 0644)
 }
 
-func main() {
+// Main runs the memomarket command: the HTTP server, or the validate and mcp
+// subcommands, configured from the environment and config.json.
+func Main() {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	dataDir := os.Getenv("DATA_DIR")
+	dataDir = os.Getenv("DATA_DIR")
 	if dataDir == "" {
 		dataDir = "./data"
 	}
@@ -52,57 +100,68 @@ func main() {
 	if d := os.Getenv("SERVER_DESC"); d != "" {
 		serverDescription = d
 	}
+	llmConfig = LLMConfig{
+		APIURL: os.Getenv("LLM_API_URL"),
+		APIKey: os.Getenv("LLM_API_KEY"),
+		Model:  os.Getenv("LLM_MODEL"),
+	}
+	if u := os.Getenv("GITHUB_API_URL"); u != "" {
+		githubAPIBase = strings.TrimSuffix(u, "/")
+	}
+	if u := os.Getenv("GITHUB_RAW_URL"); u != "" {
+		githubRawBase = strings.TrimSuffix(u, "/")
+	}
 
 	os.MkdirAll(dataDir, 0755)
+	exportDir = filepath.Join(dataDir, "exports")
+	bundleDir = filepath.Join(dataDir, "bundles")
+	coverDir = filepath.Join(dataDir, "covers")
+	attachmentDir = filepath.Join(dataDir, "attachments")
 	loadServerConfig(dataDir)
-	InitDB(dataDir)
-	log.Printf("MemoMarket backend starting on :%s (data: %s)", port, dataDir)
-
-	mux := http.NewServeMux()
-
-	// Health check
-	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
-	})
-
-	// Server info — each backend node is a channel
-	mux.HandleFunc("/api/info", func(w http.ResponseWriter, r *http.Request) {
-		writeJSON(w, http.StatusOK, ServerInfo{Name: serverName, Description: serverDescription})
-	})
-
-	// Auth
-	mux.HandleFunc("/api/register", handleRegister)
-	mux.HandleFunc("/api/login", handleLogin)
-	mux.HandleFunc("/api/me", authMiddleware(handleMe))
+	loadServerKey(dataDir)
+	st, err := OpenSQLiteStore(dataDir)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	store = st
 
-	// Memo Packs — route by method
-	mux.HandleFunc("/api/memo-packs", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			handleListMemoPacks(w, r)
-		case http.MethodPost:
-			authMiddleware(handlePublishMemoPack)(w, r)
-		default:
-			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	// `memomarket validate` checks config, storage and the database, then exits.
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		check := runSelfCheck(dataDir)
+		printSelfCheck(check)
+		if check.failed() {
+			os.Exit(1)
 		}
-	})
-	mux.HandleFunc("/api/memo-packs/", func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/download") {
-			handleDownloadMemoPack(w, r)
-			return
+		return
+	}
+	syncChannelWebhooks()
+	if err := loadTagPolicy(); err != nil {
+		log.Fatalf("Failed to load tag policy: %v", err)
+	}
+	seedPackTemplates(filepath.Join(dataDir, templateDirName))
+
+	// `memomarket mcp` serves the channel over MCP on stdio instead of HTTP.
+	if len(os.Args) > 1 && os.Args[1] == "mcp" {
+		if err := serveMCP(os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("MCP server error: %v", err)
 		}
-		switch r.Method {
-		case http.MethodGet:
-			handleGetMemoPack(w, r)
-		case http.MethodPut:
-			authMiddleware(handleUpdateMemoPack)(w, r)
-		case http.MethodDelete:
-			authMiddleware(handleDeleteMemoPack)(w, r)
-		default:
-			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+
+	check := runSelfCheck(dataDir)
+	for _, r := range check.results {
+		if r.Status != checkOK {
+			log.Printf("self-check %s: %s: %s", r.Status, r.Name, r.Detail)
 		}
-	})
+	}
+	if check.failed() {
+		log.Fatalf("Self-check failed; run `memomarket validate` for details")
+	}
 
-	handler := corsMiddleware(mux)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), handler))
+	log.Printf("MemoMarket backend starting on :%s (data: %s)", port, dataDir)
+	srv, err := NewServer(st, ServerOptions{})
+	if err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), srv))
 }
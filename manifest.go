@@ -0,0 +1,226 @@
+package memomarket
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Pack manifests (memopack.json) are the one interchange format for packs:
+// GitHub import reads them, GET /api/memo-packs/{id}/manifest writes them and
+// POST /api/validate-manifest checks one without creating anything. A
+// manifest is a publish request plus the format version, the pack's earlier
+// versions and its assets. Files without manifest_version, written before the
+// format was versioned, read as version 1.
+
+const (
+	manifestFile    = "memopack.json"
+	manifestVersion = 1
+)
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// parseManifest decodes a manifest. Fields this server doesn't know are
+// ignored and reported as warnings, so newer minor additions still import.
+func parseManifest(data []byte) (*PackManifest, []string, error) {
+	var m PackManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, nil, err
+	}
+	var warnings []string
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&PackManifest{}); err != nil {
+		warnings = append(warnings, err.Error()+" (ignored)")
+	}
+	if m.ManifestVersion == 0 {
+		m.ManifestVersion = 1
+	}
+	return &m, warnings, nil
+}
+
+// validateManifest checks a manifest the way publishing would, collecting
+// every problem rather than stopping at the first. Checks that depend on who
+// imports it, like naming policy and variant_of ownership, are left to the
+// import.
+func validateManifest(m *PackManifest) (errs, warnings []string) {
+	add := func(err error) {
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if m.ManifestVersion > manifestVersion {
+		return []string{fmt.Sprintf("manifest_version %d is newer than this server supports (%d)", m.ManifestVersion, manifestVersion)}, nil
+	}
+	if m.ManifestVersion < 1 {
+		return []string{"manifest_version must be a positive integer"}, nil
+	}
+	if m.Name == "" {
+		errs = append(errs, "name is required")
+	}
+	add(validateRules(m.Rules))
+	for _, fe := range checkContentLimits(m.SystemPrompt, m.Rules, m.Memos) {
+		errs = append(errs, fe.Field+": "+fe.Message)
+	}
+	add(validateDeprecations(m.Rules, m.Memos))
+	add(validateMemoOrder(m.Memos))
+	if m.Version != "" {
+		add(validateVersion(m.Version))
+	}
+	add(validateChannel(m.Channel))
+	add(validateEvals(m.Evals))
+	add(validatePackLinks(&m.PublishMemoPackReq))
+	locale := m.PublishMemoPackReq
+	locale.VariantOf = ""
+	add(validatePackLocale(&locale, "", ""))
+	if m.VariantOf != "" {
+		warnings = append(warnings, "variant_of is checked on import: it must name one of the importer's packs")
+	}
+
+	current := cmp.Or(m.Version, "1.0.0")
+	seen := map[string]bool{}
+	for i := range m.Versions {
+		v := &m.Versions[i]
+		if err := validateVersion(v.Version); err != nil {
+			errs = append(errs, fmt.Sprintf("versions[%d]: %v", i, err))
+			continue
+		}
+		if seen[v.Version] || v.Version == current {
+			errs = append(errs, fmt.Sprintf("versions[%d]: version %s appears more than once", i, v.Version))
+		}
+		seen[v.Version] = true
+		if err := validateRules(v.Rules); err != nil {
+			errs = append(errs, fmt.Sprintf("versions[%d]: %v", i, err))
+		}
+		if err := validateChannel(v.Channel); err != nil {
+			errs = append(errs, fmt.Sprintf("versions[%d]: %v", i, err))
+		}
+	}
+
+	paths := map[string]bool{}
+	for i, a := range m.Assets {
+		if a.Path == "" || path.IsAbs(a.Path) || path.Clean(a.Path) != a.Path || a.Path == ".." || strings.HasPrefix(a.Path, "../") {
+			errs = append(errs, fmt.Sprintf("assets[%d]: path must be a clean relative path", i))
+		} else if paths[a.Path] {
+			errs = append(errs, fmt.Sprintf("assets[%d]: path %s appears more than once", i, a.Path))
+		}
+		paths[a.Path] = true
+		if !sha256Pattern.MatchString(a.SHA256) {
+			errs = append(errs, fmt.Sprintf("assets[%d]: sha256 must be 64 lowercase hex digits", i))
+		}
+		if a.Size < 0 {
+			errs = append(errs, fmt.Sprintf("assets[%d]: size must not be negative", i))
+		}
+		if a.URL != "" {
+			if u, err := url.Parse(a.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				errs = append(errs, fmt.Sprintf("assets[%d]: url must be an http(s) URL", i))
+			}
+		}
+	}
+	return errs, warnings
+}
+
+// packManifest writes pack and its earlier versions, oldest first, as a
+// manifest.
+func packManifest(pack *MemoPack, versions []MemoPackVersion) *PackManifest {
+	m := &PackManifest{
+		ManifestVersion: manifestVersion,
+		PublishMemoPackReq: PublishMemoPackReq{
+			Name:         pack.Name,
+			Description:  pack.Description,
+			SystemPrompt: pack.SystemPrompt,
+			Rules:        pack.Rules,
+			Memos:        pack.Memos,
+			Evals:        pack.Evals,
+			Version:      pack.Version,
+			Homepage:     pack.Homepage,
+			Repository:   pack.Repository,
+			Contact:      pack.Contact,
+			RequireAuth:  pack.RequireAuth,
+			Channel:      pack.Channel,
+			Language:     pack.Language,
+			VariantOf:    pack.VariantOf,
+			Category:     pack.Category,
+		},
+		Versions: []ManifestVersion{},
+	}
+	for _, v := range slices.Backward(versions) {
+		if v.Version == pack.Version {
+			continue
+		}
+		m.Versions = append(m.Versions, ManifestVersion{
+			Version:      v.Version,
+			Name:         v.Name,
+			Description:  v.Description,
+			SystemPrompt: v.SystemPrompt,
+			Rules:        v.Rules,
+			Memos:        v.Memos,
+			Channel:      v.Channel,
+			CreatedAt:    v.CreatedAt,
+		})
+	}
+	return m
+}
+
+// GET /api/memo-packs/{id}/manifest — the pack and its version history as a
+// memopack.json manifest (public).
+func handlePackManifest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	if pack.RequireAuth && currentUser(r) == nil && !shareGrants(r, pack) {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "sign in to download this pack"})
+		return
+	}
+	versions, err := store.ListMemoPackVersions(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list versions"})
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+manifestFile+`"`)
+	writeJSON(w, http.StatusOK, packManifest(pack, versions))
+}
+
+// POST /api/validate-manifest — check a manifest without creating anything (public).
+func handleValidateManifest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxImportFileSize+1))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "failed to read body"})
+		return
+	}
+	if len(data) > maxImportFileSize {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf("manifest is larger than %d bytes", maxImportFileSize)})
+		return
+	}
+	m, warnings, err := parseManifest(data)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	errs, more := validateManifest(m)
+	warnings = append(warnings, more...)
+	writeJSON(w, http.StatusOK, ManifestValidation{
+		Valid:           len(errs) == 0,
+		ManifestVersion: m.ManifestVersion,
+		Errors:          append([]string{}, errs...),
+		Warnings:        warnings,
+	})
+}
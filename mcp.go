@@ -0,0 +1,209 @@
+package memomarket
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+)
+
+// Minimal Model Context Protocol server over stdio (newline-delimited JSON-RPC 2.0),
+// started with `memomarket mcp`. It exposes the channel's packs as agent tools.
+
+const mcpProtocolVersion = "2024-11-05"
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+var mcpTools = []mcpTool{
+	{
+		Name:        "search_packs",
+		Description: "Search published memo packs on this channel by name, description or author.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query":  map[string]any{"type": "string", "description": "Search text"},
+				"author": map[string]any{"type": "string", "description": "Author ID filter"},
+				"page":   map[string]any{"type": "integer", "minimum": 1},
+				"limit":  map[string]any{"type": "integer", "minimum": 1, "maximum": 100},
+			},
+		},
+	},
+	{
+		Name:        "get_pack",
+		Description: "Get the full contents of a memo pack by ID or slug.",
+		InputSchema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"id": map[string]any{"type": "string"}},
+			"required":   []string{"id"},
+		},
+	},
+	{
+		Name:        "install_pack",
+		Description: "Download a memo pack for installation, optionally keeping only rules and memos with the given tags and leaving out deprecated ones.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id":                 map[string]any{"type": "string"},
+				"include_memo_tags":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"include_deprecated": map[string]any{"type": "boolean"},
+			},
+			"required": []string{"id"},
+		},
+	},
+}
+
+// serveMCP reads JSON-RPC requests from in and writes responses to out until EOF.
+func serveMCP(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(out)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			enc.Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: -32700, Message: "parse error"}})
+			continue
+		}
+		result, rerr := handleMCPRequest(&req)
+		// Notifications carry no ID and get no response.
+		if req.ID == nil {
+			continue
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rerr}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func handleMCPRequest(req *rpcRequest) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": serverName, "version": "1.0.0"},
+			"instructions":    serverDescription,
+		}, nil
+	case "notifications/initialized", "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, &rpcError{Code: -32602, Message: "invalid params"}
+		}
+		v, err := callMCPTool(p.Name, p.Arguments)
+		if err != nil {
+			return mcpToolResult(err.Error(), true), nil
+		}
+		b, _ := json.MarshalIndent(v, "", "  ")
+		return mcpToolResult(string(b), false), nil
+	default:
+		return nil, &rpcError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+}
+
+func mcpToolResult(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+func callMCPTool(name string, rawArgs json.RawMessage) (any, error) {
+	var args struct {
+		Query           string   `json:"query"`
+		Author          string   `json:"author"`
+		Page            int      `json:"page"`
+		Limit           int      `json:"limit"`
+		ID              string   `json:"id"`
+		IncludeMemoTags []string `json:"include_memo_tags"`
+		// Deprecated items are installed unless this is false.
+		IncludeDeprecated *bool `json:"include_deprecated"`
+	}
+	if len(rawArgs) > 0 {
+		if err := json.Unmarshal(rawArgs, &args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %v", err)
+		}
+	}
+
+	args.ID = resolvePackID(args.ID)
+	switch name {
+	case "search_packs":
+		q := ListQuery{Search: args.Query, Author: args.Author, Page: 1, Limit: 20}
+		if args.Page > 0 {
+			q.Page = args.Page
+		}
+		if args.Limit > 0 && args.Limit <= 100 {
+			q.Limit = args.Limit
+		}
+		packs, total, err := store.ListMemoPacks(q)
+		if err != nil {
+			log.Printf("mcp search_packs: %v", err)
+			return nil, fmt.Errorf("failed to list packs")
+		}
+		return ListResponse{Items: packs, Total: total, Page: q.Page, Limit: q.Limit}, nil
+	case "get_pack":
+		pack, err := store.GetMemoPack(args.ID)
+		if err != nil || !packReachable(pack) {
+			return nil, fmt.Errorf("pack not found")
+		}
+		return pack, nil
+	case "install_pack":
+		pack, err := store.GetMemoPack(args.ID)
+		if err != nil || !packReachable(pack) {
+			return nil, fmt.Errorf("pack not found")
+		}
+		// Installs follow the stable channel.
+		if v, err := channelVersion(pack, channelStable); err != nil {
+			return nil, err
+		} else if v != nil {
+			applyPackVersion(pack, v)
+		}
+		store.IncrementMemoPackDownloads(pack.ID, pack.Version)
+		pack.Downloads++
+		if tags := applyTagPolicy(normalizeTags(args.IncludeMemoTags)); len(tags) > 0 {
+			filterByMemoTags(pack, tags)
+		}
+		if args.IncludeDeprecated != nil && !*args.IncludeDeprecated {
+			stripDeprecated(pack)
+		}
+		return pack, nil
+	default:
+		return nil, fmt.Errorf("unknown tool %q", name)
+	}
+}
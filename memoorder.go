@@ -0,0 +1,117 @@
+package memomarket
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Memos carry an explicit order, the sequence clients inject them in, and a
+// priority saying which to keep when a client can't fit them all. Memos are
+// stored and returned sorted by order and renumbered from 1, so a publish may
+// give orders with gaps, or none at all to keep the order they are listed in.
+// PUT /api/memo-packs/{id}/memos/order rearranges them without resending the
+// content.
+
+const maxMemoPriority = 100
+
+// validateMemoOrder checks memo orders and priorities and sorts the memos by
+// order in place.
+func validateMemoOrder(memos []Memo) error {
+	for i, m := range memos {
+		if m.Order < 0 {
+			return fmt.Errorf("memo %d: order must be positive", i+1)
+		}
+		if m.Priority < -maxMemoPriority || m.Priority > maxMemoPriority {
+			return fmt.Errorf("memo %d: priority must be between %d and %d", i+1, -maxMemoPriority, maxMemoPriority)
+		}
+	}
+	orderMemos(memos)
+	return nil
+}
+
+// orderMemos sorts memos by order, leaving unordered ones where they are
+// after the ordered ones, and renumbers them from 1.
+func orderMemos(memos []Memo) {
+	slices.SortStableFunc(memos, func(a, b Memo) int {
+		switch {
+		case a.Order == b.Order:
+			return 0
+		case a.Order == 0:
+			return 1
+		case b.Order == 0:
+			return -1
+		}
+		return a.Order - b.Order
+	})
+	for i := range memos {
+		memos[i].Order = i + 1
+	}
+}
+
+// PUT /api/memo-packs/{id}/memos/order — rearrange the memos of the current
+// version; the body lists their current orders in the new sequence (owner,
+// collaborator or admin with ?reason=).
+func handleReorderMemos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	existing, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, existing) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	moderated, reason, ok := checkPackEditor(w, r, user, existing)
+	if !ok {
+		return
+	}
+	if !moderated && rejectArchived(w, existing) {
+		return
+	}
+	if !checkIfMatch(w, r, existing) {
+		return
+	}
+	var req ReorderMemosReq
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if len(req.Order) != len(existing.Memos) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("order must list all %d memos", len(existing.Memos))})
+		return
+	}
+	memos := make([]Memo, 0, len(existing.Memos))
+	seen := map[int]bool{}
+	for _, n := range req.Order {
+		if n < 1 || n > len(existing.Memos) || seen[n] {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("order must list each of 1-%d once", len(existing.Memos))})
+			return
+		}
+		seen[n] = true
+		memos = append(memos, existing.Memos[n-1])
+	}
+	for i := range memos {
+		memos[i].Order = i + 1
+	}
+
+	before := *existing
+	existing.Memos = memos
+	if err := store.UpdateMemoPack(existing); err != nil {
+		writeUpdateError(w, existing.ID, err)
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackEdit, TargetKind: "pack", TargetID: existing.ID, Reason: reason, Diff: packDiff(&before, existing)})
+	if moderated {
+		notifyModeration(existing.AuthorID, existing.ID, existing.Contact, fmt.Sprintf("An admin reordered the memos of your pack %q.", existing.Name), reason)
+	}
+	writeJSON(w, http.StatusOK, existing)
+}
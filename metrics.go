@@ -0,0 +1,243 @@
+package memomarket
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Operational metrics for GET /api/admin/metrics. Signups, publishes and
+// downloads come from the database; request counts, active users and queue
+// depth are sampled in memory into hourly buckets and reset on restart.
+
+const metricsRetentionHours = 30 * 24
+
+type metricsBucket struct {
+	requests     int
+	clientErrors int
+	serverErrors int
+	users        map[string]struct{}
+	queueDepth   int
+}
+
+var requestMetrics = struct {
+	sync.Mutex
+	buckets map[string]*metricsBucket
+	since   string
+}{buckets: map[string]*metricsBucket{}, since: nowISO()}
+
+// metricsWindows maps the accepted ?window= values to their length in hours.
+var metricsWindows = map[string]int{"24h": 24, "7d": 7 * 24, "30d": 30 * 24}
+
+func hourKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15")
+}
+
+// currentBucket returns this hour's bucket, pruning expired ones. Callers
+// hold the lock.
+func currentBucket() *metricsBucket {
+	now := time.Now()
+	key := hourKey(now)
+	b, ok := requestMetrics.buckets[key]
+	if !ok {
+		b = &metricsBucket{users: map[string]struct{}{}}
+		requestMetrics.buckets[key] = b
+		cutoff := hourKey(now.Add(-metricsRetentionHours * time.Hour))
+		for k := range requestMetrics.buckets {
+			if k < cutoff {
+				delete(requestMetrics.buckets, k)
+			}
+		}
+	}
+	return b
+}
+
+// recordActiveUser notes that userID made an authenticated request.
+func recordActiveUser(userID string) {
+	requestMetrics.Lock()
+	currentBucket().users[userID] = struct{}{}
+	requestMetrics.Unlock()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Metrics middleware — counts requests and error responses per hour.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		requestMetrics.Lock()
+		b := currentBucket()
+		b.requests++
+		switch {
+		case rec.status >= 500:
+			b.serverErrors++
+		case rec.status >= 400:
+			b.clientErrors++
+		}
+		requestMetrics.Unlock()
+	})
+}
+
+// runMetricsSampler records the job and webhook queue depth every minute,
+// keeping the hourly peak.
+func runMetricsSampler() {
+	for {
+		depth, err := store.QueueDepth()
+		if err == nil {
+			requestMetrics.Lock()
+			b := currentBucket()
+			b.queueDepth = max(b.queueDepth, depth)
+			requestMetrics.Unlock()
+		}
+		if !idle(time.Minute) {
+			return
+		}
+	}
+}
+
+// buildMetrics assembles the series for the last hours, in buckets of
+// bucketHours (1 or 24).
+func buildMetrics(window string, hours, bucketHours int) (*AdminMetrics, error) {
+	keyLen := 13 // "2006-01-02T15"
+	bucketName := "hour"
+	if bucketHours == 24 {
+		keyLen = 10
+		bucketName = "day"
+	}
+	now := time.Now().UTC()
+	from := now.Add(-time.Duration(hours-1) * time.Hour).Truncate(time.Hour)
+	if bucketHours == 24 {
+		from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	// Every bucket in the window, so series have no gaps.
+	var keys []string
+	for t := from; !t.After(now); t = t.Add(time.Duration(bucketHours) * time.Hour) {
+		keys = append(keys, t.Format("2006-01-02T15")[:keyLen])
+	}
+	series := func(counts map[string]float64) []MetricPoint {
+		points := make([]MetricPoint, len(keys))
+		for i, k := range keys {
+			points[i] = MetricPoint{Time: k, Value: counts[k]}
+		}
+		return points
+	}
+
+	m := &AdminMetrics{
+		Window:          window,
+		Bucket:          bucketName,
+		From:            from.Format("2006-01-02T15:04:05"),
+		To:              now.Format("2006-01-02T15:04:05"),
+		CollectingSince: requestMetrics.since,
+		Series:          map[string][]MetricPoint{},
+	}
+	for name, table := range map[string]string{"signups": "users", "publishes": "memo_pack_versions", "downloads": "download_events"} {
+		counts, err := store.CountByPeriod(table, m.From, keyLen)
+		if err != nil {
+			return nil, err
+		}
+		m.Series[name] = series(counts)
+	}
+
+	requests := map[string]float64{}
+	clientErrors := map[string]float64{}
+	serverErrors := map[string]float64{}
+	queueDepth := map[string]float64{}
+	users := map[string]map[string]struct{}{}
+	requestMetrics.Lock()
+	for hour, b := range requestMetrics.buckets {
+		k := hour[:keyLen]
+		requests[k] += float64(b.requests)
+		clientErrors[k] += float64(b.clientErrors)
+		serverErrors[k] += float64(b.serverErrors)
+		queueDepth[k] = max(queueDepth[k], float64(b.queueDepth))
+		if users[k] == nil {
+			users[k] = map[string]struct{}{}
+		}
+		for id := range b.users {
+			users[k][id] = struct{}{}
+		}
+	}
+	requestMetrics.Unlock()
+
+	active := map[string]float64{}
+	errorRate := map[string]float64{}
+	for k, set := range users {
+		active[k] = float64(len(set))
+	}
+	for k, n := range requests {
+		if n > 0 {
+			errorRate[k] = serverErrors[k] / n
+		}
+	}
+	m.Series["active_users"] = series(active)
+	m.Series["requests"] = series(requests)
+	m.Series["client_errors"] = series(clientErrors)
+	m.Series["server_errors"] = series(serverErrors)
+	m.Series["error_rate"] = series(errorRate)
+	m.Series["queue_depth"] = series(queueDepth)
+
+	depth, err := store.QueueDepth()
+	if err != nil {
+		return nil, err
+	}
+	m.QueueDepth = depth
+	m.Breakers = breakerStatuses()
+	return m, nil
+}
+
+// GET /api/admin/metrics?window=24h|7d|30d&bucket=hour|day — time series for
+// dashboards (admin only).
+func handleAdminMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "24h"
+	}
+	hours, ok := metricsWindows[window]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "window must be 24h, 7d or 30d"})
+		return
+	}
+	bucketHours := 1
+	switch r.URL.Query().Get("bucket") {
+	case "hour":
+	case "day":
+		bucketHours = 24
+	case "":
+		if hours > 24 {
+			bucketHours = 24
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bucket must be hour or day"})
+		return
+	}
+	m, err := buildMetrics(window, hours, bucketHours)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to compute metrics"})
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
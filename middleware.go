@@ -1,23 +1,32 @@
-package main
+package memomarket
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type contextKey string
 
 const userContextKey contextKey = "user"
+const clientContextKey contextKey = "client"
+
+const clientIDCookie = "mm_cid"
 
 // CORS middleware
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Client-ID, If-Match, X-CSRF-Token")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Client-ID, ETag, X-Content-SHA256, Content-Length, Content-Range, Retry-After")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusNoContent)
@@ -27,32 +36,61 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// Auth middleware — extracts Bearer token and attaches user to context.
+// Auth middleware — extracts the Bearer token, or the session cookie, and
+// attaches user to context.
 func authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
-		if !strings.HasPrefix(auth, "Bearer ") {
+		token, fromCookie := requestToken(r)
+		if token == "" {
 			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "missing or invalid token"})
 			return
 		}
-		token := strings.TrimPrefix(auth, "Bearer ")
-		user, err := GetUserByToken(token)
+		if fromCookie && !validCSRF(r) {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "missing or invalid CSRF token"})
+			return
+		}
+		user, err := authenticate(token)
+		if err == errTokenExpired {
+			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "token expired"})
+			return
+		}
+		if err == errAccountSuspended {
+			if ban, err := store.GetUserBan(user.ID); err == nil {
+				writeSuspended(w, ban)
+				return
+			}
+		}
 		if err != nil {
 			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid token"})
 			return
 		}
+		if fromCookie {
+			// The cookie is HttpOnly; don't hand the token to scripts.
+			user.Token = ""
+		}
+		if scope := requiredScope(r); !user.hasScope(scope) {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "API key lacks the " + scope + " scope"})
+			return
+		}
+		recordActiveUser(user.ID)
 		ctx := context.WithValue(r.Context(), userContextKey, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
-// optionalAuth attaches user if token present, but doesn't require it.
+// optionalAuth attaches user if token present, but doesn't require it. An
+// API key without the scope the request needs is ignored. A session cookie
+// without its CSRF token is refused rather than ignored, so the request
+// can't go ahead anonymously in the user's browser.
 func optionalAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
-		if strings.HasPrefix(auth, "Bearer ") {
-			token := strings.TrimPrefix(auth, "Bearer ")
-			if user, err := GetUserByToken(token); err == nil {
+		if token, fromCookie := requestToken(r); token != "" {
+			if fromCookie && !validCSRF(r) {
+				writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "missing or invalid CSRF token"})
+				return
+			}
+			if user, err := authenticate(token); err == nil && user.hasScope(requiredScope(r)) {
+				recordActiveUser(user.ID)
 				ctx := context.WithValue(r.Context(), userContextKey, user)
 				r = r.WithContext(ctx)
 			}
@@ -61,6 +99,41 @@ func optionalAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// Client ID middleware — assigns an anonymous client ID (cookie + X-Client-ID header)
+// used for unique-download metrics. Requests with DNT or GPC set get none.
+func clientIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id := r.Header.Get("X-Client-ID")
+		if id == "" {
+			if c, err := r.Cookie(clientIDCookie); err == nil {
+				id = c.Value
+			}
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			id = newID()
+			http.SetCookie(w, &http.Cookie{
+				Name:     clientIDCookie,
+				Value:    id,
+				Path:     "/",
+				MaxAge:   365 * 24 * 60 * 60,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		w.Header().Set("X-Client-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientContextKey, id)))
+	})
+}
+
+func currentClientID(r *http.Request) string {
+	id, _ := r.Context().Value(clientContextKey).(string)
+	return id
+}
+
 func currentUser(r *http.Request) *User {
 	u, _ := r.Context().Value(userContextKey).(*User)
 	return u
@@ -74,23 +147,81 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	json.NewEncoder(w).Encode(v)
 }
 
+// serveJSONContent writes v as JSON through http.ServeContent, so the response
+// supports HEAD, Range and conditional requests. It sets a strong ETag and an
+// X-Content-SHA256 checksum of the full body.
+func serveJSONContent(w http.ResponseWriter, r *http.Request, v interface{}, modTime time.Time) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to encode response"})
+		return
+	}
+	serveBytes(w, r, "application/json", append(data, '\n'), modTime)
+}
+
+// serveBytes serves an in-memory body with checksum headers via http.ServeContent.
+func serveBytes(w http.ResponseWriter, r *http.Request, contentType string, data []byte, modTime time.Time) {
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+checksum+`"`)
+	w.Header().Set("X-Content-SHA256", checksum)
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+}
+
+// parseISO parses timestamps produced by nowISO, returning the zero time on failure.
+func parseISO(s string) time.Time {
+	t, _ := time.Parse("2006-01-02T15:04:05", s)
+	return t
+}
+
 func decodeJSON(r *http.Request, v interface{}) error {
 	defer r.Body.Close()
 	return json.NewDecoder(r.Body).Decode(v)
 }
 
+// maxPageSize caps the ?limit= of list endpoints.
+const maxPageSize = 100
+
 func parseListQuery(r *http.Request) ListQuery {
 	q := ListQuery{
-		Search: r.URL.Query().Get("search"),
-		Author: r.URL.Query().Get("author"),
-		Page:   1,
-		Limit:  20,
+		Search:   r.URL.Query().Get("search"),
+		Author:   r.URL.Query().Get("author"),
+		Sort:     r.URL.Query().Get("sort"),
+		Category: r.URL.Query().Get("category"),
+
+		DebugScore: r.URL.Query().Get("debug_score") == "true",
+		Languages:  listLanguages(r),
+		Page:       1,
+		Limit:      20,
 	}
 	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
 		q.Page = p
 	}
-	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= maxPageSize {
 		q.Limit = l
 	}
+	q.MinRules = queryInt(r, "min_rules")
+	q.MaxRules = queryInt(r, "max_rules")
+	q.MinMemos = queryInt(r, "min_memos")
+	q.MaxMemos = queryInt(r, "max_memos")
+	q.MaxChars = queryInt(r, "max_chars")
+	if a, err := strconv.ParseBool(r.URL.Query().Get("archived")); err == nil {
+		q.Archived = &a
+	}
+	q.Pinned = r.URL.Query().Get("pinned") == "true"
+	q.Featured = r.URL.Query().Get("featured") == "true"
+	if q.Featured && q.Sort == "" && q.Search == "" {
+		q.Sort = "featured"
+	}
 	return q
 }
+
+// queryInt returns a non-negative integer query parameter, or nil if absent or invalid.
+func queryInt(r *http.Request, name string) *int {
+	n, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || n < 0 {
+		return nil
+	}
+	return &n
+}
@@ -0,0 +1,114 @@
+package memomarket
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Milestones celebrate a pack's reach: download counts and the first install
+// from each kind of client. They go to webhooks subscribed to the event and,
+// unless the author opted out, to the author as notifications. Authors pick
+// their own download milestones.
+
+const maxDownloadMilestones = 20
+
+// defaultMilestonePrefs applies to authors who haven't set their own.
+func defaultMilestonePrefs() *MilestonePrefs {
+	return &MilestonePrefs{Downloads: slices.Clone(downloadMilestones), NewClients: true, Notify: true}
+}
+
+// milestonePrefs returns userID's milestone settings, or the defaults.
+func milestonePrefs(userID string) *MilestonePrefs {
+	p, err := store.GetMilestonePrefs(userID)
+	if err != nil {
+		return defaultMilestonePrefs()
+	}
+	if p.Downloads == nil {
+		p.Downloads = slices.Clone(downloadMilestones)
+	}
+	return p
+}
+
+// validateMilestonePrefs sorts and de-duplicates the download milestones.
+func validateMilestonePrefs(p *MilestonePrefs) error {
+	if len(p.Downloads) > maxDownloadMilestones {
+		return fmt.Errorf("at most %d download milestones", maxDownloadMilestones)
+	}
+	for _, n := range p.Downloads {
+		if n < 1 || n > 1e9 {
+			return fmt.Errorf("download milestones must be between 1 and 1000000000")
+		}
+	}
+	if p.Downloads != nil {
+		slices.Sort(p.Downloads)
+		p.Downloads = slices.Compact(p.Downloads)
+	}
+	return nil
+}
+
+// checkDownloadMilestone emits EventDownloadMilestone when downloads hits
+// one of the author's milestones.
+func checkDownloadMilestone(pack *MemoPack) {
+	prefs := milestonePrefs(pack.AuthorID)
+	if !slices.Contains(prefs.Downloads, pack.Downloads) {
+		return
+	}
+	emitMilestone(pack, prefs, EventDownloadMilestone, map[string]any{"milestone": pack.Downloads},
+		fmt.Sprintf("%s reached %d downloads", pack.Name, pack.Downloads))
+}
+
+// checkNewClient emits EventNewClient for the first install of pack
+// reported by client.
+func checkNewClient(pack *MemoPack, client string) {
+	if client == "" {
+		return
+	}
+	prefs := milestonePrefs(pack.AuthorID)
+	if !prefs.NewClients {
+		return
+	}
+	if n, err := store.CountClientInstalls(pack.ID, client); err != nil || n != 1 {
+		return
+	}
+	emitMilestone(pack, prefs, EventNewClient, map[string]any{"client": client},
+		fmt.Sprintf("%s was installed with %s for the first time", pack.Name, client))
+}
+
+func emitMilestone(pack *MemoPack, prefs *MilestonePrefs, event string, detail map[string]any, message string) {
+	emitPackEvent(event, pack, detail)
+	if prefs.Notify {
+		notify(pack.AuthorID, "milestone", pack.ID, message)
+	}
+}
+
+// GET /api/me/milestones — my milestone settings; PUT replaces them (auth required).
+func handleMyMilestones(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, milestonePrefs(user.ID))
+	case http.MethodPut:
+		req := defaultMilestonePrefs()
+		req.Downloads = nil
+		if err := decodeJSON(r, req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		if err := validateMilestonePrefs(req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if err := store.SaveMilestonePrefs(user.ID, req); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save milestone settings"})
+			return
+		}
+		writeJSON(w, http.StatusOK, milestonePrefs(user.ID))
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
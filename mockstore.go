@@ -0,0 +1,1485 @@
+// Code generated by gen_mockstore.go; DO NOT EDIT.
+
+package memomarket
+
+// MockStore is a Store for tests. Each method calls its Func field when it
+// is set and the embedded Store otherwise, so a test can fake or fail the
+// calls it cares about over a real store:
+//
+//	st, _ := memomarket.NewMemoryStore()
+//	mock := &memomarket.MockStore{Store: st}
+//	mock.GetMemoPackFunc = func(id string) (*memomarket.MemoPack, error) {
+//		return nil, errors.New("disk full")
+//	}
+//	srv, _ := memomarket.NewServer(mock, memomarket.ServerOptions{})
+//
+// With no Store, a call without a Func panics. Set the Func fields before
+// the server uses the mock.
+type MockStore struct {
+	Store
+
+	PingFunc                       func() error
+	CreateUserFunc                 func(username, passwordHash string) (*User, error)
+	GetUserByTokenFunc             func(token string) (*User, error)
+	CreateSessionFunc              func(sess *Session) error
+	GetSessionByRefreshTokenFunc   func(refreshToken string) (*Session, error)
+	RotateSessionTokensFunc        func(sess *Session, oldRefreshToken string) (bool, error)
+	ListSessionsFunc               func(userID string) ([]Session, error)
+	DeleteSessionFunc              func(id, userID string) (bool, error)
+	PurgeSessionsFunc              func(cutoff string) (int, error)
+	GetUserByAPIKeyFunc            func(keyHash string) (*User, error)
+	CreateAPIKeyFunc               func(k *APIKey, keyHash string) error
+	ListAPIKeysFunc                func(userID string) ([]APIKey, error)
+	DeleteAPIKeyFunc               func(id, userID string) (bool, error)
+	InsertServiceAccountFunc       func(sa *ServiceAccount) error
+	GetServiceAccountFunc          func(id string) (*ServiceAccount, error)
+	ListServiceAccountsFunc        func() ([]ServiceAccount, error)
+	DeleteServiceAccountFunc       func(id string) (bool, error)
+	ListServiceTokensFunc          func(accountID string) ([]APIKey, error)
+	DeleteServiceTokenFunc         func(id, accountID string) (bool, error)
+	GetUserByIDFunc                func(id string) (*User, error)
+	FindUserBySkeletonFunc         func(skeleton string) (string, error)
+	GetUserByUsernameFunc          func(username string) (*User, error)
+	GetUserProfileFunc             func(username string) (*UserProfile, error)
+	UpdateUserProfileFunc          func(userID string, req *ProfileReq) error
+	GetUserActivityFunc            func(userID, since string) (*UserActivity, error)
+	SetUserTrustLevelFunc          func(id, level string) (bool, error)
+	GetUserBanFunc                 func(userID string) (*UserBan, error)
+	BanUserFunc                    func(userID string, b *UserBan) error
+	UnbanUserFunc                  func(userID string) (bool, error)
+	DeactivateUserFunc             func(userID, by string) (bool, error)
+	ReactivateUserFunc             func(userID string) (bool, error)
+	IsUserDeactivatedFunc          func(userID string) bool
+	GetOIDCUserFunc                func(issuer, subject string) (string, error)
+	LinkOIDCIdentityFunc           func(issuer, subject, userID string) error
+	UnlinkOIDCIdentityFunc         func(issuer, userID string) (bool, error)
+	ListUsersFunc                  func(role string, page, limit int) ([]User, int, error)
+	SetUserRoleFunc                func(id, role string) (bool, error)
+	EnsureAnonymousUserFunc        func() (*User, error)
+	ListCategoriesFunc             func() ([]Category, error)
+	GetCategoryFunc                func(slug string) (*Category, error)
+	SaveCategoryFunc               func(c *Category) error
+	DeleteCategoryFunc             func(slug, into string) (bool, error)
+	ListPackTemplatesFunc          func() ([]PackTemplate, error)
+	GetPackTemplateFunc            func(slug string) (*PackTemplate, error)
+	SavePackTemplateFunc           func(t *PackTemplate) error
+	SeedPackTemplateFunc           func(t *PackTemplate) (bool, error)
+	DeletePackTemplateFunc         func(slug string) (bool, error)
+	InsertMemoPackFunc             func(mp *MemoPack) error
+	InsertMemoPacksFunc            func(packs []*MemoPack) error
+	SetPackEmbargoFunc             func(packID, until string, viewerIDs []string) error
+	IsEmbargoViewerFunc            func(packID, userID string) bool
+	ListEmbargoViewersFunc         func(packID string) ([]string, error)
+	AddCollaboratorFunc            func(packID, userID, addedBy string) (bool, error)
+	RemoveCollaboratorFunc         func(packID, userID string) (bool, error)
+	IsCollaboratorFunc             func(packID, userID string) bool
+	ListCollaboratorsFunc          func(packID string) ([]Collaborator, error)
+	InsertShareLinkFunc            func(l *ShareLink, tokenHash string) error
+	ListShareLinksFunc             func(packID string) ([]ShareLink, error)
+	DeleteShareLinkFunc            func(packID, id string) (bool, error)
+	ShareLinkValidFunc             func(packID, tokenHash string) bool
+	UpdateMemoPackFunc             func(mp *MemoPack) error
+	PublishMemoPackFunc            func(id string) (bool, error)
+	SetPackArchivedFunc            func(id, at string) error
+	PinMemoPackFunc                func(id, authorID string, limit int) (bool, error)
+	UnpinMemoPackFunc              func(id string) error
+	SetPackFeaturedFunc            func(id, at string) error
+	ListFeaturedPacksFunc          func() ([]MemoPack, error)
+	ListRelatedCandidatesFunc      func(id string) ([]MemoPack, error)
+	CoDownloadCountsFunc           func(packID string) (map[string]int, error)
+	SetPackCoverFunc               func(id, cover string) error
+	DeleteMemoPackFunc             func(id, authorID string) error
+	GetMemoPackFunc                func(id string) (*MemoPack, error)
+	PackIDExistsFunc               func(id string) bool
+	PackRowExistsFunc              func(id string) bool
+	ResolvePackSlugFunc            func(slug string) (id, current string, err error)
+	InsertAttachmentFunc           func(a *Attachment) error
+	GetAttachmentFunc              func(id string) (*Attachment, error)
+	ListAttachmentsFunc            func(packID string) ([]Attachment, error)
+	DeleteAttachmentFunc           func(id string) error
+	ListOrphanAttachmentsFunc      func() ([]Attachment, error)
+	ListMemoPacksFunc              func(q ListQuery) ([]MemoPack, int, error)
+	HasPackVariantsFunc            func(id string) bool
+	ListPackVariantsFunc           func(origID string) ([]PackVariant, error)
+	FindPopularPackBySkeletonFunc  func(skeleton, authorID string, minDownloads int) (string, error)
+	FindDuplicatePackFunc          func(hash string, sim int64, maxDistance int, authorID, excludeID string) (*DuplicateMatch, error)
+	IncrementMemoPackDownloadsFunc func(id, version string) error
+	CreatePackClaimFunc            func(packID, tokenHash string) error
+	ClaimPackFunc                  func(packID, tokenHash string, user *User) (bool, error)
+	RecordDownloadEventFunc        func(packID, version, clientID, userID string) error
+	RecordInstallEventFunc         func(ev *InstallEvent) (bool, error)
+	CountClientInstallsFunc        func(packID, client string) (int, error)
+	GetPackStatsFunc               func(packID string) (*PackStats, error)
+	SaveReviewFunc                 func(rv *Review) error
+	DeleteReviewFunc               func(packID, userID string) error
+	ListReviewsFunc                func(packID string, limit, offset int) ([]Review, int, error)
+	SetStarFunc                    func(packID, userID string, starred bool) error
+	SaveMemoPackVersionFunc        func(mp *MemoPack) error
+	GetMemoPackVersionFunc         func(packID, version string) (*MemoPackVersion, error)
+	VersionAsOfFunc                func(packID, at string) (*MemoPackVersion, error)
+	ListMemoPackVersionsFunc       func(packID string) ([]MemoPackVersion, error)
+	LatestStableVersionFunc        func(packID string) (*MemoPackVersion, error)
+	PromoteMemoPackVersionFunc     func(packID, version string) error
+	InsertEvalRunFunc              func(run *EvalRun) error
+	FinishEvalRunFunc              func(run *EvalRun) error
+	ListEvalRunsFunc               func(packID string) ([]EvalRun, error)
+	SubscribeFunc                  func(userID, packID, version, channel string) error
+	UnsubscribeFunc                func(userID, packID string) error
+	MarkSubscriptionDownloadedFunc func(userID, packID, version string) error
+	ListSubscriberIDsFunc          func(packID, channel string) ([]string, error)
+	ListPackUpdatesFunc            func(userID string) ([]PackUpdate, error)
+	InsertWebhookFunc              func(wh *Webhook) error
+	GetWebhookFunc                 func(id string) (*Webhook, error)
+	ListUserWebhooksFunc           func(ownerID string) ([]Webhook, error)
+	ListPackWebhooksFunc           func(packID string) ([]Webhook, error)
+	DeleteWebhookFunc              func(id, ownerID string) (bool, error)
+	ReplaceChannelWebhooksFunc     func(hooks []Webhook) error
+	ListWebhooksForEventFunc       func(event string, pack *MemoPack) ([]Webhook, error)
+	InsertWebhookDeliveryFunc      func(d *WebhookDelivery) error
+	UpdateWebhookDeliveryFunc      func(d *WebhookDelivery) error
+	ListDueWebhookDeliveriesFunc   func(now string, limit int) ([]WebhookDelivery, error)
+	ListWebhookDeliveriesFunc      func(webhookID string) ([]WebhookDelivery, error)
+	InsertNotificationFunc         func(n *Notification) error
+	ListNotificationsFunc          func(userID string, unreadOnly bool) ([]Notification, error)
+	ListAllNotificationsFunc       func(userID string) ([]Notification, error)
+	MarkNotificationsReadFunc      func(userID string) error
+	InsertJobFunc                  func(j *Job) error
+	UpdateJobFunc                  func(j *Job) error
+	GetJobFunc                     func(id string) (*Job, error)
+	LatestUserJobFunc              func(userID, kind string) (*Job, error)
+	ListDueJobsFunc                func(now string, limit int) ([]Job, error)
+	RequeueRunningJobsFunc         func() error
+	SetLegalHoldFunc               func(table, id string, held bool, reason string) (bool, error)
+	IsPackOnHoldFunc               func(packID string) bool
+	InsertAuditEntryFunc           func(e *AuditEntry) error
+	ListAuditEntriesFunc           func(q AuditQuery) ([]AuditEntry, int, error)
+	ListLegalHoldsFunc             func() ([]LegalHold, error)
+	PurgeDeletedPacksFunc          func(cutoff string) (int, error)
+	PurgeDownloadEventsFunc        func(cutoff string) (int, error)
+	PurgeInstallEventsFunc         func(cutoff string) (int, error)
+	PurgeWebhookDeliveriesFunc     func(cutoff string) (int, error)
+	PurgeJobsFunc                  func(cutoff string) (int, error)
+	PurgeAuditEntriesFunc          func(cutoff string) (int, error)
+	ListTagRulesFunc               func() ([]TagRule, error)
+	SaveTagRuleFunc                func(t *TagRule) error
+	DeleteTagRuleFunc              func(tag string) (bool, error)
+	ListLivePackIDsFunc            func() ([]string, error)
+	SaveRetaggedPackFunc           func(mp *MemoPack) error
+	SaveRetaggedPacksFunc          func(packs []*MemoPack) error
+	HasPendingJobFunc              func(kind string) bool
+	ListETLPacksFunc               func(afterUpdated, afterID string, limit int) ([]ETLPackRecord, error)
+	ListETLEventsFunc              func(afterID int64, limit int) ([]ETLEventRecord, []int64, error)
+	ListAuthorPacksFunc            func(authorID string, includeDeleted bool) ([]MemoPack, error)
+	ListUserReviewsFunc            func(userID string) ([]Review, error)
+	ListUserStarsFunc              func(userID string) ([]StarRecord, error)
+	ListUserSubscriptionsFunc      func(userID string) ([]SubscriptionInfo, error)
+	ListLastDownloadsFunc          func(userID string) ([]DownloadRecord, error)
+	ListUserDownloadsFunc          func(userID string) ([]DownloadRecord, error)
+	CountByPeriodFunc              func(table, from string, keyLen int) (map[string]float64, error)
+	QueueDepthFunc                 func() (int, error)
+	GetVacationFunc                func(userID string) (*Vacation, error)
+	SaveVacationFunc               func(userID string, v *Vacation) error
+	DeleteVacationFunc             func(userID string) error
+	GetMilestonePrefsFunc          func(userID string) (*MilestonePrefs, error)
+	SaveMilestonePrefsFunc         func(userID string, p *MilestonePrefs) error
+	ListActiveVacationsFunc        func(userIDs []string) (map[string]*Vacation, error)
+	InsertVerificationFunc         func(v *Verification) (*Verification, error)
+	GetVerificationFunc            func(id string) (*Verification, error)
+	ListUserVerificationsFunc      func(userID string) ([]Verification, error)
+	VerifiedSubjectOwnerFunc       func(kind, subject string) (string, error)
+	MarkVerifiedFunc               func(id string) error
+	DeleteVerificationFunc         func(id, userID string) error
+	ListVerifiedIdentitiesFunc     func(userIDs []string) (map[string][]VerifiedIdentity, error)
+	InsertCollectionFunc           func(c *Collection) error
+	UpdateCollectionFunc           func(c *Collection) error
+	DeleteCollectionFunc           func(id string) error
+	GetCollectionFunc              func(id string) (*Collection, error)
+	ListUserCollectionsFunc        func(ownerID string) ([]Collection, error)
+	SetCollectionBundleJobFunc     func(id, jobID string) error
+	InsertInviteFunc               func(inv *Invite) error
+	ListInvitesFunc                func() ([]Invite, error)
+	DeleteInviteFunc               func(code string) (bool, error)
+	UseInviteFunc                  func(code string) (bool, error)
+	ReleaseInviteFunc              func(code string) error
+}
+
+func (m *MockStore) Ping() error {
+	if m.PingFunc != nil {
+		return m.PingFunc()
+	}
+	return m.Store.Ping()
+}
+
+func (m *MockStore) CreateUser(username, passwordHash string) (*User, error) {
+	if m.CreateUserFunc != nil {
+		return m.CreateUserFunc(username, passwordHash)
+	}
+	return m.Store.CreateUser(username, passwordHash)
+}
+
+func (m *MockStore) GetUserByToken(token string) (*User, error) {
+	if m.GetUserByTokenFunc != nil {
+		return m.GetUserByTokenFunc(token)
+	}
+	return m.Store.GetUserByToken(token)
+}
+
+func (m *MockStore) CreateSession(sess *Session) error {
+	if m.CreateSessionFunc != nil {
+		return m.CreateSessionFunc(sess)
+	}
+	return m.Store.CreateSession(sess)
+}
+
+func (m *MockStore) GetSessionByRefreshToken(refreshToken string) (*Session, error) {
+	if m.GetSessionByRefreshTokenFunc != nil {
+		return m.GetSessionByRefreshTokenFunc(refreshToken)
+	}
+	return m.Store.GetSessionByRefreshToken(refreshToken)
+}
+
+func (m *MockStore) RotateSessionTokens(sess *Session, oldRefreshToken string) (bool, error) {
+	if m.RotateSessionTokensFunc != nil {
+		return m.RotateSessionTokensFunc(sess, oldRefreshToken)
+	}
+	return m.Store.RotateSessionTokens(sess, oldRefreshToken)
+}
+
+func (m *MockStore) ListSessions(userID string) ([]Session, error) {
+	if m.ListSessionsFunc != nil {
+		return m.ListSessionsFunc(userID)
+	}
+	return m.Store.ListSessions(userID)
+}
+
+func (m *MockStore) DeleteSession(id, userID string) (bool, error) {
+	if m.DeleteSessionFunc != nil {
+		return m.DeleteSessionFunc(id, userID)
+	}
+	return m.Store.DeleteSession(id, userID)
+}
+
+func (m *MockStore) PurgeSessions(cutoff string) (int, error) {
+	if m.PurgeSessionsFunc != nil {
+		return m.PurgeSessionsFunc(cutoff)
+	}
+	return m.Store.PurgeSessions(cutoff)
+}
+
+func (m *MockStore) GetUserByAPIKey(keyHash string) (*User, error) {
+	if m.GetUserByAPIKeyFunc != nil {
+		return m.GetUserByAPIKeyFunc(keyHash)
+	}
+	return m.Store.GetUserByAPIKey(keyHash)
+}
+
+func (m *MockStore) CreateAPIKey(k *APIKey, keyHash string) error {
+	if m.CreateAPIKeyFunc != nil {
+		return m.CreateAPIKeyFunc(k, keyHash)
+	}
+	return m.Store.CreateAPIKey(k, keyHash)
+}
+
+func (m *MockStore) ListAPIKeys(userID string) ([]APIKey, error) {
+	if m.ListAPIKeysFunc != nil {
+		return m.ListAPIKeysFunc(userID)
+	}
+	return m.Store.ListAPIKeys(userID)
+}
+
+func (m *MockStore) DeleteAPIKey(id, userID string) (bool, error) {
+	if m.DeleteAPIKeyFunc != nil {
+		return m.DeleteAPIKeyFunc(id, userID)
+	}
+	return m.Store.DeleteAPIKey(id, userID)
+}
+
+func (m *MockStore) InsertServiceAccount(sa *ServiceAccount) error {
+	if m.InsertServiceAccountFunc != nil {
+		return m.InsertServiceAccountFunc(sa)
+	}
+	return m.Store.InsertServiceAccount(sa)
+}
+
+func (m *MockStore) GetServiceAccount(id string) (*ServiceAccount, error) {
+	if m.GetServiceAccountFunc != nil {
+		return m.GetServiceAccountFunc(id)
+	}
+	return m.Store.GetServiceAccount(id)
+}
+
+func (m *MockStore) ListServiceAccounts() ([]ServiceAccount, error) {
+	if m.ListServiceAccountsFunc != nil {
+		return m.ListServiceAccountsFunc()
+	}
+	return m.Store.ListServiceAccounts()
+}
+
+func (m *MockStore) DeleteServiceAccount(id string) (bool, error) {
+	if m.DeleteServiceAccountFunc != nil {
+		return m.DeleteServiceAccountFunc(id)
+	}
+	return m.Store.DeleteServiceAccount(id)
+}
+
+func (m *MockStore) ListServiceTokens(accountID string) ([]APIKey, error) {
+	if m.ListServiceTokensFunc != nil {
+		return m.ListServiceTokensFunc(accountID)
+	}
+	return m.Store.ListServiceTokens(accountID)
+}
+
+func (m *MockStore) DeleteServiceToken(id, accountID string) (bool, error) {
+	if m.DeleteServiceTokenFunc != nil {
+		return m.DeleteServiceTokenFunc(id, accountID)
+	}
+	return m.Store.DeleteServiceToken(id, accountID)
+}
+
+func (m *MockStore) GetUserByID(id string) (*User, error) {
+	if m.GetUserByIDFunc != nil {
+		return m.GetUserByIDFunc(id)
+	}
+	return m.Store.GetUserByID(id)
+}
+
+func (m *MockStore) FindUserBySkeleton(skeleton string) (string, error) {
+	if m.FindUserBySkeletonFunc != nil {
+		return m.FindUserBySkeletonFunc(skeleton)
+	}
+	return m.Store.FindUserBySkeleton(skeleton)
+}
+
+func (m *MockStore) GetUserByUsername(username string) (*User, error) {
+	if m.GetUserByUsernameFunc != nil {
+		return m.GetUserByUsernameFunc(username)
+	}
+	return m.Store.GetUserByUsername(username)
+}
+
+func (m *MockStore) GetUserProfile(username string) (*UserProfile, error) {
+	if m.GetUserProfileFunc != nil {
+		return m.GetUserProfileFunc(username)
+	}
+	return m.Store.GetUserProfile(username)
+}
+
+func (m *MockStore) UpdateUserProfile(userID string, req *ProfileReq) error {
+	if m.UpdateUserProfileFunc != nil {
+		return m.UpdateUserProfileFunc(userID, req)
+	}
+	return m.Store.UpdateUserProfile(userID, req)
+}
+
+func (m *MockStore) GetUserActivity(userID, since string) (*UserActivity, error) {
+	if m.GetUserActivityFunc != nil {
+		return m.GetUserActivityFunc(userID, since)
+	}
+	return m.Store.GetUserActivity(userID, since)
+}
+
+func (m *MockStore) SetUserTrustLevel(id, level string) (bool, error) {
+	if m.SetUserTrustLevelFunc != nil {
+		return m.SetUserTrustLevelFunc(id, level)
+	}
+	return m.Store.SetUserTrustLevel(id, level)
+}
+
+func (m *MockStore) GetUserBan(userID string) (*UserBan, error) {
+	if m.GetUserBanFunc != nil {
+		return m.GetUserBanFunc(userID)
+	}
+	return m.Store.GetUserBan(userID)
+}
+
+func (m *MockStore) BanUser(userID string, b *UserBan) error {
+	if m.BanUserFunc != nil {
+		return m.BanUserFunc(userID, b)
+	}
+	return m.Store.BanUser(userID, b)
+}
+
+func (m *MockStore) UnbanUser(userID string) (bool, error) {
+	if m.UnbanUserFunc != nil {
+		return m.UnbanUserFunc(userID)
+	}
+	return m.Store.UnbanUser(userID)
+}
+
+func (m *MockStore) DeactivateUser(userID, by string) (bool, error) {
+	if m.DeactivateUserFunc != nil {
+		return m.DeactivateUserFunc(userID, by)
+	}
+	return m.Store.DeactivateUser(userID, by)
+}
+
+func (m *MockStore) ReactivateUser(userID string) (bool, error) {
+	if m.ReactivateUserFunc != nil {
+		return m.ReactivateUserFunc(userID)
+	}
+	return m.Store.ReactivateUser(userID)
+}
+
+func (m *MockStore) IsUserDeactivated(userID string) bool {
+	if m.IsUserDeactivatedFunc != nil {
+		return m.IsUserDeactivatedFunc(userID)
+	}
+	return m.Store.IsUserDeactivated(userID)
+}
+
+func (m *MockStore) GetOIDCUser(issuer, subject string) (string, error) {
+	if m.GetOIDCUserFunc != nil {
+		return m.GetOIDCUserFunc(issuer, subject)
+	}
+	return m.Store.GetOIDCUser(issuer, subject)
+}
+
+func (m *MockStore) LinkOIDCIdentity(issuer, subject, userID string) error {
+	if m.LinkOIDCIdentityFunc != nil {
+		return m.LinkOIDCIdentityFunc(issuer, subject, userID)
+	}
+	return m.Store.LinkOIDCIdentity(issuer, subject, userID)
+}
+
+func (m *MockStore) UnlinkOIDCIdentity(issuer, userID string) (bool, error) {
+	if m.UnlinkOIDCIdentityFunc != nil {
+		return m.UnlinkOIDCIdentityFunc(issuer, userID)
+	}
+	return m.Store.UnlinkOIDCIdentity(issuer, userID)
+}
+
+func (m *MockStore) ListUsers(role string, page, limit int) ([]User, int, error) {
+	if m.ListUsersFunc != nil {
+		return m.ListUsersFunc(role, page, limit)
+	}
+	return m.Store.ListUsers(role, page, limit)
+}
+
+func (m *MockStore) SetUserRole(id, role string) (bool, error) {
+	if m.SetUserRoleFunc != nil {
+		return m.SetUserRoleFunc(id, role)
+	}
+	return m.Store.SetUserRole(id, role)
+}
+
+func (m *MockStore) EnsureAnonymousUser() (*User, error) {
+	if m.EnsureAnonymousUserFunc != nil {
+		return m.EnsureAnonymousUserFunc()
+	}
+	return m.Store.EnsureAnonymousUser()
+}
+
+func (m *MockStore) ListCategories() ([]Category, error) {
+	if m.ListCategoriesFunc != nil {
+		return m.ListCategoriesFunc()
+	}
+	return m.Store.ListCategories()
+}
+
+func (m *MockStore) GetCategory(slug string) (*Category, error) {
+	if m.GetCategoryFunc != nil {
+		return m.GetCategoryFunc(slug)
+	}
+	return m.Store.GetCategory(slug)
+}
+
+func (m *MockStore) SaveCategory(c *Category) error {
+	if m.SaveCategoryFunc != nil {
+		return m.SaveCategoryFunc(c)
+	}
+	return m.Store.SaveCategory(c)
+}
+
+func (m *MockStore) DeleteCategory(slug, into string) (bool, error) {
+	if m.DeleteCategoryFunc != nil {
+		return m.DeleteCategoryFunc(slug, into)
+	}
+	return m.Store.DeleteCategory(slug, into)
+}
+
+func (m *MockStore) ListPackTemplates() ([]PackTemplate, error) {
+	if m.ListPackTemplatesFunc != nil {
+		return m.ListPackTemplatesFunc()
+	}
+	return m.Store.ListPackTemplates()
+}
+
+func (m *MockStore) GetPackTemplate(slug string) (*PackTemplate, error) {
+	if m.GetPackTemplateFunc != nil {
+		return m.GetPackTemplateFunc(slug)
+	}
+	return m.Store.GetPackTemplate(slug)
+}
+
+func (m *MockStore) SavePackTemplate(t *PackTemplate) error {
+	if m.SavePackTemplateFunc != nil {
+		return m.SavePackTemplateFunc(t)
+	}
+	return m.Store.SavePackTemplate(t)
+}
+
+func (m *MockStore) SeedPackTemplate(t *PackTemplate) (bool, error) {
+	if m.SeedPackTemplateFunc != nil {
+		return m.SeedPackTemplateFunc(t)
+	}
+	return m.Store.SeedPackTemplate(t)
+}
+
+func (m *MockStore) DeletePackTemplate(slug string) (bool, error) {
+	if m.DeletePackTemplateFunc != nil {
+		return m.DeletePackTemplateFunc(slug)
+	}
+	return m.Store.DeletePackTemplate(slug)
+}
+
+func (m *MockStore) InsertMemoPack(mp *MemoPack) error {
+	if m.InsertMemoPackFunc != nil {
+		return m.InsertMemoPackFunc(mp)
+	}
+	return m.Store.InsertMemoPack(mp)
+}
+
+func (m *MockStore) InsertMemoPacks(packs []*MemoPack) error {
+	if m.InsertMemoPacksFunc != nil {
+		return m.InsertMemoPacksFunc(packs)
+	}
+	return m.Store.InsertMemoPacks(packs)
+}
+
+func (m *MockStore) SetPackEmbargo(packID, until string, viewerIDs []string) error {
+	if m.SetPackEmbargoFunc != nil {
+		return m.SetPackEmbargoFunc(packID, until, viewerIDs)
+	}
+	return m.Store.SetPackEmbargo(packID, until, viewerIDs)
+}
+
+func (m *MockStore) IsEmbargoViewer(packID, userID string) bool {
+	if m.IsEmbargoViewerFunc != nil {
+		return m.IsEmbargoViewerFunc(packID, userID)
+	}
+	return m.Store.IsEmbargoViewer(packID, userID)
+}
+
+func (m *MockStore) ListEmbargoViewers(packID string) ([]string, error) {
+	if m.ListEmbargoViewersFunc != nil {
+		return m.ListEmbargoViewersFunc(packID)
+	}
+	return m.Store.ListEmbargoViewers(packID)
+}
+
+func (m *MockStore) AddCollaborator(packID, userID, addedBy string) (bool, error) {
+	if m.AddCollaboratorFunc != nil {
+		return m.AddCollaboratorFunc(packID, userID, addedBy)
+	}
+	return m.Store.AddCollaborator(packID, userID, addedBy)
+}
+
+func (m *MockStore) RemoveCollaborator(packID, userID string) (bool, error) {
+	if m.RemoveCollaboratorFunc != nil {
+		return m.RemoveCollaboratorFunc(packID, userID)
+	}
+	return m.Store.RemoveCollaborator(packID, userID)
+}
+
+func (m *MockStore) IsCollaborator(packID, userID string) bool {
+	if m.IsCollaboratorFunc != nil {
+		return m.IsCollaboratorFunc(packID, userID)
+	}
+	return m.Store.IsCollaborator(packID, userID)
+}
+
+func (m *MockStore) ListCollaborators(packID string) ([]Collaborator, error) {
+	if m.ListCollaboratorsFunc != nil {
+		return m.ListCollaboratorsFunc(packID)
+	}
+	return m.Store.ListCollaborators(packID)
+}
+
+func (m *MockStore) InsertShareLink(l *ShareLink, tokenHash string) error {
+	if m.InsertShareLinkFunc != nil {
+		return m.InsertShareLinkFunc(l, tokenHash)
+	}
+	return m.Store.InsertShareLink(l, tokenHash)
+}
+
+func (m *MockStore) ListShareLinks(packID string) ([]ShareLink, error) {
+	if m.ListShareLinksFunc != nil {
+		return m.ListShareLinksFunc(packID)
+	}
+	return m.Store.ListShareLinks(packID)
+}
+
+func (m *MockStore) DeleteShareLink(packID, id string) (bool, error) {
+	if m.DeleteShareLinkFunc != nil {
+		return m.DeleteShareLinkFunc(packID, id)
+	}
+	return m.Store.DeleteShareLink(packID, id)
+}
+
+func (m *MockStore) ShareLinkValid(packID, tokenHash string) bool {
+	if m.ShareLinkValidFunc != nil {
+		return m.ShareLinkValidFunc(packID, tokenHash)
+	}
+	return m.Store.ShareLinkValid(packID, tokenHash)
+}
+
+func (m *MockStore) UpdateMemoPack(mp *MemoPack) error {
+	if m.UpdateMemoPackFunc != nil {
+		return m.UpdateMemoPackFunc(mp)
+	}
+	return m.Store.UpdateMemoPack(mp)
+}
+
+func (m *MockStore) PublishMemoPack(id string) (bool, error) {
+	if m.PublishMemoPackFunc != nil {
+		return m.PublishMemoPackFunc(id)
+	}
+	return m.Store.PublishMemoPack(id)
+}
+
+func (m *MockStore) SetPackArchived(id, at string) error {
+	if m.SetPackArchivedFunc != nil {
+		return m.SetPackArchivedFunc(id, at)
+	}
+	return m.Store.SetPackArchived(id, at)
+}
+
+func (m *MockStore) PinMemoPack(id, authorID string, limit int) (bool, error) {
+	if m.PinMemoPackFunc != nil {
+		return m.PinMemoPackFunc(id, authorID, limit)
+	}
+	return m.Store.PinMemoPack(id, authorID, limit)
+}
+
+func (m *MockStore) UnpinMemoPack(id string) error {
+	if m.UnpinMemoPackFunc != nil {
+		return m.UnpinMemoPackFunc(id)
+	}
+	return m.Store.UnpinMemoPack(id)
+}
+
+func (m *MockStore) SetPackFeatured(id, at string) error {
+	if m.SetPackFeaturedFunc != nil {
+		return m.SetPackFeaturedFunc(id, at)
+	}
+	return m.Store.SetPackFeatured(id, at)
+}
+
+func (m *MockStore) ListFeaturedPacks() ([]MemoPack, error) {
+	if m.ListFeaturedPacksFunc != nil {
+		return m.ListFeaturedPacksFunc()
+	}
+	return m.Store.ListFeaturedPacks()
+}
+
+func (m *MockStore) ListRelatedCandidates(id string) ([]MemoPack, error) {
+	if m.ListRelatedCandidatesFunc != nil {
+		return m.ListRelatedCandidatesFunc(id)
+	}
+	return m.Store.ListRelatedCandidates(id)
+}
+
+func (m *MockStore) CoDownloadCounts(packID string) (map[string]int, error) {
+	if m.CoDownloadCountsFunc != nil {
+		return m.CoDownloadCountsFunc(packID)
+	}
+	return m.Store.CoDownloadCounts(packID)
+}
+
+func (m *MockStore) SetPackCover(id, cover string) error {
+	if m.SetPackCoverFunc != nil {
+		return m.SetPackCoverFunc(id, cover)
+	}
+	return m.Store.SetPackCover(id, cover)
+}
+
+func (m *MockStore) DeleteMemoPack(id, authorID string) error {
+	if m.DeleteMemoPackFunc != nil {
+		return m.DeleteMemoPackFunc(id, authorID)
+	}
+	return m.Store.DeleteMemoPack(id, authorID)
+}
+
+func (m *MockStore) GetMemoPack(id string) (*MemoPack, error) {
+	if m.GetMemoPackFunc != nil {
+		return m.GetMemoPackFunc(id)
+	}
+	return m.Store.GetMemoPack(id)
+}
+
+func (m *MockStore) PackIDExists(id string) bool {
+	if m.PackIDExistsFunc != nil {
+		return m.PackIDExistsFunc(id)
+	}
+	return m.Store.PackIDExists(id)
+}
+
+func (m *MockStore) PackRowExists(id string) bool {
+	if m.PackRowExistsFunc != nil {
+		return m.PackRowExistsFunc(id)
+	}
+	return m.Store.PackRowExists(id)
+}
+
+func (m *MockStore) ResolvePackSlug(slug string) (id, current string, err error) {
+	if m.ResolvePackSlugFunc != nil {
+		return m.ResolvePackSlugFunc(slug)
+	}
+	return m.Store.ResolvePackSlug(slug)
+}
+
+func (m *MockStore) InsertAttachment(a *Attachment) error {
+	if m.InsertAttachmentFunc != nil {
+		return m.InsertAttachmentFunc(a)
+	}
+	return m.Store.InsertAttachment(a)
+}
+
+func (m *MockStore) GetAttachment(id string) (*Attachment, error) {
+	if m.GetAttachmentFunc != nil {
+		return m.GetAttachmentFunc(id)
+	}
+	return m.Store.GetAttachment(id)
+}
+
+func (m *MockStore) ListAttachments(packID string) ([]Attachment, error) {
+	if m.ListAttachmentsFunc != nil {
+		return m.ListAttachmentsFunc(packID)
+	}
+	return m.Store.ListAttachments(packID)
+}
+
+func (m *MockStore) DeleteAttachment(id string) error {
+	if m.DeleteAttachmentFunc != nil {
+		return m.DeleteAttachmentFunc(id)
+	}
+	return m.Store.DeleteAttachment(id)
+}
+
+func (m *MockStore) ListOrphanAttachments() ([]Attachment, error) {
+	if m.ListOrphanAttachmentsFunc != nil {
+		return m.ListOrphanAttachmentsFunc()
+	}
+	return m.Store.ListOrphanAttachments()
+}
+
+func (m *MockStore) ListMemoPacks(q ListQuery) ([]MemoPack, int, error) {
+	if m.ListMemoPacksFunc != nil {
+		return m.ListMemoPacksFunc(q)
+	}
+	return m.Store.ListMemoPacks(q)
+}
+
+func (m *MockStore) HasPackVariants(id string) bool {
+	if m.HasPackVariantsFunc != nil {
+		return m.HasPackVariantsFunc(id)
+	}
+	return m.Store.HasPackVariants(id)
+}
+
+func (m *MockStore) ListPackVariants(origID string) ([]PackVariant, error) {
+	if m.ListPackVariantsFunc != nil {
+		return m.ListPackVariantsFunc(origID)
+	}
+	return m.Store.ListPackVariants(origID)
+}
+
+func (m *MockStore) FindPopularPackBySkeleton(skeleton, authorID string, minDownloads int) (string, error) {
+	if m.FindPopularPackBySkeletonFunc != nil {
+		return m.FindPopularPackBySkeletonFunc(skeleton, authorID, minDownloads)
+	}
+	return m.Store.FindPopularPackBySkeleton(skeleton, authorID, minDownloads)
+}
+
+func (m *MockStore) FindDuplicatePack(hash string, sim int64, maxDistance int, authorID, excludeID string) (*DuplicateMatch, error) {
+	if m.FindDuplicatePackFunc != nil {
+		return m.FindDuplicatePackFunc(hash, sim, maxDistance, authorID, excludeID)
+	}
+	return m.Store.FindDuplicatePack(hash, sim, maxDistance, authorID, excludeID)
+}
+
+func (m *MockStore) IncrementMemoPackDownloads(id, version string) error {
+	if m.IncrementMemoPackDownloadsFunc != nil {
+		return m.IncrementMemoPackDownloadsFunc(id, version)
+	}
+	return m.Store.IncrementMemoPackDownloads(id, version)
+}
+
+func (m *MockStore) CreatePackClaim(packID, tokenHash string) error {
+	if m.CreatePackClaimFunc != nil {
+		return m.CreatePackClaimFunc(packID, tokenHash)
+	}
+	return m.Store.CreatePackClaim(packID, tokenHash)
+}
+
+func (m *MockStore) ClaimPack(packID, tokenHash string, user *User) (bool, error) {
+	if m.ClaimPackFunc != nil {
+		return m.ClaimPackFunc(packID, tokenHash, user)
+	}
+	return m.Store.ClaimPack(packID, tokenHash, user)
+}
+
+func (m *MockStore) RecordDownloadEvent(packID, version, clientID, userID string) error {
+	if m.RecordDownloadEventFunc != nil {
+		return m.RecordDownloadEventFunc(packID, version, clientID, userID)
+	}
+	return m.Store.RecordDownloadEvent(packID, version, clientID, userID)
+}
+
+func (m *MockStore) RecordInstallEvent(ev *InstallEvent) (bool, error) {
+	if m.RecordInstallEventFunc != nil {
+		return m.RecordInstallEventFunc(ev)
+	}
+	return m.Store.RecordInstallEvent(ev)
+}
+
+func (m *MockStore) CountClientInstalls(packID, client string) (int, error) {
+	if m.CountClientInstallsFunc != nil {
+		return m.CountClientInstallsFunc(packID, client)
+	}
+	return m.Store.CountClientInstalls(packID, client)
+}
+
+func (m *MockStore) GetPackStats(packID string) (*PackStats, error) {
+	if m.GetPackStatsFunc != nil {
+		return m.GetPackStatsFunc(packID)
+	}
+	return m.Store.GetPackStats(packID)
+}
+
+func (m *MockStore) SaveReview(rv *Review) error {
+	if m.SaveReviewFunc != nil {
+		return m.SaveReviewFunc(rv)
+	}
+	return m.Store.SaveReview(rv)
+}
+
+func (m *MockStore) DeleteReview(packID, userID string) error {
+	if m.DeleteReviewFunc != nil {
+		return m.DeleteReviewFunc(packID, userID)
+	}
+	return m.Store.DeleteReview(packID, userID)
+}
+
+func (m *MockStore) ListReviews(packID string, limit, offset int) ([]Review, int, error) {
+	if m.ListReviewsFunc != nil {
+		return m.ListReviewsFunc(packID, limit, offset)
+	}
+	return m.Store.ListReviews(packID, limit, offset)
+}
+
+func (m *MockStore) SetStar(packID, userID string, starred bool) error {
+	if m.SetStarFunc != nil {
+		return m.SetStarFunc(packID, userID, starred)
+	}
+	return m.Store.SetStar(packID, userID, starred)
+}
+
+func (m *MockStore) SaveMemoPackVersion(mp *MemoPack) error {
+	if m.SaveMemoPackVersionFunc != nil {
+		return m.SaveMemoPackVersionFunc(mp)
+	}
+	return m.Store.SaveMemoPackVersion(mp)
+}
+
+func (m *MockStore) GetMemoPackVersion(packID, version string) (*MemoPackVersion, error) {
+	if m.GetMemoPackVersionFunc != nil {
+		return m.GetMemoPackVersionFunc(packID, version)
+	}
+	return m.Store.GetMemoPackVersion(packID, version)
+}
+
+func (m *MockStore) VersionAsOf(packID, at string) (*MemoPackVersion, error) {
+	if m.VersionAsOfFunc != nil {
+		return m.VersionAsOfFunc(packID, at)
+	}
+	return m.Store.VersionAsOf(packID, at)
+}
+
+func (m *MockStore) ListMemoPackVersions(packID string) ([]MemoPackVersion, error) {
+	if m.ListMemoPackVersionsFunc != nil {
+		return m.ListMemoPackVersionsFunc(packID)
+	}
+	return m.Store.ListMemoPackVersions(packID)
+}
+
+func (m *MockStore) LatestStableVersion(packID string) (*MemoPackVersion, error) {
+	if m.LatestStableVersionFunc != nil {
+		return m.LatestStableVersionFunc(packID)
+	}
+	return m.Store.LatestStableVersion(packID)
+}
+
+func (m *MockStore) PromoteMemoPackVersion(packID, version string) error {
+	if m.PromoteMemoPackVersionFunc != nil {
+		return m.PromoteMemoPackVersionFunc(packID, version)
+	}
+	return m.Store.PromoteMemoPackVersion(packID, version)
+}
+
+func (m *MockStore) InsertEvalRun(run *EvalRun) error {
+	if m.InsertEvalRunFunc != nil {
+		return m.InsertEvalRunFunc(run)
+	}
+	return m.Store.InsertEvalRun(run)
+}
+
+func (m *MockStore) FinishEvalRun(run *EvalRun) error {
+	if m.FinishEvalRunFunc != nil {
+		return m.FinishEvalRunFunc(run)
+	}
+	return m.Store.FinishEvalRun(run)
+}
+
+func (m *MockStore) ListEvalRuns(packID string) ([]EvalRun, error) {
+	if m.ListEvalRunsFunc != nil {
+		return m.ListEvalRunsFunc(packID)
+	}
+	return m.Store.ListEvalRuns(packID)
+}
+
+func (m *MockStore) Subscribe(userID, packID, version, channel string) error {
+	if m.SubscribeFunc != nil {
+		return m.SubscribeFunc(userID, packID, version, channel)
+	}
+	return m.Store.Subscribe(userID, packID, version, channel)
+}
+
+func (m *MockStore) Unsubscribe(userID, packID string) error {
+	if m.UnsubscribeFunc != nil {
+		return m.UnsubscribeFunc(userID, packID)
+	}
+	return m.Store.Unsubscribe(userID, packID)
+}
+
+func (m *MockStore) MarkSubscriptionDownloaded(userID, packID, version string) error {
+	if m.MarkSubscriptionDownloadedFunc != nil {
+		return m.MarkSubscriptionDownloadedFunc(userID, packID, version)
+	}
+	return m.Store.MarkSubscriptionDownloaded(userID, packID, version)
+}
+
+func (m *MockStore) ListSubscriberIDs(packID, channel string) ([]string, error) {
+	if m.ListSubscriberIDsFunc != nil {
+		return m.ListSubscriberIDsFunc(packID, channel)
+	}
+	return m.Store.ListSubscriberIDs(packID, channel)
+}
+
+func (m *MockStore) ListPackUpdates(userID string) ([]PackUpdate, error) {
+	if m.ListPackUpdatesFunc != nil {
+		return m.ListPackUpdatesFunc(userID)
+	}
+	return m.Store.ListPackUpdates(userID)
+}
+
+func (m *MockStore) InsertWebhook(wh *Webhook) error {
+	if m.InsertWebhookFunc != nil {
+		return m.InsertWebhookFunc(wh)
+	}
+	return m.Store.InsertWebhook(wh)
+}
+
+func (m *MockStore) GetWebhook(id string) (*Webhook, error) {
+	if m.GetWebhookFunc != nil {
+		return m.GetWebhookFunc(id)
+	}
+	return m.Store.GetWebhook(id)
+}
+
+func (m *MockStore) ListUserWebhooks(ownerID string) ([]Webhook, error) {
+	if m.ListUserWebhooksFunc != nil {
+		return m.ListUserWebhooksFunc(ownerID)
+	}
+	return m.Store.ListUserWebhooks(ownerID)
+}
+
+func (m *MockStore) ListPackWebhooks(packID string) ([]Webhook, error) {
+	if m.ListPackWebhooksFunc != nil {
+		return m.ListPackWebhooksFunc(packID)
+	}
+	return m.Store.ListPackWebhooks(packID)
+}
+
+func (m *MockStore) DeleteWebhook(id, ownerID string) (bool, error) {
+	if m.DeleteWebhookFunc != nil {
+		return m.DeleteWebhookFunc(id, ownerID)
+	}
+	return m.Store.DeleteWebhook(id, ownerID)
+}
+
+func (m *MockStore) ReplaceChannelWebhooks(hooks []Webhook) error {
+	if m.ReplaceChannelWebhooksFunc != nil {
+		return m.ReplaceChannelWebhooksFunc(hooks)
+	}
+	return m.Store.ReplaceChannelWebhooks(hooks)
+}
+
+func (m *MockStore) ListWebhooksForEvent(event string, pack *MemoPack) ([]Webhook, error) {
+	if m.ListWebhooksForEventFunc != nil {
+		return m.ListWebhooksForEventFunc(event, pack)
+	}
+	return m.Store.ListWebhooksForEvent(event, pack)
+}
+
+func (m *MockStore) InsertWebhookDelivery(d *WebhookDelivery) error {
+	if m.InsertWebhookDeliveryFunc != nil {
+		return m.InsertWebhookDeliveryFunc(d)
+	}
+	return m.Store.InsertWebhookDelivery(d)
+}
+
+func (m *MockStore) UpdateWebhookDelivery(d *WebhookDelivery) error {
+	if m.UpdateWebhookDeliveryFunc != nil {
+		return m.UpdateWebhookDeliveryFunc(d)
+	}
+	return m.Store.UpdateWebhookDelivery(d)
+}
+
+func (m *MockStore) ListDueWebhookDeliveries(now string, limit int) ([]WebhookDelivery, error) {
+	if m.ListDueWebhookDeliveriesFunc != nil {
+		return m.ListDueWebhookDeliveriesFunc(now, limit)
+	}
+	return m.Store.ListDueWebhookDeliveries(now, limit)
+}
+
+func (m *MockStore) ListWebhookDeliveries(webhookID string) ([]WebhookDelivery, error) {
+	if m.ListWebhookDeliveriesFunc != nil {
+		return m.ListWebhookDeliveriesFunc(webhookID)
+	}
+	return m.Store.ListWebhookDeliveries(webhookID)
+}
+
+func (m *MockStore) InsertNotification(n *Notification) error {
+	if m.InsertNotificationFunc != nil {
+		return m.InsertNotificationFunc(n)
+	}
+	return m.Store.InsertNotification(n)
+}
+
+func (m *MockStore) ListNotifications(userID string, unreadOnly bool) ([]Notification, error) {
+	if m.ListNotificationsFunc != nil {
+		return m.ListNotificationsFunc(userID, unreadOnly)
+	}
+	return m.Store.ListNotifications(userID, unreadOnly)
+}
+
+func (m *MockStore) ListAllNotifications(userID string) ([]Notification, error) {
+	if m.ListAllNotificationsFunc != nil {
+		return m.ListAllNotificationsFunc(userID)
+	}
+	return m.Store.ListAllNotifications(userID)
+}
+
+func (m *MockStore) MarkNotificationsRead(userID string) error {
+	if m.MarkNotificationsReadFunc != nil {
+		return m.MarkNotificationsReadFunc(userID)
+	}
+	return m.Store.MarkNotificationsRead(userID)
+}
+
+func (m *MockStore) InsertJob(j *Job) error {
+	if m.InsertJobFunc != nil {
+		return m.InsertJobFunc(j)
+	}
+	return m.Store.InsertJob(j)
+}
+
+func (m *MockStore) UpdateJob(j *Job) error {
+	if m.UpdateJobFunc != nil {
+		return m.UpdateJobFunc(j)
+	}
+	return m.Store.UpdateJob(j)
+}
+
+func (m *MockStore) GetJob(id string) (*Job, error) {
+	if m.GetJobFunc != nil {
+		return m.GetJobFunc(id)
+	}
+	return m.Store.GetJob(id)
+}
+
+func (m *MockStore) LatestUserJob(userID, kind string) (*Job, error) {
+	if m.LatestUserJobFunc != nil {
+		return m.LatestUserJobFunc(userID, kind)
+	}
+	return m.Store.LatestUserJob(userID, kind)
+}
+
+func (m *MockStore) ListDueJobs(now string, limit int) ([]Job, error) {
+	if m.ListDueJobsFunc != nil {
+		return m.ListDueJobsFunc(now, limit)
+	}
+	return m.Store.ListDueJobs(now, limit)
+}
+
+func (m *MockStore) RequeueRunningJobs() error {
+	if m.RequeueRunningJobsFunc != nil {
+		return m.RequeueRunningJobsFunc()
+	}
+	return m.Store.RequeueRunningJobs()
+}
+
+func (m *MockStore) SetLegalHold(table, id string, held bool, reason string) (bool, error) {
+	if m.SetLegalHoldFunc != nil {
+		return m.SetLegalHoldFunc(table, id, held, reason)
+	}
+	return m.Store.SetLegalHold(table, id, held, reason)
+}
+
+func (m *MockStore) IsPackOnHold(packID string) bool {
+	if m.IsPackOnHoldFunc != nil {
+		return m.IsPackOnHoldFunc(packID)
+	}
+	return m.Store.IsPackOnHold(packID)
+}
+
+func (m *MockStore) InsertAuditEntry(e *AuditEntry) error {
+	if m.InsertAuditEntryFunc != nil {
+		return m.InsertAuditEntryFunc(e)
+	}
+	return m.Store.InsertAuditEntry(e)
+}
+
+func (m *MockStore) ListAuditEntries(q AuditQuery) ([]AuditEntry, int, error) {
+	if m.ListAuditEntriesFunc != nil {
+		return m.ListAuditEntriesFunc(q)
+	}
+	return m.Store.ListAuditEntries(q)
+}
+
+func (m *MockStore) ListLegalHolds() ([]LegalHold, error) {
+	if m.ListLegalHoldsFunc != nil {
+		return m.ListLegalHoldsFunc()
+	}
+	return m.Store.ListLegalHolds()
+}
+
+func (m *MockStore) PurgeDeletedPacks(cutoff string) (int, error) {
+	if m.PurgeDeletedPacksFunc != nil {
+		return m.PurgeDeletedPacksFunc(cutoff)
+	}
+	return m.Store.PurgeDeletedPacks(cutoff)
+}
+
+func (m *MockStore) PurgeDownloadEvents(cutoff string) (int, error) {
+	if m.PurgeDownloadEventsFunc != nil {
+		return m.PurgeDownloadEventsFunc(cutoff)
+	}
+	return m.Store.PurgeDownloadEvents(cutoff)
+}
+
+func (m *MockStore) PurgeInstallEvents(cutoff string) (int, error) {
+	if m.PurgeInstallEventsFunc != nil {
+		return m.PurgeInstallEventsFunc(cutoff)
+	}
+	return m.Store.PurgeInstallEvents(cutoff)
+}
+
+func (m *MockStore) PurgeWebhookDeliveries(cutoff string) (int, error) {
+	if m.PurgeWebhookDeliveriesFunc != nil {
+		return m.PurgeWebhookDeliveriesFunc(cutoff)
+	}
+	return m.Store.PurgeWebhookDeliveries(cutoff)
+}
+
+func (m *MockStore) PurgeJobs(cutoff string) (int, error) {
+	if m.PurgeJobsFunc != nil {
+		return m.PurgeJobsFunc(cutoff)
+	}
+	return m.Store.PurgeJobs(cutoff)
+}
+
+func (m *MockStore) PurgeAuditEntries(cutoff string) (int, error) {
+	if m.PurgeAuditEntriesFunc != nil {
+		return m.PurgeAuditEntriesFunc(cutoff)
+	}
+	return m.Store.PurgeAuditEntries(cutoff)
+}
+
+func (m *MockStore) ListTagRules() ([]TagRule, error) {
+	if m.ListTagRulesFunc != nil {
+		return m.ListTagRulesFunc()
+	}
+	return m.Store.ListTagRules()
+}
+
+func (m *MockStore) SaveTagRule(t *TagRule) error {
+	if m.SaveTagRuleFunc != nil {
+		return m.SaveTagRuleFunc(t)
+	}
+	return m.Store.SaveTagRule(t)
+}
+
+func (m *MockStore) DeleteTagRule(tag string) (bool, error) {
+	if m.DeleteTagRuleFunc != nil {
+		return m.DeleteTagRuleFunc(tag)
+	}
+	return m.Store.DeleteTagRule(tag)
+}
+
+func (m *MockStore) ListLivePackIDs() ([]string, error) {
+	if m.ListLivePackIDsFunc != nil {
+		return m.ListLivePackIDsFunc()
+	}
+	return m.Store.ListLivePackIDs()
+}
+
+func (m *MockStore) SaveRetaggedPack(mp *MemoPack) error {
+	if m.SaveRetaggedPackFunc != nil {
+		return m.SaveRetaggedPackFunc(mp)
+	}
+	return m.Store.SaveRetaggedPack(mp)
+}
+
+func (m *MockStore) SaveRetaggedPacks(packs []*MemoPack) error {
+	if m.SaveRetaggedPacksFunc != nil {
+		return m.SaveRetaggedPacksFunc(packs)
+	}
+	return m.Store.SaveRetaggedPacks(packs)
+}
+
+func (m *MockStore) HasPendingJob(kind string) bool {
+	if m.HasPendingJobFunc != nil {
+		return m.HasPendingJobFunc(kind)
+	}
+	return m.Store.HasPendingJob(kind)
+}
+
+func (m *MockStore) ListETLPacks(afterUpdated, afterID string, limit int) ([]ETLPackRecord, error) {
+	if m.ListETLPacksFunc != nil {
+		return m.ListETLPacksFunc(afterUpdated, afterID, limit)
+	}
+	return m.Store.ListETLPacks(afterUpdated, afterID, limit)
+}
+
+func (m *MockStore) ListETLEvents(afterID int64, limit int) ([]ETLEventRecord, []int64, error) {
+	if m.ListETLEventsFunc != nil {
+		return m.ListETLEventsFunc(afterID, limit)
+	}
+	return m.Store.ListETLEvents(afterID, limit)
+}
+
+func (m *MockStore) ListAuthorPacks(authorID string, includeDeleted bool) ([]MemoPack, error) {
+	if m.ListAuthorPacksFunc != nil {
+		return m.ListAuthorPacksFunc(authorID, includeDeleted)
+	}
+	return m.Store.ListAuthorPacks(authorID, includeDeleted)
+}
+
+func (m *MockStore) ListUserReviews(userID string) ([]Review, error) {
+	if m.ListUserReviewsFunc != nil {
+		return m.ListUserReviewsFunc(userID)
+	}
+	return m.Store.ListUserReviews(userID)
+}
+
+func (m *MockStore) ListUserStars(userID string) ([]StarRecord, error) {
+	if m.ListUserStarsFunc != nil {
+		return m.ListUserStarsFunc(userID)
+	}
+	return m.Store.ListUserStars(userID)
+}
+
+func (m *MockStore) ListUserSubscriptions(userID string) ([]SubscriptionInfo, error) {
+	if m.ListUserSubscriptionsFunc != nil {
+		return m.ListUserSubscriptionsFunc(userID)
+	}
+	return m.Store.ListUserSubscriptions(userID)
+}
+
+func (m *MockStore) ListLastDownloads(userID string) ([]DownloadRecord, error) {
+	if m.ListLastDownloadsFunc != nil {
+		return m.ListLastDownloadsFunc(userID)
+	}
+	return m.Store.ListLastDownloads(userID)
+}
+
+func (m *MockStore) ListUserDownloads(userID string) ([]DownloadRecord, error) {
+	if m.ListUserDownloadsFunc != nil {
+		return m.ListUserDownloadsFunc(userID)
+	}
+	return m.Store.ListUserDownloads(userID)
+}
+
+func (m *MockStore) CountByPeriod(table, from string, keyLen int) (map[string]float64, error) {
+	if m.CountByPeriodFunc != nil {
+		return m.CountByPeriodFunc(table, from, keyLen)
+	}
+	return m.Store.CountByPeriod(table, from, keyLen)
+}
+
+func (m *MockStore) QueueDepth() (int, error) {
+	if m.QueueDepthFunc != nil {
+		return m.QueueDepthFunc()
+	}
+	return m.Store.QueueDepth()
+}
+
+func (m *MockStore) GetVacation(userID string) (*Vacation, error) {
+	if m.GetVacationFunc != nil {
+		return m.GetVacationFunc(userID)
+	}
+	return m.Store.GetVacation(userID)
+}
+
+func (m *MockStore) SaveVacation(userID string, v *Vacation) error {
+	if m.SaveVacationFunc != nil {
+		return m.SaveVacationFunc(userID, v)
+	}
+	return m.Store.SaveVacation(userID, v)
+}
+
+func (m *MockStore) DeleteVacation(userID string) error {
+	if m.DeleteVacationFunc != nil {
+		return m.DeleteVacationFunc(userID)
+	}
+	return m.Store.DeleteVacation(userID)
+}
+
+func (m *MockStore) GetMilestonePrefs(userID string) (*MilestonePrefs, error) {
+	if m.GetMilestonePrefsFunc != nil {
+		return m.GetMilestonePrefsFunc(userID)
+	}
+	return m.Store.GetMilestonePrefs(userID)
+}
+
+func (m *MockStore) SaveMilestonePrefs(userID string, p *MilestonePrefs) error {
+	if m.SaveMilestonePrefsFunc != nil {
+		return m.SaveMilestonePrefsFunc(userID, p)
+	}
+	return m.Store.SaveMilestonePrefs(userID, p)
+}
+
+func (m *MockStore) ListActiveVacations(userIDs []string) (map[string]*Vacation, error) {
+	if m.ListActiveVacationsFunc != nil {
+		return m.ListActiveVacationsFunc(userIDs)
+	}
+	return m.Store.ListActiveVacations(userIDs)
+}
+
+func (m *MockStore) InsertVerification(v *Verification) (*Verification, error) {
+	if m.InsertVerificationFunc != nil {
+		return m.InsertVerificationFunc(v)
+	}
+	return m.Store.InsertVerification(v)
+}
+
+func (m *MockStore) GetVerification(id string) (*Verification, error) {
+	if m.GetVerificationFunc != nil {
+		return m.GetVerificationFunc(id)
+	}
+	return m.Store.GetVerification(id)
+}
+
+func (m *MockStore) ListUserVerifications(userID string) ([]Verification, error) {
+	if m.ListUserVerificationsFunc != nil {
+		return m.ListUserVerificationsFunc(userID)
+	}
+	return m.Store.ListUserVerifications(userID)
+}
+
+func (m *MockStore) VerifiedSubjectOwner(kind, subject string) (string, error) {
+	if m.VerifiedSubjectOwnerFunc != nil {
+		return m.VerifiedSubjectOwnerFunc(kind, subject)
+	}
+	return m.Store.VerifiedSubjectOwner(kind, subject)
+}
+
+func (m *MockStore) MarkVerified(id string) error {
+	if m.MarkVerifiedFunc != nil {
+		return m.MarkVerifiedFunc(id)
+	}
+	return m.Store.MarkVerified(id)
+}
+
+func (m *MockStore) DeleteVerification(id, userID string) error {
+	if m.DeleteVerificationFunc != nil {
+		return m.DeleteVerificationFunc(id, userID)
+	}
+	return m.Store.DeleteVerification(id, userID)
+}
+
+func (m *MockStore) ListVerifiedIdentities(userIDs []string) (map[string][]VerifiedIdentity, error) {
+	if m.ListVerifiedIdentitiesFunc != nil {
+		return m.ListVerifiedIdentitiesFunc(userIDs)
+	}
+	return m.Store.ListVerifiedIdentities(userIDs)
+}
+
+func (m *MockStore) InsertCollection(c *Collection) error {
+	if m.InsertCollectionFunc != nil {
+		return m.InsertCollectionFunc(c)
+	}
+	return m.Store.InsertCollection(c)
+}
+
+func (m *MockStore) UpdateCollection(c *Collection) error {
+	if m.UpdateCollectionFunc != nil {
+		return m.UpdateCollectionFunc(c)
+	}
+	return m.Store.UpdateCollection(c)
+}
+
+func (m *MockStore) DeleteCollection(id string) error {
+	if m.DeleteCollectionFunc != nil {
+		return m.DeleteCollectionFunc(id)
+	}
+	return m.Store.DeleteCollection(id)
+}
+
+func (m *MockStore) GetCollection(id string) (*Collection, error) {
+	if m.GetCollectionFunc != nil {
+		return m.GetCollectionFunc(id)
+	}
+	return m.Store.GetCollection(id)
+}
+
+func (m *MockStore) ListUserCollections(ownerID string) ([]Collection, error) {
+	if m.ListUserCollectionsFunc != nil {
+		return m.ListUserCollectionsFunc(ownerID)
+	}
+	return m.Store.ListUserCollections(ownerID)
+}
+
+func (m *MockStore) SetCollectionBundleJob(id, jobID string) error {
+	if m.SetCollectionBundleJobFunc != nil {
+		return m.SetCollectionBundleJobFunc(id, jobID)
+	}
+	return m.Store.SetCollectionBundleJob(id, jobID)
+}
+
+func (m *MockStore) InsertInvite(inv *Invite) error {
+	if m.InsertInviteFunc != nil {
+		return m.InsertInviteFunc(inv)
+	}
+	return m.Store.InsertInvite(inv)
+}
+
+func (m *MockStore) ListInvites() ([]Invite, error) {
+	if m.ListInvitesFunc != nil {
+		return m.ListInvitesFunc()
+	}
+	return m.Store.ListInvites()
+}
+
+func (m *MockStore) DeleteInvite(code string) (bool, error) {
+	if m.DeleteInviteFunc != nil {
+		return m.DeleteInviteFunc(code)
+	}
+	return m.Store.DeleteInvite(code)
+}
+
+func (m *MockStore) UseInvite(code string) (bool, error) {
+	if m.UseInviteFunc != nil {
+		return m.UseInviteFunc(code)
+	}
+	return m.Store.UseInvite(code)
+}
+
+func (m *MockStore) ReleaseInvite(code string) error {
+	if m.ReleaseInviteFunc != nil {
+		return m.ReleaseInviteFunc(code)
+	}
+	return m.Store.ReleaseInvite(code)
+}
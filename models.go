@@ -1,33 +1,257 @@
-package main
+package memomarket
 
 import "encoding/json"
 
 // MemoRule represents a single rule within a Pack.
 type MemoRule struct {
-	Title      string `json:"title"`
-	UpdateRule string `json:"update_rule"`
+	Title      string          `json:"title"`
+	UpdateRule string          `json:"update_rule"`
+	Tags       []string        `json:"tags,omitempty"`
+	Conditions *RuleConditions `json:"conditions,omitempty"`
+	Deprecated *Deprecation    `json:"deprecated,omitempty"`
+	// SchemaVersion is 2 for rules using the typed fields below and omitted
+	// for plain title/update_rule rules.
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// Trigger is when the rule fires, e.g. "session_start" or "file_change".
+	Trigger string `json:"trigger,omitempty"`
+	// Action is what the client does with update_rule, e.g. "instruct" or
+	// "update_memo".
+	Action string `json:"action,omitempty"`
+	// Priority orders rules firing together, highest first (-100 to 100).
+	Priority int `json:"priority,omitempty"`
+}
+
+// Deprecation marks a rule or memo that is on its way out. Replacement
+// optionally points at what to use instead, e.g. the title of another rule or
+// memo in the pack, or another pack's ID.
+type Deprecation struct {
+	Reason      string `json:"reason"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// RuleConditions scopes when a rule applies. Empty fields match everything.
+type RuleConditions struct {
+	FileGlobs    []string `json:"file_globs,omitempty"`
+	Languages    []string `json:"languages,omitempty"`
+	ProjectTypes []string `json:"project_types,omitempty"`
 }
 
 // Memo represents a single memo entry.
 type Memo struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Title      string       `json:"title"`
+	Content    string       `json:"content"`
+	Tags       []string     `json:"tags,omitempty"`
+	Deprecated *Deprecation `json:"deprecated,omitempty"`
+	// Order is the memo's position, from 1; clients inject memos in order.
+	Order int `json:"order"`
+	// Priority says which memos to keep first when a client can't fit them
+	// all, highest first (-100 to 100).
+	Priority int `json:"priority,omitempty"`
+	// Attachments lists the IDs of files uploaded to the pack that go with
+	// the memo.
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// Attachment is a file uploaded to a pack for its memos to list.
+type Attachment struct {
+	ID          string `json:"id"`
+	PackID      string `json:"pack_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	UploaderID  string `json:"uploader_id"`
+	CreatedAt   string `json:"created_at"`
+	// URL is a signed download link, valid until ExpiresAt.
+	URL       string `json:"url,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
 }
 
 // MemoPack is a publishable pack containing rules and memos.
 type MemoPack struct {
-	ID           string     `json:"id"`
+	ID           string          `json:"id"`
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	AuthorID     string          `json:"author_id"`
+	AuthorName   string          `json:"author_name"`
+	SystemPrompt string          `json:"system_prompt"`
+	Rules        []MemoRule      `json:"rules"`
+	Memos        []Memo          `json:"memos"`
+	Evals        []PackEval      `json:"evals"`
+	Version      string          `json:"version"`
+	Provenance   *PackProvenance `json:"provenance,omitempty"`
+	Homepage     string          `json:"homepage"`
+	Repository   string          `json:"repository"`
+	Contact      string          `json:"contact"`
+	// RequireAuth hides the prompt content from anonymous callers; metadata stays public.
+	RequireAuth       bool `json:"require_auth"`
+	ContentRestricted bool `json:"content_restricted,omitempty"`
+	// ContentOmitted marks list items whose large bodies were left out; get
+	// or download the pack for its full content.
+	ContentOmitted bool `json:"content_omitted,omitempty"`
+	// Revision increases on every update; send it back in If-Match to update.
+	Revision int `json:"revision"`
+	// Channel is the release channel of Version, "stable" or "beta". While it
+	// is beta, StableVersion names what downloads serve by default.
+	Channel       string `json:"channel"`
+	StableVersion string `json:"stable_version,omitempty"`
+	// Language is the BCP 47 tag the pack is written in, if declared.
+	// VariantOf names the original of a localized variant; Variants lists
+	// the whole group on single-pack responses.
+	Language  string        `json:"language,omitempty"`
+	VariantOf string        `json:"variant_of,omitempty"`
+	Variants  []PackVariant `json:"variants,omitempty"`
+	// ForkedFrom names the pack this one was forked from; ForkCount counts
+	// the published forks of this one.
+	ForkedFrom string `json:"forked_from,omitempty"`
+	ForkCount  int    `json:"fork_count"`
+	// Slug names the pack in URLs in place of its ID; see slugs.go.
+	Slug string `json:"slug"`
+	// Checksum is the "sha256:"-prefixed hash of the pack's canonical
+	// content; see packContentChecksum.
+	Checksum string `json:"checksum"`
+	// Category is the slug of one of the admin-curated categories.
+	Category string `json:"category,omitempty"`
+	// DuplicateOf is set on a publish response when the pack copies an
+	// existing one.
+	DuplicateOf *DuplicateMatch `json:"duplicate_of,omitempty"`
+	// CoverURL is where the pack's cover image is served, if it has one.
+	Cover    string `json:"-"`
+	CoverURL string `json:"cover_url,omitempty"`
+	// AuthorVerified lists the domains and GitHub accounts the author has proven.
+	AuthorVerified []VerifiedIdentity `json:"author_verified,omitempty"`
+	// AuthorAway is set while the author's account is in read-only mode.
+	AuthorAway *Vacation `json:"author_away,omitempty"`
+	// EmbargoUntil is when an embargoed pack becomes public.
+	EmbargoUntil string `json:"embargo_until,omitempty"`
+	// Status is "draft" until the pack is published, then "published".
+	Status string `json:"status"`
+	// Visibility is "public", "unlisted" or "private"; see visibility.go.
+	Visibility string `json:"visibility"`
+	// Archived packs are no longer maintained and can't be changed.
+	Archived   bool   `json:"archived"`
+	ArchivedAt string `json:"archived_at,omitempty"`
+	// Pinned packs come first on their author's profile and listing.
+	Pinned   bool   `json:"pinned"`
+	PinnedAt string `json:"pinned_at,omitempty"`
+	// Featured packs are picked by admins for the homepage.
+	Featured   bool   `json:"featured"`
+	FeaturedAt string `json:"featured_at,omitempty"`
+	// Score is the search relevance, only set with ?debug_score=true.
+	Score          *float64 `json:"score,omitempty"`
+	externalFields []string
+	Content        PackContentInfo `json:"content"`
+	Ratings        PackRatings     `json:"ratings"`
+	Downloads      int             `json:"downloads"`
+	Published      bool            `json:"published"`
+	CreatedAt      string          `json:"created_at"`
+	UpdatedAt      string          `json:"updated_at"`
+}
+
+// PackVariant is one localized pack in a variant group.
+type PackVariant struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"`
+}
+
+// MemoPackVersion is a stored snapshot of a pack's content at one version.
+type MemoPackVersion struct {
+	PackID       string     `json:"pack_id"`
+	Version      string     `json:"version"`
 	Name         string     `json:"name"`
 	Description  string     `json:"description"`
-	AuthorID     string     `json:"author_id"`
-	AuthorName   string     `json:"author_name"`
 	SystemPrompt string     `json:"system_prompt"`
 	Rules        []MemoRule `json:"rules"`
 	Memos        []Memo     `json:"memos"`
+	Channel      string     `json:"channel"`
 	Downloads    int        `json:"downloads"`
-	Published    bool       `json:"published"`
 	CreatedAt    string     `json:"created_at"`
 	UpdatedAt    string     `json:"updated_at"`
+	Checksum     string     `json:"checksum"`
+}
+
+// PackContentInfo is size metadata computed when a pack is saved, so clients
+// can judge a pack without downloading its body.
+type PackContentInfo struct {
+	RuleCount         int    `json:"rule_count"`
+	MemoCount         int    `json:"memo_count"`
+	SystemPromptChars int    `json:"system_prompt_chars"`
+	TotalChars        int    `json:"total_chars"`
+	ContentUpdatedAt  string `json:"content_updated_at"`
+}
+
+// PackRatings is the review and star aggregate denormalized onto each pack
+// whenever a review or star changes.
+type PackRatings struct {
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+	Stars   int     `json:"stars"`
+}
+
+// Review is one user's rating of a pack; each user has at most one per pack.
+type Review struct {
+	PackID    string `json:"pack_id"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Rating    int    `json:"rating"`
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	// AutoReply is the author's away message, returned to the reviewer.
+	AutoReply string `json:"auto_reply,omitempty"`
+}
+
+// PackProvenance records where an imported pack came from, for re-syncing.
+type PackProvenance struct {
+	Type string `json:"type"`
+	// URL is the source of a pack imported with POST /api/memo-packs/import.
+	URL      string   `json:"url,omitempty"`
+	Repo     string   `json:"repo,omitempty"`
+	Ref      string   `json:"ref,omitempty"`
+	Path     string   `json:"path,omitempty"`
+	Commit   string   `json:"commit,omitempty"`
+	Files    []string `json:"files,omitempty"`
+	SyncedAt string   `json:"synced_at"`
+	// Mapping is the file mapping a GitHub import was made with.
+	Mapping []ImportFileMapping `json:"mapping,omitempty"`
+}
+
+// PackEval is a sample input with assertions on the expected model reply.
+type PackEval struct {
+	Name   string          `json:"name"`
+	Input  string          `json:"input"`
+	Expect []EvalAssertion `json:"expect"`
+}
+
+// EvalAssertion checks a model reply. Type is one of contains, not_contains,
+// equals or regex.
+type EvalAssertion struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// EvalResult is the outcome of a single eval within a run.
+type EvalResult struct {
+	Name     string   `json:"name"`
+	Passed   bool     `json:"passed"`
+	Output   string   `json:"output"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// EvalRun records one execution of a pack's evals against an LLM provider.
+type EvalRun struct {
+	ID          string       `json:"id"`
+	PackID      string       `json:"pack_id"`
+	PackVersion string       `json:"pack_version"`
+	Model       string       `json:"model"`
+	Status      string       `json:"status"`
+	Passed      int          `json:"passed"`
+	Failed      int          `json:"failed"`
+	Results     []EvalResult `json:"results"`
+	Error       string       `json:"error,omitempty"`
+	CreatedAt   string       `json:"created_at"`
+	FinishedAt  string       `json:"finished_at,omitempty"`
 }
 
 // User represents a registered publisher.
@@ -36,13 +260,977 @@ type User struct {
 	Username     string `json:"username"`
 	PasswordHash string `json:"-"`
 	Token        string `json:"token,omitempty"`
-	CreatedAt    string `json:"created_at"`
+	// The access token expires at TokenExpiresAt; RefreshToken gets a new
+	// one from POST /api/token/refresh until RefreshExpiresAt.
+	TokenExpiresAt   string `json:"token_expires_at,omitempty"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	RefreshExpiresAt string `json:"refresh_expires_at,omitempty"`
+	// CSRFToken replaces the tokens for logins kept in cookies; requests
+	// other than GET send it in the X-CSRF-Token header.
+	CSRFToken string `json:"csrf_token,omitempty"`
+	// Role is roleUser or roleAdmin.
+	Role      string `json:"role,omitempty"`
+	CreatedAt string `json:"created_at"`
+	// SessionID is the session the request's token belongs to. Requests
+	// made with an API key set APIKeyID and are limited to Scopes instead.
+	SessionID string   `json:"-"`
+	APIKeyID  string   `json:"-"`
+	Scopes    []string `json:"-"`
+	// ServiceAccount names the service account whose token made the
+	// request; the user is then the account's designated author.
+	ServiceAccount string `json:"-"`
+	// DeactivatedBy is the ID of whoever deactivated the account, the user
+	// or an admin, while it is deactivated.
+	DeactivatedBy string `json:"-"`
+
+	Verified []VerifiedIdentity `json:"verified,omitempty"`
+	Vacation *Vacation          `json:"vacation,omitempty"`
+}
+
+// UserProfile is what anyone can see about a user. DisplayName falls back
+// to the username.
+type UserProfile struct {
+	ID             string             `json:"id"`
+	Username       string             `json:"username"`
+	DisplayName    string             `json:"display_name"`
+	Bio            string             `json:"bio"`
+	Website        string             `json:"website,omitempty"`
+	AvatarURL      string             `json:"avatar_url,omitempty"`
+	JoinedAt       string             `json:"joined_at"`
+	PackCount      int                `json:"pack_count"`
+	TotalDownloads int                `json:"total_downloads"`
+	Verified       []VerifiedIdentity `json:"verified,omitempty"`
+	Away           *Vacation          `json:"away,omitempty"`
+	// Pinned are the packs the user pinned, the most recently pinned first.
+	Pinned []MemoPack `json:"pinned"`
+}
+
+// ProfileReq is the body of PUT /api/me/profile. It replaces the whole
+// profile; empty fields are cleared.
+type ProfileReq struct {
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	Website     string `json:"website"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+// PackStats summarizes download metrics for a pack. Downloads is the raw
+// counter; the other figures only cover clients that accepted a client ID.
+// Installs count completed installs reported by clients, so they exclude
+// downloads that were never used.
+type PackStats struct {
+	PackID           string         `json:"pack_id"`
+	Downloads        int            `json:"downloads"`
+	TrackedDownloads int            `json:"tracked_downloads"`
+	UniqueDownloads  int            `json:"unique_downloads"`
+	ReturningClients int            `json:"returning_clients"`
+	Installs         int            `json:"installs"`
+	UniqueInstalls   int            `json:"unique_installs"`
+	InstallsByClient map[string]int `json:"installs_by_client"`
+	// DownloadsByVersion counts downloads of each stored version.
+	DownloadsByVersion map[string]int `json:"downloads_by_version"`
+}
+
+// InstallEvent is an install reported through POST /api/memo-packs/{id}/installed.
+// ClientID is the anonymous client ID, empty when the client opted out.
+type InstallEvent struct {
+	PackID        string
+	Version       string
+	ClientID      string
+	Client        string
+	ClientVersion string
+}
+
+// MilestonePrefs is how an author hears about milestones of their packs.
+type MilestonePrefs struct {
+	// Downloads lists the download counts that are milestones; null restores
+	// the defaults and [] turns them off.
+	Downloads []int `json:"downloads"`
+	// NewClients marks the first install reported by each client.
+	NewClients bool `json:"new_clients"`
+	// Notify adds an in-app notification to the webhook event.
+	Notify bool `json:"notify"`
+}
+
+// InstalledReq is the body of an install report. Client names the tool that
+// installed the pack (e.g. "memomarket-cli"); all fields are optional.
+type InstalledReq struct {
+	Version       string `json:"version"`
+	Client        string `json:"client"`
+	ClientVersion string `json:"client_version"`
+}
+
+// Notification is an in-app message for a user.
+type Notification struct {
+	ID        string `json:"id"`
+	UserID    string `json:"-"`
+	Kind      string `json:"kind"`
+	PackID    string `json:"pack_id,omitempty"`
+	Message   string `json:"message"`
+	Read      bool   `json:"read"`
+	CreatedAt string `json:"created_at"`
+}
+
+// UserRoleReq changes a user's role.
+type UserRoleReq struct {
+	Role   string `json:"role"`
+	Reason string `json:"reason"`
+}
+
+// UserTrustReq pins a user's trust level; an empty Level makes it
+// automatic again.
+type UserTrustReq struct {
+	Level  string `json:"level"`
+	Reason string `json:"reason"`
+}
+
+// BanReq suspends a user. Until is a date or timestamp; empty suspends
+// indefinitely.
+type BanReq struct {
+	Reason string `json:"reason"`
+	Until  string `json:"until,omitempty"`
+}
+
+// DeactivateReq deactivates an account: its owner confirms with Password,
+// an admin gives a Reason.
+type DeactivateReq struct {
+	Password string `json:"password,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// UserBan is a suspension in force. An empty Until means indefinite.
+type UserBan struct {
+	Reason   string `json:"reason"`
+	Until    string `json:"until,omitempty"`
+	BannedBy string `json:"banned_by"`
+	BannedAt string `json:"banned_at"`
+}
+
+// SuspendedResponse answers logins and requests from a suspended account.
+type SuspendedResponse struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+	Until  string `json:"until,omitempty"`
+}
+
+// UserActivity is what a user's trust level and quotas are computed from.
+// Packs and Downloads cover published packs; LivePacks counts every pack not
+// deleted, published or not; PublishedSince counts every pack created since
+// the given time, deleted or not. TrustLevel is an admin's pin.
+type UserActivity struct {
+	CreatedAt      string
+	TrustLevel     string
+	Packs          int
+	LivePacks      int
+	Downloads      int
+	PublishedSince int
+}
+
+// CookieConfig sets the attributes of the session cookies. SameSite is lax
+// (the default), strict or none. Secure defaults to whether the request
+// came over HTTPS.
+type CookieConfig struct {
+	Domain   string `json:"domain,omitempty"`
+	Secure   *bool  `json:"secure,omitempty"`
+	SameSite string `json:"same_site,omitempty"`
+}
+
+// QuotaConfig caps what each user may publish; zero values are unlimited.
+type QuotaConfig struct {
+	MaxPacks       int `json:"max_packs,omitempty"`
+	MaxPackBytes   int `json:"max_pack_bytes,omitempty"`
+	DailyPublishes int `json:"daily_publishes,omitempty"`
+}
+
+// OIDCConfig connects an OpenID Connect provider. RedirectURL must be
+// registered with the provider; it defaults to /api/auth/oidc/callback on
+// the host the login came to. Scopes default to openid, profile and email,
+// and UsernameClaim, naming new accounts, to preferred_username. With
+// CreateAccounts false only subjects an admin has linked can sign in.
+type OIDCConfig struct {
+	Issuer         string   `json:"issuer"`
+	ClientID       string   `json:"client_id"`
+	ClientSecret   string   `json:"client_secret,omitempty"`
+	RedirectURL    string   `json:"redirect_url,omitempty"`
+	Scopes         []string `json:"scopes,omitempty"`
+	UsernameClaim  string   `json:"username_claim,omitempty"`
+	CreateAccounts *bool    `json:"create_accounts,omitempty"`
+}
+
+// OIDCLinkReq links a user to the subject the identity provider knows them
+// by.
+type OIDCLinkReq struct {
+	Subject string `json:"subject"`
+}
+
+// QuotaStatus is a user's standing against the publishing quotas. A Limit
+// of 0 is unlimited; admins are exempt from all of them.
+type QuotaStatus struct {
+	Packs          QuotaUsage `json:"packs"`
+	PublishedToday QuotaUsage `json:"published_today"`
+	MaxPackBytes   int        `json:"max_pack_bytes"`
+	Exempt         bool       `json:"exempt,omitempty"`
+}
+
+type QuotaUsage struct {
+	Used  int `json:"used"`
+	Limit int `json:"limit"`
+}
+
+// TrustStatus is a user's trust level with the figures behind it. Next is
+// what the next level requires, unless the level is pinned or the highest.
+type TrustStatus struct {
+	Level          string            `json:"level"`
+	Pinned         bool              `json:"pinned,omitempty"`
+	Limits         TrustLimits       `json:"limits"`
+	AccountDays    int               `json:"account_days"`
+	Packs          int               `json:"packs"`
+	Downloads      int               `json:"downloads"`
+	PublishedToday int               `json:"published_today"`
+	Next           *TrustRequirement `json:"next,omitempty"`
+}
+
+// TrustLimits is what a trust level allows. DailyPublishes 0 is unlimited.
+type TrustLimits struct {
+	DailyPublishes int  `json:"daily_publishes"`
+	ExternalLinks  bool `json:"external_links"`
+	Attachments    bool `json:"attachments"`
+}
+
+// TrustRequirement is what reaching a trust level takes.
+type TrustRequirement struct {
+	Level          string `json:"level"`
+	MinAccountDays int    `json:"min_account_days"`
+	MinPacks       int    `json:"min_packs"`
+	MinDownloads   int    `json:"min_downloads"`
+}
+
+// AuditEntry records one admin action and why it was taken.
+type AuditEntry struct {
+	ID        string `json:"id"`
+	ActorID   string `json:"actor_id"`
+	ActorName string `json:"actor_name"`
+	// Via names the service account that acted for the actor.
+	Via        string `json:"via,omitempty"`
+	Action     string `json:"action"`
+	TargetKind string `json:"target_kind,omitempty"`
+	TargetID   string `json:"target_id,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	// Diff summarizes what the action changed.
+	Diff      string `json:"diff,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// AuditQuery filters the audit log; empty fields match everything.
+type AuditQuery struct {
+	Actor      string // user ID or username
+	Action     string // exact, or a prefix ending in "."
+	TargetKind string
+	TargetID   string
+	IP         string
+	Since      string
+	Until      string
+	Page       int
+	Limit      int
+}
+
+// EmailPayload is the payload of a send_email job.
+type EmailPayload struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Job is a unit of background work run by the job worker.
+type Job struct {
+	ID         string `json:"id"`
+	Kind       string `json:"kind"`
+	UserID     string `json:"-"`
+	Status     string `json:"status"`
+	Payload    string `json:"-"`
+	Result     string `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Attempts   int    `json:"attempts"`
+	RunAt      string `json:"run_at"`
+	CreatedAt  string `json:"created_at"`
+	FinishedAt string `json:"finished_at,omitempty"`
+}
+
+// CollectionBundlePayload is the payload of a collection_bundle job.
+type CollectionBundlePayload struct {
+	CollectionID string `json:"collection_id"`
+}
+
+// Collection is a user's named list of packs, downloadable as one bundle.
+type Collection struct {
+	ID          string           `json:"id"`
+	OwnerID     string           `json:"owner_id"`
+	OwnerName   string           `json:"owner_name"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Items       []CollectionItem `json:"items"`
+	CreatedAt   string           `json:"created_at"`
+	UpdatedAt   string           `json:"updated_at"`
+	BundleJobID string           `json:"-"`
+}
+
+// CollectionItem is a pack in a collection. Version pins it to an exact
+// version, a constraint such as "^1.2" or "latest"; empty follows the
+// stable channel.
+type CollectionItem struct {
+	PackID  string `json:"pack_id"`
+	Version string `json:"version,omitempty"`
+}
+
+// CollectionReq is the body of POST /api/collections and PUT
+// /api/collections/{id}.
+type CollectionReq struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Items       []CollectionItem `json:"items"`
+}
+
+// BundleStatus reports on a collection's zip bundle: none, pending, running,
+// done or failed.
+type BundleStatus struct {
+	Status      string `json:"status"`
+	Job         *Job   `json:"job,omitempty"`
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// CollectionBundleIndex is collection.json in a collection bundle.
+type CollectionBundleIndex struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Owner       string        `json:"owner"`
+	Server      string        `json:"server"`
+	GeneratedAt string        `json:"generated_at"`
+	Packs       []BundleEntry `json:"packs"`
+}
+
+// BundleEntry is a collection item as bundled. Error is set, and File
+// empty, when the item no longer resolves.
+type BundleEntry struct {
+	PackID  string `json:"pack_id"`
+	Pin     string `json:"pin,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	File    string `json:"file,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Category is one entry of the curated category list. PackCount is only
+// set in the public listing.
+type Category struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Position    int    `json:"position"`
+	PackCount   int    `json:"pack_count"`
+}
+
+// CategoryReq is the body of PUT /api/admin/categories/{slug}. Categories
+// are listed by Position, then slug.
+type CategoryReq struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Position    int    `json:"position"`
+}
+
+// PackTemplate is a starter pack skeleton; see templates.go. Clients
+// publish Pack, with a name filled in, to instantiate it.
+type PackTemplate struct {
+	Slug        string             `json:"slug"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Position    int                `json:"position"`
+	Pack        PublishMemoPackReq `json:"pack"`
+	UpdatedAt   string             `json:"updated_at"`
+}
+
+// PackTemplateReq is the body of PUT /api/admin/templates/{slug} and the
+// content of a template file. Templates are listed by Position, then slug.
+type PackTemplateReq struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Position    int                `json:"position"`
+	Pack        PublishMemoPackReq `json:"pack"`
+}
+
+// TagRule blocks a rule/memo tag or merges it into another tag.
+type TagRule struct {
+	Tag       string `json:"tag"`
+	Action    string `json:"action"`
+	Into      string `json:"into,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type TagRuleReq struct {
+	Action string `json:"action"`
+	Into   string `json:"into"`
+	Reason string `json:"reason"`
+}
+
+// AuthorRetagReq is the body of POST /api/me/memo-packs/retag. To is the
+// new name for rename; an empty PackIDs means all of the caller's packs.
+type AuthorRetagReq struct {
+	Action  string   `json:"action"`
+	Tag     string   `json:"tag"`
+	To      string   `json:"to,omitempty"`
+	PackIDs []string `json:"pack_ids,omitempty"`
+}
+
+type AuthorRetagResult struct {
+	Changed int      `json:"changed"`
+	Packs   []string `json:"packs"`
+}
+
+// TagPolicyUpdate is returned after a tag rule changes. Job is the queued
+// retag, or nil when one was already pending.
+type TagPolicyUpdate struct {
+	Rules []TagRule `json:"rules"`
+	Job   *Job      `json:"job"`
+}
+
+// LegalHold is a pack or user exempt from deletion and purging.
+type LegalHold struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ETLPackRecord is one line of /api/admin/etl/packs. Fields are only ever
+// added, never renamed or removed, so warehouse schemas stay stable.
+type ETLPackRecord struct {
+	Cursor           string  `json:"cursor"`
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	AuthorID         string  `json:"author_id"`
+	AuthorName       string  `json:"author_name"`
+	Version          string  `json:"version"`
+	Published        bool    `json:"published"`
+	Deleted          bool    `json:"deleted"`
+	Downloads        int     `json:"downloads"`
+	RuleCount        int     `json:"rule_count"`
+	MemoCount        int     `json:"memo_count"`
+	TotalChars       int     `json:"total_chars"`
+	RatingAverage    float64 `json:"rating_average"`
+	RatingCount      int     `json:"rating_count"`
+	Stars            int     `json:"stars"`
+	CreatedAt        string  `json:"created_at"`
+	UpdatedAt        string  `json:"updated_at"`
+	ContentUpdatedAt string  `json:"content_updated_at"`
+	DeletedAt        string  `json:"deleted_at"`
+}
+
+// ETLEventRecord is one line of /api/admin/etl/events.
+type ETLEventRecord struct {
+	Cursor    string `json:"cursor"`
+	Type      string `json:"type"`
+	PackID    string `json:"pack_id"`
+	Version   string `json:"version"`
+	ClientID  string `json:"client_id"`
+	UserID    string `json:"user_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// AccountExport is everything stored about one account, as returned by /api/me/export.
+type AccountExport struct {
+	ExportedAt    string             `json:"exported_at"`
+	Server        string             `json:"server"`
+	Profile       User               `json:"profile"`
+	PublicProfile *UserProfile       `json:"public_profile,omitempty"`
+	Packs         []MemoPack         `json:"packs"`
+	Versions      []MemoPackVersion  `json:"versions"`
+	Reviews       []Review           `json:"reviews"`
+	Stars         []StarRecord       `json:"stars"`
+	Subscriptions []SubscriptionInfo `json:"subscriptions"`
+	Downloads     []DownloadRecord   `json:"downloads"`
+	Notifications []Notification     `json:"notifications"`
+	Webhooks      []Webhook          `json:"webhooks"`
+	Collections   []Collection       `json:"collections"`
+	Sessions      []Session          `json:"sessions"`
+	APIKeys       []APIKey           `json:"api_keys"`
+	// Activity is the audit log's record of the user's own actions.
+	Activity []AuditEntry `json:"activity"`
+}
+
+type StarRecord struct {
+	PackID    string `json:"pack_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+type SubscriptionInfo struct {
+	PackID      string `json:"pack_id"`
+	LastVersion string `json:"last_version"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// DownloadRecord is one authenticated download from the download log.
+type DownloadRecord struct {
+	PackID    string `json:"pack_id"`
+	Version   string `json:"version"`
+	CreatedAt string `json:"created_at"`
+}
+
+// LibraryHit is a match from GET /api/me/search. Source is "own" or
+// "downloaded"; Index is the position of the matching rule or memo.
+type LibraryHit struct {
+	PackID   string `json:"pack_id"`
+	PackName string `json:"pack_name"`
+	Version  string `json:"version"`
+	Source   string `json:"source"`
+	Field    string `json:"field"`
+	Index    *int   `json:"index,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Snippet  string `json:"snippet"`
+}
+
+// PackUpdate is a subscribed pack with a newer version than the one installed.
+type PackUpdate struct {
+	PackID           string `json:"pack_id"`
+	Name             string `json:"name"`
+	InstalledVersion string `json:"installed_version"`
+	LatestVersion    string `json:"latest_version"`
+	Channel          string `json:"channel"`
+	UpdatedAt        string `json:"updated_at"`
+}
+
+// Webhook is an outbound HTTP integration. Channel-wide webhooks come from
+// config and have no owner; authors may register their own, either for all of
+// their packs or for a single pack (PackID set).
+type Webhook struct {
+	ID        string   `json:"id"`
+	OwnerID   string   `json:"-"`
+	PackID    string   `json:"pack_id,omitempty"`
+	URL       string   `json:"url"`
+	Kind      string   `json:"kind"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"` // HMAC key; only returned on creation
+	CreatedAt string   `json:"created_at"`
+}
+
+// WebhookDelivery is one queued or attempted webhook call.
+type WebhookDelivery struct {
+	ID            string `json:"id"`
+	WebhookID     string `json:"webhook_id"`
+	Event         string `json:"event"`
+	Payload       string `json:"payload"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt string `json:"next_attempt_at"`
+	LastError     string `json:"last_error,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	DeliveredAt   string `json:"delivered_at,omitempty"`
 }
 
 // ServerInfo describes this backend node (each node = one channel).
 type ServerInfo struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	// PublicKey is the base64 Ed25519 key that signs install receipts.
+	PublicKey string `json:"public_key,omitempty"`
+}
+
+// InstallReceipt records which content a download served. Clients store it
+// next to the installed pack; Signature is the server's Ed25519 signature.
+type InstallReceipt struct {
+	PackID      string `json:"pack_id"`
+	Version     string `json:"version"`
+	Checksum    string `json:"checksum"` // "sha256:<hex>" of the version's content
+	RetrievedAt string `json:"retrieved_at"`
+	Server      string `json:"server"`
+	KeyID       string `json:"key_id"`
+	Signature   string `json:"signature"`
+}
+
+// DownloadedPack is a pack as the download serves it: without the download
+// counter, which changes with every download, so the body, its ETag and its
+// byte ranges stay the same from one download to the next.
+type DownloadedPack struct {
+	*MemoPack
+	Downloads *int `json:"downloads,omitempty"`
+}
+
+// DownloadWithReceipt is the download response when ?receipt=true.
+type DownloadWithReceipt struct {
+	Pack    DownloadedPack `json:"pack"`
+	Receipt InstallReceipt `json:"receipt"`
+}
+
+type ReceiptVerification struct {
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Vacation is a window during which a user's account is read-only.
+type Vacation struct {
+	StartsAt string `json:"starts_at"`
+	EndsAt   string `json:"ends_at"`
+	Message  string `json:"message,omitempty"`
+	Active   bool   `json:"active"`
+}
+
+type VacationReq struct {
+	StartsAt string `json:"starts_at"`
+	EndsAt   string `json:"ends_at"`
+	Message  string `json:"message"`
+}
+
+// Verification is a user's claim to a domain or GitHub account. The claim is
+// proven by publishing Token as a DNS TXT record or in a public gist.
+type Verification struct {
+	ID           string `json:"id"`
+	UserID       string `json:"-"`
+	Kind         string `json:"kind"` // "domain" or "github"
+	Subject      string `json:"subject"`
+	Token        string `json:"token"`
+	Status       string `json:"status"` // pending, verified
+	Instructions string `json:"instructions,omitempty"`
+	CreatedAt    string `json:"created_at"`
+	VerifiedAt   string `json:"verified_at,omitempty"`
+}
+
+type CreateVerificationReq struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+}
+
+// VerifiedIdentity is a proven domain or GitHub account, shown on the user's
+// profile and packs.
+type VerifiedIdentity struct {
+	Kind       string `json:"kind"`
+	Subject    string `json:"subject"`
+	URL        string `json:"url"`
+	VerifiedAt string `json:"verified_at"`
+}
+
+// AdminMetrics is the dashboard payload from GET /api/admin/metrics. Series
+// fed from in-memory counters only cover the time since CollectingSince.
+type AdminMetrics struct {
+	Window          string                   `json:"window"`
+	Bucket          string                   `json:"bucket"`
+	From            string                   `json:"from"`
+	To              string                   `json:"to"`
+	CollectingSince string                   `json:"collecting_since"`
+	QueueDepth      int                      `json:"queue_depth"`
+	Series          map[string][]MetricPoint `json:"series"`
+	// Breakers is the current state of each outbound dependency.
+	Breakers []BreakerStatus `json:"breakers"`
+}
+
+// BreakerStatus is the state of one circuit breaker: closed, open or
+// half_open. Trips counts how often it opened since startup.
+type BreakerStatus struct {
+	Name        string `json:"name"`
+	State       string `json:"state"`
+	Failures    int    `json:"failures"`
+	Trips       int    `json:"trips"`
+	LastError   string `json:"last_error,omitempty"`
+	LastFailure string `json:"last_failure,omitempty"`
+}
+
+// Readiness is the /readyz response. Dependencies maps each outbound
+// dependency used so far to its breaker state.
+type Readiness struct {
+	Status       string            `json:"status"`
+	Database     string            `json:"database"`
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// MetricPoint is one bucket of a series; Time is the bucket's UTC hour
+// ("2006-01-02T15") or day ("2006-01-02").
+type MetricPoint struct {
+	Time  string  `json:"t"`
+	Value float64 `json:"value"`
+}
+
+// Capabilities lists the features this node has enabled so clients can adapt
+// to differently-configured channels.
+type Capabilities struct {
+	APIVersion string           `json:"api_version"`
+	Features   map[string]bool  `json:"features"`
+	Formats    []string         `json:"formats"`
+	Auth       []string         `json:"auth"`
+	Sorts      []string         `json:"sorts"`
+	Webhooks   WebhookCaps      `json:"webhooks"`
+	RuleSchema RuleSchemaCaps   `json:"rule_schema"`
+	Limits     CapabilityLimits `json:"limits"`
+}
+
+// RuleSchemaCaps describes the typed rule fields the server accepts.
+type RuleSchemaCaps struct {
+	Version     int      `json:"version"`
+	Triggers    []string `json:"triggers"`
+	Actions     []string `json:"actions"`
+	MaxPriority int      `json:"max_priority"`
+}
+
+type WebhookCaps struct {
+	Kinds  []string `json:"kinds"`
+	Events []string `json:"events"`
+}
+
+// CapabilityLimits reports size limits; zero means unlimited.
+type CapabilityLimits struct {
+	MaxPageSize          int `json:"max_page_size"`
+	DownloadsPerMinute   int `json:"downloads_per_minute"`
+	DownloadBurst        int `json:"download_burst"`
+	PackDownloadsPerHour int `json:"pack_downloads_per_hour"`
+	// DownloadBytesPerSecond is 0 when downloads are not capped.
+	DownloadBytesPerSecond int `json:"download_bytes_per_second"`
+	RequestsPerMinute      int `json:"requests_per_minute"`
+	AuthRequestsPerMinute  int `json:"auth_requests_per_minute"`
+	// Content is what one pack may hold.
+	Content ContentLimits `json:"content"`
+}
+
+// ServerConfig is the persisted node configuration (config.json).
+type ServerConfig struct {
+	Name         string              `json:"name"`
+	Description  string              `json:"description"`
+	LLM          *LLMConfig          `json:"llm,omitempty"`
+	Webhooks     []WebhookConfig     `json:"webhooks,omitempty"`
+	NamingPolicy *NamingPolicyConfig `json:"naming_policy,omitempty"`
+	RateLimits   *RateLimitConfig    `json:"rate_limits,omitempty"`
+	Retention    *RetentionConfig    `json:"retention,omitempty"`
+	// Bodies larger than this many bytes are stored outside the packs table.
+	PackBlobThreshold int                `json:"pack_blob_threshold,omitempty"`
+	Concurrency       *ConcurrencyConfig `json:"concurrency,omitempty"`
+	// Usernames allowed to use the /api/admin endpoints.
+	Admins []string     `json:"admins,omitempty"`
+	Cache  *CacheConfig `json:"cache,omitempty"`
+	// Standby makes this node a read-only replica of another.
+	Standby *StandbyConfig `json:"standby,omitempty"`
+	// AppealURL is linked from moderation notices.
+	AppealURL string `json:"appeal_url,omitempty"`
+	// SMTP enables moderation emails.
+	SMTP *SMTPConfig `json:"smtp,omitempty"`
+	// IDs picks the ID scheme for new packs.
+	IDs *IDConfig `json:"ids,omitempty"`
+	// AnonymousPublishing lets people without an account publish packs.
+	AnonymousPublishing *AnonymousPublishingConfig `json:"anonymous_publishing,omitempty"`
+	// TrustLevels overrides the trust level thresholds and limits.
+	TrustLevels *TrustConfig `json:"trust_levels,omitempty"`
+	// Quotas caps the packs each user may publish.
+	Quotas *QuotaConfig `json:"quotas,omitempty"`
+	// Cookies configures the session cookies of browser logins.
+	Cookies *CookieConfig `json:"cookies,omitempty"`
+	// InviteOnly closes registration to everyone without an invite code.
+	InviteOnly bool `json:"invite_only,omitempty"`
+	// OIDC enables single sign-on through an OpenID Connect provider.
+	OIDC *OIDCConfig `json:"oidc,omitempty"`
+	// Duplicates configures what happens when a published pack copies an
+	// existing one.
+	Duplicates *DuplicatesConfig `json:"duplicates,omitempty"`
+	// Limits caps the size of pack content.
+	Limits *ContentLimits `json:"limits,omitempty"`
+	// Attachments configures where memo attachments are stored.
+	Attachments *AttachmentsConfig `json:"attachments,omitempty"`
+	// URLImport configures POST /api/memo-packs/import.
+	URLImport *URLImportConfig `json:"url_import,omitempty"`
+	// PromptExport sets the layout of format=prompt exports.
+	PromptExport *PromptExportConfig `json:"prompt_export,omitempty"`
+}
+
+// PromptExportConfig replaces the text/template a prompt export is
+// rendered with; see promptexport.go.
+type PromptExportConfig struct {
+	Template string `json:"template,omitempty"`
+}
+
+// URLImportConfig lets URL imports reach private networks, for channels
+// that copy from each other on a LAN.
+type URLImportConfig struct {
+	AllowPrivateNetworks bool `json:"allow_private_networks,omitempty"`
+}
+
+// AttachmentsConfig stores attachments in S3 rather than the data dir, and
+// sets how long signed download URLs last (default an hour).
+type AttachmentsConfig struct {
+	S3            *S3Config `json:"s3,omitempty"`
+	URLTTLSeconds int       `json:"url_ttl_seconds,omitempty"`
+}
+
+// S3Config addresses an S3 bucket. Endpoint is for S3-compatible servers and
+// left empty for AWS. Prefix is prepended to object keys.
+type S3Config struct {
+	Endpoint        string `json:"endpoint,omitempty"`
+	Region          string `json:"region"`
+	Bucket          string `json:"bucket"`
+	Prefix          string `json:"prefix,omitempty"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+// ContentLimits caps what one pack may hold. In the config, zero values keep
+// the defaults and negative values lift a limit; in capabilities, zero means
+// unlimited. MaxMemoChars covers memo content and rule update rules, and
+// MaxTags the tags on each rule or memo.
+type ContentLimits struct {
+	MaxRules             int `json:"max_rules"`
+	MaxMemos             int `json:"max_memos"`
+	MaxSystemPromptChars int `json:"max_system_prompt_chars"`
+	MaxTitleChars        int `json:"max_title_chars"`
+	MaxMemoChars         int `json:"max_memo_chars"`
+	MaxTags              int `json:"max_tags"`
+	MaxRequestBytes      int `json:"max_request_bytes"`
+	// MaxAttachments and MaxAttachmentBytes cap a pack's attachments and
+	// the size of each.
+	MaxAttachments     int `json:"max_attachments"`
+	MaxAttachmentBytes int `json:"max_attachment_bytes"`
+}
+
+// DuplicatesConfig sets duplicate detection on publish. Mode is "warn" (the
+// default), "reject" or "off"; MaxDistance is how many simhash bits
+// near-identical packs may differ in (default 6, 0 for exact copies only).
+type DuplicatesConfig struct {
+	Mode        string `json:"mode,omitempty"`
+	MaxDistance *int   `json:"max_distance,omitempty"`
+}
+
+// TrustConfig overrides the settings of each trust level; unset fields
+// keep their defaults.
+type TrustConfig struct {
+	New     *TrustLevelConfig `json:"new,omitempty"`
+	Basic   *TrustLevelConfig `json:"basic,omitempty"`
+	Trusted *TrustLevelConfig `json:"trusted,omitempty"`
+}
+
+// TrustLevelConfig is one trust level: what reaching it takes, and what it
+// allows. DailyPublishes 0 is unlimited.
+type TrustLevelConfig struct {
+	MinAccountDays *int  `json:"min_account_days,omitempty"`
+	MinPacks       *int  `json:"min_packs,omitempty"`
+	MinDownloads   *int  `json:"min_downloads,omitempty"`
+	DailyPublishes *int  `json:"daily_publishes,omitempty"`
+	ExternalLinks  *bool `json:"external_links,omitempty"`
+	Attachments    *bool `json:"attachments,omitempty"`
+}
+
+// AnonymousPublishingConfig turns on anonymous publishing. PerHour limits
+// packs per IP (default 3).
+type AnonymousPublishingConfig struct {
+	Enabled bool `json:"enabled"`
+	PerHour int  `json:"per_hour,omitempty"`
+}
+
+// IDConfig chooses how new pack IDs look. Strategy "short" gives base58 IDs
+// of Length characters (default 10) instead of UUIDs.
+type IDConfig struct {
+	Strategy string `json:"strategy"`
+	Length   int    `json:"length,omitempty"`
+}
+
+// SMTPConfig is the mail server used for outgoing email.
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	From     string `json:"from"`
+}
+
+// CacheConfig overrides the Cache-Control max-age, in seconds, of each route
+// class; 0 makes clients revalidate every time.
+type CacheConfig struct {
+	ListingSeconds   *int `json:"listing_seconds,omitempty"`
+	PackSeconds      *int `json:"pack_seconds,omitempty"`
+	ImmutableSeconds *int `json:"immutable_seconds,omitempty"`
+	InfoSeconds      *int `json:"info_seconds,omitempty"`
+}
+
+// StandbyConfig points a standby node at its primary. Token must belong to
+// an admin on the primary.
+type StandbyConfig struct {
+	Primary string `json:"primary"`
+	Token   string `json:"token"`
+	// RefreshToken renews Token when it expires; the renewed token is
+	// written back to config.json.
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	IntervalSeconds int    `json:"interval_seconds,omitempty"`
+}
+
+// ReplicationStatus describes a node's role. Checksum covers all replicated
+// data, so equal checksums mean a standby is caught up.
+type ReplicationStatus struct {
+	Role       string `json:"role"` // primary or standby
+	Checksum   string `json:"checksum"`
+	Primary    string `json:"primary,omitempty"`
+	LastSyncAt string `json:"last_sync_at,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+type PromotionResult struct {
+	Role             string `json:"role,omitempty"`
+	Checksum         string `json:"checksum"`
+	PrimaryReachable bool   `json:"primary_reachable"`
+	Diverged         bool   `json:"diverged"`
+	Warning          string `json:"warning,omitempty"`
+}
+
+// ConcurrencyConfig caps simultaneous requests to expensive endpoints.
+// Routes keys are import, export, evals and etl; Global spans all of them.
+type ConcurrencyConfig struct {
+	Global int            `json:"global,omitempty"`
+	Routes map[string]int `json:"routes,omitempty"`
+}
+
+// RetentionConfig sets how many days the cleanup job keeps each kind of
+// record; 0 keeps them forever. Unset fields use the defaults.
+type RetentionConfig struct {
+	DownloadEventsDays    *int `json:"download_events_days,omitempty"`
+	WebhookDeliveriesDays *int `json:"webhook_deliveries_days,omitempty"`
+	JobsDays              *int `json:"jobs_days,omitempty"`
+	DeletedPacksDays      *int `json:"deleted_packs_days,omitempty"`
+	AuditLogDays          *int `json:"audit_log_days,omitempty"`
+}
+
+// RateLimitConfig tunes the download limits; zero values keep the defaults,
+// negative values disable a limit.
+type RateLimitConfig struct {
+	DownloadsPerMinute   int `json:"downloads_per_minute"`
+	DownloadBurst        int `json:"download_burst"`
+	PackDownloadsPerHour int `json:"pack_downloads_per_hour"`
+	// DownloadBytesPerSecond caps the bandwidth of each pack or export
+	// download; 0 leaves it uncapped.
+	DownloadBytesPerSecond int `json:"download_bytes_per_second,omitempty"`
+	// Limits on all API requests: per IP for anonymous callers, per token
+	// for authenticated ones.
+	RequestsPerMinute     int `json:"requests_per_minute"`
+	RequestBurst          int `json:"request_burst"`
+	AuthRequestsPerMinute int `json:"auth_requests_per_minute"`
+	AuthRequestBurst      int `json:"auth_request_burst"`
+}
+
+// NamingPolicyConfig holds regex deny/allow lists for usernames and pack names.
+// Deny and Allow apply to both; the prefixed lists to one kind only.
+type NamingPolicyConfig struct {
+	Deny             []string `json:"deny,omitempty"`
+	Allow            []string `json:"allow,omitempty"`
+	UsernameDeny     []string `json:"username_deny,omitempty"`
+	UsernameAllow    []string `json:"username_allow,omitempty"`
+	PackDeny         []string `json:"pack_deny,omitempty"`
+	PackAllow        []string `json:"pack_allow,omitempty"`
+	ProtectDownloads *int     `json:"protect_downloads,omitempty"`
+}
+
+// WebhookConfig is a channel-wide webhook declared by the operator.
+type WebhookConfig struct {
+	URL    string   `json:"url"`
+	Kind   string   `json:"kind"`
+	Events []string `json:"events,omitempty"`
+}
+
+// LLMConfig points the eval runner at an OpenAI-compatible chat completions API.
+type LLMConfig struct {
+	APIURL string `json:"api_url"`
+	APIKey string `json:"api_key"`
+	Model  string `json:"model"`
 }
 
 // --- Request / Response types ---
@@ -53,23 +1241,361 @@ type PublishMemoPackReq struct {
 	SystemPrompt string     `json:"system_prompt"`
 	Rules        []MemoRule `json:"rules"`
 	Memos        []Memo     `json:"memos"`
+	Evals        []PackEval `json:"evals"`
+	Version      string     `json:"version"`
+	Homepage     string     `json:"homepage"`
+	Repository   string     `json:"repository"`
+	Contact      string     `json:"contact"`
+	RequireAuth  bool       `json:"require_auth"`
+	// Channel is "stable" (the default) or "beta".
+	Channel string `json:"channel"`
+	// Language and VariantOf keep their current values on update when empty.
+	Language  string `json:"language"`
+	VariantOf string `json:"variant_of"`
+	// Category keeps its current value on update when empty.
+	Category string `json:"category,omitempty"`
+	// Visibility is "public" (the default), "unlisted" or "private". It
+	// keeps its current value on update when empty.
+	Visibility string `json:"visibility,omitempty"`
+	// Embargo, on publish, hides the pack from all but the named users
+	// until a date.
+	Embargo *EmbargoReq `json:"embargo,omitempty"`
+	// Draft creates the pack unpublished, visible only to its editors until
+	// POST /api/memo-packs/{id}/publish.
+	Draft bool `json:"draft,omitempty"`
+}
+
+// PublishDraftReq is the optional body of POST /api/memo-packs/{id}/publish.
+type PublishDraftReq struct {
+	Embargo *EmbargoReq `json:"embargo,omitempty"`
+}
+
+// PackChecksum is the response of GET /api/memo-packs/{id}/checksum.
+// Revision is only set for the current version.
+type PackChecksum struct {
+	PackID    string `json:"pack_id"`
+	Version   string `json:"version"`
+	Revision  int    `json:"revision,omitempty"`
+	Checksum  string `json:"checksum"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// ReorderMemosReq is the body of PUT /api/memo-packs/{id}/memos/order: the
+// memos' current orders in their new sequence.
+type ReorderMemosReq struct {
+	Order []int `json:"order"`
+}
+
+// ForkPackReq is the optional body of POST /api/memo-packs/{id}/fork. Name
+// defaults to the original's.
+type ForkPackReq struct {
+	Name  string `json:"name,omitempty"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// BatchResult is the outcome of one pack of a batch publish. Status is what
+// a single publish of it would have answered; it is 0 for a valid pack left
+// unstored because others were refused.
+type BatchResult struct {
+	Index       int             `json:"index"`
+	Name        string          `json:"name"`
+	Status      int             `json:"status,omitempty"`
+	Error       json.RawMessage `json:"error,omitempty"`
+	ID          string          `json:"id,omitempty"`
+	Slug        string          `json:"slug,omitempty"`
+	PackStatus  string          `json:"pack_status,omitempty"`
+	DuplicateOf *DuplicateMatch `json:"duplicate_of,omitempty"`
+}
+
+// BatchResponse answers POST /api/memo-packs/batch.
+type BatchResponse struct {
+	Error   string        `json:"error,omitempty"`
+	Results []BatchResult `json:"results"`
+}
+
+// ClonePackReq is the optional body of POST /api/memo-packs/{id}/clone.
+// Name defaults to the original's with " (copy)" appended.
+type ClonePackReq struct {
+	Name string `json:"name,omitempty"`
+}
+
+// Collaborator is a user who may edit another author's pack.
+type Collaborator struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	AddedBy  string `json:"added_by"`
+	AddedAt  string `json:"added_at"`
+}
+
+// CollaboratorReq is the body of POST /api/memo-packs/{id}/collaborators.
+type CollaboratorReq struct {
+	Username string `json:"username"`
+}
+
+// ShareLink opens a private pack to whoever holds its token. Token and URL
+// are only set in the response that creates the link.
+type ShareLink struct {
+	ID        string `json:"id"`
+	PackID    string `json:"pack_id"`
+	Token     string `json:"token,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Note      string `json:"note,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	CreatedBy string `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ShareLinkReq is the body of POST /api/memo-packs/{id}/share-links. An
+// empty ExpiresAt never expires.
+type ShareLinkReq struct {
+	ExpiresAt string `json:"expires_at,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+// EmbargoReq sets a pack's embargo: Until is a date or timestamp, Viewers
+// the usernames who may see the pack before then.
+type EmbargoReq struct {
+	Until   string   `json:"until"`
+	Viewers []string `json:"viewers"`
+}
+
+// PackEmbargo is a pack's embargo; Until is empty once it is public.
+type PackEmbargo struct {
+	Until   string   `json:"until,omitempty"`
+	Viewers []string `json:"viewers"`
+}
+
+// EmbargoPayload is the payload of an embargo_release job.
+type EmbargoPayload struct {
+	PackID string `json:"pack_id"`
+	Until  string `json:"until"`
+}
+
+// PackManifest is the memopack.json interchange format: a publish request
+// plus the format version, earlier versions oldest first, and assets.
+type PackManifest struct {
+	ManifestVersion int `json:"manifest_version"`
+	PublishMemoPackReq
+	Versions []ManifestVersion `json:"versions,omitempty"`
+	Assets   []ManifestAsset   `json:"assets,omitempty"`
+}
+
+type ManifestVersion struct {
+	Version      string     `json:"version"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	SystemPrompt string     `json:"system_prompt"`
+	Rules        []MemoRule `json:"rules"`
+	Memos        []Memo     `json:"memos"`
+	Channel      string     `json:"channel,omitempty"`
+	CreatedAt    string     `json:"created_at,omitempty"`
+}
+
+// ManifestAsset describes a file shipped alongside a pack. URL is optional;
+// without one the asset travels with the manifest, e.g. in the same repo.
+type ManifestAsset struct {
+	Path      string `json:"path"`
+	MediaType string `json:"media_type,omitempty"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	URL       string `json:"url,omitempty"`
+}
+
+type ManifestValidation struct {
+	Valid           bool     `json:"valid"`
+	ManifestVersion int      `json:"manifest_version,omitempty"`
+	Errors          []string `json:"errors"`
+	Warnings        []string `json:"warnings,omitempty"`
+}
+
+// AnonymousPublishResp is an anonymously published pack with the token
+// that claims it.
+type AnonymousPublishResp struct {
+	*MemoPack
+	ClaimToken string `json:"claim_token"`
+}
+
+type ClaimPackReq struct {
+	ClaimToken string `json:"claim_token"`
+}
+
+// ImportURLReq is the body of POST /api/memo-packs/import. Category and
+// Visibility override what the fetched pack says.
+type ImportURLReq struct {
+	URL        string `json:"url"`
+	Draft      bool   `json:"draft,omitempty"`
+	Category   string `json:"category,omitempty"`
+	Visibility string `json:"visibility,omitempty"`
+}
+
+type ImportGitHubReq struct {
+	Repo string `json:"repo"`
+	Ref  string `json:"ref"`
+	Path string `json:"path"`
+	// Mapping, when set, names the files to import; see githubmapping.go.
+	Mapping []ImportFileMapping `json:"mapping,omitempty"`
+}
+
+// ImportFileMapping sends the repository files matching Pattern to Target:
+// "system_prompt", "rules" or "memos". Split "headings" (the default) makes
+// a rule or memo of each level 1-2 heading; "file" makes one of each file.
+// Tags are added to each rule or memo made.
+type ImportFileMapping struct {
+	Pattern string   `json:"pattern"`
+	Target  string   `json:"target"`
+	Split   string   `json:"split,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+type ReviewReq struct {
+	Rating int    `json:"rating"`
+	Body   string `json:"body"`
+}
+
+type SubscribeReq struct {
+	Version string `json:"version"`
+	Channel string `json:"channel"`
+}
+
+type CreateWebhookReq struct {
+	URL    string   `json:"url"`
+	Kind   string   `json:"kind"`
+	Events []string `json:"events"`
 }
 
 type RegisterReq struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// InviteCode is required when the server is invite-only.
+	InviteCode string `json:"invite_code,omitempty"`
+	// Cookie is as for LoginReq.
+	Cookie bool `json:"cookie,omitempty"`
+}
+
+// Invite is a registration code. MaxUses 0 is unlimited; ExpiresAt empty
+// never expires.
+type Invite struct {
+	Code      string `json:"code"`
+	Note      string `json:"note,omitempty"`
+	MaxUses   int    `json:"max_uses"`
+	Uses      int    `json:"uses"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	CreatedBy string `json:"created_by"`
+	CreatedAt string `json:"created_at"`
+}
+
+// InviteReq creates an invite; MaxUses defaults to 1.
+type InviteReq struct {
+	MaxUses   *int   `json:"max_uses,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	Note      string `json:"note,omitempty"`
 }
 
 type LoginReq struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// Cookie asks for the login to be kept in cookies rather than returned
+	// as tokens.
+	Cookie bool `json:"cookie,omitempty"`
+	// Reactivate restores an account its owner deactivated.
+	Reactivate bool `json:"reactivate,omitempty"`
+}
+
+type RefreshTokenReq struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenResponse is returned by POST /api/token/refresh.
+// Logins kept in cookies get the tokens as cookies and a new CSRFToken
+// instead.
+type TokenResponse struct {
+	Token            string `json:"token,omitempty"`
+	TokenExpiresAt   string `json:"token_expires_at"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	RefreshExpiresAt string `json:"refresh_expires_at"`
+	CSRFToken        string `json:"csrf_token,omitempty"`
+}
+
+// Session is one login's token pair. The tokens themselves are never listed.
+type Session struct {
+	ID               string `json:"id"`
+	UserID           string `json:"-"`
+	Token            string `json:"-"`
+	RefreshToken     string `json:"-"`
+	UserAgent        string `json:"user_agent"`
+	CreatedAt        string `json:"created_at"`
+	LastUsedAt       string `json:"last_used_at,omitempty"`
+	ExpiresAt        string `json:"expires_at"`
+	RefreshExpiresAt string `json:"refresh_expires_at"`
+	Current          bool   `json:"current"`
+}
+
+// APIKey is a long-lived credential limited to some scopes. Key is only
+// set in the response that creates it; the server keeps a hash.
+type APIKey struct {
+	ID     string `json:"id"`
+	UserID string `json:"-"`
+	// ServiceAccountID is set on the tokens of a service account.
+	ServiceAccountID string   `json:"-"`
+	Name             string   `json:"name"`
+	Prefix           string   `json:"prefix"`
+	Scopes           []string `json:"scopes"`
+	CreatedAt        string   `json:"created_at"`
+	LastUsedAt       string   `json:"last_used_at,omitempty"`
+	Key              string   `json:"key,omitempty"`
+}
+
+// ServiceAccount publishes as Author with tokens an admin issues.
+type ServiceAccount struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	AuthorID  string   `json:"-"`
+	Author    string   `json:"author"`
+	CreatedBy string   `json:"created_by"`
+	CreatedAt string   `json:"created_at"`
+	Tokens    []APIKey `json:"tokens"`
+}
+
+// ServiceAccountReq creates a service account publishing as the user named
+// Author.
+type ServiceAccountReq struct {
+	Name   string `json:"name"`
+	Author string `json:"author"`
+}
+
+type APIKeyReq struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
 }
 
 type ListQuery struct {
 	Search string
 	Author string
+	Sort   string
 	Page   int
 	Limit  int
+	// DebugScore includes each item's relevance score in search results.
+	DebugScore bool
+	// Languages are preferred primary languages, best first.
+	Languages []string
+
+	// Optional content-size filters; nil means unset.
+	MinRules *int
+	MaxRules *int
+	MinMemos *int
+	MaxMemos *int
+	MaxChars *int
+	// Archived, when set, keeps only archived or only maintained packs.
+	Archived *bool
+	// Pinned keeps only packs pinned by their author.
+	Pinned bool
+	// Featured keeps only packs featured by an admin.
+	Featured bool
+	// Category keeps only packs filed under that category slug.
+	Category string
+	// Viewer is the caller's user ID. An ?author= listing also includes the
+	// unlisted and private packs Viewer can edit.
+	Viewer string
 }
 
 type ListResponse struct {
@@ -83,6 +1609,57 @@ type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// FieldError is one field that fails validation, e.g. "memos[3].content",
+// with the limit it breaks.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+// ValidationErrors is the error response listing every invalid field.
+type ValidationErrors struct {
+	Error  string       `json:"error"`
+	Errors []FieldError `json:"errors"`
+}
+
+// DuplicateMatch is an existing pack a new one copies. Exact is set when
+// their content is the same, not just nearly.
+type DuplicateMatch struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	AuthorName string `json:"author_name"`
+	Exact      bool   `json:"exact"`
+}
+
+// RelatedPack is a pack suggested alongside another, with what they have in
+// common.
+type RelatedPack struct {
+	MemoPack
+	Related RelatedReasons `json:"related"`
+}
+
+// RelatedReasons explains a related pack's score: the tags both packs use
+// and the clients that downloaded both.
+type RelatedReasons struct {
+	Score       float64  `json:"score"`
+	SharedTags  []string `json:"shared_tags,omitempty"`
+	CoDownloads int      `json:"co_downloads,omitempty"`
+}
+
+// DuplicateConflict is returned with 409 when duplicates are rejected.
+type DuplicateConflict struct {
+	Error       string          `json:"error"`
+	DuplicateOf *DuplicateMatch `json:"duplicate_of"`
+}
+
+// RevisionConflict is returned with 409 when an update was based on a stale
+// pack revision.
+type RevisionConflict struct {
+	Error    string `json:"error"`
+	Revision int    `json:"revision"`
+}
+
 // --- JSON marshal helpers for DB storage ---
 
 func MarshalRules(rules []MemoRule) string {
@@ -110,5 +1687,39 @@ func UnmarshalMemos(s string) []Memo {
 	if memos == nil {
 		memos = []Memo{}
 	}
+	orderMemos(memos)
 	return memos
 }
+
+func MarshalEvals(evals []PackEval) string {
+	b, _ := json.Marshal(evals)
+	return string(b)
+}
+
+func UnmarshalEvals(s string) []PackEval {
+	var evals []PackEval
+	json.Unmarshal([]byte(s), &evals)
+	if evals == nil {
+		evals = []PackEval{}
+	}
+	return evals
+}
+
+func MarshalProvenance(p *PackProvenance) string {
+	if p == nil {
+		return ""
+	}
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+func UnmarshalProvenance(s string) *PackProvenance {
+	if s == "" {
+		return nil
+	}
+	var p PackProvenance
+	if json.Unmarshal([]byte(s), &p) != nil {
+		return nil
+	}
+	return &p
+}
@@ -0,0 +1,120 @@
+package memomarket
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Moderation bookkeeping: destructive admin actions need a reason, which is
+// kept in the audit log and passed on to the users affected, in-app and by
+// email when SMTP is configured, together with where to appeal.
+
+const maxAdminReasonChars = 500
+
+// appealURL is where users can contest a moderation decision, from config.json.
+var appealURL string
+
+// smtpConfig sends moderation emails; nil when email is not configured.
+var smtpConfig *SMTPConfig
+
+const JobSendEmail = "send_email"
+
+// adminReason reads the reason for an admin action from ?reason= or a JSON
+// body's "reason" field.
+func adminReason(r *http.Request) (string, error) {
+	if reason := r.URL.Query().Get("reason"); reason != "" {
+		return strings.TrimSpace(reason), nil
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := decodeJSON(r, &req); err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(req.Reason), nil
+}
+
+// validateAdminReason checks a reason given for a destructive action.
+func validateAdminReason(reason string) error {
+	if reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if utf8.RuneCountInString(reason) > maxAdminReasonChars {
+		return fmt.Errorf("reason must be at most %d characters", maxAdminReasonChars)
+	}
+	return nil
+}
+
+// notifyModeration tells userID about a moderation decision with its reason
+// and the appeal link. Unlike other notifications it is delivered during
+// vacation. contact, usually the pack's contact field, also gets an email
+// when it is an address and SMTP is configured.
+func notifyModeration(userID, packID, contact, message, reason string) {
+	msg := message
+	if reason != "" {
+		msg += " Reason: " + strings.TrimRight(reason, ".") + "."
+	}
+	if appealURL != "" {
+		msg += " To appeal, see " + appealURL
+	}
+	n := &Notification{ID: newID(), UserID: userID, Kind: "moderation", PackID: packID, Message: msg, CreatedAt: nowISO()}
+	if err := store.InsertNotification(n); err != nil {
+		log.Printf("moderation notice %s: %v", userID, err)
+	}
+	if smtpConfig == nil {
+		return
+	}
+	if addr, err := mail.ParseAddress(contact); err == nil && addr.Address == contact {
+		email := EmailPayload{To: contact, Subject: serverName + ": moderation notice", Body: msg}
+		if _, err := enqueueJob(JobSendEmail, userID, email); err != nil {
+			log.Printf("moderation email %s: %v", userID, err)
+		}
+	}
+}
+
+// runSendEmail delivers a queued email; the job worker retries failures.
+func runSendEmail(j *Job) (string, error) {
+	var email EmailPayload
+	if err := json.Unmarshal([]byte(j.Payload), &email); err != nil {
+		return "", fmt.Errorf("bad payload: %v", err)
+	}
+	cfg := smtpConfig
+	if cfg == nil {
+		return "email not configured; dropped", nil
+	}
+	msg := "From: " + cfg.From + "\r\nTo: " + email.To + "\r\nSubject: " + email.Subject +
+		"\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n" + email.Body + "\r\n"
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	var sendErr error
+	err := breaker(breakerSMTP).call(func() error {
+		sendErr = smtp.SendMail(cfg.Host+":"+strconv.Itoa(port), auth, cfg.From, []string{email.To}, []byte(msg))
+		// A rejection, such as an unknown recipient, comes from a working server.
+		var reply *textproto.Error
+		if errors.As(sendErr, &reply) {
+			return nil
+		}
+		return sendErr
+	})
+	if err = cmp.Or(err, sendErr); err != nil {
+		return "", err
+	}
+	return "sent to " + email.To, nil
+}
@@ -0,0 +1,153 @@
+package memomarket
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Naming policy applied to usernames and pack names on register, publish and rename.
+// Deny/allow lists come from config; confusable-character folding stops new names
+// from impersonating existing authors or popular packs.
+
+type namingRules struct {
+	deny  []*regexp.Regexp
+	allow []*regexp.Regexp
+}
+
+var namingPolicy = struct {
+	usernames namingRules
+	packs     namingRules
+	// Packs with at least this many downloads are protected from look-alike names.
+	protectDownloads int
+}{protectDownloads: 100}
+
+func compileNamingRules(deny, allow []string) namingRules {
+	var rules namingRules
+	for _, p := range deny {
+		if re, err := regexp.Compile("(?i)" + p); err == nil {
+			rules.deny = append(rules.deny, re)
+		} else {
+			log.Printf("Ignoring invalid naming deny pattern %q: %v", p, err)
+		}
+	}
+	for _, p := range allow {
+		if re, err := regexp.Compile("(?i)" + p); err == nil {
+			rules.allow = append(rules.allow, re)
+		} else {
+			log.Printf("Ignoring invalid naming allow pattern %q: %v", p, err)
+		}
+	}
+	return rules
+}
+
+func loadNamingPolicy(cfg *NamingPolicyConfig) {
+	if cfg == nil {
+		return
+	}
+	namingPolicy.usernames = compileNamingRules(slices.Concat(cfg.Deny, cfg.UsernameDeny), slices.Concat(cfg.Allow, cfg.UsernameAllow))
+	namingPolicy.packs = compileNamingRules(slices.Concat(cfg.Deny, cfg.PackDeny), slices.Concat(cfg.Allow, cfg.PackAllow))
+	if cfg.ProtectDownloads != nil {
+		namingPolicy.protectDownloads = *cfg.ProtectDownloads
+	}
+}
+
+func (n namingRules) active() bool {
+	return len(n.deny) > 0 || len(n.allow) > 0
+}
+
+func (n namingRules) check(kind, name string) error {
+	folded := nameSkeleton(name)
+	for _, re := range n.deny {
+		if re.MatchString(name) || re.MatchString(folded) {
+			return fmt.Errorf("%s %q is not allowed", kind, name)
+		}
+	}
+	if len(n.allow) == 0 {
+		return nil
+	}
+	for _, re := range n.allow {
+		if re.MatchString(name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %q does not match the channel's naming rules", kind, name)
+}
+
+// checkUsernamePolicy applies the naming policy and rejects look-alikes of existing users.
+func checkUsernamePolicy(name string) error {
+	if err := namingPolicy.usernames.check("username", name); err != nil {
+		return err
+	}
+	if other, err := store.FindUserBySkeleton(nameSkeleton(name)); err == nil && !strings.EqualFold(other, name) {
+		return fmt.Errorf("username %q is too similar to existing user %q", name, other)
+	}
+	return nil
+}
+
+// checkPackNamePolicy applies the naming policy and rejects look-alikes of
+// other authors' popular packs.
+func checkPackNamePolicy(name, authorID string) error {
+	if err := namingPolicy.packs.check("pack name", name); err != nil {
+		return err
+	}
+	other, err := store.FindPopularPackBySkeleton(nameSkeleton(name), authorID, namingPolicy.protectDownloads)
+	if err == nil {
+		return fmt.Errorf("pack name %q is too similar to existing pack %q", name, other)
+	}
+	return nil
+}
+
+// confusables folds characters commonly used in homograph attacks onto
+// their ASCII look-alikes.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'l', 'ј': 'j', 'ѕ': 's',
+	'ԁ': 'd', 'ӏ': 'l', 'ո': 'n',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'l', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+	// Digits and symbols
+	'0': 'o', '1': 'l', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '$': 's', '@': 'a', '|': 'l', '!': 'l',
+	// Latin look-alikes; i and l are indistinguishable in many fonts once uppercased.
+	'i': 'l', 'ı': 'l', 'ł': 'l', 'ø': 'o', 'đ': 'd', 'ß': 's',
+}
+
+// Latin letters with diacritics, folded to their base letter.
+var diacriticBase = map[rune]rune{}
+
+func init() {
+	for base, variants := range map[rune]string{
+		'a': "àáâãäåāăą", 'c': "çćĉċč", 'e': "èéêëēĕėęě", 'g': "ĝğġģ", 'i': "ìíîïĩīĭįİ",
+		'n': "ñńņňŉ", 'o': "òóôõöōŏő", 'u': "ùúûüũūŭůűų", 'y': "ýÿŷ", 'z': "źżž",
+		's': "śŝşš", 'r': "ŕŗř", 'l': "ĺļľŀ", 'k': "ķ", 'j': "ĵ", 'h': "ĥħ", 'd': "ď", 't': "ţťŧ", 'w': "ŵ",
+	} {
+		for _, v := range variants {
+			diacriticBase[v] = base
+			diacriticBase[unicode.ToUpper(v)] = base
+		}
+	}
+}
+
+// nameSkeleton reduces a name to a comparison key: lowercased, confusables and
+// diacritics folded, 'rn' read as 'm', and separators, punctuation and
+// invisible characters removed.
+func nameSkeleton(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if base, ok := diacriticBase[r]; ok {
+			r = base
+		}
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return strings.ReplaceAll(b.String(), "rn", "m")
+}
@@ -0,0 +1,653 @@
+package memomarket
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// With "oidc" set in config.json, people can sign in through the channel's
+// own identity provider. GET /api/auth/oidc/login sends the browser there
+// and the callback signs it in with session cookies. The provider's subject
+// identifies the person: the first sign-in creates an account named from
+// the username claim, and later ones reach the same account whatever the
+// name has become upstream. Admins can link a subject to an existing
+// account instead. The provider decides who may sign in, so invite_only
+// doesn't apply; password logins keep working.
+
+const (
+	oidcCookie       = "mm_oidc"
+	oidcCallbackPath = "/api/auth/oidc/callback"
+	oidcLoginTTL     = 10 * time.Minute
+	// oidcDiscoveryTTL is how long the provider's metadata is cached; keys
+	// are refetched sooner when a token names one we don't have.
+	oidcDiscoveryTTL = time.Hour
+	oidcKeysMinAge   = time.Minute
+)
+
+var errOIDCNotLinked = errors.New("no account is linked to this sign-in; ask an admin to link one")
+
+var oidcClient = &http.Client{Timeout: 15 * time.Second, Transport: newBreakerTransport(breakerOIDC, false)}
+
+// oidcConfig is set when single sign-on is configured.
+var oidcConfig *OIDCConfig
+
+// oidcCache holds the provider's metadata and signing keys.
+var oidcCache struct {
+	sync.Mutex
+	provider    *oidcProvider
+	fetched     time.Time
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+func loadOIDC(cfg *OIDCConfig) {
+	oidcCache.Lock()
+	oidcCache.provider, oidcCache.keys = nil, nil
+	oidcCache.Unlock()
+	oidcConfig = nil
+	if cfg == nil || cfg.Issuer == "" || cfg.ClientID == "" {
+		return
+	}
+	c := *cfg
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"openid", "profile", "email"}
+	} else if !slices.Contains(c.Scopes, "openid") {
+		c.Scopes = append([]string{"openid"}, c.Scopes...)
+	}
+	if c.UsernameClaim == "" {
+		c.UsernameClaim = "preferred_username"
+	}
+	oidcConfig = &c
+}
+
+func (c *OIDCConfig) createAccounts() bool {
+	return c.CreateAccounts == nil || *c.CreateAccounts
+}
+
+// redirectURL is where the provider sends the browser back to.
+func (c *OIDCConfig) redirectURL(r *http.Request) string {
+	if c.RedirectURL != "" {
+		return c.RedirectURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + oidcCallbackPath
+}
+
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcDiscover returns the provider's metadata, fetching it when the cached
+// copy is missing or stale.
+func oidcDiscover() (*oidcProvider, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+	if oidcCache.provider != nil && time.Since(oidcCache.fetched) < oidcDiscoveryTTL {
+		return oidcCache.provider, nil
+	}
+	var p oidcProvider
+	if err := oidcGetJSON(strings.TrimSuffix(oidcConfig.Issuer, "/")+"/.well-known/openid-configuration", &p); err != nil {
+		return nil, err
+	}
+	if p.Issuer != oidcConfig.Issuer {
+		return nil, fmt.Errorf("provider reports issuer %q, expected %q", p.Issuer, oidcConfig.Issuer)
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return nil, fmt.Errorf("provider metadata is missing endpoints")
+	}
+	if oidcCache.provider == nil || oidcCache.provider.JWKSURI != p.JWKSURI {
+		oidcCache.keys = nil
+	}
+	oidcCache.provider, oidcCache.fetched = &p, time.Now()
+	return &p, nil
+}
+
+func oidcGetJSON(u string, out any) error {
+	resp, err := oidcClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// oidcKey returns the provider's signing key kid, refetching the key set
+// when it doesn't have it, as after the provider rotates its keys. An empty
+// kid matches the only key of a set that has one.
+func oidcKey(p *oidcProvider, kid string) (crypto.PublicKey, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+	find := func() crypto.PublicKey {
+		if kid == "" && len(oidcCache.keys) == 1 {
+			for _, k := range oidcCache.keys {
+				return k
+			}
+		}
+		return oidcCache.keys[kid]
+	}
+	if k := find(); k != nil {
+		return k, nil
+	}
+	if oidcCache.keys != nil && time.Since(oidcCache.keysFetched) < oidcKeysMinAge {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := oidcGetJSON(p.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if k, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = k
+		}
+	}
+	oidcCache.keys, oidcCache.keysFetched = keys, time.Now()
+	if k := find(); k != nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	b64 := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("bad key parameter")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := b64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31 {
+			return nil, fmt.Errorf("bad RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// verifyJWT checks the signature of a compact JWT and returns its payload.
+func verifyJWT(p *oidcProvider, token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(raw, &header) != nil {
+		return nil, fmt.Errorf("malformed token header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature")
+	}
+	var hash crypto.Hash
+	switch header.Alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+	key, err := oidcKey(p, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+
+	valid := false
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		valid = header.Alg[0] == 'R' && rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if header.Alg[0] == 'E' && len(sig) == 2*size {
+			r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+			valid = ecdsa.Verify(k, digest, r, s)
+		}
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token payload")
+	}
+	return payload, nil
+}
+
+// oidcLogin is the sign-in in progress, kept in a short-lived cookie so the
+// callback can check it came from the browser that started it.
+type oidcLogin struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to,omitempty"`
+}
+
+func newOIDCSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func setOIDCCookie(w http.ResponseWriter, r *http.Request, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    value,
+		Path:     "/api/auth/oidc/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   cookieConfig.secure(r),
+		// The provider sends the browser back with a top-level redirect,
+		// which lax cookies survive and strict ones don't.
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// validReturnTo reports whether path is a path on this site, so the
+// callback can't be used to redirect elsewhere.
+func validReturnTo(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.ContainsAny(path, "\\\r\n")
+}
+
+// GET /api/auth/oidc/login — start single sign-on by redirecting to the
+// identity provider (public). return_to is a path on this site to come back
+// to once signed in.
+func handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	if oidcConfig == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "single sign-on is not configured"})
+		return
+	}
+	login := oidcLogin{State: newOIDCSecret(), Nonce: newOIDCSecret(), Verifier: newOIDCSecret()}
+	if login.ReturnTo = r.URL.Query().Get("return_to"); login.ReturnTo != "" && !validReturnTo(login.ReturnTo) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "return_to must be a path on this site"})
+		return
+	}
+	p, err := oidcDiscover()
+	if err != nil {
+		log.Printf("oidc: discovery: %v", err)
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "identity provider is unavailable"})
+		return
+	}
+	u, err := url.Parse(p.AuthorizationEndpoint)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "identity provider is unavailable"})
+		return
+	}
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", oidcConfig.ClientID)
+	q.Set("redirect_uri", oidcConfig.redirectURL(r))
+	q.Set("scope", strings.Join(oidcConfig.Scopes, " "))
+	q.Set("state", login.State)
+	q.Set("nonce", login.Nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	data, _ := json.Marshal(login)
+	setOIDCCookie(w, r, base64.RawURLEncoding.EncodeToString(data), int(oidcLoginTTL/time.Second))
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// GET /api/auth/oidc/callback — where the identity provider sends the
+// browser back (public). Signs in with session cookies, creating the
+// account on first sign-in, then redirects to return_to; without one it
+// responds with the user like /api/login.
+func handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	if oidcConfig == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "single sign-on is not configured"})
+		return
+	}
+	var login oidcLogin
+	if c, err := r.Cookie(oidcCookie); err == nil {
+		if data, err := base64.RawURLEncoding.DecodeString(c.Value); err == nil {
+			json.Unmarshal(data, &login)
+		}
+	}
+	setOIDCCookie(w, r, "", -1)
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		msg := "identity provider refused sign-in: " + e
+		if d := q.Get("error_description"); d != "" {
+			msg += ": " + d
+		}
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: msg})
+		return
+	}
+	if login.State == "" || q.Get("state") != login.State {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "sign-in expired or was started in another browser; try again"})
+		return
+	}
+	if q.Get("code") == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "code is required"})
+		return
+	}
+	p, err := oidcDiscover()
+	if err != nil {
+		log.Printf("oidc: discovery: %v", err)
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "identity provider is unavailable"})
+		return
+	}
+	claims, err := oidcExchange(r, p, q.Get("code"), &login)
+	if err != nil {
+		log.Printf("oidc: sign-in: %v", err)
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "single sign-on failed"})
+		return
+	}
+	user, created, err := oidcUser(claims)
+	if err == errOIDCNotLinked {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("oidc: account for %s: %v", claims.Subject, err)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to set up account"})
+		return
+	}
+	if ban, err := store.GetUserBan(user.ID); err == nil {
+		writeSuspended(w, ban)
+		return
+	}
+	if user.DeactivatedBy != "" {
+		writeDeactivated(w, user)
+		return
+	}
+	if err := issueSession(user, r); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to issue token"})
+		return
+	}
+	if created {
+		writeAudit(r, user, &AuditEntry{Action: auditRegister, TargetKind: "user", TargetID: user.ID, Diff: "oidc " + claims.Subject})
+	} else {
+		recordAudit(r, user, auditLogin, "user", user.ID, "oidc")
+	}
+	setSessionCookies(w, r, user)
+	if login.ReturnTo != "" {
+		http.Redirect(w, r, login.ReturnTo, http.StatusFound)
+		return
+	}
+	user.PasswordHash = ""
+	writeJSON(w, http.StatusOK, user)
+}
+
+type oidcClaims struct {
+	Subject  string
+	Username string
+}
+
+// oidcExchange redeems code at the token endpoint and verifies the ID token
+// that comes back.
+func oidcExchange(r *http.Request, p *oidcProvider, code string, login *oidcLogin) (*oidcClaims, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {oidcConfig.redirectURL(r)},
+		"code_verifier": {login.Verifier},
+	}
+	if oidcConfig.ClientSecret == "" {
+		form.Set("client_id", oidcConfig.ClientID)
+	}
+	req, err := http.NewRequest(http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if oidcConfig.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(oidcConfig.ClientID), url.QueryEscape(oidcConfig.ClientSecret))
+	}
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("token endpoint: status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || tok.IDToken == "" {
+		return nil, fmt.Errorf("token endpoint: status %d %s", resp.StatusCode, tok.Error)
+	}
+
+	payload, err := verifyJWT(p, tok.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed token payload")
+	}
+	str := func(name string) string {
+		s, _ := claims[name].(string)
+		return s
+	}
+	if str("iss") != p.Issuer {
+		return nil, fmt.Errorf("token issued by %q", str("iss"))
+	}
+	var audience []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audience = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+	}
+	if !slices.Contains(audience, oidcConfig.ClientID) {
+		return nil, fmt.Errorf("token is not for this client")
+	}
+	if exp, ok := claims["exp"].(float64); !ok || time.Now().After(time.Unix(int64(exp), 0).Add(time.Minute)) {
+		return nil, fmt.Errorf("token has expired")
+	}
+	if str("nonce") != login.Nonce {
+		return nil, fmt.Errorf("token nonce doesn't match")
+	}
+	if str("sub") == "" {
+		return nil, fmt.Errorf("token has no subject")
+	}
+	return &oidcClaims{Subject: str("sub"), Username: str(oidcConfig.UsernameClaim)}, nil
+}
+
+// oidcUser returns the account linked to the signed-in subject, creating
+// one when there is none and that's allowed.
+func oidcUser(c *oidcClaims) (*User, bool, error) {
+	id, err := store.GetOIDCUser(oidcConfig.Issuer, c.Subject)
+	if err == nil {
+		user, err := store.GetUserByID(id)
+		return user, false, err
+	}
+	if !oidcConfig.createAccounts() {
+		return nil, false, errOIDCNotLinked
+	}
+	// The account has no password, so it can only sign in this way.
+	for _, name := range oidcUsernames(c.Username) {
+		if validateUsername(name) != nil || checkUsernamePolicy(name) != nil {
+			continue
+		}
+		user, err := store.CreateUser(name, "")
+		if err != nil {
+			continue
+		}
+		if err := store.LinkOIDCIdentity(oidcConfig.Issuer, c.Subject, user.ID); err != nil {
+			return nil, false, err
+		}
+		return user, true, nil
+	}
+	return nil, false, fmt.Errorf("no free username")
+}
+
+var usernameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// oidcUsernames lists usernames to try for a new account, starting with
+// the claim made safe: the part of an email address before the @, with
+// anything a username can't hold replaced.
+func oidcUsernames(claim string) []string {
+	base, _, _ := strings.Cut(claim, "@")
+	base = strings.Trim(usernameUnsafe.ReplaceAllString(base, "-"), "-_")
+	if len(base) > 28 {
+		base = base[:28]
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+	names := []string{base}
+	for i := 2; i <= 20; i++ {
+		names = append(names, fmt.Sprintf("%s-%d", base, i))
+	}
+	for range 5 {
+		b := make([]byte, 3)
+		rand.Read(b)
+		names = append(names, fmt.Sprintf("%s-%x", base, b))
+	}
+	return names
+}
+
+// PUT /api/admin/users/{id}/oidc — link a user to the subject {subject}
+// the identity provider knows them by, replacing any earlier link; DELETE
+// unlinks them (admin only).
+func handleAdminUserOIDC(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	if oidcConfig == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "single sign-on is not configured"})
+		return
+	}
+	admin := currentUser(r)
+	target, err := store.GetUserByID(id)
+	if err != nil || id == anonymousUserID {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user not found"})
+		return
+	}
+	if r.Method == http.MethodDelete {
+		found, err := store.UnlinkOIDCIdentity(oidcConfig.Issuer, target.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to unlink user"})
+			return
+		}
+		if !found {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user is not linked"})
+			return
+		}
+		recordAudit(r, admin, auditOIDCUnlink, "user", target.ID, "")
+		writeJSON(w, http.StatusOK, map[string]string{"status": "unlinked"})
+		return
+	}
+	var req OIDCLinkReq
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if req.Subject = strings.TrimSpace(req.Subject); req.Subject == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "subject is required"})
+		return
+	}
+	if other, err := store.GetOIDCUser(oidcConfig.Issuer, req.Subject); err == nil && other != target.ID {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "subject is linked to another user"})
+		return
+	}
+	if _, err := store.UnlinkOIDCIdentity(oidcConfig.Issuer, target.ID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to link user"})
+		return
+	}
+	if err := store.LinkOIDCIdentity(oidcConfig.Issuer, req.Subject, target.ID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to link user"})
+		return
+	}
+	recordAudit(r, admin, auditOIDCLink, "user", target.ID, req.Subject)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "linked", "subject": req.Subject})
+}
@@ -0,0 +1,195 @@
+package memomarket
+
+import (
+	"archive/zip"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Pack export lays a pack out for people rather than clients: a zip with
+// pack.json (the same document the download returns), one Markdown file per
+// memo under memos/, and a README.md with the description, system prompt and
+// rules, linking the memos in order. Other formats are a single prompt to
+// paste into a chat (see promptexport.go) and the instruction files of IDE
+// assistants (see ruleexport.go). Every export picks the version like the
+// download does and counts as one.
+
+// packExportFormats are the export formats besides the rule files in
+// ruleFileFormats.
+var packExportFormats = []string{"zip", "prompt"}
+
+// GET /api/memo-packs/{id}/export?format=zip — the pack as an archive, with
+// format=prompt as one prompt text, or with format=cursorrules and the like
+// as an IDE assistant's rules file; ?version= and ?channel= as for the
+// download (public; sign-in needed when the pack requires it).
+func handleExportMemoPack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	limitKey := rateLimitKey(r)
+	if ok, wait := downloadLimiter.allow(limitKey); !ok {
+		writeRateLimited(w, wait)
+		return
+	}
+	format := cmp.Or(r.URL.Query().Get("format"), "zip")
+	ruleFile, isRuleFile := ruleFileFormats[format]
+	if !slices.Contains(packExportFormats, format) && !isRuleFile {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "format must be zip, prompt, " + ruleFileFormatNames})
+		return
+	}
+	pack, ok := exportedPack(w, r)
+	if !ok {
+		return
+	}
+	countBundleDownload(r, limitKey, []*MemoPack{pack})
+	setCacheHeaders(w, cachePack, privateRead(pack))
+	if format == "prompt" {
+		text, err := renderPrompt(pack)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to render prompt"})
+			return
+		}
+		serveBytes(w, r, "text/plain; charset=utf-8", []byte(text), parseISO(pack.UpdatedAt))
+		return
+	}
+	if isRuleFile {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, ruleFile.name(pack)))
+		serveBytes(w, r, ruleFile.contentType, []byte(ruleFile.render(pack)), parseISO(pack.UpdatedAt))
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, cmp.Or(pack.Slug, pack.ID)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	writePackZip(limitBandwidth(w), pack)
+}
+
+// exportedPack loads the pack an export names, at the version ?version= or
+// ?channel= picks. It writes the error and returns false when the pack can't
+// be exported to the caller.
+func exportedPack(w http.ResponseWriter, r *http.Request) (*MemoPack, bool) {
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/memo-packs/"), "/export")
+	pack, err := store.GetMemoPack(id)
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return nil, false
+	}
+	if pack.RequireAuth && currentUser(r) == nil && !shareGrants(r, pack) {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "sign in to download this pack"})
+		return nil, false
+	}
+	channel := r.URL.Query().Get("channel")
+	if err := validateChannel(channel); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+	var v *MemoPackVersion
+	if spec := r.URL.Query().Get("version"); spec != "" {
+		v, err = resolvePackVersion(pack, spec, channel)
+	} else {
+		v, err = channelVersion(pack, channel)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return nil, false
+	}
+	if v != nil {
+		applyPackVersion(pack, v)
+	}
+	return pack, true
+}
+
+// memoFileNames names each memo's file in the archive, numbered in order
+// and padded so they sort that way.
+func memoFileNames(memos []Memo) []string {
+	width := max(len(fmt.Sprint(len(memos))), 2)
+	names := make([]string, len(memos))
+	for i, m := range memos {
+		names[i] = fmt.Sprintf("memos/%0*d-%s.md", width, i+1, slugify(m.Title))
+	}
+	return names
+}
+
+// writePackZip writes pack as the export archive.
+func writePackZip(w io.Writer, pack *MemoPack) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	create := func(name string) (io.Writer, error) {
+		return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+	}
+	fw, err := create("pack.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(pack); err != nil {
+		return err
+	}
+	names := memoFileNames(pack.Memos)
+	fw, err = create("README.md")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(fw, packReadme(pack, names)); err != nil {
+		return err
+	}
+	for i, m := range pack.Memos {
+		fw, err := create(names[i])
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, memoMarkdown(m)); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// memoMarkdown renders a memo as its title heading and content, with its
+// tags when it has any.
+func memoMarkdown(m Memo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", cmp.Or(m.Title, "Untitled"))
+	if len(m.Tags) > 0 {
+		fmt.Fprintf(&b, "Tags: %s\n\n", strings.Join(m.Tags, ", "))
+	}
+	b.WriteString(strings.TrimRight(m.Content, "\n"))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// packReadme renders the README.md of an export; memoFiles are the memos'
+// paths in the archive.
+func packReadme(pack *MemoPack, memoFiles []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", pack.Name)
+	if pack.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(pack.Description))
+	}
+	fmt.Fprintf(&b, "Version %s by %s, exported from %s on %s.\n", pack.Version, pack.AuthorName, serverName, nowISO())
+	if pack.SystemPrompt != "" {
+		fmt.Fprintf(&b, "\n## System prompt\n\n%s\n", strings.TrimSpace(pack.SystemPrompt))
+	}
+	if len(pack.Rules) > 0 {
+		b.WriteString("\n## Rules\n\n")
+		for _, r := range pack.Rules {
+			fmt.Fprintf(&b, "- **%s**: %s\n", r.Title, strings.TrimSpace(r.UpdateRule))
+		}
+	}
+	if len(pack.Memos) > 0 {
+		b.WriteString("\n## Memos\n\n")
+		for i, m := range pack.Memos {
+			fmt.Fprintf(&b, "%d. [%s](%s)\n", i+1, cmp.Or(m.Title, "Untitled"), memoFiles[i])
+		}
+	}
+	return b.String()
+}
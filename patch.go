@@ -0,0 +1,85 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+)
+
+// PATCH /api/memo-packs/{id} takes a JSON merge patch (RFC 7396) of the
+// body PUT takes: members it names are replaced, null removes a member, and
+// everything it leaves out keeps its current value. Objects merge member by
+// member, but arrays such as rules, memos and their tags are replaced
+// whole. The patched body then goes through the same checks as a PUT.
+
+const mergePatchType = "application/merge-patch+json"
+
+// packUpdateBase is the PUT body that would leave pack as it is.
+func packUpdateBase(pack *MemoPack) PublishMemoPackReq {
+	return PublishMemoPackReq{
+		Name:         pack.Name,
+		Description:  pack.Description,
+		SystemPrompt: pack.SystemPrompt,
+		Rules:        pack.Rules,
+		Memos:        pack.Memos,
+		Evals:        pack.Evals,
+		Version:      pack.Version,
+		Homepage:     pack.Homepage,
+		Repository:   pack.Repository,
+		Contact:      pack.Contact,
+		RequireAuth:  pack.RequireAuth,
+		Channel:      pack.Channel,
+		Language:     pack.Language,
+		VariantOf:    pack.VariantOf,
+		Category:     pack.Category,
+		Visibility:   pack.Visibility,
+	}
+}
+
+// mergePatch applies patch to target as RFC 7396 describes.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// decodePackPatch reads a merge patch for pack from r into req. It writes
+// the error response and returns false when the patch can't be applied.
+func decodePackPatch(w http.ResponseWriter, r *http.Request, pack *MemoPack, req *PublishMemoPackReq) bool {
+	// A JSON Patch (RFC 6902) would parse but mean something else.
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/json-patch+json" {
+		writeJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{Error: "PATCH takes " + mergePatchType})
+		return false
+	}
+	var patch any
+	if err := decodeJSON(r, &patch); err != nil {
+		writeDecodeError(w, err)
+		return false
+	}
+	if _, ok := patch.(map[string]any); !ok {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "a pack patch must be a JSON object"})
+		return false
+	}
+	var base any
+	data, _ := json.Marshal(packUpdateBase(pack))
+	json.Unmarshal(data, &base)
+	data, _ = json.Marshal(mergePatch(base, patch))
+	if err := json.Unmarshal(data, req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "patch gives an invalid pack: " + err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package memomarket
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Authors pin up to maxPinnedPacks of their packs. Pinned packs are listed
+// on the author's profile and come first in ?author= listings, the most
+// recently pinned first; ?pinned=true lists only them.
+
+const maxPinnedPacks = 6
+
+// POST /api/memo-packs/{id}/pin — pin a pack to its author's profile (owner,
+// or admin with ?reason=). DELETE unpins it.
+func handlePackPin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	moderated, reason, ok := checkPackOwner(w, r, user, pack)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodDelete {
+		if !pack.Pinned {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is not pinned"})
+			return
+		}
+		if err := store.UnpinMemoPack(pack.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update pack"})
+			return
+		}
+		recordAudit(r, user, auditPackUnpin, "pack", pack.ID, reason)
+		if moderated {
+			notifyModeration(pack.AuthorID, pack.ID, pack.Contact, fmt.Sprintf("An admin unpinned your pack %q.", pack.Name), reason)
+		}
+		pack.PinnedAt, pack.Pinned = "", false
+		writeJSON(w, http.StatusOK, pack)
+		return
+	}
+	if !pack.Published {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "drafts can't be pinned"})
+		return
+	}
+	if pack.Pinned {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "pack is already pinned"})
+		return
+	}
+	pinned, err := store.PinMemoPack(pack.ID, pack.AuthorID, maxPinnedPacks)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update pack"})
+		return
+	}
+	if !pinned {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("at most %d packs can be pinned; unpin one first", maxPinnedPacks)})
+		return
+	}
+	recordAudit(r, user, auditPackPin, "pack", pack.ID, reason)
+	if moderated {
+		notifyModeration(pack.AuthorID, pack.ID, pack.Contact, fmt.Sprintf("An admin pinned your pack %q.", pack.Name), reason)
+	}
+	pack, err = store.GetMemoPack(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load pack"})
+		return
+	}
+	writeJSON(w, http.StatusOK, pack)
+}
+
+// pinnedPacks lists the packs a user pinned, as their public listing shows
+// them.
+func pinnedPacks(r *http.Request, userID string) []MemoPack {
+	packs, _, err := store.ListMemoPacks(ListQuery{Author: userID, Pinned: true, Page: 1, Limit: maxPinnedPacks})
+	if err != nil {
+		return []MemoPack{}
+	}
+	for i := range packs {
+		restrictContent(r, &packs[i])
+		attachAuthorInfo(&packs[i])
+	}
+	return packs
+}
@@ -0,0 +1,83 @@
+package memomarket
+
+import (
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+// A preview is a pack cut down for a detail page: its metadata and content
+// sizes, the system prompt and each rule and memo body truncated to ?chars=,
+// and only the first ?items= rules and memos. content_omitted is set when
+// anything was cut; the content sizes say how much the full pack holds.
+
+const (
+	defaultPreviewItems = 3
+	maxPreviewItems     = 20
+	defaultPreviewChars = 500
+	maxPreviewChars     = 5000
+)
+
+// previewParam reads a positive integer query parameter, capped at max.
+func previewParam(r *http.Request, name string, def, max int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || n < 0 {
+		return def
+	}
+	return min(n, max)
+}
+
+// truncateChars cuts s to n characters, reporting whether it did.
+func truncateChars(s string, n int) (string, bool) {
+	if utf8.RuneCountInString(s) <= n {
+		return s, false
+	}
+	return string([]rune(s)[:n]) + "…", true
+}
+
+// previewPack trims pack to a preview in place.
+func previewPack(pack *MemoPack, items, chars int) {
+	cut := false
+	trunc := func(s *string) {
+		var c bool
+		*s, c = truncateChars(*s, chars)
+		cut = cut || c
+	}
+	trunc(&pack.SystemPrompt)
+	if len(pack.Rules) > items {
+		pack.Rules, cut = pack.Rules[:items], true
+	}
+	for i := range pack.Rules {
+		trunc(&pack.Rules[i].UpdateRule)
+	}
+	if len(pack.Memos) > items {
+		pack.Memos, cut = pack.Memos[:items], true
+	}
+	for i := range pack.Memos {
+		trunc(&pack.Memos[i].Content)
+	}
+	if len(pack.Evals) > 0 {
+		pack.Evals, cut = []PackEval{}, true
+	}
+	pack.ContentOmitted = pack.ContentOmitted || cut
+}
+
+// GET /api/memo-packs/{id}/preview — the pack's metadata with the start of
+// its content; ?items= rules and memos (default 3, at most 20) cut to
+// ?chars= characters (default 500, at most 5000) (public).
+func handlePackPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	restrictContent(r, pack)
+	attachAuthorInfo(pack)
+	previewPack(pack, previewParam(r, "items", defaultPreviewItems, maxPreviewItems), previewParam(r, "chars", defaultPreviewChars, maxPreviewChars))
+	setCacheHeaders(w, cachePack, privateRead(pack))
+	serveJSONContent(w, r, pack, parseISO(pack.UpdatedAt))
+}
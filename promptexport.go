@@ -0,0 +1,80 @@
+package memomarket
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// A prompt export (format=prompt) joins a pack's system prompt, rules and
+// memos into one text to paste into a chat with any LLM. The layout is a
+// text/template run on the pack, defaultPromptTemplate unless the channel
+// sets prompt_export.template. Templates can use trim, join and scope, the
+// last describing a rule's conditions. Deprecated rules and memos are left
+// out.
+
+const defaultPromptTemplate = `{{with trim .SystemPrompt}}{{.}}
+
+{{end}}{{if .Rules}}## Rules
+
+{{range .Rules}}### {{.Title}}
+
+{{with scope .Conditions}}{{.}}
+
+{{end}}{{trim .UpdateRule}}
+
+{{end}}{{end}}{{if .Memos}}## Reference
+
+{{range .Memos}}### {{.Title}}
+
+{{trim .Content}}
+
+{{end}}{{end}}`
+
+var promptTemplateFuncs = template.FuncMap{
+	"trim":  strings.TrimSpace,
+	"join":  strings.Join,
+	"scope": ruleScope,
+}
+
+var promptTemplate = template.Must(parsePromptTemplate(defaultPromptTemplate))
+
+func parsePromptTemplate(text string) (*template.Template, error) {
+	return template.New("prompt").Funcs(promptTemplateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// loadPromptExportConfig sets the prompt template, keeping the default when
+// cfg's doesn't parse.
+func loadPromptExportConfig(cfg *PromptExportConfig) {
+	promptTemplate = template.Must(parsePromptTemplate(defaultPromptTemplate))
+	if cfg == nil || cfg.Template == "" {
+		return
+	}
+	if t, err := parsePromptTemplate(cfg.Template); err == nil {
+		promptTemplate = t
+	}
+}
+
+// validatePromptExportConfig reports a template loadPromptExportConfig
+// would ignore.
+func validatePromptExportConfig(cfg *PromptExportConfig) error {
+	if cfg.Template == "" {
+		return nil
+	}
+	if _, err := parsePromptTemplate(cfg.Template); err != nil {
+		return fmt.Errorf("invalid template: %v", err)
+	}
+	return nil
+}
+
+// renderPrompt runs the prompt template on pack, without its deprecated
+// rules and memos.
+func renderPrompt(pack *MemoPack) (string, error) {
+	stripDeprecated(pack)
+	var b bytes.Buffer
+	if err := promptTemplate.Execute(&b, pack); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()) + "\n", nil
+}
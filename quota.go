@@ -0,0 +1,113 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Quotas cap what each account may publish: how many packs it keeps, how
+// large one pack may be, and how many it creates a day. They are set under
+// "quotas" in config.json and are off by default. Unlike trust levels they
+// don't loosen as an account ages; admins and the anonymous user, which has
+// its own per-IP limit, are exempt.
+
+// quotas holds the effective settings.
+var quotas QuotaConfig
+
+func loadQuotas(cfg *QuotaConfig) {
+	quotas = QuotaConfig{}
+	if cfg != nil {
+		quotas = QuotaConfig{
+			MaxPacks:       max(cfg.MaxPacks, 0),
+			MaxPackBytes:   max(cfg.MaxPackBytes, 0),
+			DailyPublishes: max(cfg.DailyPublishes, 0),
+		}
+	}
+}
+
+func quotaExempt(user *User) bool {
+	return isAdmin(user) || user.ID == anonymousUserID
+}
+
+// userQuota reports user's usage against the quotas.
+func userQuota(user *User) (*QuotaStatus, error) {
+	since := time.Now().UTC().Add(-24 * time.Hour).Format("2006-01-02T15:04:05")
+	a, err := store.GetUserActivity(user.ID, since)
+	if err != nil {
+		return nil, err
+	}
+	st := &QuotaStatus{
+		Packs:          QuotaUsage{Used: a.LivePacks},
+		PublishedToday: QuotaUsage{Used: a.PublishedSince},
+		Exempt:         quotaExempt(user),
+	}
+	if !st.Exempt {
+		st.Packs.Limit = quotas.MaxPacks
+		st.PublishedToday.Limit = quotas.DailyPublishes
+		st.MaxPackBytes = quotas.MaxPackBytes
+	}
+	return st, nil
+}
+
+// packSize is the size in bytes of pack as served.
+func packSize(pack *MemoPack) int {
+	data, _ := json.Marshal(pack)
+	return len(data)
+}
+
+// checkQuota applies user's quotas to pack, which is about to be created,
+// or saved when creating is false. It writes the error response and returns
+// false when the quota is exceeded.
+func checkQuota(w http.ResponseWriter, user *User, pack *MemoPack, creating bool) bool {
+	if quotaExempt(user) || quotas == (QuotaConfig{}) {
+		return true
+	}
+	if quotas.MaxPackBytes > 0 {
+		if n := packSize(pack); n > quotas.MaxPackBytes {
+			writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: fmt.Sprintf(
+				"pack is %d bytes; the limit is %d", n, quotas.MaxPackBytes)})
+			return false
+		}
+	}
+	if !creating {
+		return true
+	}
+	st, err := userQuota(user)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to check quota"})
+		return false
+	}
+	if st.Packs.Limit > 0 && st.Packs.Used >= st.Packs.Limit {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: fmt.Sprintf(
+			"you have %d packs, the most allowed; delete one first", st.Packs.Used)})
+		return false
+	}
+	if st.PublishedToday.Limit > 0 && st.PublishedToday.Used >= st.PublishedToday.Limit {
+		writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: fmt.Sprintf(
+			"you may publish %d packs a day", st.PublishedToday.Limit)})
+		return false
+	}
+	return true
+}
+
+// GET /api/me/quota — my publishing quotas and how much of them I've used
+// (auth required).
+func handleMyQuota(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	st, err := userQuota(user)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to check quota"})
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
@@ -0,0 +1,183 @@
+package memomarket
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter is an in-memory token bucket per key. Each bucket holds up to
+// burst tokens and refills at rate tokens per second.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Buckets are pruned once the map grows past this many keys.
+const maxRateBuckets = 10000
+
+func newRateLimiter(perInterval int, interval time.Duration, burst int) *rateLimiter {
+	burst = max(burst, 1)
+	return &rateLimiter{
+		rate:    float64(perInterval) / interval.Seconds(),
+		burst:   float64(burst),
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// allow takes a token for key. When none is left it reports how long until one is.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	if l == nil || l.rate <= 0 {
+		return true, 0
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateBuckets {
+			l.prune(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// prune drops buckets that have refilled completely; they behave like new ones.
+func (l *rateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Download limits. The per-client limiter throttles with 429s; the per-pack
+// limiter is soft: downloads past it are served but not counted, so scripted
+// loops can't farm a pack's counter while a viral pack stays downloadable.
+var (
+	downloadLimiter     *rateLimiter
+	packDownloadLimiter *rateLimiter
+)
+
+// Request limits for the whole API, keeping any one client from tying up
+// the single database writer: anonymous requests are limited per IP,
+// requests with a bearer token per token.
+var (
+	anonRequestLimiter *rateLimiter
+	authRequestLimiter *rateLimiter
+)
+
+// rateLimits holds the effective limits, reported by /api/capabilities.
+var rateLimits RateLimitConfig
+
+func loadRateLimits(cfg *RateLimitConfig) {
+	c := RateLimitConfig{
+		DownloadsPerMinute: 120, DownloadBurst: 60, PackDownloadsPerHour: 10,
+		RequestsPerMinute: 300, RequestBurst: 100, AuthRequestsPerMinute: 600, AuthRequestBurst: 200,
+	}
+	if cfg != nil {
+		if cfg.DownloadsPerMinute != 0 {
+			c.DownloadsPerMinute = cfg.DownloadsPerMinute
+		}
+		if cfg.DownloadBurst != 0 {
+			c.DownloadBurst = cfg.DownloadBurst
+		}
+		if cfg.PackDownloadsPerHour != 0 {
+			c.PackDownloadsPerHour = cfg.PackDownloadsPerHour
+		}
+		c.DownloadBytesPerSecond = max(cfg.DownloadBytesPerSecond, 0)
+		if cfg.RequestsPerMinute != 0 {
+			c.RequestsPerMinute = cfg.RequestsPerMinute
+		}
+		if cfg.RequestBurst != 0 {
+			c.RequestBurst = cfg.RequestBurst
+		}
+		if cfg.AuthRequestsPerMinute != 0 {
+			c.AuthRequestsPerMinute = cfg.AuthRequestsPerMinute
+		}
+		if cfg.AuthRequestBurst != 0 {
+			c.AuthRequestBurst = cfg.AuthRequestBurst
+		}
+	}
+	rateLimits = c
+	anonRequestLimiter = newRateLimiter(c.RequestsPerMinute, time.Minute, c.RequestBurst)
+	authRequestLimiter = newRateLimiter(c.AuthRequestsPerMinute, time.Minute, c.AuthRequestBurst)
+	downloadLimiter = newRateLimiter(c.DownloadsPerMinute, time.Minute, c.DownloadBurst)
+	packDownloadLimiter = newRateLimiter(c.PackDownloadsPerHour, time.Hour, c.PackDownloadsPerHour)
+}
+
+func init() {
+	loadRateLimits(nil)
+}
+
+// rateLimitKey identifies the caller: the user when authenticated, otherwise the remote IP.
+func rateLimitKey(r *http.Request) string {
+	if user := currentUser(r); user != nil {
+		return "user:" + user.ID
+	}
+	return "ip:" + remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requestLimitMiddleware applies the request limits before any handler
+// runs. Tokens are not looked up here, so the bucket is keyed by a hash of
+// the token itself; an invalid one costs no more than one lookup and a 401.
+func requestLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/health" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limiter, key := anonRequestLimiter, "ip:"+remoteIP(r)
+		if token, _ := requestToken(r); token != "" {
+			sum := sha256.Sum256([]byte(token))
+			limiter, key = authRequestLimiter, "token:"+hex.EncodeToString(sum[:12])
+		}
+		if ok, wait := limiter.allow(key); !ok {
+			writeRateLimited(w, wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeRateLimited sends a 429 with a Retry-After rounded up to whole seconds.
+func writeRateLimited(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: "rate limit exceeded"})
+}
+
+// packDownloadAllowed applies the soft per-pack cap for one caller.
+func packDownloadAllowed(key, packID string) bool {
+	ok, _ := packDownloadLimiter.allow(key + "|" + packID)
+	return ok
+}
@@ -0,0 +1,135 @@
+package memomarket
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Install receipts: a download can come with a manifest signed by this
+// server's key, which clients keep alongside the installed pack and can later
+// check against POST /api/verify-receipt or the public key from /api/info.
+
+// serverKey signs install receipts. It is generated on first start and kept
+// in the data directory.
+var serverKey ed25519.PrivateKey
+
+// loadServerKey reads the signing key from dataDir, creating it if missing.
+func loadServerKey(dataDir string) {
+	path := filepath.Join(dataDir, "server.key")
+	if data, err := os.ReadFile(path); err == nil {
+		seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			log.Fatalf("Invalid server key in %s", path)
+		}
+		serverKey = ed25519.NewKeyFromSeed(seed)
+		return
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("Failed to generate server key: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key.Seed())+"\n"), 0600); err != nil {
+		log.Fatalf("Failed to save server key: %v", err)
+	}
+	serverKey = key
+}
+
+func serverPublicKey() string {
+	return base64.StdEncoding.EncodeToString(serverKey.Public().(ed25519.PublicKey))
+}
+
+// serverKeyID is a short fingerprint of the public key, so clients holding
+// receipts from several channels can pick the right key.
+func serverKeyID() string {
+	sum := sha256.Sum256(serverKey.Public().(ed25519.PublicKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// packContentChecksum hashes the parts of a pack a client installs. It is
+// taken before any include_memo_tags filtering, and is stored as the pack's
+// checksum.
+func packContentChecksum(pack *MemoPack) string {
+	data, _ := json.Marshal(struct {
+		Name         string     `json:"name"`
+		Description  string     `json:"description"`
+		SystemPrompt string     `json:"system_prompt"`
+		Rules        []MemoRule `json:"rules"`
+		Memos        []Memo     `json:"memos"`
+	}{pack.Name, pack.Description, pack.SystemPrompt, pack.Rules, pack.Memos})
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// receiptPayload is the byte string covered by a receipt's signature.
+func receiptPayload(rc *InstallReceipt) []byte {
+	return []byte(strings.Join([]string{"memomarket-receipt-v1", rc.Server, rc.PackID, rc.Version, rc.Checksum, rc.RetrievedAt}, "\n"))
+}
+
+// issueReceipt signs a receipt for pack as it is about to be served.
+func issueReceipt(pack *MemoPack) InstallReceipt {
+	rc := InstallReceipt{
+		PackID:      pack.ID,
+		Version:     pack.Version,
+		Checksum:    packContentChecksum(pack),
+		RetrievedAt: nowISO(),
+		Server:      serverName,
+		KeyID:       serverKeyID(),
+	}
+	rc.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(serverKey, receiptPayload(&rc)))
+	return rc
+}
+
+// verifyReceipt checks the signature and that the receipt's checksum still
+// matches the content this server holds for that version. It returns a
+// reason when the receipt does not hold up.
+func verifyReceipt(rc *InstallReceipt) string {
+	if rc.KeyID != serverKeyID() {
+		return "receipt was not issued with this server's key"
+	}
+	sig, err := base64.StdEncoding.DecodeString(rc.Signature)
+	if err != nil || !ed25519.Verify(serverKey.Public().(ed25519.PublicKey), receiptPayload(rc), sig) {
+		return "signature does not match"
+	}
+	pack, err := store.GetMemoPack(rc.PackID)
+	if err != nil {
+		return "pack no longer exists"
+	}
+	v, err := store.GetMemoPackVersion(pack.ID, rc.Version)
+	if err != nil {
+		return "version " + rc.Version + " no longer exists"
+	}
+	applyPackVersion(pack, v)
+	if packContentChecksum(pack) != rc.Checksum {
+		return "content of version " + rc.Version + " has changed since the receipt was issued"
+	}
+	return ""
+}
+
+// POST /api/verify-receipt — confirm an install receipt was issued here and
+// matches the content served (public).
+func handleVerifyReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	var rc InstallReceipt
+	if err := decodeJSON(r, &rc); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if rc.PackID == "" || rc.Version == "" || rc.Checksum == "" || rc.Signature == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "pack_id, version, checksum and signature are required"})
+		return
+	}
+	reason := verifyReceipt(&rc)
+	writeJSON(w, http.StatusOK, ReceiptVerification{Valid: reason == "", Reason: reason})
+}
@@ -0,0 +1,162 @@
+package memomarket
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Related packs are suggested on a pack's detail page as alternatives. Each
+// listed pack is scored on three signals, each between 0 and 1: the overlap
+// of its rule and memo tags with the pack's, the overlap of the words in
+// their names and descriptions, and how many of the clients that downloaded
+// the pack also downloaded it, relative to the most co-downloaded one. The
+// weighted sum ranks them; packs scoring 0 aren't suggested. Variants are
+// left out, as listings collapse them into their original.
+
+const (
+	relatedTagWeight      = 0.4
+	relatedTextWeight     = 0.3
+	relatedDownloadWeight = 0.3
+)
+
+const (
+	defaultRelatedLimit = 5
+	maxRelatedLimit     = 20
+)
+
+// relatedStopwords are words too common in pack descriptions to say two are
+// alike.
+var relatedStopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true,
+	"your": true, "you": true, "from": true, "into": true, "are": true, "its": true,
+	"pack": true, "memo": true, "memos": true, "rules": true,
+}
+
+// packTags is the set of tags on pack's live rules and memos.
+func packTags(pack *MemoPack) map[string]bool {
+	tags := map[string]bool{}
+	for _, r := range pack.Rules {
+		if r.Deprecated == nil {
+			for _, t := range r.Tags {
+				tags[t] = true
+			}
+		}
+	}
+	for _, m := range pack.Memos {
+		if m.Deprecated == nil {
+			for _, t := range m.Tags {
+				tags[t] = true
+			}
+		}
+	}
+	return tags
+}
+
+// packWords is the set of words in pack's name and description, lowercased,
+// without stopwords and words shorter than three letters.
+func packWords(pack *MemoPack) map[string]bool {
+	words := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(pack.Name+" "+pack.Description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) >= 3 && !relatedStopwords[w] {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+// jaccard is the share of the union of a and b they have in common, and the
+// common part.
+func jaccard(a, b map[string]bool) (float64, []string) {
+	var shared []string
+	for k := range a {
+		if b[k] {
+			shared = append(shared, k)
+		}
+	}
+	union := len(a) + len(b) - len(shared)
+	if union == 0 {
+		return 0, nil
+	}
+	slices.Sort(shared)
+	return float64(len(shared)) / float64(union), shared
+}
+
+// relatedPacks scores candidates against pack, given how many clients
+// downloaded each along with it, and returns the best limit.
+func relatedPacks(pack *MemoPack, candidates []MemoPack, coDownloads map[string]int, limit int) []RelatedPack {
+	tags, words := packTags(pack), packWords(pack)
+	maxCo := 0
+	for _, n := range coDownloads {
+		maxCo = max(maxCo, n)
+	}
+	related := []RelatedPack{}
+	for _, c := range candidates {
+		if c.ID == pack.ID || c.VariantOf != "" {
+			continue
+		}
+		tagScore, shared := jaccard(tags, packTags(&c))
+		textScore, _ := jaccard(words, packWords(&c))
+		var coScore float64
+		if maxCo > 0 {
+			coScore = float64(coDownloads[c.ID]) / float64(maxCo)
+		}
+		score := relatedTagWeight*tagScore + relatedTextWeight*textScore + relatedDownloadWeight*coScore
+		if score == 0 {
+			continue
+		}
+		related = append(related, RelatedPack{MemoPack: c, Related: RelatedReasons{
+			Score:       score,
+			SharedTags:  shared,
+			CoDownloads: coDownloads[c.ID],
+		}})
+	}
+	slices.SortFunc(related, func(a, b RelatedPack) int {
+		return cmp.Or(
+			cmp.Compare(b.Related.Score, a.Related.Score),
+			cmp.Compare(b.Downloads, a.Downloads),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	return related[:min(limit, len(related))]
+}
+
+// GET /api/memo-packs/{id}/related?limit= — listed packs like this one, best
+// first, with what they have in common (public).
+func handleRelatedPacks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	pack, err := store.GetMemoPack(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/memo-packs/"), "/related"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	limit := defaultRelatedLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= maxRelatedLimit {
+		limit = l
+	}
+	candidates, err := store.ListRelatedCandidates(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to find related packs"})
+		return
+	}
+	coDownloads, err := store.CoDownloadCounts(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to find related packs"})
+		return
+	}
+	related := relatedPacks(pack, candidates, coDownloads, limit)
+	for i := range related {
+		restrictContent(r, &related[i].MemoPack)
+		attachAuthorInfo(&related[i].MemoPack)
+	}
+	setCacheHeaders(w, cacheListing, privateRead(pack))
+	writeJSON(w, http.StatusOK, related)
+}
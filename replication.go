@@ -0,0 +1,558 @@
+package memomarket
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Active/standby replication. A standby node (config "standby") pulls a
+// consistent snapshot of the primary's database every interval, copies it over
+// its own tables and refuses writes. An admin promotes it once the primary is
+// gone; promotion checks that the standby holds the same data as the primary
+// when the primary is still reachable.
+
+const defaultStandbyInterval = 60 * time.Second
+
+const replicationChecksumHeader = "X-Replication-Checksum"
+
+// dataDir is the node's data directory, for snapshots and config rewrites.
+var dataDir string
+
+var replication = struct {
+	sync.Mutex
+	standby    *StandbyConfig
+	lastSyncAt string
+	checksum   string // of the last snapshot applied
+	lastError  string
+}{}
+
+var replicationClient = &http.Client{Timeout: 5 * time.Minute, Transport: newBreakerTransport(breakerPrimary, false)}
+
+func loadStandby(cfg *StandbyConfig) {
+	replication.Lock()
+	defer replication.Unlock()
+	if cfg == nil || cfg.Primary == "" {
+		replication.standby = nil
+		return
+	}
+	c := *cfg
+	c.Primary = strings.TrimSuffix(c.Primary, "/")
+	replication.standby = &c
+}
+
+func isStandby() bool {
+	replication.Lock()
+	defer replication.Unlock()
+	return replication.standby != nil
+}
+
+// Standby middleware — a standby only serves reads until it is promoted.
+func standbyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if r.URL.Path != "/api/admin/replication/promote" && isStandby() {
+				writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "this node is a read-only standby"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// replicatedTables lists the tables copied from the primary. The full-text
+// index is left out; its triggers rebuild it as packs are copied.
+func replicatedTables(conn *sql.DB) ([]string, error) {
+	rows, err := conn.Query(`SELECT name FROM sqlite_master WHERE type='table'
+		AND name NOT LIKE 'sqlite_%' AND name NOT LIKE 'memo_packs_fts%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			tables = append(tables, name)
+		}
+	}
+	return tables, rows.Err()
+}
+
+// dataChecksum hashes every row of the replicated tables, so two nodes
+// holding the same data get the same value regardless of file layout.
+func dataChecksum(conn *sql.DB) (string, error) {
+	tables, err := replicatedTables(conn)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, table := range tables {
+		if err := hashTable(conn, table, h); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashTable(conn *sql.DB, table string, h io.Writer) error {
+	rows, err := conn.Query(`SELECT * FROM "` + table + `" ORDER BY rowid`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(h, "table %s %s\n", table, strings.Join(cols, ","))
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		for _, v := range vals {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			fmt.Fprintf(h, "%q\x1f", fmt.Sprint(v))
+		}
+		h.Write([]byte{'\n'})
+	}
+	return rows.Err()
+}
+
+// checksumFile computes dataChecksum over a database file.
+func checksumFile(path string) (string, error) {
+	conn, err := sql.Open(sqliteDriver, path+"?mode=ro")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return dataChecksum(conn)
+}
+
+// errNoSQLite is returned when replication runs on a Store other than
+// SQLiteStore; snapshots are copies of the SQLite file.
+var errNoSQLite = errors.New("replication needs the SQLite store")
+
+// replicationDB returns the database replication copies.
+func replicationDB() (*sql.DB, error) {
+	if db := sqliteDB(); db != nil {
+		return db, nil
+	}
+	return nil, errNoSQLite
+}
+
+// applySnapshot replaces the contents of every replicated table with the
+// snapshot's, in one transaction.
+func applySnapshot(path string) error {
+	db, err := replicationDB()
+	if err != nil {
+		return err
+	}
+	// Foreign keys would cascade deletes while tables are being refilled.
+	if _, err := db.Exec(`PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+	defer db.Exec(`PRAGMA foreign_keys = ON`)
+	if _, err := db.Exec(`ATTACH DATABASE ? AS snap`, path); err != nil {
+		return err
+	}
+	defer db.Exec(`DETACH DATABASE snap`)
+
+	tables, err := replicatedTables(db)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range tables {
+		cols, err := sharedColumns(tx, table)
+		if err != nil {
+			return err
+		}
+		if len(cols) == 0 {
+			continue
+		}
+		list := `"` + strings.Join(cols, `", "`) + `"`
+		if _, err := tx.Exec(`DELETE FROM main."` + table + `"`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO main."` + table + `" (` + list + `) SELECT ` + list + ` FROM snap."` + table + `" ORDER BY rowid`); err != nil {
+			return fmt.Errorf("copy %s: %v", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// sharedColumns lists the columns table has in both the local database and
+// the snapshot; empty if the snapshot lacks the table.
+func sharedColumns(tx *sql.Tx, table string) ([]string, error) {
+	columns := func(schema string) (map[string]bool, []string, error) {
+		rows, err := tx.Query(`SELECT name FROM pragma_table_info(?, ?)`, table, schema)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer rows.Close()
+		set := map[string]bool{}
+		var order []string
+		for rows.Next() {
+			var name string
+			if rows.Scan(&name) == nil {
+				set[name] = true
+				order = append(order, name)
+			}
+		}
+		return set, order, rows.Err()
+	}
+	snap, _, err := columns("snap")
+	if err != nil {
+		return nil, err
+	}
+	_, local, err := columns("main")
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	for _, c := range local {
+		if snap[c] {
+			shared = append(shared, c)
+		}
+	}
+	return shared, nil
+}
+
+// fetchPrimary makes an authenticated admin request to the primary,
+// refreshing the access token once if the primary rejects it.
+func fetchPrimary(cfg *StandbyConfig, path string) (*http.Response, error) {
+	resp, err := getPrimary(cfg, path)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && cfg.RefreshToken != "" {
+		resp.Body.Close()
+		if err := refreshStandbyToken(cfg); err != nil {
+			return nil, err
+		}
+		if resp, err = getPrimary(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("primary returned %s for %s", resp.Status, path)
+	}
+	return resp, nil
+}
+
+func getPrimary(cfg *StandbyConfig, path string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, cfg.Primary+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	replication.Lock()
+	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	replication.Unlock()
+	return replicationClient.Do(req)
+}
+
+// refreshStandbyToken gets a new access token from the primary and saves it
+// to config.json so a restart doesn't start from an expired one.
+func refreshStandbyToken(cfg *StandbyConfig) error {
+	body, _ := json.Marshal(RefreshTokenReq{RefreshToken: cfg.RefreshToken})
+	resp, err := replicationClient.Post(cfg.Primary+"/api/token/refresh", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("primary refused to refresh the standby token: %s", resp.Status)
+	}
+	var tokens TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return err
+	}
+	replication.Lock()
+	cfg.Token = tokens.Token
+	cfg.RefreshToken = tokens.RefreshToken
+	replication.Unlock()
+	return rewriteConfig(func(raw map[string]json.RawMessage) error {
+		var sb map[string]any
+		if err := json.Unmarshal(raw["standby"], &sb); err != nil {
+			return err
+		}
+		sb["token"] = tokens.Token
+		sb["refresh_token"] = tokens.RefreshToken
+		data, err := json.Marshal(sb)
+		raw["standby"] = data
+		return err
+	})
+}
+
+// syncFromPrimary downloads and applies one snapshot.
+func syncFromPrimary(cfg *StandbyConfig) error {
+	resp, err := fetchPrimary(cfg, "/api/admin/replication/snapshot")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	want := resp.Header.Get(replicationChecksumHeader)
+
+	tmp, err := os.CreateTemp(dataDir, "standby-*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, resp.Body)
+	tmp.Close()
+	if err != nil {
+		return fmt.Errorf("download snapshot: %v", err)
+	}
+	if err := applySnapshot(tmp.Name()); err != nil {
+		return fmt.Errorf("apply snapshot: %v", err)
+	}
+	if err := loadTagPolicy(); err != nil {
+		return err
+	}
+	db, err := replicationDB()
+	if err != nil {
+		return err
+	}
+	got, err := dataChecksum(db)
+	if err != nil {
+		return err
+	}
+	if want != "" && got != want {
+		return fmt.Errorf("applied snapshot checksum %s does not match primary's %s", got, want)
+	}
+
+	replication.Lock()
+	replication.lastSyncAt = nowISO()
+	replication.checksum = got
+	replication.Unlock()
+	return nil
+}
+
+// runStandbySync replicates from the primary until the node is promoted or
+// the workers are stopped.
+func runStandbySync() {
+	for {
+		replication.Lock()
+		cfg := replication.standby
+		replication.Unlock()
+		if cfg == nil {
+			return
+		}
+		err := syncFromPrimary(cfg)
+		replication.Lock()
+		if err != nil {
+			log.Printf("standby sync: %v", err)
+			replication.lastError = err.Error()
+		} else {
+			replication.lastError = ""
+		}
+		replication.Unlock()
+
+		interval := defaultStandbyInterval
+		if cfg.IntervalSeconds > 0 {
+			interval = time.Duration(cfg.IntervalSeconds) * time.Second
+		}
+		if !idle(interval) {
+			return
+		}
+	}
+}
+
+// removeStandbyConfig drops "standby" from config.json so a restart after
+// promotion keeps the node primary. Other settings are left as written.
+func removeStandbyConfig() error {
+	return rewriteConfig(func(raw map[string]json.RawMessage) error {
+		delete(raw, "standby")
+		return nil
+	})
+}
+
+// rewriteConfig applies edit to the top-level keys of config.json.
+func rewriteConfig(edit func(raw map[string]json.RawMessage) error) error {
+	path := filepath.Join(dataDir, "config.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if err := edit(raw); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// GET /api/admin/replication — this node's role and sync state (admin only).
+func handleReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	db, err := replicationDB()
+	if err != nil {
+		writeJSON(w, http.StatusNotImplemented, ErrorResponse{Error: err.Error()})
+		return
+	}
+	checksum, err := dataChecksum(db)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to checksum data"})
+		return
+	}
+	status := ReplicationStatus{Role: "primary", Checksum: checksum}
+	replication.Lock()
+	if replication.standby != nil {
+		status.Role = "standby"
+		status.Primary = replication.standby.Primary
+		status.LastSyncAt = replication.lastSyncAt
+		status.LastError = replication.lastError
+	}
+	replication.Unlock()
+	writeJSON(w, http.StatusOK, status)
+}
+
+// GET /api/admin/replication/snapshot — a consistent copy of the database
+// for a standby, with its data checksum in a header (admin only).
+func handleReplicationSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	db, err := replicationDB()
+	if err != nil {
+		writeJSON(w, http.StatusNotImplemented, ErrorResponse{Error: err.Error()})
+		return
+	}
+	path := filepath.Join(dataDir, "snapshot-"+newID()+".db")
+	defer os.Remove(path)
+	if _, err := db.Exec(`VACUUM INTO ?`, path); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create snapshot"})
+		return
+	}
+	checksum, err := checksumFile(path)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to checksum snapshot"})
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to read snapshot"})
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set(replicationChecksumHeader, checksum)
+	io.Copy(w, f)
+}
+
+// POST /api/admin/replication/promote — turn a standby into the primary
+// (admin only). If the old primary still answers, its data must match this
+// node's; ?force=true promotes anyway. A reason is required.
+func handleReplicationPromote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	reason, err := adminReason(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if err := validateAdminReason(reason); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	replication.Lock()
+	cfg := replication.standby
+	replication.Unlock()
+	if cfg == nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "this node is already the primary"})
+		return
+	}
+
+	replication.Lock()
+	lastSync := cmp.Or(replication.lastSyncAt, "never")
+	replication.Unlock()
+	result := PromotionResult{PrimaryReachable: true}
+	db, err := replicationDB()
+	if err != nil {
+		writeJSON(w, http.StatusNotImplemented, ErrorResponse{Error: err.Error()})
+		return
+	}
+	local, err := dataChecksum(db)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to checksum data"})
+		return
+	}
+	result.Checksum = local
+	primary, err := primaryChecksum(cfg)
+	if err != nil {
+		result.PrimaryReachable = false
+		result.Warning = "primary unreachable (" + err.Error() + "); changes after the last sync at " + lastSync + " may be lost"
+	} else if primary != local {
+		result.Diverged = true
+		if r.URL.Query().Get("force") != "true" {
+			writeJSON(w, http.StatusConflict, result)
+			return
+		}
+		result.Warning = "standby data differs from the primary; promoted anyway"
+	}
+
+	if err := removeStandbyConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update config"})
+		return
+	}
+	loadStandby(nil)
+	startBackgroundWorkers()
+	log.Printf("Promoted to primary (checksum %s)", local)
+	recordAudit(r, currentUser(r), auditPromote, "node", "", reason)
+	result.Role = "primary"
+	writeJSON(w, http.StatusOK, result)
+}
+
+// primaryChecksum asks the primary for its current data checksum.
+func primaryChecksum(cfg *StandbyConfig) (string, error) {
+	resp, err := fetchPrimary(cfg, "/api/admin/replication")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var status ReplicationStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return "", err
+	}
+	return status.Checksum, nil
+}
@@ -0,0 +1,96 @@
+package memomarket
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Retention periods in days; 0 keeps records forever. Download events are
+// kept by default because pack stats are computed from them.
+var retention = struct {
+	downloadEvents    int
+	webhookDeliveries int
+	jobs              int
+	deletedPacks      int
+	auditLog          int
+}{webhookDeliveries: 30, jobs: 30, deletedPacks: 30, auditLog: 365}
+
+func loadRetention(cfg *RetentionConfig) {
+	if cfg == nil {
+		return
+	}
+	for _, f := range []struct {
+		val *int
+		dst *int
+	}{
+		{cfg.DownloadEventsDays, &retention.downloadEvents},
+		{cfg.WebhookDeliveriesDays, &retention.webhookDeliveries},
+		{cfg.JobsDays, &retention.jobs},
+		{cfg.DeletedPacksDays, &retention.deletedPacks},
+		{cfg.AuditLogDays, &retention.auditLog},
+	} {
+		if f.val != nil && *f.val >= 0 {
+			*f.dst = *f.val
+		}
+	}
+}
+
+const JobCleanup = "cleanup"
+
+const cleanupInterval = 6 * time.Hour
+
+// runCleanupScheduler queues a cleanup job at startup and every cleanupInterval.
+func runCleanupScheduler() {
+	for {
+		if !store.HasPendingJob(JobCleanup) {
+			if _, err := enqueueJob(JobCleanup, "", nil); err != nil {
+				log.Printf("cleanup scheduler: %v", err)
+			}
+		}
+		if !idle(cleanupInterval) {
+			return
+		}
+	}
+}
+
+// runCleanup purges records older than their retention period. Packs and
+// users under legal hold, and their download events, are never purged.
+func runCleanup(j *Job) (string, error) {
+	var summary []string
+	for _, step := range []struct {
+		name  string
+		days  int
+		purge func(cutoff string) (int, error)
+	}{
+		{"deleted packs", retention.deletedPacks, store.PurgeDeletedPacks},
+		{"download events", retention.downloadEvents, store.PurgeDownloadEvents},
+		{"install events", retention.downloadEvents, store.PurgeInstallEvents},
+		{"webhook deliveries", retention.webhookDeliveries, store.PurgeWebhookDeliveries},
+		{"jobs", retention.jobs, store.PurgeJobs},
+		{"audit entries", retention.auditLog, store.PurgeAuditEntries},
+		// Expired sessions are useless; they go a day after refresh stops working.
+		{"expired sessions", 1, store.PurgeSessions},
+	} {
+		if step.days <= 0 {
+			continue
+		}
+		cutoff := time.Now().UTC().AddDate(0, 0, -step.days).Format("2006-01-02T15:04:05")
+		n, err := step.purge(cutoff)
+		if err != nil {
+			return "", fmt.Errorf("purge %s: %v", step.name, err)
+		}
+		summary = append(summary, fmt.Sprintf("%d %s", n, step.name))
+	}
+	if n := removeOrphanCovers(); n > 0 {
+		summary = append(summary, fmt.Sprintf("%d covers", n))
+	}
+	if n := removeOrphanAttachments(); n > 0 {
+		summary = append(summary, fmt.Sprintf("%d attachments", n))
+	}
+	if len(summary) == 0 {
+		return "nothing to purge", nil
+	}
+	return "purged " + strings.Join(summary, ", "), nil
+}
@@ -0,0 +1,96 @@
+package memomarket
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// Rule file exports flatten a pack into the instruction file an IDE
+// assistant reads from the project: the system prompt first, then each rule
+// as a "## title" section, with the files and languages it is scoped to.
+// Deprecated rules are left out. The result reads back through the GitHub
+// import of .cursorrules as the same system prompt and rules. Memos aren't
+// included; these files hold standing instructions, not reference material.
+
+// ruleFileFormat is one IDE assistant's instruction file.
+type ruleFileFormat struct {
+	// filename is the file's usual name; "" names it after the pack, with
+	// extension, for tools that keep a file per rule set.
+	filename    string
+	extension   string
+	contentType string
+	// frontMatter, when set, returns the header the file starts with.
+	frontMatter func(pack *MemoPack) string
+}
+
+var ruleFileFormats = map[string]ruleFileFormat{
+	"cursorrules": {filename: ".cursorrules", contentType: "text/plain; charset=utf-8"},
+	"cursor":      {extension: ".mdc", contentType: "text/markdown; charset=utf-8", frontMatter: cursorFrontMatter},
+	"windsurf":    {filename: ".windsurfrules", contentType: "text/plain; charset=utf-8"},
+	"copilot":     {filename: "copilot-instructions.md", contentType: "text/markdown; charset=utf-8"},
+	"agents":      {filename: "AGENTS.md", contentType: "text/markdown; charset=utf-8"},
+	"claude":      {filename: "CLAUDE.md", contentType: "text/markdown; charset=utf-8"},
+}
+
+// ruleFileFormatNames lists ruleFileFormats for error messages.
+const ruleFileFormatNames = "cursorrules, cursor, windsurf, copilot, agents or claude"
+
+// name is the file name pack is exported under.
+func (f ruleFileFormat) name(pack *MemoPack) string {
+	return cmp.Or(f.filename, cmp.Or(pack.Slug, pack.ID)+f.extension)
+}
+
+// render writes pack as the format's file.
+func (f ruleFileFormat) render(pack *MemoPack) string {
+	var b strings.Builder
+	if prompt := strings.TrimSpace(pack.SystemPrompt); prompt != "" {
+		b.WriteString(prompt + "\n")
+	}
+	for _, r := range pack.Rules {
+		if r.Deprecated != nil {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "## %s\n\n", strings.TrimSpace(r.Title))
+		if scope := ruleScope(r.Conditions); scope != "" {
+			fmt.Fprintf(&b, "%s\n\n", scope)
+		}
+		b.WriteString(strings.TrimSpace(r.UpdateRule) + "\n")
+	}
+	if f.frontMatter != nil {
+		return f.frontMatter(pack) + b.String()
+	}
+	return b.String()
+}
+
+// ruleScope describes what a rule's conditions limit it to, or "" when it
+// applies everywhere.
+func ruleScope(c *RuleConditions) string {
+	if c == nil {
+		return ""
+	}
+	var parts []string
+	if len(c.FileGlobs) > 0 {
+		parts = append(parts, "files matching "+strings.Join(c.FileGlobs, ", "))
+	}
+	if len(c.Languages) > 0 {
+		parts = append(parts, strings.Join(c.Languages, ", ")+" code")
+	}
+	if len(c.ProjectTypes) > 0 {
+		parts = append(parts, strings.Join(c.ProjectTypes, ", ")+" projects")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Applies to " + strings.Join(parts, "; ") + "."
+}
+
+// cursorFrontMatter is the header of a Cursor project rule (.mdc), applied
+// to every request.
+func cursorFrontMatter(pack *MemoPack) string {
+	desc := strings.Join(strings.Fields(cmp.Or(pack.Description, pack.Name)), " ")
+	return "---\ndescription: " + yamlScalar(desc) + "\nglobs:\nalwaysApply: true\n---\n\n"
+}
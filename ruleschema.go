@@ -0,0 +1,56 @@
+package memomarket
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Rules have a typed schema next to their free-form title and update_rule:
+// a trigger saying when the rule fires, its conditions, an action saying
+// what the client does and a priority ordering rules that fire together.
+// Rules written before the schema are version 1 and carry none of these;
+// a rule using any of them is version 2 and must name a trigger and an
+// action. schema_version tells clients which reading applies.
+
+const (
+	ruleSchemaLegacy  = 1
+	ruleSchemaVersion = 2
+)
+
+const maxRulePriority = 100
+
+// ruleTriggers are the events a rule can fire on.
+var ruleTriggers = []string{"always", "session_start", "session_end", "message", "file_change", "tool_use"}
+
+// ruleActions are what a client does when a rule fires: follow update_rule
+// as an instruction, or apply it to the pack's memos.
+var ruleActions = []string{"instruct", "update_memo", "append_memo", "create_memo"}
+
+// validateRuleSchema checks the typed fields of rule i and stamps the schema
+// version of a rule that uses them.
+func validateRuleSchema(i int, rule *MemoRule) error {
+	typed := rule.Trigger != "" || rule.Action != "" || rule.Priority != 0
+	switch {
+	case rule.SchemaVersion > ruleSchemaVersion:
+		return fmt.Errorf("rule %d: schema_version %d is newer than this server supports (%d)", i+1, rule.SchemaVersion, ruleSchemaVersion)
+	case rule.SchemaVersion < 0:
+		return fmt.Errorf("rule %d: schema_version must be positive", i+1)
+	case rule.SchemaVersion == ruleSchemaLegacy && typed:
+		return fmt.Errorf("rule %d: trigger, action and priority need schema_version %d", i+1, ruleSchemaVersion)
+	case rule.SchemaVersion == 0 && typed:
+		rule.SchemaVersion = ruleSchemaVersion
+	}
+	if rule.SchemaVersion < ruleSchemaVersion {
+		return nil
+	}
+	if !slices.Contains(ruleTriggers, rule.Trigger) {
+		return fmt.Errorf("rule %d: trigger must be one of %v", i+1, ruleTriggers)
+	}
+	if !slices.Contains(ruleActions, rule.Action) {
+		return fmt.Errorf("rule %d: action must be one of %v", i+1, ruleActions)
+	}
+	if rule.Priority < -maxRulePriority || rule.Priority > maxRulePriority {
+		return fmt.Errorf("rule %d: priority must be between %d and %d", i+1, -maxRulePriority, maxRulePriority)
+	}
+	return nil
+}
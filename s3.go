@@ -0,0 +1,131 @@
+package memomarket
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// A minimal S3 client for storing attachments: single-request PUT, GET and
+// DELETE of objects, signed with AWS Signature Version 4. It works with AWS
+// and with S3-compatible servers like MinIO given an endpoint, which is then
+// addressed path-style.
+
+var s3HTTPClient = &http.Client{Timeout: time.Minute, Transport: newBreakerTransport(breakerS3, false)}
+
+type s3Client struct {
+	cfg S3Config
+}
+
+// objectURL is where key lives: {endpoint}/{bucket}/{key} with an endpoint,
+// https://{bucket}.s3.{region}.amazonaws.com/{key} without.
+func (c *s3Client) objectURL(key string) *url.URL {
+	key = strings.TrimPrefix(strings.Trim(c.cfg.Prefix, "/")+"/"+key, "/")
+	if c.cfg.Endpoint != "" {
+		u, _ := url.Parse(strings.TrimSuffix(c.cfg.Endpoint, "/"))
+		u.Path += "/" + c.cfg.Bucket + "/" + key
+		return u
+	}
+	return &url.URL{Scheme: "https", Host: c.cfg.Bucket + ".s3." + c.cfg.Region + ".amazonaws.com", Path: "/" + key}
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// do sends a signed request for key.
+func (c *s3Client) do(method, key string, body []byte, contentType string) (*http.Response, error) {
+	u := c.objectURL(key)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	headers := [][2]string{{"host", u.Host}}
+	if contentType != "" {
+		headers = append([][2]string{{"content-type", contentType}}, headers...)
+		req.Header.Set("Content-Type", contentType)
+	}
+	headers = append(headers, [2]string{"x-amz-content-sha256", payloadHash}, [2]string{"x-amz-date", amzDate})
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	var canonicalHeaders strings.Builder
+	names := make([]string, len(headers))
+	for i, h := range headers {
+		canonicalHeaders.WriteString(h[0] + ":" + strings.TrimSpace(h[1]) + "\n")
+		names[i] = h[0]
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical := strings.Join([]string{method, u.EscapedPath(), "", canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + c.cfg.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	signingKey := []byte("AWS4" + c.cfg.SecretAccessKey)
+	for _, part := range []string{date, c.cfg.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.cfg.AccessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(signingKey, toSign))))
+
+	resp, err := s3HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, os.ErrNotExist
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s", method, key, resp.Status)
+	}
+	return resp, nil
+}
+
+func (c *s3Client) Put(key string, data []byte, contentType string) error {
+	resp, err := c.do(http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *s3Client) Get(key string) (io.ReadCloser, error) {
+	resp, err := c.do(http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *s3Client) Delete(key string) error {
+	resp, err := c.do(http.MethodDelete, key, nil, "")
+	if err == os.ErrNotExist {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
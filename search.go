@@ -0,0 +1,130 @@
+package memomarket
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"log"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is go-sqlite3 with the functions used by search ranking.
+const sqliteDriver = "sqlite3_memomarket"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("fts_rank", ftsRank, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("popularity", popularity, true)
+		},
+	})
+}
+
+// relevanceScore ranks search results in tiers: exact name match (30+),
+// name prefix (20+), full-text match (10+), substring only (0+). Within a
+// tier the full-text rank (0-5) and a popularity boost (0-1) break ties.
+// Its placeholders are the search text and the prefix pattern.
+const relevanceScore = `(CASE
+	WHEN name = ? COLLATE NOCASE THEN 30
+	WHEN name LIKE ? THEN 20
+	WHEN f.fts IS NOT NULL THEN 10
+	ELSE 0 END
+	+ COALESCE(f.fts, 0)
+	+ popularity(downloads))`
+
+// Column weights for fts_rank: name, description, author_name.
+var ftsWeights = []float64{3, 1, 1}
+
+// ftsRank scores a row from FTS4 matchinfo(..., 'pcx'): for each phrase and
+// column, the share of all hits that fall in this row, weighted by column and
+// averaged over phrases. The result lies in [0, 5].
+func ftsRank(matchinfo []byte) float64 {
+	if len(matchinfo) < 8 {
+		return 0
+	}
+	ints := make([]uint32, len(matchinfo)/4)
+	for i := range ints {
+		ints[i] = binary.NativeEndian.Uint32(matchinfo[i*4:])
+	}
+	phrases, cols := int(ints[0]), int(ints[1])
+	var score float64
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < cols && c < len(ftsWeights); c++ {
+			x := ints[2+3*(p*cols+c):]
+			if x[1] > 0 {
+				score += ftsWeights[c] * float64(x[0]) / float64(x[1])
+			}
+		}
+	}
+	if phrases > 0 {
+		score /= float64(phrases)
+	}
+	return score
+}
+
+// popularity maps a download count onto [0, 1] on a log scale, reaching 1
+// at a million downloads.
+func popularity(downloads int64) float64 {
+	return min(math.Log1p(float64(max(downloads, 0)))/math.Log1p(1e6), 1)
+}
+
+// ftsMatchQuery turns free text into an FTS4 query that requires every word,
+// matching word prefixes. It returns "" when the text has no words.
+func ftsMatchQuery(search string) string {
+	words := strings.FieldsFunc(search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = `"` + w + `*"`
+	}
+	return strings.Join(words, " ")
+}
+
+// scoreScanner scans a memo pack row followed by a score column.
+type scoreScanner struct {
+	rows  *sql.Rows
+	score *float64
+}
+
+func (s scoreScanner) Scan(dest ...any) error {
+	return s.rows.Scan(append(dest, s.score)...)
+}
+
+// migrateSearchIndex creates the full-text index over pack names,
+// descriptions and authors, kept in sync by triggers, and rebuilds it when
+// it is out of step with the packs table.
+func (s *SQLiteStore) migrateSearchIndex() {
+	_, err := s.db.Exec(`
+	CREATE VIRTUAL TABLE IF NOT EXISTS memo_packs_fts USING fts4(name, description, author_name);
+
+	CREATE TRIGGER IF NOT EXISTS memo_packs_fts_insert AFTER INSERT ON memo_packs BEGIN
+		INSERT INTO memo_packs_fts (docid, name, description, author_name) VALUES (new.rowid, new.name, new.description, new.author_name);
+	END;
+	CREATE TRIGGER IF NOT EXISTS memo_packs_fts_update AFTER UPDATE OF name, description, author_name ON memo_packs BEGIN
+		DELETE FROM memo_packs_fts WHERE docid = old.rowid;
+		INSERT INTO memo_packs_fts (docid, name, description, author_name) VALUES (new.rowid, new.name, new.description, new.author_name);
+	END;
+	CREATE TRIGGER IF NOT EXISTS memo_packs_fts_delete AFTER DELETE ON memo_packs BEGIN
+		DELETE FROM memo_packs_fts WHERE docid = old.rowid;
+	END;`)
+	if err != nil {
+		log.Fatalf("Failed to create search index: %v", err)
+	}
+
+	var packs, indexed int
+	s.db.QueryRow(`SELECT COUNT(*) FROM memo_packs`).Scan(&packs)
+	s.db.QueryRow(`SELECT COUNT(*) FROM memo_packs_fts`).Scan(&indexed)
+	if packs == indexed {
+		return
+	}
+	_, err = s.db.Exec(`DELETE FROM memo_packs_fts;
+		INSERT INTO memo_packs_fts (docid, name, description, author_name) SELECT rowid, name, description, author_name FROM memo_packs`)
+	if err != nil {
+		log.Fatalf("Failed to rebuild search index: %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package memomarket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Self-check run at startup and by `memomarket validate`. Failures stop the
+// server before it takes traffic; warnings are logged.
+
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// Free space below this fails the check; below minFreeWarn warns.
+const (
+	minFreeFail = 50 << 20
+	minFreeWarn = 500 << 20
+)
+
+type checkResult struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+type selfCheck struct {
+	results []checkResult
+}
+
+func (c *selfCheck) add(name, status, format string, args ...any) {
+	c.results = append(c.results, checkResult{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (c *selfCheck) failed() bool {
+	return slices.ContainsFunc(c.results, func(r checkResult) bool { return r.Status == checkFail })
+}
+
+// checkConfig validates config.json beyond what loadServerConfig needs,
+// which silently skips anything it can't use.
+func (c *selfCheck) checkConfig(dataDir string) {
+	data, err := os.ReadFile(filepath.Join(dataDir, "config.json"))
+	if os.IsNotExist(err) {
+		c.add("config", checkOK, "no config.json; using defaults")
+		return
+	}
+	if err != nil {
+		c.add("config", checkFail, "cannot read config.json: %v", err)
+		return
+	}
+	var cfg ServerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		c.add("config", checkFail, "config.json is not valid: %v", err)
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ServerConfig{}); err != nil {
+		c.add("config", checkWarn, "config.json: %v (ignored)", err)
+	} else {
+		c.add("config", checkOK, "config.json parsed")
+	}
+
+	if p := cfg.NamingPolicy; p != nil {
+		for _, pattern := range slices.Concat(p.Deny, p.Allow, p.UsernameDeny, p.UsernameAllow, p.PackDeny, p.PackAllow) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				c.add("naming_policy", checkFail, "invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	for _, wc := range cfg.Webhooks {
+		wh := Webhook{URL: wc.URL, Kind: wc.Kind, Events: wc.Events}
+		if err := validateWebhook(&wh); err != nil {
+			c.add("webhooks", checkFail, "%s: %v", wc.URL, err)
+		}
+	}
+	if rl := cfg.RateLimits; rl != nil && (rl.DownloadsPerMinute < 0 || rl.DownloadBurst < 0 || rl.PackDownloadsPerHour < 0 || rl.DownloadBytesPerSecond < 0 ||
+		rl.RequestsPerMinute < 0 || rl.RequestBurst < 0 || rl.AuthRequestsPerMinute < 0 || rl.AuthRequestBurst < 0) {
+		c.add("rate_limits", checkFail, "rate limits must not be negative")
+	}
+	if rc := cfg.Retention; rc != nil {
+		for _, days := range []*int{rc.DownloadEventsDays, rc.WebhookDeliveriesDays, rc.JobsDays, rc.DeletedPacksDays, rc.AuditLogDays} {
+			if days != nil && *days < 0 {
+				c.add("retention", checkFail, "retention days must not be negative")
+				break
+			}
+		}
+	}
+	if cc := cfg.Concurrency; cc != nil {
+		for group := range cc.Routes {
+			if _, ok := defaultConcurrency[group]; !ok {
+				c.add("concurrency", checkWarn, "unknown route group %q (ignored)", group)
+			}
+		}
+	}
+	if cc := cfg.Cache; cc != nil {
+		for _, secs := range []*int{cc.ListingSeconds, cc.PackSeconds, cc.ImmutableSeconds, cc.InfoSeconds} {
+			if secs != nil && *secs < 0 {
+				c.add("cache", checkFail, "cache TTLs must not be negative")
+				break
+			}
+		}
+	}
+	if cfg.PackBlobThreshold < 0 {
+		c.add("pack_blob_threshold", checkFail, "pack_blob_threshold must not be negative")
+	}
+	if cfg.IDs != nil {
+		if err := validateIDConfig(cfg.IDs); err != nil {
+			c.add("ids", checkFail, "%v", err)
+		}
+	}
+	if cfg.Duplicates != nil {
+		if err := validateDuplicatesConfig(cfg.Duplicates); err != nil {
+			c.add("duplicates", checkFail, "%v", err)
+		}
+	}
+	if cfg.Attachments != nil {
+		if err := validateAttachmentsConfig(cfg.Attachments); err != nil {
+			c.add("attachments", checkFail, "%v", err)
+		}
+	}
+	if cfg.PromptExport != nil {
+		if err := validatePromptExportConfig(cfg.PromptExport); err != nil {
+			c.add("prompt_export", checkFail, "%v", err)
+		}
+	}
+	if cfg.AppealURL != "" && !isHTTPURL(cfg.AppealURL) {
+		c.add("appeal_url", checkFail, "appeal_url %q is not an http(s) URL", cfg.AppealURL)
+	}
+	if sc := cfg.SMTP; sc != nil && sc.Host != "" {
+		if addr, err := mail.ParseAddress(sc.From); err != nil || addr.Address != sc.From {
+			c.add("smtp", checkFail, "from %q is not an email address", sc.From)
+		}
+	}
+	if cfg.LLM != nil {
+		c.checkLLM(*cfg.LLM)
+	}
+	if cfg.Standby != nil {
+		c.checkStandby(cfg.Standby)
+	}
+}
+
+func (c *selfCheck) checkLLM(llm LLMConfig) {
+	if llm.APIURL == "" {
+		return
+	}
+	if !isHTTPURL(llm.APIURL) {
+		c.add("llm", checkFail, "api_url %q is not an http(s) URL", llm.APIURL)
+		return
+	}
+	if llm.Model == "" {
+		c.add("llm", checkWarn, "no model set; eval runs will use the provider default")
+	}
+	c.checkReachable("llm", llm.APIURL)
+}
+
+func (c *selfCheck) checkStandby(sb *StandbyConfig) {
+	if !isHTTPURL(sb.Primary) {
+		c.add("standby", checkFail, "primary %q is not an http(s) URL", sb.Primary)
+		return
+	}
+	if sb.Token == "" {
+		c.add("standby", checkFail, "standby needs an admin token for the primary")
+		return
+	}
+	if sb.RefreshToken == "" {
+		c.add("standby", checkWarn, "no refresh_token; replication stops when the access token expires")
+	}
+	if _, err := primaryChecksum(&StandbyConfig{Primary: strings.TrimSuffix(sb.Primary, "/"), Token: sb.Token}); err != nil {
+		c.add("standby", checkWarn, "primary not usable yet: %v", err)
+	} else {
+		c.add("standby", checkOK, "primary %s reachable", sb.Primary)
+	}
+}
+
+// checkReachable warns when nothing answers at rawURL's host.
+func (c *selfCheck) checkReachable(name, rawURL string) {
+	u, _ := url.Parse(rawURL)
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Head(u.Scheme + "://" + u.Host)
+	if err != nil {
+		c.add(name, checkWarn, "%s unreachable: %v", u.Host, err)
+		return
+	}
+	resp.Body.Close()
+	c.add(name, checkOK, "%s reachable", u.Host)
+}
+
+// checkStorage verifies the data directory is writable and has room.
+func (c *selfCheck) checkStorage(dataDir string) {
+	f, err := os.CreateTemp(dataDir, ".selfcheck-*")
+	if err != nil {
+		c.add("data_dir", checkFail, "%s is not writable: %v", dataDir, err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	c.add("data_dir", checkOK, "%s writable", dataDir)
+
+	free, ok := diskFree(dataDir)
+	switch {
+	case !ok:
+		c.add("disk_space", checkWarn, "free space unknown on this platform")
+	case free < minFreeFail:
+		c.add("disk_space", checkFail, "only %d MiB free in %s", free>>20, dataDir)
+	case free < minFreeWarn:
+		c.add("disk_space", checkWarn, "only %d MiB free in %s", free>>20, dataDir)
+	default:
+		c.add("disk_space", checkOK, "%d MiB free", free>>20)
+	}
+}
+
+// checkDatabase confirms the database accepts writes, rolling the probe
+// back, and that configured admins exist.
+func (c *selfCheck) checkDatabase() {
+	if status, detail := probeDatabase(); status != checkOK {
+		c.add("database", status, "%s", detail)
+		return
+	}
+	c.add("database", checkOK, "writable, integrity ok")
+	for _, name := range adminUsernames {
+		if _, err := store.GetUserByUsername(name); err != nil {
+			c.add("admins", checkWarn, "admin %q has no account yet", name)
+		}
+	}
+}
+
+// probeDatabase holds the only connection until it returns, so it must not
+// call other database helpers. Stores other than SQLiteStore are not probed.
+func probeDatabase() (string, string) {
+	db := sqliteDB()
+	if db == nil {
+		return checkOK, ""
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return checkFail, "cannot begin transaction: " + err.Error()
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`CREATE TABLE selfcheck_probe (x INTEGER)`); err != nil {
+		return checkFail, "database is not writable: " + err.Error()
+	}
+	var result string
+	if err := tx.QueryRow(`PRAGMA quick_check`).Scan(&result); err != nil || result != "ok" {
+		return checkFail, "integrity check failed: " + result
+	}
+	return checkOK, ""
+}
+
+// runSelfCheck runs every check against dataDir. The database must be open.
+func runSelfCheck(dataDir string) *selfCheck {
+	c := &selfCheck{}
+	c.checkConfig(dataDir)
+	c.checkStorage(dataDir)
+	c.checkDatabase()
+	c.checkTemplates(dataDir)
+	return c
+}
+
+// checkTemplates reports template files seedPackTemplates would skip.
+func (c *selfCheck) checkTemplates(dataDir string) {
+	files, _ := filepath.Glob(filepath.Join(dataDir, templateDirName, "*.json"))
+	for _, path := range files {
+		if _, err := readTemplateFile(path); err != nil {
+			c.add("templates", checkWarn, "%s: %v (skipped)", filepath.Base(path), err)
+		}
+	}
+}
+
+// printSelfCheck writes one line per check, for `memomarket validate`.
+func printSelfCheck(c *selfCheck) {
+	for _, r := range c.results {
+		fmt.Printf("%-4s  %-20s %s\n", strings.ToUpper(r.Status), r.Name, r.Detail)
+	}
+}
@@ -0,0 +1,151 @@
+package memomarket
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+)
+
+// parseVersion splits a validated version into numeric core and pre-release.
+func parseVersion(v string) ([3]int, string) {
+	var core [3]int
+	pre := ""
+	if i := strings.Index(v, "-"); i >= 0 {
+		v, pre = v[:i], v[i+1:]
+	}
+	for i, part := range strings.SplitN(v, ".", 3) {
+		core[i], _ = strconv.Atoi(part)
+	}
+	return core, pre
+}
+
+// compareVersions returns -1, 0 or 1 following semver precedence.
+func compareVersions(a, b string) int {
+	ac, apre := parseVersion(a)
+	bc, bpre := parseVersion(b)
+	for i := 0; i < 3; i++ {
+		if ac[i] != bc[i] {
+			if ac[i] < bc[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case apre == bpre:
+		return 0
+	case apre == "":
+		return 1
+	case bpre == "":
+		return -1
+	default:
+		return comparePrerelease(apre, bpre)
+	}
+}
+
+// comparePrerelease orders two pre-release suffixes by semver §11: their
+// dot-separated identifiers are compared in turn, numerically when both are
+// numbers and lexically otherwise, and numbers sort before other
+// identifiers. When all of one's identifiers match the other's, the shorter
+// one comes first, so rc.9 < rc.10 and rc < rc.1.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.ParseUint(as[i], 10, 64)
+		bn, berr := strconv.ParseUint(bs[i], 10, 64)
+		var c int
+		switch {
+		case aerr == nil && berr == nil:
+			c = cmp.Compare(an, bn)
+		case aerr == nil:
+			c = -1
+		case berr == nil:
+			c = 1
+		default:
+			c = strings.Compare(as[i], bs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// matchVersionConstraint reports whether v satisfies constraint. Supported forms:
+// exact ("1.2.0"), wildcards ("1.x", "1.2.*"), caret ("^1.2.0"), tilde ("~1.2.0")
+// and space-separated comparators (">=1.0.0 <2.0.0").
+func matchVersionConstraint(v, constraint string) bool {
+	for _, part := range strings.Fields(constraint) {
+		if !matchVersionComparator(v, part) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchVersionComparator(v, c string) bool {
+	switch {
+	case strings.HasPrefix(c, ">="):
+		return compareVersions(v, padVersion(c[2:])) >= 0
+	case strings.HasPrefix(c, "<="):
+		return compareVersions(v, padVersion(c[2:])) <= 0
+	case strings.HasPrefix(c, ">"):
+		return compareVersions(v, padVersion(c[1:])) > 0
+	case strings.HasPrefix(c, "<"):
+		return compareVersions(v, padVersion(c[1:])) < 0
+	case strings.HasPrefix(c, "="):
+		return compareVersions(v, padVersion(c[1:])) == 0
+	case strings.HasPrefix(c, "^"):
+		lo := padVersion(c[1:])
+		core, _ := parseVersion(lo)
+		var hi string
+		switch {
+		case core[0] > 0:
+			hi = strconv.Itoa(core[0]+1) + ".0.0"
+		case core[1] > 0:
+			hi = "0." + strconv.Itoa(core[1]+1) + ".0"
+		default:
+			hi = "0.0." + strconv.Itoa(core[2]+1)
+		}
+		return compareVersions(v, lo) >= 0 && compareVersions(v, hi) < 0
+	case strings.HasPrefix(c, "~"):
+		lo := padVersion(c[1:])
+		core, _ := parseVersion(lo)
+		hi := strconv.Itoa(core[0]) + "." + strconv.Itoa(core[1]+1) + ".0"
+		return compareVersions(v, lo) >= 0 && compareVersions(v, hi) < 0
+	default:
+		// Exact version or wildcard pattern; missing parts act as wildcards.
+		want := strings.Split(c, ".")
+		core, _ := parseVersion(v)
+		for i, w := range want {
+			if i >= 3 {
+				break
+			}
+			if w == "x" || w == "X" || w == "*" {
+				return true
+			}
+			if i == 2 && strings.Contains(w, "-") {
+				return compareVersions(v, c) == 0
+			}
+			n, err := strconv.Atoi(w)
+			if err != nil || n != core[i] {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// padVersion fills in missing minor/patch parts: "1.2" becomes "1.2.0".
+func padVersion(v string) string {
+	for strings.Count(v, ".") < 2 {
+		v += ".0"
+	}
+	return v
+}
+
+// isPrerelease reports whether v has a pre-release suffix.
+func isPrerelease(v string) bool {
+	_, pre := parseVersion(v)
+	return pre != ""
+}
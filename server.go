@@ -0,0 +1,439 @@
+package memomarket
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ServerOptions configures a server built with NewServer. Zero values keep
+// the current settings, which Main loads from the environment and
+// config.json.
+type ServerOptions struct {
+	Name        string
+	Description string
+	// DataDir holds exports, snapshots and the receipt signing key; a new
+	// temporary directory when empty.
+	DataDir string
+	// Config is applied as if it had been read from config.json.
+	Config *ServerConfig
+	// NoBackgroundWorkers leaves webhook deliveries, jobs and the cleanup
+	// schedule to the caller.
+	NoBackgroundWorkers bool
+}
+
+// Server is the MemoMarket HTTP API. Its settings and store live in package
+// variables, so a process runs one Server at a time: NewServer fails until
+// the previous one is closed.
+type Server struct {
+	handler http.Handler
+	closed  atomic.Bool
+}
+
+// serverRunning is set while a Server built by NewServer is open.
+var serverRunning atomic.Bool
+
+// errServerRunning is returned by NewServer while another Server is open.
+var errServerRunning = errors.New("another MemoMarket server is running in this process; close it first")
+
+// Close stops the background workers, waiting for any job or delivery in
+// progress, and releases the process's server settings so NewServer can
+// build another Server. It doesn't close the store.
+func (s *Server) Close() error {
+	if s.closed.CompareAndSwap(false, true) {
+		stopWorkers()
+		serverRunning.Store(false)
+	}
+	return nil
+}
+
+// workers tracks the background goroutines so Close can stop them.
+var workers struct {
+	sync.Mutex
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// workerStop returns the channel that is closed when the workers should
+// stop. Callers hold workers.
+func workerStop() chan struct{} {
+	if workers.stop == nil {
+		workers.stop = make(chan struct{})
+	}
+	return workers.stop
+}
+
+// goWorker runs fn in the background as one of the workers.
+func goWorker(fn func()) {
+	workers.Lock()
+	workerStop()
+	workers.wg.Add(1)
+	workers.Unlock()
+	go func() {
+		defer workers.wg.Done()
+		fn()
+	}()
+}
+
+// idle waits d between a worker's rounds and reports whether it should run
+// another, which is false once the workers are stopped.
+func idle(d time.Duration) bool {
+	workers.Lock()
+	stop := workerStop()
+	workers.Unlock()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-stop:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// stopWorkers stops the workers and waits for them to return.
+func stopWorkers() {
+	workers.Lock()
+	close(workerStop())
+	workers.Unlock()
+	workers.wg.Wait()
+	workers.Lock()
+	workers.stop = nil
+	workers.Unlock()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// RunPending runs the jobs and webhook deliveries that are due until none
+// are left, for servers built with NoBackgroundWorkers. It returns how many
+// ran. Failed attempts that are retried later don't count again until due.
+func (s *Server) RunPending() int {
+	total := 0
+	for {
+		n := runDueJobs() + deliverDueWebhooks()
+		if n == 0 {
+			return total
+		}
+		total += n
+	}
+}
+
+// NewServer returns the API served from st, so other programs can embed
+// MemoMarket in their own binaries and tests. It starts the background
+// workers unless opts.NoBackgroundWorkers is set, and fails while another
+// Server is open.
+func NewServer(st Store, opts ServerOptions) (_ *Server, err error) {
+	if !serverRunning.CompareAndSwap(false, true) {
+		return nil, errServerRunning
+	}
+	defer func() {
+		if err != nil {
+			serverRunning.Store(false)
+		}
+	}()
+	store = st
+	if opts.Name != "" {
+		serverName = opts.Name
+	}
+	if opts.Description != "" {
+		serverDescription = opts.Description
+	}
+	if opts.Config != nil {
+		applyServerConfig(opts.Config)
+	}
+	if opts.DataDir != "" {
+		dataDir = opts.DataDir
+	}
+	if dataDir == "" {
+		dir, err := os.MkdirTemp("", "memomarket-")
+		if err != nil {
+			return nil, err
+		}
+		dataDir = dir
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, err
+	}
+	exportDir = filepath.Join(dataDir, "exports")
+	bundleDir = filepath.Join(dataDir, "bundles")
+	coverDir = filepath.Join(dataDir, "covers")
+	attachmentDir = filepath.Join(dataDir, "attachments")
+	if serverKey == nil {
+		loadServerKey(dataDir)
+	}
+	resolveAdmins()
+	syncChannelWebhooks()
+	if err := loadTagPolicy(); err != nil {
+		return nil, err
+	}
+
+	if !opts.NoBackgroundWorkers {
+		if isStandby() {
+			log.Printf("Running as standby of %s", replication.standby.Primary)
+			goWorker(runStandbySync)
+		} else {
+			startBackgroundWorkers()
+		}
+		goWorker(runMetricsSampler)
+	}
+	return &Server{handler: routes()}, nil
+}
+
+// routes builds the API's handler.
+func routes() http.Handler {
+	mux := http.NewServeMux()
+
+	// Health check
+	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/readyz", handleReadyz)
+
+	// Server info — each backend node is a channel
+	mux.HandleFunc("/api/info", func(w http.ResponseWriter, r *http.Request) {
+		setCacheHeaders(w, cacheInfo, false)
+		writeJSON(w, http.StatusOK, ServerInfo{Name: serverName, Description: serverDescription, PublicKey: serverPublicKey()})
+	})
+
+	mux.HandleFunc("/api/capabilities", handleCapabilities)
+	mux.HandleFunc("/api/categories", handleCategories)
+	mux.HandleFunc("/api/templates", handleTemplates)
+	mux.HandleFunc("/api/templates/", handleTemplates)
+	mux.HandleFunc("/api/attachments/", optionalAuth(handleAttachmentDownload))
+	mux.HandleFunc("/api/verify-receipt", handleVerifyReceipt)
+
+	// Auth
+	mux.HandleFunc("/api/register", handleRegister)
+	mux.HandleFunc("/api/login", handleLogin)
+	mux.HandleFunc("/api/token/refresh", handleRefreshToken)
+	mux.HandleFunc("/api/logout", authMiddleware(handleLogout))
+	mux.HandleFunc("/api/auth/oidc/login", handleOIDCLogin)
+	mux.HandleFunc(oidcCallbackPath, handleOIDCCallback)
+	mux.HandleFunc("/api/me", authMiddleware(handleMe))
+	mux.HandleFunc("/api/me/updates", authMiddleware(handleMyUpdates))
+	mux.HandleFunc("/api/me/search", authMiddleware(handleMySearch))
+	mux.HandleFunc("/api/me/memo-packs/retag", authMiddleware(handleMyRetag))
+	mux.HandleFunc("/api/me/notifications", authMiddleware(handleNotifications))
+	mux.HandleFunc("/api/me/export", authMiddleware(handleMyExport))
+	mux.HandleFunc("/api/me/export/download", authMiddleware(limitConcurrency("export", handleMyExportDownload)))
+	mux.HandleFunc("/api/me/vacation", authMiddleware(handleMyVacation))
+	mux.HandleFunc("/api/me/milestones", authMiddleware(handleMyMilestones))
+	mux.HandleFunc("/api/me/sessions", authMiddleware(handleMySessions))
+	mux.HandleFunc("/api/me/sessions/", authMiddleware(handleMySession))
+	mux.HandleFunc("/api/me/api-keys", authMiddleware(handleMyAPIKeys))
+	mux.HandleFunc("/api/me/api-keys/", authMiddleware(handleMyAPIKey))
+	mux.HandleFunc("/api/me/verifications", authMiddleware(handleMyVerifications))
+	mux.HandleFunc("/api/me/verifications/", authMiddleware(handleMyVerification))
+	mux.HandleFunc("/api/me/webhooks", authMiddleware(handleMyWebhooks))
+	mux.HandleFunc("/api/me/webhooks/", authMiddleware(handleMyWebhook))
+	mux.HandleFunc("/api/me/collections", authMiddleware(handleMyCollections))
+	mux.HandleFunc("/api/me/profile", authMiddleware(handleMyProfile))
+	mux.HandleFunc("/api/me/quota", authMiddleware(handleMyQuota))
+	mux.HandleFunc("/api/me/drafts", authMiddleware(handleMyDrafts))
+	mux.HandleFunc("/api/me/deactivate", authMiddleware(handleMyDeactivate))
+	mux.HandleFunc("/api/me/trust", authMiddleware(handleMyTrust))
+
+	// Admin
+	mux.HandleFunc("/api/admin/legal-holds", adminMiddleware(handleLegalHolds))
+	mux.HandleFunc("/api/admin/legal-holds/", adminMiddleware(handleLegalHold))
+	mux.HandleFunc("/api/admin/cleanup", adminMiddleware(handleAdminCleanup))
+	mux.HandleFunc("/api/admin/audit", adminMiddleware(handleAdminAudit))
+	mux.HandleFunc("/api/admin/users", adminMiddleware(handleAdminUsers))
+	mux.HandleFunc("/api/admin/invites", adminMiddleware(handleAdminInvites))
+	mux.HandleFunc("/api/admin/service-accounts", adminMiddleware(handleServiceAccounts))
+	mux.HandleFunc("/api/admin/service-accounts/", adminMiddleware(handleServiceAccount))
+	mux.HandleFunc("/api/admin/invites/", adminMiddleware(handleAdminInvite))
+	mux.HandleFunc("/api/admin/users/", adminMiddleware(handleAdminUser))
+	mux.HandleFunc("/api/admin/tags", adminMiddleware(handleAdminTags))
+	mux.HandleFunc("/api/admin/tags/", adminMiddleware(handleAdminTag))
+	mux.HandleFunc("/api/admin/categories/", adminMiddleware(handleAdminCategory))
+	mux.HandleFunc("/api/admin/templates/", adminMiddleware(handleAdminTemplate))
+	mux.HandleFunc("/api/admin/featured", adminMiddleware(handleAdminFeaturedList))
+	mux.HandleFunc("/api/admin/featured/", adminMiddleware(handleAdminFeatured))
+	mux.HandleFunc("/api/admin/metrics", adminMiddleware(handleAdminMetrics))
+	mux.HandleFunc("/api/admin/replication", adminMiddleware(handleReplicationStatus))
+	mux.HandleFunc("/api/admin/replication/snapshot", adminMiddleware(limitConcurrency("etl", handleReplicationSnapshot)))
+	mux.HandleFunc("/api/admin/replication/promote", adminMiddleware(handleReplicationPromote))
+	mux.HandleFunc("/api/admin/etl/packs", adminMiddleware(limitConcurrency("etl", handleETLPacks)))
+	mux.HandleFunc("/api/admin/etl/events", adminMiddleware(limitConcurrency("etl", handleETLEvents)))
+
+	mux.HandleFunc("/api/validate-manifest", handleValidateManifest)
+
+	mux.HandleFunc("/api/users/", handleUserProfile)
+
+	// Collections
+	mux.HandleFunc("/api/collections", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+			return
+		}
+		authMiddleware(handleCreateCollection)(w, r)
+	})
+	mux.HandleFunc("/api/collections/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/download"):
+			optionalAuth(limitConcurrency("export", handleCollectionDownload))(w, r)
+		case strings.HasSuffix(r.URL.Path, "/bundle"):
+			handleCollectionBundle(w, r)
+		case r.Method == http.MethodGet:
+			handleCollection(w, r)
+		default:
+			authMiddleware(handleCollection)(w, r)
+		}
+	})
+
+	// Memo Packs — route by method
+	mux.HandleFunc("/api/memo-packs", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			optionalAuth(handleListMemoPacks)(w, r)
+		case http.MethodPost:
+			if token, _ := requestToken(r); token == "" && anonPublishing.Enabled {
+				handlePublishAnonymous(w, r)
+				return
+			}
+			authMiddleware(handlePublishMemoPack)(w, r)
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		}
+	})
+	mux.HandleFunc("/api/memo-packs/", func(w http.ResponseWriter, r *http.Request) {
+		if !resolvePackPath(w, r) {
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/memo-packs/import-github":
+			authMiddleware(limitConcurrency("import", handleImportGitHub))(w, r)
+			return
+		case r.URL.Path == "/api/memo-packs/import":
+			authMiddleware(limitConcurrency("import", handleImportURL))(w, r)
+			return
+		case r.URL.Path == "/api/memo-packs/batch":
+			authMiddleware(handleBatchPublish)(w, r)
+			return
+		case strings.Contains(r.URL.Path, "/webhooks"):
+			authMiddleware(handlePackWebhooks)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/sync-github"):
+			authMiddleware(limitConcurrency("import", handleSyncGitHub))(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/download"):
+			optionalAuth(handleDownloadMemoPack)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/export"):
+			optionalAuth(handleExportMemoPack)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/related"):
+			optionalAuth(handleRelatedPacks)(w, r)
+			return
+		case strings.Contains(r.URL.Path, "/collaborators"):
+			authMiddleware(handlePackCollaborators)(w, r)
+			return
+		case strings.Contains(r.URL.Path, "/share-links"):
+			authMiddleware(handlePackShareLinks)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/embargo"):
+			authMiddleware(handlePackEmbargo)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/claim"):
+			authMiddleware(handleClaimPack)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/publish"):
+			authMiddleware(handlePublishDraft)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/archive"):
+			authMiddleware(handlePackArchive)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/pin"):
+			authMiddleware(handlePackPin)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/cover"):
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				optionalAuth(handlePackCover)(w, r)
+			} else {
+				authMiddleware(handlePackCover)(w, r)
+			}
+			return
+		case strings.Contains(r.URL.Path, "/attachments"):
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				optionalAuth(handlePackAttachments)(w, r)
+			} else {
+				authMiddleware(handlePackAttachments)(w, r)
+			}
+			return
+		case strings.HasSuffix(r.URL.Path, "/memos/order"):
+			authMiddleware(handleReorderMemos)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/fork"):
+			authMiddleware(handleForkPack)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/clone"):
+			authMiddleware(handleClonePack)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/checksum"):
+			optionalAuth(handlePackChecksum)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/preview"):
+			optionalAuth(handlePackPreview)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/manifest"):
+			optionalAuth(handlePackManifest)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/installed"):
+			optionalAuth(handleInstalled)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/stats"):
+			optionalAuth(handlePackStats)(w, r)
+			return
+		case strings.Contains(r.URL.Path, "/versions/") && strings.HasSuffix(r.URL.Path, "/promote"):
+			authMiddleware(handlePromoteVersion)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/versions"):
+			optionalAuth(handleListVersions)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/reviews"):
+			optionalAuth(handleReviews)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/star"):
+			authMiddleware(handleStar)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/subscribe"):
+			authMiddleware(handleSubscribe)(w, r)
+			return
+		case strings.HasSuffix(r.URL.Path, "/evals/runs"):
+			if r.Method == http.MethodPost {
+				authMiddleware(handleRunEvals)(w, r)
+			} else {
+				optionalAuth(handleListEvalRuns)(w, r)
+			}
+			return
+		case strings.HasSuffix(r.URL.Path, "/evals"):
+			if r.Method == http.MethodPut {
+				authMiddleware(handlePutEvals)(w, r)
+			} else {
+				optionalAuth(handleGetEvals)(w, r)
+			}
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			optionalAuth(handleGetMemoPack)(w, r)
+		case http.MethodPut, http.MethodPatch:
+			authMiddleware(handleUpdateMemoPack)(w, r)
+		case http.MethodDelete:
+			authMiddleware(handleDeleteMemoPack)(w, r)
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		}
+	})
+
+	return metricsMiddleware(corsMiddleware(requestLimitMiddleware(standbyMiddleware(clientIDMiddleware(mux)))))
+}
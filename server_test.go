@@ -0,0 +1,94 @@
+package memomarket_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/n0n4we/memomarket"
+	"github.com/n0n4we/memomarket/testserver"
+)
+
+// newServer builds a Server over st for the rest of t.
+func newServer(t *testing.T, st memomarket.Store, workers bool) *memomarket.Server {
+	t.Helper()
+	srv, err := memomarket.NewServer(st, memomarket.ServerOptions{
+		DataDir:             t.TempDir(),
+		Config:              testserver.DefaultConfig(),
+		NoBackgroundWorkers: !workers,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func TestMockStore(t *testing.T) {
+	st, err := memomarket.NewMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	var asked string
+	mock := &memomarket.MockStore{Store: st}
+	mock.GetMemoPackFunc = func(id string) (*memomarket.MemoPack, error) {
+		asked = id
+		return &memomarket.MemoPack{ID: id, Name: "Faked pack", Published: true, Visibility: "public"}, nil
+	}
+	hs := httptest.NewServer(newServer(t, mock, false))
+	defer hs.Close()
+
+	resp, err := http.Get(hs.URL + "/api/memo-packs/p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got memomarket.MemoPack
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || asked != "p1" || got.Name != "Faked pack" {
+		t.Errorf("GET p1: status %d, %+v, %v, store asked for %q; want the faked pack", resp.StatusCode, got, err, asked)
+	}
+	// Ping has no Func, so /readyz reaches the embedded store.
+	if resp, err = http.Get(hs.URL + "/readyz"); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /readyz: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestCloseStopsWorkers(t *testing.T) {
+	st, err := memomarket.NewMemoryStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	before := runtime.NumGoroutine()
+	srv := newServer(t, st, true)
+	if n := runtime.NumGoroutine(); n <= before {
+		t.Fatalf("%d goroutines after NewServer, %d before; want the workers running", n, before)
+	}
+	done := make(chan struct{})
+	go func() {
+		srv.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close didn't return")
+	}
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after Close, %d before NewServer", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	again := newServer(t, st, false)
+	again.Close()
+}
@@ -0,0 +1,162 @@
+package memomarket
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Service accounts let publishing pipelines push packs without a person's
+// credentials. An admin creates one for a designated author and issues it
+// labelled tokens; requests made with them act as the author, limited to
+// the read and publish scopes. A service account has no password and can't
+// log in, and each token can be revoked on its own. The audit log names the
+// service account next to the author.
+
+// GET /api/admin/service-accounts — list service accounts (admin only).
+// POST creates one from {name, author}.
+func handleServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := store.ListServiceAccounts()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list service accounts"})
+			return
+		}
+		for i := range list {
+			if list[i].Tokens, err = store.ListServiceTokens(list[i].ID); err != nil {
+				writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list service accounts"})
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		var req ServiceAccountReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "name is required"})
+			return
+		}
+		if utf8.RuneCountInString(req.Name) > maxAPIKeyNameLength {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "name is too long"})
+			return
+		}
+		author, err := store.GetUserByUsername(strings.TrimSpace(req.Author))
+		if err != nil || author.ID == anonymousUserID {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "author not found"})
+			return
+		}
+		admin := currentUser(r)
+		sa := &ServiceAccount{
+			ID:        newID(),
+			Name:      req.Name,
+			AuthorID:  author.ID,
+			Author:    author.Username,
+			CreatedBy: admin.ID,
+			CreatedAt: nowISO(),
+			Tokens:    []APIKey{},
+		}
+		if err := store.InsertServiceAccount(sa); err != nil {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+			return
+		}
+		recordAudit(r, admin, auditServiceCreate, "service_account", sa.ID, sa.Name+" as "+author.Username)
+		sa.CreatedBy = admin.Username
+		writeJSON(w, http.StatusCreated, sa)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// GET /api/admin/service-accounts/{id} — a service account and its tokens;
+// DELETE removes it and revokes them (admin only).
+// POST /api/admin/service-accounts/{id}/tokens issues a token from
+// {name, scopes}, returning it once; DELETE .../tokens/{tokenID} revokes one.
+func handleServiceAccount(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/admin/service-accounts/")
+	id, sub, _ := strings.Cut(rest, "/")
+	sa, err := store.GetServiceAccount(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "service account not found"})
+		return
+	}
+	admin := currentUser(r)
+	tokenID, hasToken := strings.CutPrefix(sub, "tokens/")
+
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		if sa.Tokens, err = store.ListServiceTokens(sa.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list tokens"})
+			return
+		}
+		writeJSON(w, http.StatusOK, sa)
+	case sub == "" && r.Method == http.MethodDelete:
+		if _, err := store.DeleteServiceAccount(sa.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete service account"})
+			return
+		}
+		recordAudit(r, admin, auditServiceDelete, "service_account", sa.ID, sa.Name)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	case sub == "tokens" && r.Method == http.MethodPost:
+		var req APIKeyReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		if msg := validateAPIKeyReq(&req); msg != "" {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: msg})
+			return
+		}
+		if slices.Contains(req.Scopes, scopeManage) {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "service account tokens are limited to the read and publish scopes"})
+			return
+		}
+		existing, err := store.ListServiceTokens(sa.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create token"})
+			return
+		}
+		if len(existing) >= maxAPIKeysPerUser {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "too many tokens; revoke one first"})
+			return
+		}
+		key := newAPIKeyValue()
+		k := &APIKey{
+			ID:               newID(),
+			UserID:           sa.AuthorID,
+			ServiceAccountID: sa.ID,
+			Name:             req.Name,
+			Prefix:           key[:len(apiKeyPrefix)+8],
+			Scopes:           req.Scopes,
+			CreatedAt:        nowISO(),
+		}
+		if err := store.CreateAPIKey(k, hashAPIKey(key)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create token"})
+			return
+		}
+		recordAudit(r, admin, auditServiceToken, "service_account", sa.ID, k.Name)
+		k.Key = key
+		writeJSON(w, http.StatusCreated, k)
+	case hasToken && tokenID != "" && r.Method == http.MethodDelete:
+		deleted, err := store.DeleteServiceToken(tokenID, sa.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to revoke token"})
+			return
+		}
+		if !deleted {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "token not found"})
+			return
+		}
+		recordAudit(r, admin, auditServiceRevoke, "service_account", sa.ID, tokenID)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "revoked"})
+	case sub == "" || sub == "tokens" || hasToken:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	default:
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+	}
+}
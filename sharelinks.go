@@ -0,0 +1,154 @@
+package memomarket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Share links open a private pack to people without an account. The author
+// mints a link, optionally expiring, and anyone holding it can read and
+// download the pack with ?share=TOKEN on its GET routes, even when the pack
+// requires sign-in. Revoking the link shuts them out again. Only the hash
+// of a token is stored, so the token is shown once, when it is created.
+
+const shareTokenPrefix = "mms_"
+
+const (
+	maxShareLinks          = 20
+	maxShareLinkNoteLength = 200
+)
+
+func newShareToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return shareTokenPrefix + hex.EncodeToString(b)
+}
+
+// shareGrants reports whether r reads p with a live share link. Links only
+// open published private packs outside an embargo.
+func shareGrants(r *http.Request, p *MemoPack) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	token := r.URL.Query().Get("share")
+	if !strings.HasPrefix(token, shareTokenPrefix) || !p.Published || embargoed(p) || p.Visibility != visibilityPrivate {
+		return false
+	}
+	return store.ShareLinkValid(p.ID, hashAPIKey(token))
+}
+
+// GET /api/memo-packs/{id}/share-links — list a pack's share links (author,
+// collaborators or admin). POST {expires_at, note} mints one for a private
+// pack (author only); the token is only in this response.
+// DELETE /api/memo-packs/{id}/share-links/{linkID} revokes one (author, or
+// admin with ?reason=).
+func handlePackShareLinks(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	pack, err := store.GetMemoPack(extractID(r.URL.Path, "/api/memo-packs/"))
+	if err != nil || packHidden(r, pack) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+		return
+	}
+	_, linkID, _ := strings.Cut(r.URL.Path, "/share-links")
+	linkID = strings.Trim(linkID, "/")
+
+	switch {
+	case r.Method == http.MethodGet && linkID == "":
+		if !canEditPack(user, pack) && !isAdmin(user) {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "not your pack"})
+			return
+		}
+	case r.Method == http.MethodPost && linkID == "":
+		if !requireWritable(w, user) {
+			return
+		}
+		if pack.AuthorID != user.ID {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "only the author can share a pack"})
+			return
+		}
+		if pack.Visibility != visibilityPrivate {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "only private packs have share links"})
+			return
+		}
+		var req ShareLinkReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		link := &ShareLink{ID: newID(), PackID: pack.ID, Note: strings.TrimSpace(req.Note), CreatedBy: user.ID, CreatedAt: nowISO()}
+		if utf8.RuneCountInString(link.Note) > maxShareLinkNoteLength {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "note is too long"})
+			return
+		}
+		if req.ExpiresAt != "" {
+			t, ok := parseTimeParam(strings.TrimSpace(req.ExpiresAt))
+			if !ok {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "expires_at must be a date or timestamp"})
+				return
+			}
+			if link.ExpiresAt = t.Format("2006-01-02T15:04:05"); link.ExpiresAt <= link.CreatedAt {
+				writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "expires_at must be in the future"})
+				return
+			}
+		}
+		list, err := store.ListShareLinks(pack.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create share link"})
+			return
+		}
+		if len(list) >= maxShareLinks {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("a pack has at most %d share links", maxShareLinks)})
+			return
+		}
+		token := newShareToken()
+		if err := store.InsertShareLink(link, hashAPIKey(token)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create share link"})
+			return
+		}
+		recordAudit(r, user, auditShareCreate, "pack", pack.ID, link.ID)
+		link.Token = token
+		link.URL = "/api/memo-packs/" + pack.ID + "?share=" + token
+		link.CreatedBy = user.Username
+		writeJSON(w, http.StatusCreated, link)
+		return
+	case r.Method == http.MethodDelete && linkID != "":
+		if !requireWritable(w, user) {
+			return
+		}
+		moderated, reason, ok := checkPackOwner(w, r, user, pack)
+		if !ok {
+			return
+		}
+		found, err := store.DeleteShareLink(pack.ID, linkID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to revoke share link"})
+			return
+		}
+		if !found {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "share link not found"})
+			return
+		}
+		writeAudit(r, user, &AuditEntry{Action: auditShareRevoke, TargetKind: "pack", TargetID: pack.ID, Reason: reason, Diff: "share link " + linkID})
+		if moderated {
+			notifyModeration(pack.AuthorID, pack.ID, pack.Contact, fmt.Sprintf("An admin revoked a share link of your pack %q.", pack.Name), reason)
+		}
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+
+	list, err := store.ListShareLinks(pack.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list share links"})
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,120 @@
+package memomarket
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Every pack gets a slug made from its name, unique across the server:
+// "My Coding Assistant" becomes my-coding-assistant, or
+// my-coding-assistant-2 when that is taken. Renaming a pack gives it a new
+// slug, and the old one keeps pointing at it. A slug works anywhere a pack
+// ID does, in /api/memo-packs/{id} paths, collection items, variant_of and
+// the MCP tools. /api/memo-packs/slug/{slug} also redirects a former slug to
+// the current one.
+
+const maxSlugLength = 60
+
+// slugPattern matches what slugify produces, so other path segments skip
+// the slug lookup.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// reservedSlugs are path segments under /api/memo-packs/ that aren't packs.
+var reservedSlugs = map[string]bool{"slug": true, "import-github": true, "import": true, "batch": true}
+
+// slugify turns a pack name into a slug base: lowercase ASCII letters and
+// digits, diacritics folded, with single hyphens for everything else.
+func slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		if base, ok := diacriticBase[r]; ok {
+			r = base
+		}
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		hyphen = true
+	}
+	s := b.String()
+	if len(s) > maxSlugLength {
+		s = strings.TrimRight(s[:maxSlugLength], "-")
+	}
+	if s == "" {
+		return "pack"
+	}
+	return s
+}
+
+// resolvePackID returns the ID of the pack ref names, by ID or by current
+// or former slug. Anything else comes back unchanged.
+func resolvePackID(ref string) string {
+	if !slugPattern.MatchString(ref) {
+		return ref
+	}
+	if id, _, err := store.ResolvePackSlug(ref); err == nil {
+		return id
+	}
+	return ref
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func publicPackID(id string) bool {
+	pack, err := store.GetMemoPack(id)
+	return err == nil && packReachable(pack)
+}
+
+// resolvePackPath rewrites a /api/memo-packs/{slug}/... or
+// /api/memo-packs/slug/{slug}/... request to the pack's ID. A GET of a
+// former slug under /slug/ is redirected to the current one. It writes the
+// response and returns false when the request is already answered.
+func resolvePackPath(w http.ResponseWriter, r *http.Request) bool {
+	rest := strings.TrimPrefix(r.URL.Path, "/api/memo-packs/")
+	if after, ok := strings.CutPrefix(rest, "slug/"); ok {
+		slug, suffix, _ := strings.Cut(after, "/")
+		id, current, err := store.ResolvePackSlug(slug)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "pack not found"})
+			return false
+		}
+		// Only public packs are redirected, so a former slug can't reveal a
+		// draft's name.
+		if slug != current && (r.Method == http.MethodGet || r.Method == http.MethodHead) && publicPackID(id) {
+			target := "/api/memo-packs/slug/" + current
+			if suffix != "" {
+				target += "/" + suffix
+			}
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return false
+		}
+		rest = id
+		if suffix != "" {
+			rest += "/" + suffix
+		}
+	} else {
+		seg, suffix, found := strings.Cut(rest, "/")
+		id := resolvePackID(seg)
+		if id == seg {
+			return true
+		}
+		rest = id
+		if found {
+			rest += "/" + suffix
+		}
+	}
+	r.URL.Path = "/api/memo-packs/" + rest
+	r.URL.RawPath = ""
+	return true
+}
@@ -0,0 +1,303 @@
+package memomarket
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Store is everything the API reads from and writes to persistent storage.
+// SQLiteStore is the implementation the server ships with; programs that
+// embed MemoMarket through NewServer can pass their own, and tests can wrap
+// one in a MockStore.
+//
+//go:generate go run gen_mockstore.go
+type Store interface {
+	// Ping reports whether storage is reachable, for /readyz.
+	Ping() error
+
+	// Users, sessions and API keys
+	CreateUser(username, passwordHash string) (*User, error)
+	GetUserByToken(token string) (*User, error)
+	CreateSession(sess *Session) error
+	GetSessionByRefreshToken(refreshToken string) (*Session, error)
+	RotateSessionTokens(sess *Session, oldRefreshToken string) (bool, error)
+	ListSessions(userID string) ([]Session, error)
+	DeleteSession(id, userID string) (bool, error)
+	PurgeSessions(cutoff string) (int, error)
+	GetUserByAPIKey(keyHash string) (*User, error)
+	CreateAPIKey(k *APIKey, keyHash string) error
+	ListAPIKeys(userID string) ([]APIKey, error)
+	DeleteAPIKey(id, userID string) (bool, error)
+	InsertServiceAccount(sa *ServiceAccount) error
+	GetServiceAccount(id string) (*ServiceAccount, error)
+	ListServiceAccounts() ([]ServiceAccount, error)
+	DeleteServiceAccount(id string) (bool, error)
+	ListServiceTokens(accountID string) ([]APIKey, error)
+	DeleteServiceToken(id, accountID string) (bool, error)
+	GetUserByID(id string) (*User, error)
+	FindUserBySkeleton(skeleton string) (string, error)
+	GetUserByUsername(username string) (*User, error)
+	GetUserProfile(username string) (*UserProfile, error)
+	UpdateUserProfile(userID string, req *ProfileReq) error
+	GetUserActivity(userID, since string) (*UserActivity, error)
+	SetUserTrustLevel(id, level string) (bool, error)
+	GetUserBan(userID string) (*UserBan, error)
+	BanUser(userID string, b *UserBan) error
+	UnbanUser(userID string) (bool, error)
+	DeactivateUser(userID, by string) (bool, error)
+	ReactivateUser(userID string) (bool, error)
+	IsUserDeactivated(userID string) bool
+	GetOIDCUser(issuer, subject string) (string, error)
+	LinkOIDCIdentity(issuer, subject, userID string) error
+	UnlinkOIDCIdentity(issuer, userID string) (bool, error)
+	ListUsers(role string, page, limit int) ([]User, int, error)
+	SetUserRole(id, role string) (bool, error)
+	EnsureAnonymousUser() (*User, error)
+
+	// Categories
+	ListCategories() ([]Category, error)
+	GetCategory(slug string) (*Category, error)
+	SaveCategory(c *Category) error
+	DeleteCategory(slug, into string) (bool, error)
+
+	// Templates
+	ListPackTemplates() ([]PackTemplate, error)
+	GetPackTemplate(slug string) (*PackTemplate, error)
+	SavePackTemplate(t *PackTemplate) error
+	SeedPackTemplate(t *PackTemplate) (bool, error)
+	DeletePackTemplate(slug string) (bool, error)
+
+	// Packs
+	InsertMemoPack(mp *MemoPack) error
+	InsertMemoPacks(packs []*MemoPack) error
+	SetPackEmbargo(packID, until string, viewerIDs []string) error
+	IsEmbargoViewer(packID, userID string) bool
+	ListEmbargoViewers(packID string) ([]string, error)
+	AddCollaborator(packID, userID, addedBy string) (bool, error)
+	RemoveCollaborator(packID, userID string) (bool, error)
+	IsCollaborator(packID, userID string) bool
+	ListCollaborators(packID string) ([]Collaborator, error)
+	InsertShareLink(l *ShareLink, tokenHash string) error
+	ListShareLinks(packID string) ([]ShareLink, error)
+	DeleteShareLink(packID, id string) (bool, error)
+	ShareLinkValid(packID, tokenHash string) bool
+	UpdateMemoPack(mp *MemoPack) error
+	PublishMemoPack(id string) (bool, error)
+	SetPackArchived(id, at string) error
+	PinMemoPack(id, authorID string, limit int) (bool, error)
+	UnpinMemoPack(id string) error
+	SetPackFeatured(id, at string) error
+	ListFeaturedPacks() ([]MemoPack, error)
+	ListRelatedCandidates(id string) ([]MemoPack, error)
+	CoDownloadCounts(packID string) (map[string]int, error)
+	SetPackCover(id, cover string) error
+	DeleteMemoPack(id, authorID string) error
+	GetMemoPack(id string) (*MemoPack, error)
+	PackIDExists(id string) bool
+	PackRowExists(id string) bool
+	ResolvePackSlug(slug string) (id, current string, err error)
+	InsertAttachment(a *Attachment) error
+	GetAttachment(id string) (*Attachment, error)
+	ListAttachments(packID string) ([]Attachment, error)
+	DeleteAttachment(id string) error
+	ListOrphanAttachments() ([]Attachment, error)
+	ListMemoPacks(q ListQuery) ([]MemoPack, int, error)
+	HasPackVariants(id string) bool
+	ListPackVariants(origID string) ([]PackVariant, error)
+	FindPopularPackBySkeleton(skeleton, authorID string, minDownloads int) (string, error)
+	FindDuplicatePack(hash string, sim int64, maxDistance int, authorID, excludeID string) (*DuplicateMatch, error)
+	IncrementMemoPackDownloads(id, version string) error
+	CreatePackClaim(packID, tokenHash string) error
+	ClaimPack(packID, tokenHash string, user *User) (bool, error)
+
+	// Download metrics
+	RecordDownloadEvent(packID, version, clientID, userID string) error
+	RecordInstallEvent(ev *InstallEvent) (bool, error)
+	CountClientInstalls(packID, client string) (int, error)
+	GetPackStats(packID string) (*PackStats, error)
+
+	// Reviews and stars
+	SaveReview(rv *Review) error
+	DeleteReview(packID, userID string) error
+	ListReviews(packID string, limit, offset int) ([]Review, int, error)
+	SetStar(packID, userID string, starred bool) error
+
+	// Version history
+	SaveMemoPackVersion(mp *MemoPack) error
+	GetMemoPackVersion(packID, version string) (*MemoPackVersion, error)
+	VersionAsOf(packID, at string) (*MemoPackVersion, error)
+	ListMemoPackVersions(packID string) ([]MemoPackVersion, error)
+	LatestStableVersion(packID string) (*MemoPackVersion, error)
+	PromoteMemoPackVersion(packID, version string) error
+
+	// Eval runs
+	InsertEvalRun(run *EvalRun) error
+	FinishEvalRun(run *EvalRun) error
+	ListEvalRuns(packID string) ([]EvalRun, error)
+
+	// Subscriptions
+	Subscribe(userID, packID, version, channel string) error
+	Unsubscribe(userID, packID string) error
+	MarkSubscriptionDownloaded(userID, packID, version string) error
+	ListSubscriberIDs(packID, channel string) ([]string, error)
+	ListPackUpdates(userID string) ([]PackUpdate, error)
+
+	// Webhooks
+	InsertWebhook(wh *Webhook) error
+	GetWebhook(id string) (*Webhook, error)
+	ListUserWebhooks(ownerID string) ([]Webhook, error)
+	ListPackWebhooks(packID string) ([]Webhook, error)
+	DeleteWebhook(id, ownerID string) (bool, error)
+	ReplaceChannelWebhooks(hooks []Webhook) error
+	ListWebhooksForEvent(event string, pack *MemoPack) ([]Webhook, error)
+	InsertWebhookDelivery(d *WebhookDelivery) error
+	UpdateWebhookDelivery(d *WebhookDelivery) error
+	ListDueWebhookDeliveries(now string, limit int) ([]WebhookDelivery, error)
+	ListWebhookDeliveries(webhookID string) ([]WebhookDelivery, error)
+
+	// Notifications
+	InsertNotification(n *Notification) error
+	ListNotifications(userID string, unreadOnly bool) ([]Notification, error)
+	ListAllNotifications(userID string) ([]Notification, error)
+	MarkNotificationsRead(userID string) error
+
+	// Jobs
+	InsertJob(j *Job) error
+	UpdateJob(j *Job) error
+	GetJob(id string) (*Job, error)
+	LatestUserJob(userID, kind string) (*Job, error)
+	ListDueJobs(now string, limit int) ([]Job, error)
+	RequeueRunningJobs() error
+
+	// Legal hold and retention
+	SetLegalHold(table, id string, held bool, reason string) (bool, error)
+	IsPackOnHold(packID string) bool
+
+	// Audit log
+	InsertAuditEntry(e *AuditEntry) error
+	ListAuditEntries(q AuditQuery) ([]AuditEntry, int, error)
+	ListLegalHolds() ([]LegalHold, error)
+	PurgeDeletedPacks(cutoff string) (int, error)
+	PurgeDownloadEvents(cutoff string) (int, error)
+	PurgeInstallEvents(cutoff string) (int, error)
+	PurgeWebhookDeliveries(cutoff string) (int, error)
+	PurgeJobs(cutoff string) (int, error)
+	PurgeAuditEntries(cutoff string) (int, error)
+
+	// Tag policy
+	ListTagRules() ([]TagRule, error)
+	SaveTagRule(t *TagRule) error
+	DeleteTagRule(tag string) (bool, error)
+	ListLivePackIDs() ([]string, error)
+	SaveRetaggedPack(mp *MemoPack) error
+	SaveRetaggedPacks(packs []*MemoPack) error
+	HasPendingJob(kind string) bool
+
+	// ETL
+	ListETLPacks(afterUpdated, afterID string, limit int) ([]ETLPackRecord, error)
+	ListETLEvents(afterID int64, limit int) ([]ETLEventRecord, []int64, error)
+
+	// Account export
+	ListAuthorPacks(authorID string, includeDeleted bool) ([]MemoPack, error)
+	ListUserReviews(userID string) ([]Review, error)
+	ListUserStars(userID string) ([]StarRecord, error)
+	ListUserSubscriptions(userID string) ([]SubscriptionInfo, error)
+	ListLastDownloads(userID string) ([]DownloadRecord, error)
+	ListUserDownloads(userID string) ([]DownloadRecord, error)
+
+	// Metrics
+	CountByPeriod(table, from string, keyLen int) (map[string]float64, error)
+	QueueDepth() (int, error)
+
+	// Vacations
+	GetVacation(userID string) (*Vacation, error)
+	SaveVacation(userID string, v *Vacation) error
+	DeleteVacation(userID string) error
+	GetMilestonePrefs(userID string) (*MilestonePrefs, error)
+	SaveMilestonePrefs(userID string, p *MilestonePrefs) error
+	ListActiveVacations(userIDs []string) (map[string]*Vacation, error)
+
+	// Verifications
+	InsertVerification(v *Verification) (*Verification, error)
+	GetVerification(id string) (*Verification, error)
+	ListUserVerifications(userID string) ([]Verification, error)
+	VerifiedSubjectOwner(kind, subject string) (string, error)
+	MarkVerified(id string) error
+	DeleteVerification(id, userID string) error
+	ListVerifiedIdentities(userIDs []string) (map[string][]VerifiedIdentity, error)
+
+	// Collections
+	InsertCollection(c *Collection) error
+	UpdateCollection(c *Collection) error
+	DeleteCollection(id string) error
+	GetCollection(id string) (*Collection, error)
+	ListUserCollections(ownerID string) ([]Collection, error)
+	SetCollectionBundleJob(id, jobID string) error
+
+	// Invites
+	InsertInvite(inv *Invite) error
+	ListInvites() ([]Invite, error)
+	DeleteInvite(code string) (bool, error)
+	UseInvite(code string) (bool, error)
+	ReleaseInvite(code string) error
+}
+
+// store is the Store the handlers use, set by NewServer.
+var store Store
+
+// SQLiteStore keeps everything in one SQLite database.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens, creating and migrating if needed, the database in
+// dataDir.
+func OpenSQLiteStore(dataDir string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, err
+	}
+	dbPath := filepath.Join(dataDir, "memomarket.db")
+	return openSQLiteStore(dbPath + "?_journal_mode=WAL&_foreign_keys=ON")
+}
+
+// NewMemoryStore returns a SQLiteStore on a fresh in-memory database, for
+// tests and other short-lived embedded servers. Its data is lost on Close.
+func NewMemoryStore() (*SQLiteStore, error) {
+	return openSQLiteStore("file:memomarket-" + newID() + "?mode=memory&_foreign_keys=ON")
+}
+
+func openSQLiteStore(dsn string) (*SQLiteStore, error) {
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %v", err)
+	}
+	db.SetMaxOpenConns(1) // SQLite single-writer
+	db.SetMaxIdleConns(2)
+	db.SetConnMaxLifetime(0)
+	s := &SQLiteStore{db: db}
+	s.migrate()
+	return s, nil
+}
+
+// Ping runs a trivial query, so a locked or lost database file shows.
+func (s *SQLiteStore) Ping() error {
+	var one int
+	return s.db.QueryRow(`SELECT 1`).Scan(&one)
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// sqliteDB returns the database behind the store for the features that work
+// on the SQLite file itself, replication and the self-check, or nil when the
+// server runs on another Store.
+func sqliteDB() *sql.DB {
+	if s, ok := store.(*SQLiteStore); ok {
+		return s.db
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package memomarket
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Large responses are encoded and written piece by piece instead of being
+// marshaled into one buffer, and downloads can be capped in bandwidth so a
+// few big transfers can't saturate the node.
+
+// streamThreshold is the estimated body size above which a download is
+// streamed. Streamed responses skip ETag and Range support; the checksum
+// arrives as a trailer.
+const streamThreshold = 1 << 20
+
+// streamChunk is how much is buffered before each flush to the client.
+const streamChunk = 32 << 10
+
+// shouldStream reports whether a pack download of about size bytes is
+// streamed. HEAD, ranged and conditional requests need the whole body up
+// front and are served from memory.
+func shouldStream(r *http.Request, size int) bool {
+	if size < streamThreshold || r.Method != http.MethodGet {
+		return false
+	}
+	for _, h := range []string{"Range", "If-Range", "If-None-Match", "If-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		if r.Header.Get(h) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// flushWriter flushes the response after every write.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	return n, err
+}
+
+// streamJSON writes v as JSON without holding the whole body in memory. The
+// X-Content-SHA256 checksum is sent as a trailer.
+func streamJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Trailer", "X-Content-SHA256")
+	w.WriteHeader(http.StatusOK)
+	hash := sha256.New()
+	buf := bufio.NewWriterSize(io.MultiWriter(flushWriter{w}, hash), streamChunk)
+	if err := encodeJSONStream(buf, reflect.ValueOf(v)); err != nil {
+		return
+	}
+	buf.WriteByte('\n')
+	if buf.Flush() == nil {
+		w.Header().Set("X-Content-SHA256", hex.EncodeToString(hash.Sum(nil)))
+	}
+}
+
+var jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+
+// encodeJSONStream writes v as encoding/json would, but structs and slices
+// are written field by field and element by element, so only one leaf value
+// is marshaled at a time.
+func encodeJSONStream(w *bufio.Writer, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			_, err := w.WriteString("null")
+			return err
+		}
+		v = v.Elem()
+	}
+	if v.Type().Implements(jsonMarshalerType) || reflect.PointerTo(v.Type()).Implements(jsonMarshalerType) {
+		return writeMarshaled(w, v.Interface())
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		w.WriteByte('{')
+		first := true
+		if err := encodeStructFields(w, v, &first, nil); err != nil {
+			return err
+		}
+		return w.WriteByte('}')
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+			return writeMarshaled(w, v.Interface())
+		}
+		w.WriteByte('[')
+		for i := range v.Len() {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := encodeJSONStream(w, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return w.WriteByte(']')
+	default:
+		return writeMarshaled(w, v.Interface())
+	}
+}
+
+// encodeStructFields writes the exported fields of v, inlining untagged
+// embedded structs. As with encoding/json, a field of an embedded struct is
+// left out when an outer one has its name; shadowed holds those names.
+func encodeStructFields(w *bufio.Writer, v reflect.Value, first *bool, shadowed map[string]bool) error {
+	t := v.Type()
+	own := map[string]bool{}
+	for k := range shadowed {
+		own[k] = true
+	}
+	for i := range t.NumField() {
+		if f := t.Field(i); !f.Anonymous || f.Tag.Get("json") != "" {
+			own[cmp.Or(strings.Split(f.Tag.Get("json"), ",")[0], f.Name)] = true
+		}
+	}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := encodeStructFields(w, fv, first, own); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !f.IsExported() || shadowed[cmp.Or(name, f.Name)] {
+			continue
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyJSONValue(fv) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !*first {
+			w.WriteByte(',')
+		}
+		*first = false
+		if err := writeMarshaled(w, name); err != nil {
+			return err
+		}
+		w.WriteByte(':')
+		if strings.Contains(opts, "string") {
+			if err := writeMarshaled(w, fv.Interface()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := encodeJSONStream(w, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEmptyJSONValue matches encoding/json's omitempty rule.
+func isEmptyJSONValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+func writeMarshaled(w *bufio.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// throttledResponse caps the bytes per second written to one response.
+type throttledResponse struct {
+	http.ResponseWriter
+	rate    int
+	start   time.Time
+	written int
+}
+
+// limitBandwidth wraps w with the configured per-download bandwidth cap,
+// if any.
+func limitBandwidth(w http.ResponseWriter) http.ResponseWriter {
+	if rateLimits.DownloadBytesPerSecond <= 0 {
+		return w
+	}
+	return &throttledResponse{ResponseWriter: w, rate: rateLimits.DownloadBytesPerSecond, start: time.Now()}
+}
+
+func (t *throttledResponse) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		n := min(len(p), t.rate/10+1)
+		if due := t.start.Add(time.Duration(float64(t.written) / float64(t.rate) * float64(time.Second))); time.Until(due) > 0 {
+			time.Sleep(time.Until(due))
+		}
+		m, err := t.ResponseWriter.Write(p[:n])
+		total += m
+		t.written += m
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
+
+func (t *throttledResponse) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+func (t *throttledResponse) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// serveFile serves a file on disk with the same checksum headers as
+// serveBytes, reading it twice instead of into memory.
+func serveFile(w http.ResponseWriter, r *http.Request, contentType, path string, modTime time.Time) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+checksum+`"`)
+	w.Header().Set("X-Content-SHA256", checksum)
+	http.ServeContent(w, r, "", modTime, f)
+	return nil
+}
@@ -0,0 +1,232 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Templates are starter pack skeletons, such as a coding assistant or a
+// journaling companion, that clients offer when someone creates a pack:
+// the client fills in the name and publishes the template's pack as its
+// own. Admins curate them with PUT and DELETE /api/admin/templates/{slug}.
+// At startup every DATA_DIR/templates/{slug}.json not yet in the database is
+// added, so a file works as a seed the API can then edit. A new data dir
+// gets files for defaultTemplates.
+
+const templateDirName = "templates"
+
+var templateSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+const (
+	maxTemplateNameChars        = 64
+	maxTemplateDescriptionChars = 280
+)
+
+var defaultTemplates = map[string]PackTemplateReq{
+	"coding-assistant": {
+		Name:        "Coding assistant",
+		Description: "Remembers a project's conventions and the decisions made while working on it.",
+		Position:    0,
+		Pack: PublishMemoPackReq{
+			SystemPrompt: "You are a careful pair programmer. Follow the project's conventions, explain trade-offs briefly and keep changes small.",
+			Rules: []MemoRule{
+				{Title: "Conventions", UpdateRule: "When the user states a coding convention, record it in the Conventions memo."},
+				{Title: "Decisions", UpdateRule: "When a design decision is made, add it to the Decisions memo with the reason."},
+			},
+			Memos: []Memo{
+				{Title: "Conventions", Content: "Language, formatting, naming and testing conventions of the project."},
+				{Title: "Decisions", Content: "Design decisions and why they were made."},
+			},
+			Category: "coding",
+		},
+	},
+	"journaling-companion": {
+		Name:        "Journaling companion",
+		Description: "A gentle companion for daily reflection that remembers what matters to you.",
+		Position:    1,
+		Pack: PublishMemoPackReq{
+			SystemPrompt: "You are a warm, curious journaling companion. Ask one open question at a time and reflect back what you hear without judging.",
+			Rules: []MemoRule{
+				{Title: "Themes", UpdateRule: "When a theme comes up again across entries, note it in the Themes memo."},
+				{Title: "Goals", UpdateRule: "When the user sets or changes a personal goal, update the Goals memo."},
+			},
+			Memos: []Memo{
+				{Title: "Themes", Content: "Recurring themes in the user's entries."},
+				{Title: "Goals", Content: "Goals the user is working towards."},
+			},
+			Category: "productivity",
+		},
+	},
+	"writing-coach": {
+		Name:        "Writing coach",
+		Description: "Keeps track of a writer's voice, audience and style preferences.",
+		Position:    2,
+		Pack: PublishMemoPackReq{
+			SystemPrompt: "You are an encouraging writing coach. Suggest concrete edits and keep the writer's voice.",
+			Rules: []MemoRule{
+				{Title: "Style", UpdateRule: "When the user accepts or rejects a style suggestion, record the preference in the Style memo."},
+			},
+			Memos: []Memo{
+				{Title: "Style", Content: "Voice, tone and style preferences."},
+				{Title: "Audience", Content: "Who the writing is for."},
+			},
+			Category: "writing",
+		},
+	},
+}
+
+// validatePackTemplate normalizes req and reports why it can't be saved.
+// Settings that only make sense for a published pack are dropped.
+func validatePackTemplate(req *PackTemplateReq) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if utf8.RuneCountInString(req.Name) > maxTemplateNameChars || utf8.RuneCountInString(req.Description) > maxTemplateDescriptionChars {
+		return fmt.Errorf("name is limited to %d characters and description to %d", maxTemplateNameChars, maxTemplateDescriptionChars)
+	}
+	req.Pack.Embargo = nil
+	req.Pack.VariantOf = ""
+	req.Pack.Draft = false
+	if err := validateRules(req.Pack.Rules); err != nil {
+		return err
+	}
+	if errs := checkContentLimits(req.Pack.SystemPrompt, req.Pack.Rules, req.Pack.Memos); len(errs) > 0 {
+		return fmt.Errorf("%s: %s", errs[0].Field, errs[0].Message)
+	}
+	return nil
+}
+
+// readTemplateFile reads a template seed file, named by its slug.
+func readTemplateFile(path string) (*PackTemplate, error) {
+	slug := strings.TrimSuffix(filepath.Base(path), ".json")
+	if !templateSlugPattern.MatchString(slug) {
+		return nil, fmt.Errorf("file names must be a slug of 1-64 lowercase letters, digits and hyphens")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var req PackTemplateReq
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	if err := validatePackTemplate(&req); err != nil {
+		return nil, err
+	}
+	return &PackTemplate{Slug: slug, Name: req.Name, Description: req.Description, Position: req.Position, Pack: req.Pack}, nil
+}
+
+// seedPackTemplates adds the templates in dir that the database doesn't
+// have yet, first writing defaultTemplates there when dir doesn't exist.
+// Files that can't be read are logged and skipped.
+func seedPackTemplates(dir string) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		os.MkdirAll(dir, 0755)
+		for slug, t := range defaultTemplates {
+			data, _ := json.MarshalIndent(t, "", "  ")
+			if err := os.WriteFile(filepath.Join(dir, slug+".json"), append(data, '\n'), 0644); err != nil {
+				log.Printf("templates: %v", err)
+			}
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for _, path := range files {
+		t, err := readTemplateFile(path)
+		if err != nil {
+			log.Printf("templates: skipping %s: %v", filepath.Base(path), err)
+			continue
+		}
+		if _, err := store.SeedPackTemplate(t); err != nil {
+			log.Printf("templates: seeding %s: %v", t.Slug, err)
+		}
+	}
+}
+
+// GET /api/templates — the starter templates in display order (public).
+// GET /api/templates/{slug} returns one.
+func handleTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	if slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/templates"), "/"); slug != "" {
+		t, err := store.GetPackTemplate(slug)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "template not found"})
+			return
+		}
+		setCacheHeaders(w, cacheListing, false)
+		writeJSON(w, http.StatusOK, t)
+		return
+	}
+	templates, err := store.ListPackTemplates()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list templates"})
+		return
+	}
+	setCacheHeaders(w, cacheListing, false)
+	writeJSON(w, http.StatusOK, templates)
+}
+
+// PUT /api/admin/templates/{slug} — add or replace a template. DELETE
+// removes it (admin only).
+func handleAdminTemplate(w http.ResponseWriter, r *http.Request) {
+	admin := currentUser(r)
+	slug := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/admin/templates/")))
+	switch r.Method {
+	case http.MethodPut:
+		if !templateSlugPattern.MatchString(slug) {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "template slugs are 1-64 lowercase letters, digits and hyphens"})
+			return
+		}
+		var req PackTemplateReq
+		limitRequestBody(w, r)
+		if err := decodePackBody(r, &req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if err := validatePackTemplate(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if err := validatePackCategory(&req.Pack); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		t := &PackTemplate{Slug: slug, Name: req.Name, Description: req.Description, Position: req.Position, Pack: req.Pack}
+		if err := store.SavePackTemplate(t); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save template"})
+			return
+		}
+		recordAudit(r, admin, auditTemplateSet, "template", slug, "")
+		saved, err := store.GetPackTemplate(slug)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load template"})
+			return
+		}
+		writeJSON(w, http.StatusOK, saved)
+	case http.MethodDelete:
+		found, err := store.DeletePackTemplate(slug)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete template"})
+			return
+		}
+		if !found {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "template not found"})
+			return
+		}
+		recordAudit(r, admin, auditTemplateDelete, "template", slug, "")
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
@@ -0,0 +1,224 @@
+// Package testserver runs the full MemoMarket API in-process on an
+// in-memory database, so SDKs, CLIs and plugins can run integration tests
+// against a real server:
+//
+//	srv := testserver.New(t, memomarket.ServerOptions{})
+//	alice := srv.CreateUser("alice")
+//	pack := srv.CreatePack(alice, memomarket.PublishMemoPackReq{Name: "Go style", ...})
+//	client := mysdk.New(srv.URL, alice.Token)
+//
+// The server's settings live in package variables and memomarket.NewServer
+// refuses to build a second Server while one is open, so New waits until
+// any earlier Server in the process has been closed; tests using it can't
+// run in parallel with each other. Everything is torn down when the test ends.
+package testserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/n0n4we/memomarket"
+)
+
+// Password is the password of every user made by CreateUser.
+const Password = "testserver-password"
+
+// running is held from New until the server's test ends.
+var running sync.Mutex
+
+// Server is a MemoMarket API listening on a local port.
+type Server struct {
+	// URL is the base URL of the API, such as http://127.0.0.1:41234.
+	URL string
+	// Store is the server's database, for seeding or inspecting what the
+	// HTTP API doesn't expose.
+	Store *memomarket.SQLiteStore
+	// API is the server's handler.
+	API *memomarket.Server
+
+	t    testing.TB
+	http *httptest.Server
+}
+
+// New starts a server for the rest of t. Background workers never run, so
+// jobs and webhook deliveries happen only when the test calls RunPending.
+// DataDir defaults to a temporary directory. Without a Config the server
+// gets DefaultConfig.
+func New(t testing.TB, opts memomarket.ServerOptions) *Server {
+	t.Helper()
+	running.Lock()
+	st, err := memomarket.NewMemoryStore()
+	if err != nil {
+		running.Unlock()
+		t.Fatalf("testserver: open store: %v", err)
+	}
+	opts.NoBackgroundWorkers = true
+	if opts.DataDir == "" {
+		opts.DataDir = t.TempDir()
+	}
+	if opts.Config == nil {
+		opts.Config = DefaultConfig()
+	}
+	api, err := memomarket.NewServer(st, opts)
+	if err != nil {
+		st.Close()
+		running.Unlock()
+		t.Fatalf("testserver: start: %v", err)
+	}
+	hs := httptest.NewServer(api)
+	s := &Server{URL: hs.URL, Store: st, API: api, t: t, http: hs}
+	t.Cleanup(func() {
+		hs.Close()
+		api.Close()
+		st.Close()
+		running.Unlock()
+	})
+	return s
+}
+
+// DefaultConfig is the configuration New uses when ServerOptions has none,
+// suited to tests: request limits high enough not to get in the way, and no
+// daily publish cap or link or attachment ban for new accounts. Tests that
+// need another setting change it in a fresh copy:
+//
+//	cfg := testserver.DefaultConfig()
+//	cfg.InviteOnly = true
+//	srv := testserver.New(t, memomarket.ServerOptions{Config: cfg})
+func DefaultConfig() *memomarket.ServerConfig {
+	unlimited, allowed := 0, true
+	open := &memomarket.TrustLevelConfig{DailyPublishes: &unlimited, ExternalLinks: &allowed, Attachments: &allowed}
+	return &memomarket.ServerConfig{
+		Name: "testserver",
+		RateLimits: &memomarket.RateLimitConfig{
+			DownloadsPerMinute: 100000, DownloadBurst: 100000, PackDownloadsPerHour: 100000,
+			RequestsPerMinute: 100000, RequestBurst: 100000, AuthRequestsPerMinute: 100000, AuthRequestBurst: 100000,
+		},
+		TrustLevels: &memomarket.TrustConfig{New: open, Basic: open},
+	}
+}
+
+// RunPending runs the jobs and webhook deliveries that are due, such as
+// collection bundles and embargo releases, and returns how many ran.
+func (s *Server) RunPending() int {
+	return s.API.RunPending()
+}
+
+// CreateUser registers username with Password and returns it logged in:
+// Token authenticates its requests.
+func (s *Server) CreateUser(username string) *memomarket.User {
+	s.t.Helper()
+	var user memomarket.User
+	s.mustDo(nil, http.MethodPost, "/api/register", memomarket.RegisterReq{Username: username, Password: Password}, http.StatusCreated, &user)
+	return &user
+}
+
+// CreateAdmin is CreateUser for a user with the admin role.
+func (s *Server) CreateAdmin(username string) *memomarket.User {
+	s.t.Helper()
+	user := s.CreateUser(username)
+	if _, err := s.Store.SetUserRole(user.ID, "admin"); err != nil {
+		s.t.Fatalf("testserver: make %s an admin: %v", username, err)
+	}
+	user.Role = "admin"
+	return user
+}
+
+// CreatePack publishes a pack as user. A request without memos gets one, so
+// CreatePack(user, PublishMemoPackReq{Name: "x"}) is enough for most tests.
+func (s *Server) CreatePack(user *memomarket.User, req memomarket.PublishMemoPackReq) *memomarket.MemoPack {
+	s.t.Helper()
+	if req.Description == "" {
+		req.Description = "Created by testserver."
+	}
+	if len(req.Memos) == 0 {
+		req.Memos = []memomarket.Memo{{Title: "Example", Content: "An example memo."}}
+	}
+	var pack memomarket.MemoPack
+	s.mustDo(user, http.MethodPost, "/api/memo-packs", req, http.StatusCreated, &pack)
+	return &pack
+}
+
+// Do sends a request to the API as user, or anonymously when user is nil.
+// A non-nil body is sent as JSON, or as is when it is an io.Reader; PATCH
+// bodies as JSON merge patches. PUT and PATCH overwrite whatever revision
+// is current. The caller closes the response body.
+func (s *Server) Do(user *memomarket.User, method, path string, body any) *http.Response {
+	s.t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		r = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("testserver: encode %s %s: %v", method, path, err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.URL+path, r)
+	if err != nil {
+		s.t.Fatalf("testserver: %s %s: %v", method, path, err)
+	}
+	if body != nil {
+		if method == http.MethodPatch {
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+		} else {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if method == http.MethodPut || method == http.MethodPatch {
+		req.Header.Set("If-Match", "*")
+	}
+	if user != nil {
+		req.Header.Set("Authorization", "Bearer "+user.Token)
+	}
+	resp, err := s.http.Client().Do(req)
+	if err != nil {
+		s.t.Fatalf("testserver: %s %s: %v", method, path, err)
+	}
+	return resp
+}
+
+// DoJSON is Do that decodes a JSON response into out, when out is not nil,
+// and returns the status code.
+func (s *Server) DoJSON(user *memomarket.User, method, path string, body, out any) int {
+	s.t.Helper()
+	resp := s.Do(user, method, path, body)
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			s.t.Fatalf("testserver: decode %s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// mustDo is DoJSON that fails the test unless the response has status want.
+func (s *Server) mustDo(user *memomarket.User, method, path string, body any, want int, out any) {
+	s.t.Helper()
+	resp := s.Do(user, method, path, body)
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != want {
+		s.t.Fatalf("testserver: %s %s: %s", method, path, describe(resp.StatusCode, data))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		s.t.Fatalf("testserver: decode %s %s: %v", method, path, err)
+	}
+}
+
+func describe(status int, body []byte) string {
+	msg := fmt.Sprintf("status %d", status)
+	if text := strings.TrimSpace(string(body)); text != "" {
+		msg += ": " + text
+	}
+	return msg
+}
@@ -0,0 +1,645 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A TOML codec for packs, alongside the YAML one. The encoder writes scalars
+// and inline arrays as key = value, objects as [tables] and arrays of
+// objects as [[arrays of tables]], with multi-line strings in """ blocks.
+// TOML has no null, so null fields are left out. The parser reads TOML 1.0;
+// dates and times come back as strings.
+
+// encodeTOML writes v, which must encode to a JSON object, as TOML.
+func encodeTOML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := readDoc(data)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := doc.(docObject)
+	if !ok {
+		return nil, errors.New("only objects can be written as TOML")
+	}
+	var b strings.Builder
+	writeTOMLTable(&b, obj, nil)
+	return []byte(strings.TrimPrefix(b.String(), "\n")), nil
+}
+
+// writeTOMLTable writes the keys of the table at path, then its subtables.
+func writeTOMLTable(b *strings.Builder, obj docObject, path []string) {
+	var tables []docField
+	for _, f := range obj {
+		switch v := f.val.(type) {
+		case nil:
+			continue
+		case docObject:
+			if len(v) > 0 {
+				tables = append(tables, f)
+				continue
+			}
+		case []any:
+			if tomlTableArray(v) {
+				tables = append(tables, f)
+				continue
+			}
+		}
+		fmt.Fprintf(b, "%s = %s\n", tomlKey(f.key), tomlValue(f.val, true))
+	}
+	for _, f := range tables {
+		sub := append(path[:len(path):len(path)], f.key)
+		switch v := f.val.(type) {
+		case docObject:
+			fmt.Fprintf(b, "\n[%s]\n", tomlPath(sub))
+			writeTOMLTable(b, v, sub)
+		case []any:
+			for _, e := range v {
+				fmt.Fprintf(b, "\n[[%s]]\n", tomlPath(sub))
+				writeTOMLTable(b, e.(docObject), sub)
+			}
+		}
+	}
+}
+
+// tomlTableArray reports whether arr is written as an array of tables.
+func tomlTableArray(arr []any) bool {
+	for _, e := range arr {
+		if _, ok := e.(docObject); !ok {
+			return false
+		}
+	}
+	return len(arr) > 0
+}
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(k string) string {
+	if tomlBareKey.MatchString(k) {
+		return k
+	}
+	return tomlString(k, false)
+}
+
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = tomlKey(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+// tomlValue writes v inline. Top-level strings may use multi-line form.
+func tomlValue(v any, top bool) string {
+	switch v := v.(type) {
+	case string:
+		return tomlString(v, top)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		var parts []string
+		for _, e := range v {
+			if e != nil {
+				parts = append(parts, tomlValue(e, false))
+			}
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case docObject:
+		var parts []string
+		for _, f := range v {
+			if f.val != nil {
+				parts = append(parts, tomlKey(f.key)+" = "+tomlValue(f.val, false))
+			}
+		}
+		if len(parts) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(parts, ", ") + " }"
+	}
+	return fmt.Sprint(v)
+}
+
+// tomlString quotes s as a basic string, or as a multi-line one when
+// multiline is set and s spans lines.
+func tomlString(s string, multiline bool) string {
+	multiline = multiline && strings.Contains(s, "\n")
+	var b strings.Builder
+	if multiline {
+		b.WriteString(`"""` + "\n")
+	} else {
+		b.WriteByte('"')
+	}
+	quotes := 0
+	for _, r := range s {
+		switch {
+		case r == '"' && multiline && quotes < 2:
+			b.WriteRune(r)
+			quotes++
+			continue
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n' && multiline:
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteRune(r)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+		quotes = 0
+	}
+	if multiline {
+		// A quote right before the closing delimiter would merge with it.
+		out := b.String()
+		if quotes > 0 {
+			out = out[:len(out)-quotes] + strings.Repeat(`\"`, quotes)
+		}
+		return out + `"""`
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// parseTOML reads a TOML document into map[string]any, []any, string,
+// json.Number and bool.
+func parseTOML(data []byte) (any, error) {
+	p := &tomlParser{s: strings.TrimPrefix(string(data), "\ufeff"), kinds: map[uintptr]tomlKind{}}
+	root := map[string]any{}
+	if err := p.parse(root); err != nil {
+		return nil, fmt.Errorf("line %d: %v", strings.Count(p.s[:min(p.i, len(p.s))], "\n")+1, err)
+	}
+	return root, nil
+}
+
+type tomlParser struct {
+	s string
+	i int
+	// kinds records how each table was made, so one can't be defined twice
+	// or extended where TOML doesn't allow it.
+	kinds map[uintptr]tomlKind
+}
+
+// tomlKind is how a table came about.
+type tomlKind int
+
+const (
+	// tomlImplicit tables were named on the way to a header's table, and
+	// may still get a header of their own.
+	tomlImplicit tomlKind = iota
+	tomlHeader
+	tomlDotted
+	// tomlInline tables are complete where they are written.
+	tomlInline
+	tomlArrayElem
+)
+
+func tableID(m map[string]any) uintptr { return reflect.ValueOf(m).Pointer() }
+
+// newTable makes a table of kind.
+func (p *tomlParser) newTable(kind tomlKind) map[string]any {
+	t := map[string]any{}
+	p.kinds[tableID(t)] = kind
+	return t
+}
+
+// closeValue marks the tables in v, the value of a key, as inline.
+func (p *tomlParser) closeValue(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		p.kinds[tableID(v)] = tomlInline
+		for _, e := range v {
+			p.closeValue(e)
+		}
+	case []any:
+		for _, e := range v {
+			p.closeValue(e)
+		}
+	}
+}
+
+func (p *tomlParser) peek(prefix string) bool { return strings.HasPrefix(p.s[p.i:], prefix) }
+
+// skip moves past spaces and tabs, and past newlines and comments too when
+// lines is set.
+func (p *tomlParser) skip(lines bool) {
+	for p.i < len(p.s) {
+		switch c := p.s[p.i]; {
+		case c == ' ' || c == '\t':
+			p.i++
+		case c == '#':
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i++
+			}
+		case lines && (c == '\n' || c == '\r'):
+			p.i++
+		default:
+			return
+		}
+	}
+}
+
+// endOfLine consumes the rest of a line after a statement.
+func (p *tomlParser) endOfLine() error {
+	p.skip(false)
+	switch {
+	case p.i >= len(p.s):
+		return nil
+	case p.peek("\n"):
+		p.i++
+		return nil
+	case p.peek("\r\n"):
+		p.i += 2
+		return nil
+	}
+	return errors.New("expected a new line")
+}
+
+func (p *tomlParser) parse(root map[string]any) error {
+	current := root
+	for {
+		p.skip(true)
+		if p.i >= len(p.s) {
+			return nil
+		}
+		if p.peek("[") {
+			array := p.peek("[[")
+			if array {
+				p.i += 2
+			} else {
+				p.i++
+			}
+			path, err := p.keyPath()
+			if err != nil {
+				return err
+			}
+			closing := "]"
+			if array {
+				closing = "]]"
+			}
+			p.skip(false)
+			if !p.peek(closing) {
+				return fmt.Errorf("expected %s", closing)
+			}
+			p.i += len(closing)
+			if current, err = p.openTable(root, path, array); err != nil {
+				return err
+			}
+			if err := p.endOfLine(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.keyValue(current); err != nil {
+			return err
+		}
+		if err := p.endOfLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// openTable finds or makes the table a [path] or [[path]] header names.
+func (p *tomlParser) openTable(root map[string]any, path []string, array bool) (map[string]any, error) {
+	m := root
+	for _, k := range path[:len(path)-1] {
+		var err error
+		if m, err = p.descend(m, k, tomlImplicit); err != nil {
+			return nil, err
+		}
+	}
+	last := path[len(path)-1]
+	if !array {
+		switch t := m[last].(type) {
+		case nil:
+			return p.descend(m, last, tomlHeader)
+		case map[string]any:
+			if p.kinds[tableID(t)] == tomlImplicit {
+				p.kinds[tableID(t)] = tomlHeader
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("table %q is already defined", tomlPath(path))
+	}
+	t := p.newTable(tomlArrayElem)
+	switch v := m[last].(type) {
+	case nil:
+		m[last] = []any{t}
+	case []any:
+		if !p.tableArray(v) {
+			return nil, fmt.Errorf("key %q is already defined as an array", tomlPath(path))
+		}
+		m[last] = append(v, t)
+	default:
+		return nil, fmt.Errorf("key %q is already defined", tomlPath(path))
+	}
+	return t, nil
+}
+
+// tableArray reports whether arr was made by [[headers]] rather than
+// written as a value.
+func (p *tomlParser) tableArray(arr []any) bool {
+	if len(arr) == 0 {
+		return false
+	}
+	t, ok := arr[0].(map[string]any)
+	return ok && p.kinds[tableID(t)] == tomlArrayElem
+}
+
+// descend returns the table under key k of m, making one of kind if needed.
+// For an array of tables it is the last one. Dotted keys only reach into
+// tables other dotted keys made; headers into any but inline ones.
+func (p *tomlParser) descend(m map[string]any, k string, kind tomlKind) (map[string]any, error) {
+	switch v := m[k].(type) {
+	case nil:
+		t := p.newTable(kind)
+		m[k] = t
+		return t, nil
+	case map[string]any:
+		switch p.kinds[tableID(v)] {
+		case tomlInline:
+			return nil, fmt.Errorf("table %q is an inline table and can't be extended", k)
+		case tomlDotted:
+			return v, nil
+		}
+		if kind != tomlDotted {
+			return v, nil
+		}
+		return nil, fmt.Errorf("table %q is already defined", k)
+	case []any:
+		if kind != tomlDotted && p.tableArray(v) {
+			return v[len(v)-1].(map[string]any), nil
+		}
+	}
+	return nil, fmt.Errorf("key %q is already defined as a value", k)
+}
+
+// keyValue reads key = value into table.
+func (p *tomlParser) keyValue(table map[string]any) error {
+	path, err := p.keyPath()
+	if err != nil {
+		return err
+	}
+	p.skip(false)
+	if !p.peek("=") {
+		return errors.New("expected =")
+	}
+	p.i++
+	p.skip(false)
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	p.closeValue(v)
+	for _, k := range path[:len(path)-1] {
+		if table, err = p.descend(table, k, tomlDotted); err != nil {
+			return err
+		}
+	}
+	last := path[len(path)-1]
+	if _, dup := table[last]; dup {
+		return fmt.Errorf("key %q is already defined", last)
+	}
+	table[last] = v
+	return nil
+}
+
+// keyPath reads a key, which may be dotted and quoted.
+func (p *tomlParser) keyPath() ([]string, error) {
+	var path []string
+	for {
+		p.skip(false)
+		var k string
+		switch {
+		case p.peek(`"`) || p.peek("'"):
+			s, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			k = s
+		default:
+			start := p.i
+			for p.i < len(p.s) && (isAlnum(p.s[p.i]) || p.s[p.i] == '_' || p.s[p.i] == '-') {
+				p.i++
+			}
+			if p.i == start {
+				return nil, errors.New("expected a key")
+			}
+			k = p.s[start:p.i]
+		}
+		path = append(path, k)
+		p.skip(false)
+		if !p.peek(".") {
+			return path, nil
+		}
+		p.i++
+	}
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *tomlParser) value() (any, error) {
+	if p.i >= len(p.s) {
+		return nil, errors.New("expected a value")
+	}
+	switch p.s[p.i] {
+	case '"', '\'':
+		return p.quoted()
+	case '[':
+		p.i++
+		arr := []any{}
+		for {
+			p.skip(true)
+			if p.peek("]") {
+				p.i++
+				return arr, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+			p.skip(true)
+			switch {
+			case p.peek(","):
+				p.i++
+			case !p.peek("]"):
+				return nil, errors.New("expected , or ] in an array")
+			}
+		}
+	case '{':
+		p.i++
+		table := map[string]any{}
+		for {
+			p.skip(true)
+			if p.peek("}") {
+				p.i++
+				return table, nil
+			}
+			if err := p.keyValue(table); err != nil {
+				return nil, err
+			}
+			p.skip(true)
+			switch {
+			case p.peek(","):
+				p.i++
+			case !p.peek("}"):
+				return nil, errors.New("expected , or } in an inline table")
+			}
+		}
+	}
+	return p.bareValue()
+}
+
+var (
+	tomlDatePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?([Zz]|[-+]\d{2}:\d{2})?)?|^\d{2}:\d{2}(:\d{2}(\.\d+)?)?`)
+	tomlIntPattern  = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)$`)
+)
+
+// bareValue reads a boolean, number, or date or time, which is kept as
+// a string.
+func (p *tomlParser) bareValue() (any, error) {
+	rest := p.s[p.i:]
+	if m := tomlDatePattern.FindString(rest); m != "" {
+		p.i += len(m)
+		return m, nil
+	}
+	end := strings.IndexAny(rest, " \t\r\n,]}#")
+	if end < 0 {
+		end = len(rest)
+	}
+	tok := rest[:end]
+	p.i += end
+	switch {
+	case tok == "true":
+		return true, nil
+	case tok == "false":
+		return false, nil
+	case strings.HasPrefix(tok, "0x") || strings.HasPrefix(tok, "0o") || strings.HasPrefix(tok, "0b"):
+		n, err := strconv.ParseInt(tok, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", tok)
+		}
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	case tomlIntPattern.MatchString(tok):
+		n, err := strconv.ParseInt(strings.ReplaceAll(tok, "_", ""), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", tok)
+		}
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	}
+	if strings.Contains(tok, "inf") || strings.Contains(tok, "nan") {
+		return nil, errors.New("inf and nan are not supported")
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(tok, "_", ""), 64)
+	if tok == "" || err != nil {
+		return nil, fmt.Errorf("invalid value %q", tok)
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
+
+// quoted reads a basic, literal or multi-line string.
+func (p *tomlParser) quoted() (string, error) {
+	quote := p.s[p.i : p.i+1]
+	multi := p.peek(strings.Repeat(quote, 3))
+	if multi {
+		p.i += 3
+		// A newline right after the opening delimiter is trimmed.
+		if p.peek("\r\n") {
+			p.i += 2
+		} else if p.peek("\n") {
+			p.i++
+		}
+	} else {
+		p.i++
+	}
+	var b strings.Builder
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case multi && p.peek(strings.Repeat(quote, 3)):
+			// Up to two quotes may sit right before the closing delimiter.
+			n := 3
+			for n < 5 && p.peek(strings.Repeat(quote, n+1)) {
+				n++
+			}
+			b.WriteString(strings.Repeat(quote, n-3))
+			p.i += n
+			return b.String(), nil
+		case !multi && c == quote[0]:
+			p.i++
+			return b.String(), nil
+		case !multi && c == '\n':
+			return "", errors.New("unterminated string")
+		case c == '\\' && quote == `"`:
+			if err := p.escape(&b, multi); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.i++
+		}
+	}
+	return "", errors.New("unterminated string")
+}
+
+var tomlEscapes = map[byte]string{
+	'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", 'e': "\x1b", '"': `"`, '\\': `\`,
+}
+
+// escape reads the escape sequence at p.i into b.
+func (p *tomlParser) escape(b *strings.Builder, multi bool) error {
+	p.i++
+	if p.i >= len(p.s) {
+		return errors.New("unterminated string")
+	}
+	c := p.s[p.i]
+	if e, ok := tomlEscapes[c]; ok {
+		b.WriteString(e)
+		p.i++
+		return nil
+	}
+	if multi {
+		// A backslash ending a line joins it to the next non-blank text.
+		j := p.i
+		for j < len(p.s) && (p.s[j] == ' ' || p.s[j] == '\t' || p.s[j] == '\r') {
+			j++
+		}
+		if j < len(p.s) && p.s[j] == '\n' {
+			for j < len(p.s) && strings.IndexByte(" \t\r\n", p.s[j]) >= 0 {
+				j++
+			}
+			p.i = j
+			return nil
+		}
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	if digits == 0 || p.i+1+digits > len(p.s) {
+		return fmt.Errorf("invalid escape \\%c", c)
+	}
+	n, err := strconv.ParseUint(p.s[p.i+1:p.i+1+digits], 16, 32)
+	if err != nil {
+		return fmt.Errorf("invalid escape \\%s", p.s[p.i:p.i+1+digits])
+	}
+	b.WriteRune(rune(n))
+	p.i += 1 + digits
+	return nil
+}
@@ -0,0 +1,143 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"scalars", "s = \"text\"\ni = 42\nf = -1.5\nb = true\nl = 'C:\\path'",
+			`{"b":true,"f":-1.5,"i":42,"l":"C:\\path","s":"text"}`},
+		{"numbers", "a = 0x1F\nb = 0o17\nc = 0b101\nd = 1_000\ne = +7\nf = 6.02e23\ng = 1_0.5",
+			`{"a":31,"b":15,"c":5,"d":1000,"e":7,"f":6.02e+23,"g":10.5}`},
+		{"escapes", `a = "tab\there \"q\" \u00e9 \U0001F600"`,
+			`{"a":"tab\there \"q\" é 😀"}`},
+		{"multi-line basic", "a = \"\"\"\none\n  two\"\"\"\nb = \"\"\"\\\n  joined \\\n  here\"\"\"",
+			`{"a":"one\n  two","b":"joined here"}`},
+		{"multi-line literal", "a = '''\nraw \\n\n''quoted'''''",
+			`{"a":"raw \\n\n''quoted''"}`},
+		{"dotted keys", "a.b.c = 1\na.b.d = 2\n\"x.y\".z = 3\nsite.\"google.com\" = true",
+			`{"a":{"b":{"c":1,"d":2}},"site":{"google.com":true},"x.y":{"z":3}}`},
+		{"tables", "top = 1\n[a]\nb = 2\n[a.c]\nd = 3\n[ e . f ]\ng = 4",
+			`{"a":{"b":2,"c":{"d":3}},"e":{"f":{"g":4}},"top":1}`},
+		{"super table after subtable", "[a.b]\nc = 1\n[a]\nd = 2",
+			`{"a":{"b":{"c":1},"d":2}}`},
+		{"header under dotted keys", "[fruit]\napple.color = 'red'\n[fruit.apple.texture]\nsmooth = true",
+			`{"fruit":{"apple":{"color":"red","texture":{"smooth":true}}}}`},
+		{"arrays of tables", "[[rules]]\ntitle = 'a'\n[[rules]]\ntitle = 'b'\n[rules.meta]\nx = 1\n[[rules.tags]]\nn = 't'",
+			`{"rules":[{"title":"a"},{"meta":{"x":1},"tags":[{"n":"t"}],"title":"b"}]}`},
+		{"inline tables and arrays", "a = { b = 1, c.d = [1, 'two', { e = [] }] }\nf = [\n  1,\n  2, # two\n]",
+			`{"a":{"b":1,"c":{"d":[1,"two",{"e":[]}]}},"f":[1,2]}`},
+		{"datetimes stay strings", "a = 1979-05-27T07:32:00Z\nb = 1979-05-27T00:32:00.999-07:00\nc = 1979-05-27 07:32:00\nd = 1979-05-27\ne = 07:32:00",
+			`{"a":"1979-05-27T07:32:00Z","b":"1979-05-27T00:32:00.999-07:00","c":"1979-05-27 07:32:00","d":"1979-05-27","e":"07:32:00"}`},
+		{"comments", "# header\na = 1 # trailing\nb = \"# not a comment\"",
+			`{"a":1,"b":"# not a comment"}`},
+	}
+	for _, tt := range tests {
+		doc, err := parseTOML([]byte(tt.in))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := jsonForm(t, doc); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"a = 1\na = 2", "already defined"},
+		{"[t]\n[t]", `table "t" is already defined`},
+		{"a.b = 1\n[a]", `table "a" is already defined`},
+		{"[fruit]\napple.color = 'red'\n[fruit.apple]", `table "fruit.apple" is already defined`},
+		{"a = { b = 1 }\na.c = 2", "can't be extended"},
+		{"a = []\n[[a]]", "already defined as an array"},
+		{"[[a]]\n[a]", `table "a" is already defined`},
+		{"a = inf", "inf and nan"},
+		{"a = 1 b = 2", ""},
+		{"a = \"unterminated", ""},
+		{"a = [1, 2", ""},
+	}
+	for _, tt := range tests {
+		if _, err := parseTOML([]byte(tt.in)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: error %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestTOMLRoundTrip(t *testing.T) {
+	pack := samplePack()
+	data, err := encodeTOML(pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parseTOML(data)
+	if err != nil {
+		t.Fatalf("parse encoded pack: %v\n%s", err, data)
+	}
+	// TOML has no null, so compare against the pack without its null fields.
+	var want any
+	if err := json.Unmarshal([]byte(jsonForm(t, pack)), &want); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := jsonForm(t, doc), jsonForm(t, dropNulls(want)); got != want {
+		t.Errorf("round trip changed the pack:\n got %s\nwant %s\nTOML:\n%s", got, want, data)
+	}
+}
+
+// dropNulls is v without null object fields, which TOML can't hold.
+func dropNulls(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if e == nil {
+				delete(v, k)
+			} else {
+				v[k] = dropNulls(e)
+			}
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = dropNulls(e)
+		}
+	}
+	return v
+}
+
+// FuzzTOML checks that the parser doesn't panic, and that whatever it
+// reads is written back by the encoder as a document that reads the same.
+func FuzzTOML(f *testing.F) {
+	for _, seed := range []string{
+		"a = 1\n[b]\nc.d = 'e'\n[[f]]\ng = [1, { h = 2 }]\n",
+		"s = \"\"\"\nline\\\n  more\"\"\"\nt = '''\nraw'''\n",
+		"d = 1979-05-27T07:32:00Z\nx = 0x10\ny = 1_000.5\n",
+		"[a.b]\nc = 1\n[a]\nd = 2\n[[a.e]]\n[[a.e]]\nf = true\n",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		doc, err := parseTOML([]byte(in))
+		if err != nil {
+			return
+		}
+		want := jsonForm(t, doc)
+		data, err := encodeTOML(doc)
+		if err != nil {
+			t.Fatalf("encode %s: %v", want, err)
+		}
+		again, err := parseTOML(data)
+		if err != nil {
+			t.Fatalf("parse encoded %s: %v\n%s", want, err, data)
+		}
+		if got := jsonForm(t, again); got != want {
+			t.Fatalf("round trip of %q:\n got %s\nwant %s\nTOML:\n%s", in, got, want, data)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package memomarket
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Trust levels gate what an account may do while it is new. Accounts start
+// at "new" and move up automatically as they age and publish packs that get
+// downloaded; admins are always trusted. Thresholds and what each level
+// allows come from config.json, and an admin can pin a user to a level.
+
+const (
+	trustNew     = "new"
+	trustBasic   = "basic"
+	trustTrusted = "trusted"
+)
+
+// trustLevel is one level's effective settings. The requirements are
+// ignored for the new level.
+type trustLevel struct {
+	Name           string
+	MinAccountDays int
+	MinPacks       int
+	MinDownloads   int
+	// DailyPublishes caps packs created per 24 hours; 0 is unlimited.
+	DailyPublishes int
+	ExternalLinks  bool
+	Attachments    bool
+}
+
+// trustLevels lists the levels from lowest to highest.
+var trustLevels []trustLevel
+
+func loadTrustLevels(cfg *TrustConfig) {
+	levels := []trustLevel{
+		{Name: trustNew, DailyPublishes: 3},
+		{Name: trustBasic, MinAccountDays: 2, MinPacks: 1, DailyPublishes: 10, ExternalLinks: true},
+		{Name: trustTrusted, MinAccountDays: 30, MinPacks: 3, MinDownloads: 100, ExternalLinks: true, Attachments: true},
+	}
+	if cfg != nil {
+		for i, c := range []*TrustLevelConfig{cfg.New, cfg.Basic, cfg.Trusted} {
+			if c == nil {
+				continue
+			}
+			l := &levels[i]
+			for _, f := range []struct {
+				dst *int
+				src *int
+			}{
+				{&l.MinAccountDays, c.MinAccountDays},
+				{&l.MinPacks, c.MinPacks},
+				{&l.MinDownloads, c.MinDownloads},
+				{&l.DailyPublishes, c.DailyPublishes},
+			} {
+				if f.src != nil {
+					*f.dst = max(*f.src, 0)
+				}
+			}
+			if c.ExternalLinks != nil {
+				l.ExternalLinks = *c.ExternalLinks
+			}
+			if c.Attachments != nil {
+				l.Attachments = *c.Attachments
+			}
+		}
+	}
+	trustLevels = levels
+}
+
+func init() {
+	loadTrustLevels(nil)
+}
+
+func findTrustLevel(name string) (trustLevel, bool) {
+	for _, l := range trustLevels {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return trustLevel{}, false
+}
+
+// userTrust works out user's level and how far it is from the next one.
+func userTrust(user *User) (*TrustStatus, error) {
+	since := time.Now().UTC().Add(-24 * time.Hour).Format("2006-01-02T15:04:05")
+	a, err := store.GetUserActivity(user.ID, since)
+	if err != nil {
+		return nil, err
+	}
+	st := &TrustStatus{
+		AccountDays:    int(time.Since(parseISO(a.CreatedAt)).Hours() / 24),
+		Packs:          a.Packs,
+		Downloads:      a.Downloads,
+		PublishedToday: a.PublishedSince,
+	}
+	level := trustLevels[0]
+	switch {
+	case isAdmin(user):
+		level = trustLevels[len(trustLevels)-1]
+	case a.TrustLevel != "":
+		if l, ok := findTrustLevel(a.TrustLevel); ok {
+			level = l
+			st.Pinned = true
+		}
+	default:
+		for _, l := range trustLevels[1:] {
+			if !st.meets(l) {
+				st.Next = &TrustRequirement{Level: l.Name, MinAccountDays: l.MinAccountDays, MinPacks: l.MinPacks, MinDownloads: l.MinDownloads}
+				break
+			}
+			level = l
+		}
+	}
+	st.Level = level.Name
+	st.Limits = TrustLimits{DailyPublishes: level.DailyPublishes, ExternalLinks: level.ExternalLinks, Attachments: level.Attachments}
+	return st, nil
+}
+
+func (st *TrustStatus) meets(l trustLevel) bool {
+	return st.AccountDays >= l.MinAccountDays && st.Packs >= l.MinPacks && st.Downloads >= l.MinDownloads
+}
+
+// linkPattern spots URLs and bare www. hosts in free text.
+var linkPattern = regexp.MustCompile(`(?i)\b(https?://|www\.)\S`)
+
+// checkTrust applies the publish limits of user's trust level: the daily
+// publish cap when creating a pack, and the external link ban to the
+// description. It writes the error response and returns false when the
+// action is refused. Anonymous packs are limited per IP instead of by a
+// daily cap.
+func checkTrust(w http.ResponseWriter, user *User, creating bool, description string) bool {
+	st, err := userTrust(user)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to check trust level"})
+		return false
+	}
+	if creating && user.ID != anonymousUserID && st.Limits.DailyPublishes > 0 && st.PublishedToday >= st.Limits.DailyPublishes {
+		writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: fmt.Sprintf(
+			"accounts at trust level %s may publish %d packs a day", st.Level, st.Limits.DailyPublishes)})
+		return false
+	}
+	if !st.Limits.ExternalLinks && linkPattern.MatchString(description) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: fmt.Sprintf(
+			"accounts at trust level %s can't put links in descriptions", st.Level)})
+		return false
+	}
+	return true
+}
+
+// GET /api/me/trust — my trust level, what it allows, and what the next
+// level needs (auth required).
+func handleMyTrust(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	st, err := userTrust(user)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to check trust level"})
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// GET /api/admin/users/{id}/trust — a user's trust level (admin only). PUT
+// pins it to a level, or with an empty level returns it to automatic; a
+// reason is required.
+func handleAdminUserTrust(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	if r.Method == http.MethodPut {
+		var req UserTrustReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		if _, ok := findTrustLevel(req.Level); !ok && req.Level != "" {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "level must be new, basic, trusted or empty"})
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if err := validateAdminReason(req.Reason); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		found, err := store.SetUserTrustLevel(id, req.Level)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to update trust level"})
+			return
+		}
+		if !found {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user not found"})
+			return
+		}
+		action := auditUserTrust + req.Level
+		if req.Level == "" {
+			action += "auto"
+		}
+		recordAudit(r, currentUser(r), action, "user", id, req.Reason)
+	}
+	user, err := store.GetUserByID(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "user not found"})
+		return
+	}
+	st, err := userTrust(user)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to check trust level"})
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
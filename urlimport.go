@@ -0,0 +1,214 @@
+package memomarket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// POST /api/memo-packs/import fetches a pack JSON from another site, such as
+// GET /api/memo-packs/{id} of another channel, and publishes it as a new
+// pack of the caller's. The fetch can't be pointed at the server's own
+// network: addresses are checked after DNS resolution, on every redirect,
+// and loopback, private, link-local and carrier-grade NAT ranges are
+// refused unless url_import.allow_private_networks is set. The body is
+// capped at limits.max_request_bytes. Users' webhooks are delivered with the
+// same guard.
+
+const maxImportRedirects = 3
+
+var urlImport struct {
+	allowPrivate bool
+}
+
+func loadURLImportConfig(cfg *URLImportConfig) {
+	urlImport.allowPrivate = cfg != nil && cfg.AllowPrivateNetworks
+}
+
+// errPrivateAddress is returned, wrapped, for fetches that would reach a
+// non-public address.
+var errPrivateAddress = errors.New("url points to a private network address")
+
+var (
+	cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+	thisNetwork = netip.MustParsePrefix("0.0.0.0/8")
+)
+
+// publicAddr reports whether ip is a public unicast address.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !cgnatPrefix.Contains(ip) && !thisNetwork.Contains(ip)
+}
+
+// checkDialAddress refuses connections to non-public addresses. It runs on
+// the resolved address, so a hostname can't smuggle one in.
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	if urlImport.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !publicAddr(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// publicTransport only connects to public addresses; see checkDialAddress.
+func publicTransport() *http.Transport {
+	return &http.Transport{
+		// No proxy: the address check must see the real destination.
+		Proxy:                 nil,
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second, Control: checkDialAddress}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+	}
+}
+
+var urlImportClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: publicTransport(),
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxImportRedirects {
+			return fmt.Errorf("more than %d redirects", maxImportRedirects)
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to a %s URL", req.URL.Scheme)
+		}
+		return nil
+	},
+}
+
+// parseImportURL checks that raw is an absolute http or https URL.
+func parseImportURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("url can't carry credentials")
+	}
+	return u, nil
+}
+
+// importedPack is a fetched pack JSON. ContentRestricted is set by
+// MemoMarket channels that hid an auth-only pack's content.
+type importedPack struct {
+	PublishMemoPackReq
+	ContentRestricted bool `json:"content_restricted"`
+}
+
+// fetchPackURL downloads and decodes the pack JSON at u.
+func fetchPackURL(u *url.URL) (*importedPack, error) {
+	limit := contentLimits.MaxRequestBytes
+	if limit <= 0 {
+		limit = defaultContentLimits.MaxRequestBytes
+	}
+	req, _ := http.NewRequest(http.MethodGet, u.String(), nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err := urlImportClient.Do(req)
+	if err != nil {
+		if errors.Is(err, errPrivateAddress) {
+			return nil, errPrivateAddress
+		}
+		return nil, fmt.Errorf("fetching %s: %v", u.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: status %d", u.Host, resp.StatusCode)
+	}
+	if resp.ContentLength > int64(limit) {
+		return nil, fmt.Errorf("pack is larger than %d bytes", limit)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %v", u.Host, err)
+	}
+	if len(data) > limit {
+		return nil, fmt.Errorf("pack is larger than %d bytes", limit)
+	}
+	var p importedPack
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s did not return a pack JSON object", u.Host)
+	}
+	return &p, nil
+}
+
+// POST /api/memo-packs/import — fetch a pack JSON from {url} and publish it
+// as mine, or keep it as a draft with "draft" (auth required).
+func handleImportURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	if !requireWritable(w, user) {
+		return
+	}
+	var body ImportURLReq
+	if err := decodeJSON(r, &body); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	u, err := parseImportURL(body.URL)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	fetched, err := fetchPackURL(u)
+	if errors.Is(err, errPrivateAddress) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if fetched.ContentRestricted {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: "the source hides this pack's content from anonymous callers"})
+		return
+	}
+
+	// What only makes sense on the source channel is dropped: its pack
+	// IDs, attachments and embargo. Categories are kept when this channel
+	// has them too.
+	req := fetched.PublishMemoPackReq
+	req.Draft, req.Visibility, req.VariantOf, req.Embargo = body.Draft, body.Visibility, "", nil
+	if body.Category != "" {
+		req.Category = body.Category
+	} else if _, err := store.GetCategory(req.Category); err != nil {
+		req.Category = ""
+	}
+	for i := range req.Memos {
+		req.Memos[i].Attachments = nil
+	}
+	pack, _ := newPackFromReq(w, user, &req)
+	if pack == nil {
+		return
+	}
+	pack.Provenance = &PackProvenance{Type: "url", URL: u.String(), SyncedAt: nowISO()}
+	if err := store.InsertMemoPack(pack); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to import"})
+		return
+	}
+	writeAudit(r, user, &AuditEntry{Action: auditPackImport, TargetKind: "pack", TargetID: pack.ID, Diff: packSummary(pack) + " from " + u.String()})
+	if pack.Published {
+		emitPackEvent(EventPackPublished, pack, nil)
+	}
+	writeJSON(w, http.StatusCreated, pack)
+}
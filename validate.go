@@ -0,0 +1,226 @@
+package memomarket
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// validateRules checks the rule schema and activation conditions and
+// normalizes them in place.
+func validateRules(rules []MemoRule) error {
+	for i := range rules {
+		if err := validateRuleSchema(i, &rules[i]); err != nil {
+			return err
+		}
+		c := rules[i].Conditions
+		if c == nil {
+			continue
+		}
+		for _, g := range c.FileGlobs {
+			if strings.TrimSpace(g) == "" {
+				return fmt.Errorf("rule %d: empty file glob", i+1)
+			}
+			if _, err := path.Match(g, ""); err != nil {
+				return fmt.Errorf("rule %d: invalid file glob %q", i+1, g)
+			}
+		}
+		c.Languages = normalizeTags(c.Languages)
+		c.ProjectTypes = normalizeTags(c.ProjectTypes)
+		if len(c.FileGlobs) == 0 && len(c.Languages) == 0 && len(c.ProjectTypes) == 0 {
+			rules[i].Conditions = nil
+		}
+	}
+	return nil
+}
+
+const (
+	maxDeprecationReasonChars = 500
+	maxReplacementChars       = 200
+)
+
+// validateDeprecations checks the deprecation notes on rules and memos,
+// trimming them in place.
+func validateDeprecations(rules []MemoRule, memos []Memo) error {
+	check := func(kind string, i int, d *Deprecation) error {
+		if d == nil {
+			return nil
+		}
+		d.Reason = strings.TrimSpace(d.Reason)
+		d.Replacement = strings.TrimSpace(d.Replacement)
+		if d.Reason == "" {
+			return fmt.Errorf("%s %d: deprecation reason is required", kind, i+1)
+		}
+		if utf8.RuneCountInString(d.Reason) > maxDeprecationReasonChars {
+			return fmt.Errorf("%s %d: deprecation reason must be at most %d characters", kind, i+1, maxDeprecationReasonChars)
+		}
+		if utf8.RuneCountInString(d.Replacement) > maxReplacementChars {
+			return fmt.Errorf("%s %d: replacement must be at most %d characters", kind, i+1, maxReplacementChars)
+		}
+		return nil
+	}
+	for i := range rules {
+		if err := check("rule", i, rules[i].Deprecated); err != nil {
+			return err
+		}
+	}
+	for i := range memos {
+		if err := check("memo", i, memos[i].Deprecated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var versionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+
+// validateVersion requires a semantic version such as 1.2.0 or 2.0.0-beta.1.
+func validateVersion(v string) error {
+	if !versionPattern.MatchString(v) {
+		return fmt.Errorf("version must look like 1.2.3")
+	}
+	return nil
+}
+
+// validateEvals checks that every eval has an input and well-formed assertions.
+func validateEvals(evals []PackEval) error {
+	for i, e := range evals {
+		if strings.TrimSpace(e.Input) == "" {
+			return fmt.Errorf("eval %d: input is required", i+1)
+		}
+		if len(e.Expect) == 0 {
+			return fmt.Errorf("eval %d: at least one assertion is required", i+1)
+		}
+		for _, a := range e.Expect {
+			switch a.Type {
+			case "contains", "not_contains", "equals":
+			case "regex":
+				if _, err := regexp.Compile(a.Value); err != nil {
+					return fmt.Errorf("eval %d: invalid regex %q", i+1, a.Value)
+				}
+			default:
+				return fmt.Errorf("eval %d: unknown assertion type %q", i+1, a.Type)
+			}
+		}
+	}
+	return nil
+}
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{2,31}$`)
+
+// Usernames that would collide with routes, roles or staff identities.
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "api": true, "support": true, "system": true,
+	"root": true, "moderator": true, "mod": true, "staff": true, "help": true,
+	"me": true, "www": true, "anonymous": true, "null": true, "undefined": true,
+	"memomarket": true,
+}
+
+// validateUsername enforces length, charset and the reserved-name list.
+func validateUsername(name string) error {
+	if !usernamePattern.MatchString(name) {
+		return fmt.Errorf("username must be 3-32 characters of letters, digits, '_' or '-', starting with a letter or digit")
+	}
+	if reservedUsernames[strings.ToLower(name)] {
+		return fmt.Errorf("username %q is reserved", name)
+	}
+	return nil
+}
+
+const maxReviewChars = 4000
+
+// validateReview checks the rating range and body length.
+func validateReview(req *ReviewReq) error {
+	if req.Rating < 1 || req.Rating > 5 {
+		return fmt.Errorf("rating must be between 1 and 5")
+	}
+	if utf8.RuneCountInString(req.Body) > maxReviewChars {
+		return fmt.Errorf("review must be at most %d characters", maxReviewChars)
+	}
+	return nil
+}
+
+const (
+	maxDisplayNameChars = 50
+	maxBioChars         = 1000
+)
+
+// validateProfile checks a profile update, trimming whitespace in place.
+// A display name may not pass for another user's username.
+func validateProfile(req *ProfileReq, user *User) error {
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+	req.Bio = strings.TrimSpace(req.Bio)
+	req.Website = strings.TrimSpace(req.Website)
+	req.AvatarURL = strings.TrimSpace(req.AvatarURL)
+	if utf8.RuneCountInString(req.DisplayName) > maxDisplayNameChars {
+		return fmt.Errorf("display_name must be at most %d characters", maxDisplayNameChars)
+	}
+	if strings.IndexFunc(req.DisplayName, unicode.IsControl) >= 0 {
+		return fmt.Errorf("display_name must not contain control characters")
+	}
+	if req.DisplayName != "" {
+		if other, err := store.FindUserBySkeleton(nameSkeleton(req.DisplayName)); err == nil && !strings.EqualFold(other, user.Username) {
+			return fmt.Errorf("display_name %q is too similar to existing user %q", req.DisplayName, other)
+		}
+	}
+	if utf8.RuneCountInString(req.Bio) > maxBioChars {
+		return fmt.Errorf("bio must be at most %d characters", maxBioChars)
+	}
+	for _, f := range []struct{ name, value string }{
+		{"website", req.Website},
+		{"avatar_url", req.AvatarURL},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if len(f.value) > maxLinkChars {
+			return fmt.Errorf("%s must be at most %d characters", f.name, maxLinkChars)
+		}
+		if !isHTTPURL(f.value) {
+			return fmt.Errorf("%s must be an http(s) URL", f.name)
+		}
+	}
+	return nil
+}
+
+const maxLinkChars = 500
+
+// validatePackLinks checks the optional homepage, repository and contact
+// fields, trimming whitespace in place. Contact may be an email address or URL.
+func validatePackLinks(req *PublishMemoPackReq) error {
+	req.Homepage = strings.TrimSpace(req.Homepage)
+	req.Repository = strings.TrimSpace(req.Repository)
+	req.Contact = strings.TrimSpace(req.Contact)
+	for _, f := range []struct{ name, value string }{
+		{"homepage", req.Homepage},
+		{"repository", req.Repository},
+		{"contact", req.Contact},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if len(f.value) > maxLinkChars {
+			return fmt.Errorf("%s must be at most %d characters", f.name, maxLinkChars)
+		}
+		if f.name == "contact" && !strings.Contains(f.value, "://") {
+			if addr, err := mail.ParseAddress(f.value); err != nil || addr.Address != f.value {
+				return fmt.Errorf("contact must be an email address or http(s) URL")
+			}
+			continue
+		}
+		if !isHTTPURL(f.value) {
+			return fmt.Errorf("%s must be an http(s) URL", f.name)
+		}
+	}
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
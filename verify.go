@@ -0,0 +1,242 @@
+package memomarket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Publisher verification: a user claims a domain or GitHub account, publishes
+// the claim's token where only the owner could, and asks the server to check.
+
+const verifyTokenPrefix = "memomarket-verification="
+
+// lookupTXT resolves DNS TXT records.
+var lookupTXT = net.LookupTXT
+
+var (
+	domainPattern      = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+	githubLoginPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,37}[a-z0-9])?$`)
+)
+
+// normalizeVerificationReq lowercases and validates the claimed subject.
+func normalizeVerificationReq(req *CreateVerificationReq) error {
+	req.Subject = strings.ToLower(strings.TrimSpace(req.Subject))
+	switch req.Kind {
+	case "domain":
+		req.Subject = strings.TrimSuffix(req.Subject, ".")
+		if !domainPattern.MatchString(req.Subject) {
+			return fmt.Errorf("subject must be a domain name such as example.com")
+		}
+	case "github":
+		req.Subject = strings.TrimPrefix(req.Subject, "@")
+		if !githubLoginPattern.MatchString(req.Subject) {
+			return fmt.Errorf("subject must be a GitHub username")
+		}
+	default:
+		return fmt.Errorf("kind must be domain or github")
+	}
+	return nil
+}
+
+func identityURL(kind, subject string) string {
+	if kind == "github" {
+		return "https://github.com/" + subject
+	}
+	return "https://" + subject
+}
+
+// verificationInstructions tells the user where to publish the token.
+func verificationInstructions(v *Verification) string {
+	if v.Status == "verified" {
+		return ""
+	}
+	if v.Kind == "domain" {
+		return fmt.Sprintf("Add a DNS TXT record on _memomarket.%s with the value %q, then run the check.", v.Subject, v.Token)
+	}
+	return fmt.Sprintf("Create a public gist as %s whose description contains %q, then run the check.", v.Subject, v.Token)
+}
+
+func newVerificationToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return verifyTokenPrefix + hex.EncodeToString(b)
+}
+
+// checkVerificationProof looks for the claim's token where the subject's
+// owner would have published it.
+func checkVerificationProof(v *Verification) error {
+	if v.Kind == "domain" {
+		return checkDomainProof(v.Subject, v.Token)
+	}
+	return checkGitHubProof(v.Subject, v.Token)
+}
+
+func checkDomainProof(domain, token string) error {
+	records, err := lookupTXT("_memomarket." + domain)
+	if err != nil {
+		return fmt.Errorf("no TXT record found on _memomarket.%s", domain)
+	}
+	if !slices.Contains(records, token) {
+		return fmt.Errorf("TXT records on _memomarket.%s do not contain the verification token", domain)
+	}
+	return nil
+}
+
+func checkGitHubProof(login, token string) error {
+	req, _ := http.NewRequest(http.MethodGet, githubAPIBase+"/users/"+login+"/gists?per_page=100", nil)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if t := os.Getenv("GITHUB_TOKEN"); t != "" {
+		req.Header.Set("Authorization", "Bearer "+t)
+	}
+	resp, err := githubClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("github request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("GitHub user %s not found", login)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github returned %s", resp.Status)
+	}
+	var gists []struct {
+		Description string `json:"description"`
+		Owner       struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxImportFileSize)).Decode(&gists); err != nil {
+		return fmt.Errorf("could not read gists for %s", login)
+	}
+	for _, g := range gists {
+		if strings.EqualFold(g.Owner.Login, login) && strings.Contains(g.Description, token) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no public gist by %s contains the verification token", login)
+}
+
+// attachAuthorInfo fills in AuthorVerified and AuthorAway on each pack.
+func attachAuthorInfo(packs ...*MemoPack) {
+	var authors []string
+	for _, p := range packs {
+		if !slices.Contains(authors, p.AuthorID) {
+			authors = append(authors, p.AuthorID)
+		}
+	}
+	verified, err := store.ListVerifiedIdentities(authors)
+	if err != nil {
+		return
+	}
+	away, err := store.ListActiveVacations(authors)
+	if err != nil {
+		return
+	}
+	for _, p := range packs {
+		p.AuthorVerified = verified[p.AuthorID]
+		p.AuthorAway = away[p.AuthorID]
+	}
+}
+
+// GET /api/me/verifications — list my verification claims; POST starts one (auth required).
+func handleMyVerifications(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		vs, err := store.ListUserVerifications(user.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to list verifications"})
+			return
+		}
+		for i := range vs {
+			vs[i].Instructions = verificationInstructions(&vs[i])
+		}
+		writeJSON(w, http.StatusOK, vs)
+	case http.MethodPost:
+		var req CreateVerificationReq
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+			return
+		}
+		if err := normalizeVerificationReq(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		if owner, err := store.VerifiedSubjectOwner(req.Kind, req.Subject); err == nil && owner != user.ID {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: req.Subject + " is already verified by another user"})
+			return
+		}
+		v, err := store.InsertVerification(&Verification{
+			ID:        newID(),
+			UserID:    user.ID,
+			Kind:      req.Kind,
+			Subject:   req.Subject,
+			Token:     newVerificationToken(),
+			CreatedAt: nowISO(),
+		})
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to create verification"})
+			return
+		}
+		v.Instructions = verificationInstructions(v)
+		writeJSON(w, http.StatusCreated, v)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// POST /api/me/verifications/{id}/check — look for the published proof.
+// DELETE /api/me/verifications/{id} — withdraw a claim (auth required).
+func handleMyVerification(w http.ResponseWriter, r *http.Request) {
+	user := currentUser(r)
+	if user == nil {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "not authenticated"})
+		return
+	}
+	v, err := store.GetVerification(extractID(r.URL.Path, "/api/me/verifications/"))
+	if err != nil || v.UserID != user.ID {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "verification not found"})
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/check") && r.Method == http.MethodPost:
+		if v.Status != "verified" {
+			if owner, err := store.VerifiedSubjectOwner(v.Kind, v.Subject); err == nil && owner != user.ID {
+				writeJSON(w, http.StatusConflict, ErrorResponse{Error: v.Subject + " is already verified by another user"})
+				return
+			}
+			if err := checkVerificationProof(v); err != nil {
+				writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+				return
+			}
+			if err := store.MarkVerified(v.ID); err != nil {
+				writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to save verification"})
+				return
+			}
+			v, _ = store.GetVerification(v.ID)
+		}
+		writeJSON(w, http.StatusOK, v)
+	case r.Method == http.MethodDelete:
+		if err := store.DeleteVerification(v.ID, user.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to delete"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
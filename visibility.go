@@ -0,0 +1,46 @@
+package memomarket
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A published pack is public, unlisted or private. Public packs show up in
+// listings, search, categories and profiles. Unlisted packs leave all of
+// those out but anyone with the ID or slug can still fetch and download
+// them. Private packs are seen only by their editors and admins, like
+// drafts. Visibility is separate from being a draft: it says who may see the
+// pack once it is published.
+
+const (
+	visibilityPublic   = "public"
+	visibilityUnlisted = "unlisted"
+	visibilityPrivate  = "private"
+)
+
+// listedSQL is the condition packs must meet to be listed.
+const listedSQL = "visibility = 'public'"
+
+// validatePackVisibility normalizes the visibility of a publish or update.
+// An empty one is left for the caller to default.
+func validatePackVisibility(req *PublishMemoPackReq) error {
+	req.Visibility = strings.ToLower(strings.TrimSpace(req.Visibility))
+	switch req.Visibility {
+	case "", visibilityPublic, visibilityUnlisted, visibilityPrivate:
+		return nil
+	}
+	return fmt.Errorf("visibility must be public, unlisted or private")
+}
+
+// privateRead reports whether reads of p must stay out of shared caches, so
+// that revoking a share link or making the pack private takes effect at
+// once.
+func privateRead(p *MemoPack) bool {
+	return p.RequireAuth || !packReachable(p)
+}
+
+// packReachable reports whether anyone may fetch p by ID: published, out of
+// embargo and not private.
+func packReachable(p *MemoPack) bool {
+	return p.Published && !embargoed(p) && p.Visibility != visibilityPrivate
+}
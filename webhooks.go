@@ -0,0 +1,271 @@
+package memomarket
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Webhook events. EventPing is only sent by test deliveries. Generic
+// payloads carry the event's detail: the milestone reached, the client
+// name, or for EventPackReview the reviewer, rating and body of a review
+// that was written or changed.
+const (
+	EventPackPublished     = "pack.published"
+	EventDownloadMilestone = "pack.download_milestone"
+	EventNewClient         = "pack.new_client"
+	EventPackReview        = "pack.review"
+	EventPing              = "ping"
+)
+
+var webhookEvents = []string{EventPackPublished, EventDownloadMilestone, EventNewClient, EventPackReview}
+
+var webhookKinds = []string{"generic", "discord", "slack"}
+
+// Download counts that trigger EventDownloadMilestone, unless the author
+// chose others.
+var downloadMilestones = []int{10, 100, 1000, 10000, 100000, 1000000}
+
+// Channel-wide webhooks from config.json; synced into the webhooks table at startup.
+var channelWebhooks []WebhookConfig
+
+const maxWebhookAttempts = 5
+
+// channelWebhookClient delivers the operator's channel webhooks, which may
+// point inside the operator's network. userWebhookClient delivers the
+// webhooks users register, and only reaches public addresses unless
+// url_import.allow_private_networks is set.
+var (
+	channelWebhookClient = &http.Client{Timeout: 10 * time.Second, Transport: newBreakerTransport(breakerWebhooks, true)}
+	userWebhookClient    = &http.Client{Timeout: 10 * time.Second, Transport: &breakerTransport{name: breakerWebhooks, perHost: true, next: publicTransport()}}
+)
+
+// syncChannelWebhooks replaces the operator-owned webhooks with those from config.
+func syncChannelWebhooks() {
+	hooks := make([]Webhook, 0, len(channelWebhooks))
+	for _, c := range channelWebhooks {
+		wh := Webhook{URL: c.URL, Kind: c.Kind, Events: c.Events, CreatedAt: nowISO()}
+		if err := validateWebhook(&wh); err != nil {
+			log.Printf("Ignoring channel webhook %s: %v", c.URL, err)
+			continue
+		}
+		// Stable IDs keep queued deliveries attached across restarts.
+		sum := sha256.Sum256([]byte(c.URL))
+		wh.ID = "channel-" + hex.EncodeToString(sum[:8])
+		hooks = append(hooks, wh)
+	}
+	if err := store.ReplaceChannelWebhooks(hooks); err != nil {
+		log.Printf("Failed to sync channel webhooks: %v", err)
+	}
+}
+
+// validateWebhook checks the URL, kind and events, filling in defaults.
+// Users' webhooks can't name a private address; one that resolves to one
+// fails at delivery instead.
+func validateWebhook(wh *Webhook) error {
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return fmt.Errorf("url must be an http(s) URL")
+	}
+	if wh.OwnerID != "" && !urlImport.allowPrivate {
+		if ip, err := netip.ParseAddr(u.Hostname()); (err == nil && !publicAddr(ip)) || strings.EqualFold(u.Hostname(), "localhost") {
+			return errPrivateAddress
+		}
+	}
+	if wh.Kind == "" {
+		wh.Kind = "generic"
+	}
+	if !slices.Contains(webhookKinds, wh.Kind) {
+		return fmt.Errorf("kind must be generic, discord or slack")
+	}
+	if len(wh.Events) == 0 {
+		wh.Events = webhookEvents
+	}
+	for _, e := range wh.Events {
+		if !slices.Contains(webhookEvents, e) {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+	return nil
+}
+
+// emitPackEvent queues deliveries of event to channel webhooks and to the
+// pack author's webhooks that subscribe to it. Channel webhooks only hear
+// about public packs.
+func emitPackEvent(event string, pack *MemoPack, detail map[string]any) {
+	hooks, err := store.ListWebhooksForEvent(event, pack)
+	if err != nil {
+		log.Printf("webhook lookup for %s: %v", event, err)
+		return
+	}
+	public := packPublic(pack)
+	for _, wh := range hooks {
+		if wh.OwnerID == "" && !public {
+			continue
+		}
+		payload := renderWebhookPayload(wh.Kind, event, pack, detail)
+		d := &WebhookDelivery{
+			ID:            newID(),
+			WebhookID:     wh.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        "pending",
+			NextAttemptAt: nowISO(),
+			CreatedAt:     nowISO(),
+		}
+		if err := store.InsertWebhookDelivery(d); err != nil {
+			log.Printf("queue webhook delivery: %v", err)
+		}
+	}
+}
+
+func renderWebhookPayload(kind, event string, pack *MemoPack, detail map[string]any) []byte {
+	var text string
+	switch event {
+	case EventPackPublished:
+		text = fmt.Sprintf("New pack published on %s: **%s** v%s by %s", serverName, pack.Name, pack.Version, pack.AuthorName)
+	case EventDownloadMilestone:
+		text = fmt.Sprintf("**%s** by %s reached %v downloads on %s", pack.Name, pack.AuthorName, detail["milestone"], serverName)
+	case EventNewClient:
+		text = fmt.Sprintf("**%s** by %s was installed with %v for the first time on %s", pack.Name, pack.AuthorName, detail["client"], serverName)
+	case EventPackReview:
+		text = fmt.Sprintf("**%s** by %s got a %v-star review from %s on %s", pack.Name, pack.AuthorName, detail["rating"], detail["reviewer"], serverName)
+	case EventPing:
+		text = fmt.Sprintf("Test delivery for **%s** from %s", pack.Name, serverName)
+	default:
+		text = fmt.Sprintf("%s: %s", event, pack.Name)
+	}
+
+	var v any
+	switch kind {
+	case "discord":
+		v = map[string]any{
+			"content": text,
+			"embeds": []map[string]any{{
+				"title":       pack.Name,
+				"description": pack.Description,
+				"footer":      map[string]string{"text": "id: " + pack.ID},
+			}},
+		}
+	case "slack":
+		// Slack mrkdwn uses single asterisks for bold.
+		v = map[string]any{"text": strings.ReplaceAll(text, "**", "*")}
+	default:
+		v = map[string]any{
+			"event":      event,
+			"server":     serverName,
+			"pack_id":    pack.ID,
+			"pack_name":  pack.Name,
+			"version":    pack.Version,
+			"author":     pack.AuthorName,
+			"detail":     detail,
+			"created_at": nowISO(),
+		}
+	}
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// runWebhookWorker delivers queued webhooks until the workers are stopped.
+func runWebhookWorker() {
+	for {
+		deliverDueWebhooks()
+		if !idle(5 * time.Second) {
+			return
+		}
+	}
+}
+
+// deliverDueWebhooks attempts a batch of the deliveries that are due and
+// returns how many it tried.
+func deliverDueWebhooks() int {
+	deliveries, err := store.ListDueWebhookDeliveries(nowISO(), 20)
+	if err != nil {
+		log.Printf("webhook worker: %v", err)
+	}
+	for i := range deliveries {
+		deliverWebhook(&deliveries[i])
+	}
+	return len(deliveries)
+}
+
+func deliverWebhook(d *WebhookDelivery) {
+	wh, err := store.GetWebhook(d.WebhookID)
+	if err != nil {
+		d.Status = "failed"
+		d.LastError = "webhook no longer exists"
+		store.UpdateWebhookDelivery(d)
+		return
+	}
+
+	d.Attempts++
+	err = postWebhook(wh, d)
+	if err == nil {
+		d.Status = "delivered"
+		d.DeliveredAt = nowISO()
+		d.LastError = ""
+	} else {
+		d.LastError = err.Error()
+		if d.Attempts >= maxWebhookAttempts {
+			d.Status = "failed"
+		} else {
+			backoff := time.Duration(d.Attempts*d.Attempts) * time.Minute
+			d.NextAttemptAt = time.Now().UTC().Add(backoff).Format("2006-01-02T15:04:05")
+		}
+	}
+	if err := store.UpdateWebhookDelivery(d); err != nil {
+		log.Printf("webhook worker: failed to update delivery %s: %v", d.ID, err)
+	}
+}
+
+func postWebhook(wh *Webhook, d *WebhookDelivery) error {
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MemoMarket-Webhook")
+	req.Header.Set("X-MemoMarket-Event", d.Event)
+	req.Header.Set("X-MemoMarket-Delivery", d.ID)
+	if wh.Secret != "" {
+		req.Header.Set("X-MemoMarket-Signature", "sha256="+signWebhookPayload(wh.Secret, d.Payload))
+	}
+	client := userWebhookClient
+	if wh.OwnerID == "" {
+		client = channelWebhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// signWebhookPayload returns the hex HMAC-SHA256 of payload keyed by secret.
+func signWebhookPayload(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookSecret returns a random signing key for a webhook.
+func newWebhookSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,904 @@
+package memomarket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A small YAML codec for packs, so they can be kept as YAML under version
+// control. The encoder writes block style with literal blocks for multi-line
+// text, keeping the field order of the JSON. The parser reads the subset
+// people write by hand: block and flow collections, plain, quoted, literal
+// and folded scalars, and comments, resolving plain scalars by the YAML 1.2
+// core schema. Anchors, aliases, tags and multiple documents are refused.
+
+// docField is a key of an object read by readDoc, in document order.
+type docField struct {
+	key string
+	val any
+}
+
+type docObject []docField
+
+// readDoc decodes JSON into docObject, []any, string, json.Number, bool and
+// nil, keeping the order of object keys.
+func readDoc(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return readDocValue(dec)
+}
+
+func readDocValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := docObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := readDocValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, docField{key.(string), val})
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			val, err := readDocValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+// encodeYAML writes v, as encoding/json would see it, as a YAML document.
+func encodeYAML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := readDoc(data)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	switch d := doc.(type) {
+	case docObject:
+		if len(d) == 0 {
+			b.WriteString("{}\n")
+		} else {
+			writeYAMLBlock(&b, d, 0, "")
+		}
+	case []any:
+		if len(d) == 0 {
+			b.WriteString("[]\n")
+		} else {
+			writeYAMLBlock(&b, d, 0, "")
+		}
+	default:
+		b.WriteString(yamlScalar(d) + "\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// writeYAMLBlock writes a non-empty object or array at indent; its first
+// line starts with lead in place of the indentation.
+func writeYAMLBlock(b *strings.Builder, v any, indent int, lead string) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case docObject:
+		for i, f := range v {
+			if i == 0 {
+				b.WriteString(lead)
+			} else {
+				b.WriteString(pad)
+			}
+			b.WriteString(yamlScalar(f.key) + ":")
+			writeYAMLChild(b, f.val, indent, false)
+		}
+	case []any:
+		for i, e := range v {
+			if i == 0 {
+				b.WriteString(lead)
+			} else {
+				b.WriteString(pad)
+			}
+			b.WriteString("-")
+			writeYAMLChild(b, e, indent, true)
+		}
+	}
+}
+
+// writeYAMLChild writes v after the "key:" or "-" of a collection at
+// indent. Collections in a sequence start on the item's line.
+func writeYAMLChild(b *strings.Builder, v any, indent int, inSeq bool) {
+	switch c := v.(type) {
+	case docObject:
+		if len(c) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+	case []any:
+		if len(c) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+	case string:
+		if header, ok := yamlLiteralHeader(c); ok {
+			b.WriteString(" " + header + "\n")
+			pad := strings.Repeat(" ", indent+2)
+			for _, line := range strings.Split(strings.TrimRight(c, "\n"), "\n") {
+				if line != "" {
+					b.WriteString(pad + line)
+				}
+				b.WriteByte('\n')
+			}
+			if header == "|+" {
+				b.WriteString(strings.Repeat("\n", len(c)-len(strings.TrimRight(c, "\n"))-1))
+			}
+			return
+		}
+		b.WriteString(" " + yamlScalar(c) + "\n")
+		return
+	default:
+		b.WriteString(" " + yamlScalar(c) + "\n")
+		return
+	}
+	if inSeq {
+		writeYAMLBlock(b, v, indent+2, " ")
+		return
+	}
+	b.WriteByte('\n')
+	writeYAMLBlock(b, v, indent+2, strings.Repeat(" ", indent+2))
+}
+
+// yamlLiteralHeader returns the literal block header for s, or false when
+// s is better written quoted: on one line, indented on its first line, or
+// holding characters a block can't.
+func yamlLiteralHeader(s string) (string, bool) {
+	if !strings.Contains(strings.TrimRight(s, "\n"), "\n") {
+		return "", false
+	}
+	first := strings.TrimLeft(s, "\n")
+	if strings.HasPrefix(first, " ") || strings.HasPrefix(first, "\t") {
+		return "", false
+	}
+	for _, r := range s {
+		if r != '\n' && r != '\t' && !yamlPrintable(r) {
+			return "", false
+		}
+	}
+	switch trailing := len(s) - len(strings.TrimRight(s, "\n")); trailing {
+	case 0:
+		return "|-", true
+	case 1:
+		return "|", true
+	}
+	return "|+", true
+}
+
+func yamlPrintable(r rune) bool {
+	return r >= 0x20 && r != 0x7f && r != 0x85 && r != 0x2028 && r != 0x2029 && r != 0xfeff && r != utf8.RuneError
+}
+
+// yamlReserved are plain scalars a YAML 1.1 or 1.2 parser reads as
+// something other than a string.
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"y": true, "n": true, "null": true, "~": true,
+}
+
+// yamlScalar writes a scalar, quoting strings that wouldn't read back as the
+// same string plain.
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		if yamlPlain(v) {
+			return v
+		}
+		var b bytes.Buffer
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		enc.Encode(v)
+		return strings.TrimSuffix(b.String(), "\n")
+	}
+	return fmt.Sprint(v)
+}
+
+func yamlPlain(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s || yamlReserved[strings.ToLower(s)] {
+		return false
+	}
+	if first, _ := utf8.DecodeRuneInString(s); !unicode.IsLetter(first) && first != '_' && first != '/' {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if !yamlPrintable(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseYAML reads one YAML document into map[string]any, []any, string,
+// json.Number, bool and nil.
+func parseYAML(data []byte) (any, error) {
+	text := strings.TrimPrefix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\ufeff")
+	p := &yamlParser{lines: strings.Split(text, "\n")}
+	for p.skipBlank(); !p.eof(); p.pos++ {
+		line := p.lines[p.pos]
+		if !strings.HasPrefix(line, "%") && line != "---" {
+			break
+		}
+	}
+	p.skipBlank()
+	if p.eof() {
+		return nil, nil
+	}
+	v, err := p.parseBlock(-1)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if !p.eof() && p.lines[p.pos] == "..." {
+		p.pos++
+		p.skipBlank()
+	}
+	if !p.eof() {
+		if p.docMarker() {
+			return nil, p.errorf("only one document is allowed")
+		}
+		return nil, p.errorf("unexpected content")
+	}
+	return v, nil
+}
+
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+func (p *yamlParser) eof() bool { return p.pos >= len(p.lines) }
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	return p.errorfAt(p.pos+1, format, args...)
+}
+
+// errorfAt reports an error on line, counted from 1.
+func (p *yamlParser) errorfAt(line int, format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skipBlank moves past empty and comment-only lines.
+func (p *yamlParser) skipBlank() {
+	for !p.eof() {
+		t := strings.TrimSpace(p.lines[p.pos])
+		if t != "" && !strings.HasPrefix(t, "#") {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *yamlParser) docMarker() bool {
+	line := p.lines[p.pos]
+	return line == "---" || line == "..." || strings.HasPrefix(line, "--- ")
+}
+
+// indent returns the indentation of the current line and its text.
+func (p *yamlParser) indent() (int, string, error) {
+	line := p.lines[p.pos]
+	text := strings.TrimLeft(line, " ")
+	if strings.HasPrefix(text, "\t") {
+		return 0, "", p.errorf("tabs can't be used for indentation")
+	}
+	return len(line) - len(text), text, nil
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ") || strings.HasPrefix(text, "-\t")
+}
+
+// parseBlock reads the collection or scalar starting on the current line,
+// which is indented more than parent.
+func (p *yamlParser) parseBlock(parent int) (any, error) {
+	indent, text, err := p.indent()
+	if err != nil {
+		return nil, err
+	}
+	if isSeqItem(text) {
+		return p.parseSeq(indent)
+	}
+	if _, _, ok, err := splitYAMLKey(text); err != nil {
+		return nil, p.errorf("%v", err)
+	} else if ok {
+		return p.parseMap(indent)
+	}
+	p.pos++
+	return p.parseValue(text, parent)
+}
+
+func (p *yamlParser) parseMap(indent int) (any, error) {
+	obj := map[string]any{}
+	for p.skipBlank(); !p.eof() && !p.docMarker(); p.skipBlank() {
+		ind, text, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		if ind < indent {
+			break
+		}
+		if ind > indent {
+			return nil, p.errorf("bad indentation")
+		}
+		key, rest, ok, err := splitYAMLKey(text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if !ok {
+			return nil, p.errorf("expected a key followed by a colon")
+		}
+		if _, dup := obj[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+		var val any
+		if rest == "" || strings.HasPrefix(rest, "#") {
+			// A sequence may sit at the same indentation as its key.
+			if p.skipBlank(); !p.eof() {
+				if ind, text, _ := p.indent(); ind == indent && isSeqItem(text) {
+					val, err = p.parseSeq(indent)
+					if err != nil {
+						return nil, err
+					}
+					obj[key] = val
+					continue
+				}
+			}
+		}
+		if val, err = p.parseValue(rest, indent); err != nil {
+			return nil, err
+		}
+		obj[key] = val
+	}
+	return obj, nil
+}
+
+func (p *yamlParser) parseSeq(indent int) (any, error) {
+	arr := []any{}
+	for p.skipBlank(); !p.eof() && !p.docMarker(); p.skipBlank() {
+		ind, text, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		if ind < indent || ind == indent && !isSeqItem(text) {
+			break
+		}
+		if ind > indent {
+			return nil, p.errorf("bad indentation")
+		}
+		rest := strings.TrimLeft(text[1:], " \t")
+		var val any
+		if rest == "" || strings.HasPrefix(rest, "#") {
+			p.pos++
+			val, err = p.parseValue("", indent)
+		} else {
+			// Read the item as if it started on its own line at its column.
+			p.lines[p.pos] = strings.Repeat(" ", indent+len(text)-len(rest)) + rest
+			val, err = p.parseBlock(indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, val)
+	}
+	return arr, nil
+}
+
+// parseValue reads the value written after a key or dash as rest, with any
+// lines that continue it. Its lines must be indented more than parent.
+func (p *yamlParser) parseValue(rest string, parent int) (any, error) {
+	line := p.pos
+	rest = strings.TrimSpace(rest)
+	if rest == "" || strings.HasPrefix(rest, "#") {
+		if p.skipBlank(); p.eof() || p.docMarker() {
+			return nil, nil
+		}
+		ind, _, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		if ind <= parent {
+			return nil, nil
+		}
+		return p.parseBlock(parent)
+	}
+	switch rest[0] {
+	case '|', '>':
+		return p.blockScalar(rest, parent)
+	case '[', '{':
+		for yamlFlowDepth(rest) > 0 && !p.eof() {
+			rest += "\n" + p.lines[p.pos]
+			p.pos++
+		}
+		f := &yamlFlow{s: rest}
+		v, err := f.value()
+		if err == nil {
+			f.skipSpace()
+			if f.i < len(f.s) {
+				err = errors.New("unexpected text after a flow collection")
+			}
+		}
+		if err != nil {
+			return nil, p.errorfAt(line, "%v", err)
+		}
+		return v, nil
+	case '"', '\'':
+		for {
+			s, n, err := parseYAMLQuoted(rest)
+			if errors.Is(err, errUnterminated) && !p.eof() {
+				rest += "\n" + strings.TrimSpace(p.lines[p.pos])
+				p.pos++
+				continue
+			}
+			if err != nil {
+				return nil, p.errorfAt(line, "%v", err)
+			}
+			if after := strings.TrimSpace(rest[n:]); after != "" && !strings.HasPrefix(after, "#") {
+				return nil, p.errorfAt(line, "unexpected text after a quoted string")
+			}
+			return s, nil
+		}
+	case '&', '*', '!':
+		return nil, p.errorfAt(line, "anchors, aliases and tags are not supported")
+	case '@', '`':
+		return nil, p.errorfAt(line, "%q can't start a plain scalar", rest[0])
+	}
+	s := stripYAMLComment(rest)
+	for !p.eof() {
+		// Empty lines within a plain scalar are kept as line breaks; a
+		// single line break folds to a space.
+		start, breaks := p.pos, 0
+		for ; !p.eof() && strings.TrimSpace(p.lines[p.pos]) == ""; p.pos++ {
+			breaks++
+		}
+		if p.eof() {
+			p.pos = start
+			break
+		}
+		ind, text, err := p.indent()
+		if err != nil || ind <= parent || strings.HasPrefix(text, "#") {
+			p.pos = start
+			break
+		}
+		if _, _, ok, _ := splitYAMLKey(text); ok {
+			return nil, p.errorf("a key can't follow a plain value; check the indentation")
+		}
+		if breaks > 0 {
+			s += strings.Repeat("\n", breaks)
+		} else {
+			s += " "
+		}
+		s += stripYAMLComment(text)
+		p.pos++
+	}
+	return resolveYAMLPlain(s), nil
+}
+
+// blockScalar reads a literal (|) or folded (>) block.
+func (p *yamlParser) blockScalar(header string, parent int) (any, error) {
+	header = stripYAMLComment(header)
+	style, chomp, indent := header[0], byte(0), -1
+	for _, c := range header[1:] {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = byte(c)
+		case c >= '1' && c <= '9' && indent < 0:
+			indent = max(parent, 0) + int(c-'0')
+		default:
+			return nil, p.errorfAt(p.pos, "invalid block scalar header %q", header)
+		}
+	}
+	var lines []string
+	for ; !p.eof(); p.pos++ {
+		raw := p.lines[p.pos]
+		text := strings.TrimLeft(raw, " ")
+		if text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		ind := len(raw) - len(text)
+		if indent < 0 {
+			if ind <= parent {
+				break
+			}
+			indent = ind
+		}
+		if ind < indent {
+			break
+		}
+		lines = append(lines, raw[indent:])
+	}
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var s string
+	if style == '|' {
+		s = strings.Join(lines, "\n")
+	} else {
+		s = foldYAMLLines(lines)
+	}
+	switch {
+	case chomp == '+':
+		if len(lines) > 0 {
+			trailing++
+		}
+		s += strings.Repeat("\n", trailing)
+	case chomp == 0 && len(lines) > 0:
+		s += "\n"
+	}
+	return s, nil
+}
+
+// foldYAMLLines joins the lines of a folded block: single line breaks
+// between text lines become spaces, and more-indented lines keep theirs.
+func foldYAMLLines(lines []string) string {
+	var b strings.Builder
+	started, prevMore, breaks := false, false, 0
+	for _, l := range lines {
+		if l == "" {
+			breaks++
+			continue
+		}
+		more := l[0] == ' ' || l[0] == '\t'
+		switch {
+		case !started:
+			b.WriteString(strings.Repeat("\n", breaks))
+		case more || prevMore:
+			b.WriteString(strings.Repeat("\n", breaks+1))
+		case breaks == 0:
+			b.WriteByte(' ')
+		default:
+			b.WriteString(strings.Repeat("\n", breaks))
+		}
+		b.WriteString(l)
+		started, prevMore, breaks = true, more, 0
+	}
+	return b.String()
+}
+
+// stripYAMLComment drops a trailing comment from a plain scalar or header.
+func stripYAMLComment(s string) string {
+	if strings.HasPrefix(s, "#") {
+		return ""
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			s = s[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// splitYAMLKey splits a "key: value" line. ok is false when the line isn't
+// a mapping entry.
+func splitYAMLKey(text string) (key, rest string, ok bool, err error) {
+	if text == "" {
+		return "", "", false, nil
+	}
+	switch text[0] {
+	case '"', '\'':
+		k, n, err := parseYAMLQuoted(text)
+		if err != nil {
+			return "", "", false, nil
+		}
+		after := strings.TrimLeft(text[n:], " \t")
+		if strings.HasPrefix(after, ":") && (len(after) == 1 || after[1] == ' ' || after[1] == '\t') {
+			return k, strings.TrimSpace(after[1:]), true, nil
+		}
+		return "", "", false, nil
+	case '[', '{', '#', '|', '>':
+		return "", "", false, nil
+	case '?':
+		if len(text) == 1 || text[1] == ' ' {
+			return "", "", false, errors.New("complex keys are not supported")
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '#' && i > 0 && (text[i-1] == ' ' || text[i-1] == '\t'):
+			return "", "", false, nil
+		case text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t'):
+			key = strings.TrimSpace(text[:i])
+			return key, strings.TrimSpace(text[i+1:]), key != "", nil
+		}
+	}
+	return "", "", false, nil
+}
+
+var (
+	yamlIntPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloatPattern = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// resolveYAMLPlain types a plain scalar by the YAML 1.2 core schema.
+// Infinity and NaN, which JSON can't hold, stay strings.
+func resolveYAMLPlain(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	switch {
+	case yamlIntPattern.MatchString(s):
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(n, 10))
+		}
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0o"):
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return json.Number(strconv.FormatInt(n, 10))
+		}
+		return s
+	case !yamlFloatPattern.MatchString(s):
+		return s
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+var errUnterminated = errors.New("unterminated quoted string")
+
+// parseYAMLQuoted reads the single- or double-quoted string s starts with,
+// returning it and the bytes it took. Line breaks inside fold to spaces.
+func parseYAMLQuoted(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); {
+		c := s[i]
+		switch {
+		case c == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			b.WriteByte('\'')
+			i += 2
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\n':
+			breaks := 0
+			for i < len(s) && (s[i] == '\n' || s[i] == ' ' || s[i] == '\t') {
+				if s[i] == '\n' {
+					breaks++
+				}
+				i++
+			}
+			trimmed := strings.TrimRight(b.String(), " \t")
+			b.Reset()
+			b.WriteString(trimmed)
+			if breaks == 1 {
+				b.WriteByte(' ')
+			} else {
+				b.WriteString(strings.Repeat("\n", breaks-1))
+			}
+		case c == '\\' && quote == '"':
+			if i+1 >= len(s) {
+				return "", 0, errUnterminated
+			}
+			n, err := yamlEscape(&b, s[i+1:])
+			if err != nil {
+				return "", 0, err
+			}
+			i += 1 + n
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, errUnterminated
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+	'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// yamlEscape writes the escape sequence s starts with (after the
+// backslash) and returns its length.
+func yamlEscape(b *strings.Builder, s string) (int, error) {
+	if e, ok := yamlEscapes[s[0]]; ok {
+		b.WriteString(e)
+		return 1, nil
+	}
+	if s[0] == '\n' {
+		return 1 + len(s[1:]) - len(strings.TrimLeft(s[1:], " \t")), nil
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[0]]
+	if digits == 0 || len(s) < 1+digits {
+		return 0, fmt.Errorf("invalid escape \\%c", s[0])
+	}
+	n, err := strconv.ParseUint(s[1:1+digits], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid escape \\%s", s[:1+digits])
+	}
+	b.WriteRune(rune(n))
+	return 1 + digits, nil
+}
+
+// yamlFlowDepth returns how many flow collections s leaves open.
+func yamlFlowDepth(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t' || s[i-1] == '\n'):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+// yamlFlow reads a flow collection such as [a, "b"] or {k: v}.
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.i < len(f.s) {
+		switch c := f.s[f.i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			f.i++
+		case c == '#':
+			for f.i < len(f.s) && f.s[f.i] != '\n' {
+				f.i++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (f *yamlFlow) value() (any, error) {
+	f.skipSpace()
+	if f.i >= len(f.s) {
+		return nil, errors.New("unterminated flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		arr := []any{}
+		for {
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return arr, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.i++
+		obj := map[string]any{}
+		for {
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return obj, nil
+			}
+			k, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			f.skipSpace()
+			if f.i >= len(f.s) || f.s[f.i] != ':' {
+				return nil, errors.New("expected a colon in a flow mapping")
+			}
+			f.i++
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+			}
+			obj[key] = v
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		s, n, err := parseYAMLQuoted(f.s[f.i:])
+		if err != nil {
+			return nil, err
+		}
+		f.i += n
+		return s, nil
+	case '&', '*', '!':
+		return nil, errors.New("anchors, aliases and tags are not supported")
+	}
+	start := f.i
+	for f.i < len(f.s) {
+		c := f.s[f.i]
+		if strings.IndexByte(",[]{}\n", c) >= 0 || c == ':' && (f.i+1 == len(f.s) || strings.IndexByte(" \t\n,]}", f.s[f.i+1]) >= 0) {
+			break
+		}
+		if c == '#' && f.i > start && (f.s[f.i-1] == ' ' || f.s[f.i-1] == '\t') {
+			break
+		}
+		f.i++
+	}
+	return resolveYAMLPlain(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+// separator consumes the comma after an entry, or sees the closing bracket.
+func (f *yamlFlow) separator(end byte) error {
+	f.skipSpace()
+	switch {
+	case f.i >= len(f.s):
+		return errors.New("unterminated flow collection")
+	case f.s[f.i] == ',':
+		f.i++
+		return nil
+	case f.s[f.i] == end:
+		return nil
+	}
+	return fmt.Errorf("expected , or %c in a flow collection", end)
+}
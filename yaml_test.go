@@ -0,0 +1,162 @@
+package memomarket
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// jsonForm is v as canonical JSON: numbers as written, object keys sorted.
+func jsonForm(t testing.TB, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal %#v: %v", v, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		t.Fatalf("marshal %s: %v", data, err)
+	}
+	return string(data)
+}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"scalars", "s: text\ni: 42\nf: -1.5\nb: true\nn: ~\nq: 'it''s'\ndq: \"tab\\there\"",
+			`{"b":true,"dq":"tab\there","f":-1.5,"i":42,"n":null,"q":"it's","s":"text"}`},
+		{"core schema", "a: yes\nb: True\nc: 0x1F\nd: 0o17\ne: 012\nf: 1e3\ng: null\nh: .inf",
+			`{"a":"yes","b":true,"c":31,"d":15,"e":12,"f":1000,"g":null,"h":".inf"}`},
+		{"literal block", "a: |\n  one\n    two\n\n  three\nb: x",
+			`{"a":"one\n  two\n\nthree\n","b":"x"}`},
+		{"literal block strip and keep", "a: |-\n  one\nb: |+\n  two\n\nc: x",
+			`{"a":"one","b":"two\n\n","c":"x"}`},
+		{"literal block indentation indicator", "a: |2\n   indented\n",
+			`{"a":" indented\n"}`},
+		{"folded block", "a: >\n  one\n  two\n\n  three\n    kept\n",
+			`{"a":"one two\nthree\n  kept\n"}`},
+		{"multi-line plain", "a: one\n  two\n\n  three",
+			`{"a":"one two\nthree"}`},
+		{"multi-line quoted", "a: \"one\n  two\"\nb: 'three\n\n  four'",
+			`{"a":"one two","b":"three\nfour"}`},
+		{"flow collections", "a: [1, 'two', {b: c}]\nd: {e: [f, g], h: {}}\ni: []",
+			`{"a":[1,"two",{"b":"c"}],"d":{"e":["f","g"],"h":{}},"i":[]}`},
+		{"multi-line flow", "a: [\n  1,\n  2,\n]",
+			`{"a":[1,2]}`},
+		{"nested blocks", "a:\n  - 1\n  -\n    b: 2\n  - - 3\nc:\n  d:\n    e: f",
+			`{"a":[1,{"b":2},[3]],"c":{"d":{"e":"f"}}}`},
+		{"sequence of maps", "- name: a\n  tags: [x]\n- name: b",
+			`[{"name":"a","tags":["x"]},{"name":"b"}]`},
+		{"comments and markers", "# header\n---\na: b # trailing\nc: 'x # y'\n...\n",
+			`{"a":"b","c":"x # y"}`},
+		{"datetimes stay strings", "a: 2024-01-02T03:04:05Z\nb: 2024-01-02",
+			`{"a":"2024-01-02T03:04:05Z","b":"2024-01-02"}`},
+		{"quoted keys", "\"a b\": 1\n'c': \"\\u00e9\"",
+			`{"a b":1,"c":"é"}`},
+	}
+	for _, tt := range tests {
+		doc, err := parseYAML([]byte(tt.in))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := jsonForm(t, doc); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"a: &x 1\nb: *x", "anchors, aliases and tags"},
+		{"a: !!str 1", "anchors, aliases and tags"},
+		{"a: 1\na: 2", `duplicate key "a"`},
+		{"a: 1\n---\nb: 2", "only one document"},
+		{"\ta: 1", "tabs"},
+		{"a: [1, 2", "unterminated flow collection"},
+	}
+	for _, tt := range tests {
+		if _, err := parseYAML([]byte(tt.in)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: error %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+// samplePack has the awkward strings pack files hold: multi-line text,
+// indentation, quotes, reserved words and characters YAML and TOML treat
+// specially.
+func samplePack() *MemoPack {
+	return &MemoPack{
+		ID:           "p1",
+		Name:         "Go: style & idioms",
+		Description:  "yes",
+		SystemPrompt: "You review Go.\n\n  Indented line\nLast line without newline",
+		Rules: []MemoRule{
+			{Title: "- not a list", UpdateRule: "Use `gofmt`.\n# not a comment\n"},
+			{Title: "'quoted'", UpdateRule: "\"double\" and 'single' \\ backslash\ttab"},
+		},
+		Memos: []Memo{
+			{Title: "null", Content: "  leading spaces\ntrailing spaces  \n\n\n"},
+			{Title: "1.0", Content: "key: value\n[table]\nx = 1\n\"\"\"triple\"\"\""},
+		},
+		Version:   "1.2.0",
+		CreatedAt: "2024-01-02T03:04:05Z",
+		Published: true,
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	pack := samplePack()
+	data, err := encodeYAML(pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parseYAML(data)
+	if err != nil {
+		t.Fatalf("parse encoded pack: %v\n%s", err, data)
+	}
+	if got, want := jsonForm(t, doc), jsonForm(t, pack); got != want {
+		t.Errorf("round trip changed the pack:\n got %s\nwant %s\nYAML:\n%s", got, want, data)
+	}
+}
+
+// FuzzYAML checks that the parser doesn't panic, and that whatever it
+// reads is written back by the encoder as a document that reads the same.
+func FuzzYAML(f *testing.F) {
+	for _, seed := range []string{
+		"a: |\n  one\n  two\nb: [1, {c: d}]\n",
+		"- a\n- b: c\n  d: >\n    folded\n    text\n",
+		"a: 'x''y'\nb: \"\\t\\u00e9\"\nc: ~\nd: 0x10\n",
+		"a:\n  b:\n    - 1\n    - [2, 3]\n",
+		"plain\n  continued",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		doc, err := parseYAML([]byte(in))
+		if err != nil {
+			return
+		}
+		want := jsonForm(t, doc)
+		data, err := encodeYAML(doc)
+		if err != nil {
+			t.Fatalf("encode %s: %v", want, err)
+		}
+		again, err := parseYAML(data)
+		if err != nil {
+			t.Fatalf("parse encoded %s: %v\n%s", want, err, data)
+		}
+		if got := jsonForm(t, again); got != want {
+			t.Fatalf("round trip of %q:\n got %s\nwant %s\nYAML:\n%s", in, got, want, data)
+		}
+	})
+}